@@ -1,7 +1,8 @@
 """Main FastAPI application for the AI Agent"""
 
+import hmac
 import os
-from fastapi import FastAPI, HTTPException
+from fastapi import FastAPI, HTTPException, Request
 from fastapi.staticfiles import StaticFiles
 from fastapi.responses import FileResponse, JSONResponse
 from pydantic import BaseModel
@@ -11,10 +12,15 @@ import traceback
 # Check if cleanup flag is set
 CLEANUP_ON_STARTUP = os.getenv("CLEANUP_DATABASE", "false").lower() == "true"
 
+# Key the Discord bot must send (AGENT_API_KEY there too); unset allows anyone
+AGENT_API_KEY = os.getenv("AGENT_API_KEY", "")
+AGENT_AUTH_HEADER = os.getenv("AGENT_AUTH_HEADER", "Authorization")
+
 class ChatMessage(BaseModel):
     message: str
     context: dict = {}
     conversation_history: list = []
+    request_id: str = ""
 
 from contextlib import asynccontextmanager
 
@@ -62,6 +68,19 @@ async def lifespan(app: FastAPI):
 # Initialize FastAPI app with lifespan
 app = FastAPI(title="Elsie AI Agent", lifespan=lifespan)
 
+@app.middleware("http")
+async def require_api_key(request: Request, call_next):
+    """Reject requests without AGENT_API_KEY, except for the web page itself"""
+    path = request.url.path
+    if not AGENT_API_KEY or path == "/" or path.startswith("/static"):
+        return await call_next(request)
+    sent = request.headers.get(AGENT_AUTH_HEADER, "")
+    if AGENT_AUTH_HEADER.lower() == "authorization":
+        sent = sent.removeprefix("Bearer ")
+    if not hmac.compare_digest(sent.encode(), AGENT_API_KEY.encode()):
+        return JSONResponse(status_code=401, content={"detail": "Invalid or missing API key"})
+    return await call_next(request)
+
 # Serve static files
 app.mount("/static", StaticFiles(directory="static"), name="static")
 
@@ -112,11 +131,18 @@ async def process_message(message: ChatMessage):
             )
             print(f"   ✅ Response generated: {response_text}")
 
-            # Construct the response in the format Go expects
+            # Construct the response in the format Go expects. Staying quiet
+            # is an explicit action rather than a magic response string.
+            action = "reply"
+            if response_text == "NO_RESPONSE":
+                action = "silent"
+                response_text = ""
             ai_response = {
                 "status": "success",
+                "action": action,
                 "response": response_text,
                 "session_id": message.context.get("session_id"),
+                "request_id": message.request_id or message.context.get("request_id", ""),
                 "context": message.context,
                 "bartender": "elsie"
             }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	registerCommand(command{name: "ack", handler: ackCommand})
+}
+
+// channelAckReaction returns the emoji Elsie reacts with to messages she
+// stays quiet on in the channel or, in a thread, its parent; "" means she
+// leaves them be.
+func channelAckReaction(s *discordgo.Session, guildID, channelID string) string {
+	if guildID == "" {
+		return ""
+	}
+	reactions := loadGuildConfig(guildID).AckReactions
+	if len(reactions) == 0 {
+		return ""
+	}
+	if emoji, ok := reactions[channelID]; ok {
+		return emoji
+	}
+	if channel, err := getChannel(s, channelID); err == nil && isThreadChannel(channel) {
+		return reactions[channel.ParentID]
+	}
+	return ""
+}
+
+// acknowledgeSilently reacts to a monitored message Elsie won't answer, so
+// the player knows she heard it.
+func acknowledgeSilently(s *discordgo.Session, m *discordgo.MessageCreate, rlog requestLog) {
+	emoji := channelAckReaction(s, m.GuildID, m.ChannelID)
+	if emoji == "" {
+		return
+	}
+	if err := s.MessageReactionAdd(m.ChannelID, m.ID, reactionAPIName(emoji)); err != nil {
+		rlog.Printf("Error acknowledging with %s: %v", emoji, err)
+		return
+	}
+	metrics.Inc("silent_acks_total")
+}
+
+// ackCommand is `!elsie ack [#channel] [<emoji>|off]`: the reaction Elsie
+// leaves on messages she hears but doesn't answer in the channel, or every
+// channel's without arguments.
+func ackCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Acknowledgments are per channel — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change how I acknowledge messages."))
+		return
+	}
+	args := ctx.args
+	if len(args) == 0 {
+		reactions := loadGuildConfig(ctx.m.GuildID).AckReactions
+		if len(reactions) == 0 {
+			ctx.reply(ctx.tr("👀 I don't react to messages I stay quiet on anywhere. Turn it on with `!elsie ack [#channel] 👀`."))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(ctx.tr("👀 **Silent acknowledgments**") + "\n")
+		for _, channelID := range sortedKeys(reactions) {
+			fmt.Fprintf(&b, "• <#%s> — %s\n", channelID, reactions[channelID])
+		}
+		ctx.reply(b.String())
+		return
+	}
+
+	channelID := ctx.m.ChannelID
+	if id := parseChannelMention(args[0]); id != "" {
+		channelID = id
+		args = args[1:]
+	}
+	emoji := "👀"
+	if len(args) > 0 {
+		emoji = args[0]
+	}
+	if strings.EqualFold(emoji, "off") {
+		emoji = ""
+	} else if err := ctx.s.MessageReactionAdd(ctx.m.ChannelID, ctx.m.ID, reactionAPIName(emoji)); err != nil {
+		// Reacting to the command itself checks that the emoji is one
+		// Elsie can use
+		ctx.reply(ctx.tr("*squints* I can't react with %s. Use an emoji like 👀 or 🍺, or one from this server.", emoji))
+		return
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		if emoji == "" {
+			delete(cfg.AckReactions, channelID)
+			return
+		}
+		if cfg.AckReactions == nil {
+			cfg.AckReactions = map[string]string{}
+		}
+		cfg.AckReactions[channelID] = emoji
+	})
+	if err != nil {
+		log.Printf("Error saving acknowledgment reaction: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if emoji == "" {
+		ctx.reply(ctx.tr("👀 I'll stop reacting to messages I don't answer in <#%s>.", channelID))
+		return
+	}
+	ctx.reply(ctx.tr("%s I'll react with %s to messages I hear but don't answer in <#%s>.", emoji, emoji, channelID))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Action styles a guild can pick for RP actions in responses.
+const (
+	actionStyleItalic   = "italic"   // *waves*
+	actionStyleBrackets = "brackets" // [waves]
+)
+
+const diceRollPattern = `[0-9]*d[0-9]+(?:\s*[+-]\s*[0-9]+)*`
+
+var (
+	// diceNotation matches tabletop rolls like 1d20+3 or d6, optionally
+	// wrapped in [brackets] or [[inline roll]] markers.
+	diceNotation = regexp.MustCompile(`\[\[\s*(` + diceRollPattern + `)\s*\]\]|\[\s*(` + diceRollPattern + `)\s*\]|\b(` + diceRollPattern + `)\b`)
+	// actionMarkup matches, in order: bold text, which is left alone,
+	// *asterisk*, _underscore_ and [bracket] actions. Asterisks and
+	// underscores must hug the action, so "* item" bullets don't match. A
+	// bracket followed by "(" is a markdown link.
+	actionMarkup = regexp.MustCompile(`(?m)(\*\*[^*\n]+\*\*)|\*([^*\s](?:[^*\n]*[^*\s])?)\*|(^|\s)_([^_\s](?:[^_\n]*[^_\s])?)_|\[([^[\]\n]+)\](\()?`)
+	// bracketTag matches bracketed tags such as [OOC] or [DGM], which aren't
+	// actions.
+	bracketTag = regexp.MustCompile(`^[A-Z0-9 ]+$`)
+	// curlyQuotes are the speech marks models use besides plain quotes.
+	curlyQuotes = strings.NewReplacer("“", `"`, "”", `"`, "„", `"`, "«", `"`, "»", `"`)
+)
+
+// actionStyle returns the guild's action style.
+func actionStyle(guildID string) string {
+	if style := loadGuildConfig(guildID).ActionStyle; style != "" {
+		return style
+	}
+	return actionStyleItalic
+}
+
+// formatActions normalizes the RP markup in a response: actions written as
+// *this*, _this_ or [this] follow the guild's style, an action left open at
+// the start of a line is closed, curly speech marks become plain quotes
+// and dice notation is set in code. Code is left untouched.
+func formatActions(s *discordgo.Session, guildID, text string) string {
+	style := actionStyle(guildID)
+	blocks := strings.Split(text, "```")
+	for i := 0; i < len(blocks); i += 2 {
+		spans := strings.Split(blocks[i], "`")
+		for j := 0; j < len(spans); j += 2 {
+			spans[j] = formatActionText(spans[j], style)
+		}
+		blocks[i] = strings.Join(spans, "`")
+	}
+	return strings.Join(blocks, "```")
+}
+
+func formatActionText(text, style string) string {
+	lines := strings.Split(curlyQuotes.Replace(text), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) > 1 && trimmed[0] == '*' && !strings.ContainsAny(trimmed[1:2], "* \t") && strings.Count(trimmed, "*") == 1 {
+			lines[i] = strings.TrimRight(line, " ") + "*"
+		}
+	}
+	text = strings.Join(lines, "\n")
+
+	var b strings.Builder
+	last := 0
+	for _, m := range actionMarkup.FindAllStringSubmatchIndex(text, -1) {
+		group := func(n int) string {
+			if m[2*n] < 0 {
+				return ""
+			}
+			return text[m[2*n]:m[2*n+1]]
+		}
+		b.WriteString(text[last:m[0]])
+		last = m[1]
+		switch {
+		case group(1) != "":
+			b.WriteString(group(1))
+		case m[4] >= 0:
+			b.WriteString(renderAction(group(2), style))
+		case m[8] >= 0:
+			b.WriteString(group(3) + renderAction(group(4), style))
+		case group(6) != "" || bracketTag.MatchString(group(5)) || diceNotation.FindString(group(5)) == group(5):
+			b.WriteString(text[m[0]:m[1]])
+		default:
+			b.WriteString(renderAction(group(5), style))
+		}
+	}
+	b.WriteString(text[last:])
+
+	return diceNotation.ReplaceAllStringFunc(b.String(), func(roll string) string {
+		parts := diceNotation.FindStringSubmatch(roll)
+		return "`" + strings.ReplaceAll(parts[1]+parts[2]+parts[3], " ", "") + "`"
+	})
+}
+
+func renderAction(action, style string) string {
+	action = strings.TrimSpace(action)
+	if action == "" {
+		return ""
+	}
+	if style == actionStyleBrackets {
+		return "[" + action + "]"
+	}
+	return "*" + action + "*"
+}
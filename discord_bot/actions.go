@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Agent action types.
+const (
+	actionAddReaction  = "add_reaction"
+	actionCreateThread = "create_thread"
+	actionPinMessage   = "pin_message"
+	actionAssignRole   = "assign_role"
+)
+
+var agentActionTypes = []string{actionAddReaction, actionCreateThread, actionPinMessage, actionAssignRole}
+
+// agentAction is one entry of the agent response's `actions` list. Target
+// is "reply" (Elsie's first reply message, the default) or "trigger" (the
+// player's message); the agent never names arbitrary message IDs.
+type agentAction struct {
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"`
+	Emoji  string `json:"emoji,omitempty"`   // add_reaction
+	Name   string `json:"name,omitempty"`    // create_thread
+	RoleID string `json:"role_id,omitempty"` // assign_role, always to the player
+}
+
+// maxAgentActions bounds how many actions one response can run.
+const maxAgentActions = 5
+
+// actionScope is what an action may touch: the channel, the player's
+// message and Elsie's reply.
+type actionScope struct {
+	guildID   string
+	channelID string
+	authorID  string
+	trigger   string
+	reply     string
+}
+
+// actionPermissions are the bot permissions each action needs.
+var actionPermissions = map[string]int64{
+	actionAddReaction:  discordgo.PermissionAddReactions,
+	actionCreateThread: discordgo.PermissionCreatePublicThreads,
+	actionPinMessage:   discordgo.PermissionManageMessages,
+	actionAssignRole:   discordgo.PermissionManageRoles,
+}
+
+// runAgentActions validates and executes the actions an agent response
+// asked for. Each action must be enabled by the operator (AGENT_ACTIONS)
+// and not disabled by the guild, and the bot must hold the permission it
+// needs; role assignment is further limited to the guild's whitelisted
+// roles. Failures are logged and skipped.
+func runAgentActions(s *discordgo.Session, actions []agentAction, scope actionScope, rlog requestLog) {
+	if len(actions) == 0 {
+		return
+	}
+	if len(actions) > maxAgentActions {
+		rlog.Printf("🎬 Agent sent %d actions, running the first %d", len(actions), maxAgentActions)
+		actions = actions[:maxAgentActions]
+	}
+	cfg := loadGuildConfig(scope.guildID)
+	var perms int64
+	if scope.guildID != "" {
+		var err error
+		if perms, err = s.UserChannelPermissions(s.State.User.ID, scope.channelID); err != nil {
+			rlog.Printf("Error resolving permissions for agent actions: %v", err)
+			return
+		}
+	}
+	for _, a := range actions {
+		err := validateAgentAction(a, scope, cfg, perms)
+		if err == nil {
+			err = executeAgentAction(s, a, scope)
+		}
+		result := "ok"
+		if err != nil {
+			result = "rejected"
+			rlog.Printf("🎬 Agent action %s skipped: %v", a.Type, err)
+		} else {
+			rlog.Printf("🎬 Agent action %s done", a.Type)
+		}
+		metrics.Inc(metricLabel(metricLabel("agent_actions_total", "type", a.Type), "result", result))
+	}
+}
+
+func validateAgentAction(a agentAction, scope actionScope, cfg *GuildConfig, perms int64) error {
+	if !slices.Contains(config().AgentActions, a.Type) {
+		return fmt.Errorf("action type %q is not enabled", a.Type)
+	}
+	if slices.Contains(cfg.DisabledActions, a.Type) {
+		return fmt.Errorf("disabled by the server")
+	}
+	if a.Target != "" && a.Target != "reply" && a.Target != "trigger" {
+		return fmt.Errorf("unknown target %q", a.Target)
+	}
+	if scope.guildID == "" {
+		if a.Type != actionAddReaction {
+			return fmt.Errorf("not available in DMs")
+		}
+		return nil
+	}
+	if perms&discordgo.PermissionAdministrator == 0 && perms&actionPermissions[a.Type] == 0 {
+		return fmt.Errorf("missing the permission it needs")
+	}
+	switch a.Type {
+	case actionAddReaction:
+		if a.Emoji == "" {
+			return fmt.Errorf("no emoji")
+		}
+	case actionCreateThread:
+		if strings.TrimSpace(a.Name) == "" {
+			return fmt.Errorf("no thread name")
+		}
+	case actionAssignRole:
+		if !slices.Contains(cfg.AssignableRoles, a.RoleID) {
+			return fmt.Errorf("role %q is not assignable in this server", a.RoleID)
+		}
+	}
+	return nil
+}
+
+func executeAgentAction(s *discordgo.Session, a agentAction, scope actionScope) error {
+	messageID := scope.reply
+	if a.Target == "trigger" || messageID == "" {
+		messageID = scope.trigger
+	}
+	switch a.Type {
+	case actionAddReaction:
+		return s.MessageReactionAdd(scope.channelID, messageID, strings.Trim(a.Emoji, "<:>"))
+	case actionCreateThread:
+		_, err := s.MessageThreadStart(scope.channelID, messageID, truncateText(a.Name, 100), 1440)
+		return err
+	case actionPinMessage:
+		return s.ChannelMessagePin(scope.channelID, messageID)
+	case actionAssignRole:
+		return s.GuildMemberRoleAdd(scope.guildID, scope.authorID, a.RoleID)
+	}
+	return fmt.Errorf("unknown action")
+}
+
+func init() {
+	registerCommand(command{name: "actions", handler: actionsCommand})
+}
+
+// actionsCommand is `!elsie actions [enable|disable <type>|role @role]`.
+func actionsCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Agent actions are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can manage agent actions.")
+		return
+	}
+	usage := "Usage: `!elsie actions enable|disable <" + strings.Join(agentActionTypes, "|") + ">`, `!elsie actions role @role`"
+	if len(ctx.args) == 0 {
+		ctx.replyQuietly(describeAgentActions(loadGuildConfig(ctx.m.GuildID)) + "\n" + usage)
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	switch sub := strings.ToLower(ctx.args[0]); sub {
+	case "enable", "disable":
+		if len(ctx.args) < 2 || !slices.Contains(agentActionTypes, strings.ToLower(ctx.args[1])) {
+			ctx.reply(usage)
+			return
+		}
+		action := strings.ToLower(ctx.args[1])
+		apply = func(cfg *GuildConfig) {
+			var kept []string
+			for _, t := range cfg.DisabledActions {
+				if t != action {
+					kept = append(kept, t)
+				}
+			}
+			if sub == "disable" {
+				kept = append(kept, action)
+			}
+			cfg.DisabledActions = kept
+		}
+	case "role":
+		if len(ctx.m.MentionRoles) == 0 {
+			ctx.reply("Mention the role, e.g. `!elsie actions role @Regulars`. Running it again removes the role.")
+			return
+		}
+		roleID := ctx.m.MentionRoles[0]
+		apply = func(cfg *GuildConfig) {
+			for i, id := range cfg.AssignableRoles {
+				if id == roleID {
+					cfg.AssignableRoles = append(cfg.AssignableRoles[:i], cfg.AssignableRoles[i+1:]...)
+					return
+				}
+			}
+			cfg.AssignableRoles = append(cfg.AssignableRoles, roleID)
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	var cfg GuildConfig
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(c *GuildConfig) {
+		apply(c)
+		cfg = *c
+	})
+	if err != nil {
+		log.Printf("Error saving agent action settings: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.replyQuietly(describeAgentActions(&cfg))
+}
+
+func describeAgentActions(cfg *GuildConfig) string {
+	var lines []string
+	for _, t := range agentActionTypes {
+		state := "on"
+		switch {
+		case !slices.Contains(config().AgentActions, t):
+			state = "off (disabled by the bot operator)"
+		case slices.Contains(cfg.DisabledActions, t):
+			state = "off"
+		}
+		lines = append(lines, fmt.Sprintf("• `%s`: %s", t, state))
+	}
+	roles := "none"
+	if len(cfg.AssignableRoles) > 0 {
+		var mentions []string
+		for _, id := range cfg.AssignableRoles {
+			mentions = append(mentions, "<@&"+id+">")
+		}
+		roles = strings.Join(mentions, ", ")
+	}
+	return "🎬 **Agent actions**\n" + strings.Join(lines, "\n") + "\nAssignable roles: " + roles
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// agentBackend tracks the health of one AI agent URL.
+type agentBackend struct {
+	url       string
+	mu        sync.Mutex
+	healthy   bool
+	lastError string
+	caps      *agentCapabilities // nil until a handshake says otherwise
+}
+
+func (b *agentBackend) setHealthy(healthy bool, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.healthy != healthy {
+		if healthy {
+			log.Printf("💚 AI agent %s is healthy again", b.url)
+			go fetchCapabilities(b)
+			resolveAlert(alertAgentDown+":"+b.url, fmt.Sprintf("AI agent %s is answering again.", b.url))
+		} else {
+			log.Printf("💔 AI agent %s marked unhealthy: %s", b.url, reason)
+		}
+	}
+	if !healthy {
+		raiseAlert(alertAgentDown+":"+b.url, fmt.Sprintf("**AI agent down**: %s isn't answering (%s).", b.url, reason))
+	}
+	b.healthy = healthy
+	b.lastError = reason
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	metrics.Set(metricLabel("agent_healthy", "url", b.url), value)
+}
+
+func (b *agentBackend) isHealthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy
+}
+
+// agentPool is the ordered set of agents serving one persona; the first is
+// the primary and the rest are failover targets.
+type agentPool struct {
+	name     string
+	backends []*agentBackend
+	load     poolLoad
+}
+
+var (
+	// agentPoolsMu guards the pools below, which a config reload replaces.
+	agentPoolsMu sync.RWMutex
+	// agentBackends holds every distinct agent URL, shared between pools so
+	// each is health-checked once.
+	agentBackends []*agentBackend
+	// defaultAgentPool serves Elsie and any persona without its own URLs.
+	defaultAgentPool *agentPool
+	agentPools       map[string]*agentPool
+)
+
+// initAgentBackends builds the default pool from urls and a dedicated pool
+// for each persona listed in personaURLs. Agents that were already known
+// keep their health and capabilities.
+func initAgentBackends(urls []string, personaURLs map[string][]string) {
+	agentPoolsMu.Lock()
+	defer agentPoolsMu.Unlock()
+	known := make(map[string]*agentBackend)
+	for _, b := range agentBackends {
+		known[b.url] = b
+	}
+	agentBackends = nil
+	byURL := make(map[string]*agentBackend)
+	newPool := func(name string, urls []string) *agentPool {
+		pool := &agentPool{name: name}
+		for _, url := range urls {
+			b, ok := byURL[url]
+			if !ok {
+				if b, ok = known[url]; !ok {
+					b = &agentBackend{url: url, healthy: true}
+				}
+				byURL[url] = b
+				agentBackends = append(agentBackends, b)
+			}
+			pool.backends = append(pool.backends, b)
+		}
+		return pool
+	}
+
+	defaultAgentPool = newPool(defaultPersonaID, urls)
+	agentPools = make(map[string]*agentPool)
+	for personaID, urls := range personaURLs {
+		agentPools[personaID] = newPool(personaID, urls)
+		log.Printf("🎭 Persona %s routed to %v", personaID, urls)
+	}
+}
+
+// poolFor returns the agents serving personaID.
+func poolFor(personaID string) *agentPool {
+	agentPoolsMu.RLock()
+	defer agentPoolsMu.RUnlock()
+	if pool, ok := agentPools[personaID]; ok {
+		return pool
+	}
+	return defaultAgentPool
+}
+
+// allAgentPools returns the default pool followed by the persona pools.
+func allAgentPools() []*agentPool {
+	agentPoolsMu.RLock()
+	defer agentPoolsMu.RUnlock()
+	pools := []*agentPool{defaultAgentPool}
+	for _, p := range agentPools {
+		pools = append(pools, p)
+	}
+	return pools
+}
+
+// allAgentBackends returns every distinct agent.
+func allAgentBackends() []*agentBackend {
+	agentPoolsMu.RLock()
+	defer agentPoolsMu.RUnlock()
+	return agentBackends
+}
+
+// runAgentHealthChecks polls each agent's /health endpoint so requests skip
+// agents that are known to be down.
+func runAgentHealthChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, b := range allAgentBackends() {
+			checkAgentHealth(b)
+		}
+	}
+}
+
+func checkAgentHealth(b *agentBackend) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"/health", nil)
+	if err != nil {
+		b.setHealthy(false, err.Error())
+		return
+	}
+	authorizeAgentRequest(req)
+	resp, err := agentClient.Do(req)
+	if err != nil {
+		b.setHealthy(false, err.Error())
+		return
+	}
+	closeAgentBody(resp)
+	checkAgentAuth(resp)
+	if resp.StatusCode >= 500 {
+		b.setHealthy(false, resp.Status)
+		return
+	}
+	b.setHealthy(true, "")
+}
+
+// callOrder returns healthy agents first, in priority order, followed by
+// unhealthy ones as a last resort.
+func (p *agentPool) callOrder() []*agentBackend {
+	var healthy, unhealthy []*agentBackend
+	for _, b := range p.backends {
+		if b.isHealthy() {
+			healthy = append(healthy, b)
+		} else {
+			unhealthy = append(unhealthy, b)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// callAgent sends message to the /process endpoint of the agents serving its
+// persona, failing over to the next agent when one is down, times out or
+// returns a server error.
+func callAgent(message Message) (*AIResponse, error) {
+	if message.RequestID == "" {
+		message.RequestID = newRequestID()
+		if message.Context != nil {
+			message.Context["request_id"] = message.RequestID
+		}
+	}
+	rlog := requestLog{id: message.RequestID}
+
+	message.SchemaVersion = agentSchemaVersion
+
+	pool := poolFor(message.Persona)
+	start := pool.load.begin(pool.name)
+	if config().LoadHintsEnabled && message.Context != nil && pool.supports(featureLoadHints) {
+		message.Context["load_hints"] = pool.load.hints()
+	}
+	body, backend, err := pool.callBackend(message.RequestID, "/process", message)
+	pool.load.end(pool.name, start, err == nil)
+	if err != nil {
+		guildID, _ := message.Context["guild_id"].(string)
+		channelID, _ := message.Context["channel_id"].(string)
+		reportError(errorKindAgent, err, map[string]string{
+			"request_id": message.RequestID, "persona": message.Persona, "path": "/process",
+			"guild_id": guildID, "channel_id": channelID,
+		}, nil)
+		return nil, err
+	}
+	rlog.Printf("DEBUG: Received response: %s", logText(string(body)))
+
+	var aiResponse AIResponse
+	if err := json.Unmarshal(body, &aiResponse); err != nil {
+		return nil, fmt.Errorf("unmarshaling AI response: %w", err)
+	}
+	switch aiResponse.RequestID {
+	case message.RequestID:
+	case "":
+		rlog.Printf("DEBUG: Agent did not echo the request ID")
+	default:
+		rlog.Printf("⚠️  Agent echoed a different request ID: %s", aiResponse.RequestID)
+	}
+	degradeResponse(backend, &aiResponse, rlog)
+	applySlowDown(aiResponse.SlowDown, rlog)
+	return &aiResponse, nil
+}
+
+// call POSTs payload as JSON to path on the first of the pool's agents
+// that answers, failing over in priority order, and returns the response
+// body. requestID is sent as X-Request-ID and prefixes every log line.
+func (p *agentPool) call(requestID, path string, payload interface{}) ([]byte, error) {
+	body, _, err := p.callBackend(requestID, path, payload)
+	return body, err
+}
+
+// callBackend is call, also returning the agent that answered.
+func (p *agentPool) callBackend(requestID, path string, payload interface{}) ([]byte, *agentBackend, error) {
+	rlog := requestLog{id: requestID}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshaling payload: %w", err)
+	}
+
+	var lastErr error
+	for i, b := range p.callOrder() {
+		if i > 0 {
+			rlog.Printf("🔀 FAILOVER: retrying %s against AI agent %s after: %v", path, b.url, lastErr)
+			metrics.Inc("agent_failover_total")
+		}
+		body, err := postToAgent(b.url+path, requestID, jsonData)
+		var rejected *agentRejectedError
+		if err == nil || errors.As(err, &rejected) {
+			// The agent is up; a rejected request won't fare better elsewhere.
+			b.setHealthy(true, "")
+			return body, b, err
+		}
+		rlog.Printf("Error calling AI agent %s%s: %v", b.url, path, err)
+		metrics.Inc(metricLabel("agent_errors_total", "url", b.url))
+		b.setHealthy(false, err.Error())
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no AI agents configured")
+	}
+	return nil, nil, lastErr
+}
+
+func postToAgent(url, requestID string, jsonData []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config().AgentTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", requestID)
+	authorizeAgentRequest(req)
+
+	if err := injectAgentTimeout(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := agentClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	checkAgentAuth(resp)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("agent returned %s", resp.Status)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &agentRejectedError{status: resp.Status, body: string(body)}
+	}
+	return body, nil
+}
+
+// agentRejectedError is a 4xx answer from a healthy agent, e.g. an older
+// agent that doesn't know an endpoint.
+type agentRejectedError struct {
+	status string
+	body   string
+}
+
+func (e *agentRejectedError) Error() string {
+	return fmt.Sprintf("agent rejected request: %s %s", e.status, e.body)
+}
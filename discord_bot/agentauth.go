@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// agentClient makes every request to an AI agent. initAgentClient gives
+	// it a pooled transport, with the client certificate and CA from
+	// AGENT_TLS_* when they are set.
+	agentClient = http.DefaultClient
+
+	// agentAuthWarned stops a rejected key from being logged on every
+	// health check.
+	agentAuthWarned sync.Map
+)
+
+// initAgentClient sets up authentication to the agents. A half-configured
+// setup stops the bot rather than quietly talking to agents without it.
+func initAgentClient() {
+	c := config()
+	transport := newAgentTransport()
+	if c.AgentTLSCert != "" || c.AgentTLSKey != "" || c.AgentTLSCA != "" {
+		cfg, err := agentTLSConfig()
+		if err != nil {
+			log.Fatal("Error loading agent TLS settings: ", err)
+		}
+		transport.TLSClientConfig = cfg
+		log.Printf("🔐 Using TLS settings from AGENT_TLS_* for AI agent requests")
+	}
+	agentClient = &http.Client{Transport: connTrackingTransport{base: transport}}
+	if c.AgentAPIKey == "" {
+		return
+	}
+	log.Printf("🔐 Sending an API key to AI agents in %s", c.AgentAuthHeader)
+	for _, raw := range allAgentURLs() {
+		if u, err := url.Parse(raw); err == nil && u.Scheme == "http" && !isLoopbackHost(u.Hostname()) {
+			log.Printf("⚠️  AI agent %s is plain HTTP; AGENT_API_KEY is sent unencrypted", raw)
+		}
+	}
+}
+
+// agentTLSConfig loads the client certificate the bot presents to agents,
+// and the CA their certificates must chain to.
+func agentTLSConfig() (*tls.Config, error) {
+	c := config()
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if (c.AgentTLSCert == "") != (c.AgentTLSKey == "") {
+		return nil, errors.New("AGENT_TLS_CERT and AGENT_TLS_KEY must be set together")
+	}
+	if c.AgentTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.AgentTLSCert, c.AgentTLSKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.AgentTLSCA != "" {
+		pem, err := os.ReadFile(c.AgentTLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in AGENT_TLS_CA")
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// allAgentURLs lists every configured agent, including persona agents.
+func allAgentURLs() []string {
+	urls := append([]string(nil), config().AIAgentURLs...)
+	for _, personaURLs := range config().PersonaAgentURLs {
+		urls = append(urls, personaURLs...)
+	}
+	return urls
+}
+
+func isLoopbackHost(host string) bool {
+	return host == "localhost" || strings.HasPrefix(host, "127.") || host == "::1"
+}
+
+// authorizeAgentRequest adds AGENT_API_KEY to a request to an agent: as a
+// bearer token in Authorization, or as-is in any other header.
+func authorizeAgentRequest(req *http.Request) {
+	c := config()
+	if c.AgentAPIKey == "" {
+		return
+	}
+	if strings.EqualFold(c.AgentAuthHeader, "Authorization") {
+		req.Header.Set("Authorization", "Bearer "+c.AgentAPIKey)
+		return
+	}
+	req.Header.Set(c.AgentAuthHeader, c.AgentAPIKey)
+}
+
+// checkAgentAuth logs, once per agent, that it turned down the bot's
+// credentials, since every request to it will fail the same way.
+func checkAgentAuth(resp *http.Response) {
+	agentURL := resp.Request.URL.Scheme + "://" + resp.Request.URL.Host
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+		agentAuthWarned.Delete(agentURL)
+		return
+	}
+	metrics.Inc(metricLabel("agent_auth_failures_total", "url", agentURL))
+	if _, warned := agentAuthWarned.LoadOrStore(agentURL, true); warned {
+		return
+	}
+	if config().AgentAPIKey == "" {
+		log.Printf("⚠️  AI agent %s wants authentication (%s); set AGENT_API_KEY", agentURL, resp.Status)
+		return
+	}
+	log.Printf("⚠️  AI agent %s rejected the bot's credentials (%s); check AGENT_API_KEY and AGENT_AUTH_HEADER", agentURL, resp.Status)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelTypeNames maps Discord channel types to the names the AI agent
+// expects in the channel_type context field.
+var channelTypeNames = map[discordgo.ChannelType]string{
+	discordgo.ChannelTypeDM:                 "DM",
+	discordgo.ChannelTypeGuildText:          "GUILD_TEXT",
+	discordgo.ChannelTypeGuildVoice:         "GUILD_VOICE",
+	discordgo.ChannelTypeGuildPublicThread:  "GUILD_PUBLIC_THREAD",
+	discordgo.ChannelTypeGuildPrivateThread: "GUILD_PRIVATE_THREAD",
+	discordgo.ChannelTypeGuildNewsThread:    "GUILD_NEWS_THREAD",
+	discordgo.ChannelTypeGuildNews:          "GUILD_NEWS",
+	discordgo.ChannelTypeGuildStageVoice:    "GUILD_STAGE_VOICE",
+	discordgo.ChannelTypeGuildCategory:      "GUILD_CATEGORY",
+	discordgo.ChannelTypeGuildForum:         "GUILD_FORUM",
+}
+
+func isThreadChannel(channel *discordgo.Channel) bool {
+	return channel.Type == discordgo.ChannelTypeGuildPublicThread ||
+		channel.Type == discordgo.ChannelTypeGuildPrivateThread ||
+		channel.Type == discordgo.ChannelTypeGuildNewsThread
+}
+
+// baseContext builds the agent context for requests that don't originate
+// from a plain message, such as slash commands and scheduled posts. It
+// carries the same channel fields as processWithAIEnhanced. user may be nil
+// for bot-initiated requests.
+func baseContext(s *discordgo.Session, channelID, guildID string, user *discordgo.User) map[string]interface{} {
+	ctx := map[string]interface{}{
+		"session_id": channelID,
+		"platform":   "discord",
+		"channel_id": channelID,
+		"guild_id":   guildID,
+		"is_dm":      guildID == "",
+	}
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	ctx["locale"] = agentLocale(guildID, userID)
+	ctx["stardate"] = formatStardate(stardateOf(guildID, time.Now()))
+	if channel, err := getChannel(s, channelID); err == nil {
+		channelType, ok := channelTypeNames[channel.Type]
+		if !ok {
+			channelType = "UNKNOWN"
+		}
+		channelName := channel.Name
+		if channel.Type == discordgo.ChannelTypeDM {
+			channelName = "DM"
+		}
+		ctx["channel_name"] = channelName
+		ctx["channel_type"] = channelType
+		ctx["is_thread"] = isThreadChannel(channel)
+		ctx["is_nsfw"] = isNSFWChannel(s, channel)
+	} else {
+		log.Printf("Could not get channel info for agent context: %v", err)
+	}
+	if results := recentUtilityResults(channelID); len(results) > 0 {
+		ctx["utility_results"] = results
+	}
+	if initiative := initiativeContext(channelID); initiative != nil {
+		ctx["initiative"] = initiative
+	}
+	if rules := rulesContext(channelID); rules != nil {
+		ctx["scene_rules"] = rules
+	}
+	if user != nil {
+		ctx["user_id"] = user.ID
+		ctx["username"] = user.Username
+		if profile := loadProfile(user.ID); profile != nil && !profile.isEmpty() {
+			ctx["user_profile"] = profile.contextFields()
+		}
+		if orders := sessionOrdersContext(channelID, user.ID); len(orders) > 0 {
+			ctx["session_orders"] = orders
+		}
+	}
+	return ctx
+}
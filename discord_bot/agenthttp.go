@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+)
+
+// maxDrainBytes is how much of an unread agent response body is read off
+// so its connection can go back to the pool; anything longer is cheaper to
+// drop with the connection.
+const maxDrainBytes = 64 << 10
+
+// newAgentTransport returns the transport shared by every agent request.
+// Connections are kept alive and pooled per agent, so a busy bot doesn't
+// pay for a TCP and TLS handshake on each message; HTTPS agents get HTTP/2
+// unless AGENT_HTTP2 is off.
+func newAgentTransport() *http.Transport {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(config().AgentHTTP2)
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		Protocols:             protocols,
+		MaxIdleConns:          config().AgentMaxIdleConns,
+		MaxIdleConnsPerHost:   config().AgentMaxIdleConns,
+		IdleConnTimeout:       config().AgentIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// connTrackingTransport counts whether each agent request got a fresh or a
+// pooled connection, in agent_connections_total{reused}.
+type connTrackingTransport struct {
+	base http.RoundTripper
+}
+
+func (t connTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.Inc(metricLabel("agent_connections_total", "reused", strconv.FormatBool(info.Reused)))
+		},
+	}
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// closeAgentBody reads off what's left of a response body and closes it,
+// which lets the transport reuse the connection.
+func closeAgentBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
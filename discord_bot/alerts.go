@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Kinds of operational alert. Each is deduplicated on its own, per agent
+// or pool where that applies.
+const (
+	alertAgentDown  = "agent_down"
+	alertErrorRate  = "error_rate"
+	alertDisconnect = "disconnect"
+	alertQueueFull  = "queue_full"
+	alertBotLoop    = "bot_loop"
+)
+
+// alertMinCalls is how many agent calls a window needs before its error
+// rate means anything.
+const alertMinCalls = 10
+
+// alertState is what the bot remembers about one alert key.
+type alertState struct {
+	lastSent   time.Time
+	suppressed int  // repeats since lastSent that weren't posted
+	active     bool // raised and not resolved yet
+}
+
+var (
+	// alertsMu guards alerts.
+	alertsMu sync.Mutex
+	alerts   = map[string]*alertState{}
+
+	// agentWindowMu guards the agent call counts of the current error rate
+	// window.
+	agentWindowMu    sync.Mutex
+	agentWindowStart time.Time
+	agentWindowCalls int
+	agentWindowFails int
+
+	// disconnectMu guards the gateway disconnect watch.
+	disconnectMu    sync.Mutex
+	disconnectTimer *time.Timer
+	disconnectedAt  time.Time
+	alertsStopped   bool
+)
+
+// alertChannel is where alerts go: ALERT_CHANNEL_ID, or ADMIN_CHANNEL_ID
+// when that's unset.
+func alertChannel() string {
+	if config().AlertChannelID != "" {
+		return config().AlertChannelID
+	}
+	return config().AdminChannelID
+}
+
+// raiseAlert posts an operational alert. The same key is posted at most
+// once per ALERT_INTERVAL; repeats in between are counted and mentioned in
+// the next post.
+func raiseAlert(key, text string) {
+	kind, _, _ := strings.Cut(key, ":")
+	alertsMu.Lock()
+	st := alerts[key]
+	if st == nil {
+		st = &alertState{}
+		alerts[key] = st
+	}
+	st.active = true
+	if !st.lastSent.IsZero() && time.Since(st.lastSent) < config().AlertInterval {
+		st.suppressed++
+		alertsMu.Unlock()
+		metrics.Inc(metricLabel("alerts_suppressed_total", "kind", kind))
+		return
+	}
+	if st.suppressed > 0 {
+		text += fmt.Sprintf(" _(%d more since the last alert)_", st.suppressed)
+	}
+	st.lastSent, st.suppressed = time.Now(), 0
+	alertsMu.Unlock()
+
+	metrics.Inc(metricLabel("alerts_total", "kind", kind))
+	log.Printf("🚨 Alert: %s", text)
+	go postAlert("🚨 " + text)
+}
+
+// resolveAlert posts text when key has an active alert, and marks it
+// resolved. Its dedup window keeps running, so a flapping agent doesn't
+// post on every flap.
+func resolveAlert(key, text string) {
+	alertsMu.Lock()
+	st := alerts[key]
+	if st == nil || !st.active {
+		alertsMu.Unlock()
+		return
+	}
+	st.active = false
+	alertsMu.Unlock()
+
+	log.Printf("✅ Resolved: %s", text)
+	go postAlert("✅ " + text)
+}
+
+func postAlert(text string) {
+	channelID := alertChannel()
+	if channelID == "" || botSession == nil {
+		return
+	}
+	if _, err := botSession.ChannelMessageSend(channelID, truncateText(text, maxMessageLength)); err != nil {
+		log.Printf("Error posting alert: %v", err)
+	}
+}
+
+// noteAgentCall counts a /process call for the error rate, and alerts when
+// over ALERT_ERROR_RATE percent of the calls in an ALERT_WINDOW failed.
+func noteAgentCall(ok bool) {
+	if config().AlertErrorRate <= 0 {
+		return
+	}
+	agentWindowMu.Lock()
+	now := time.Now()
+	if now.Sub(agentWindowStart) > config().AlertWindow {
+		agentWindowStart, agentWindowCalls, agentWindowFails = now, 0, 0
+	}
+	agentWindowCalls++
+	if !ok {
+		agentWindowFails++
+	}
+	calls, fails := agentWindowCalls, agentWindowFails
+	agentWindowMu.Unlock()
+
+	if calls >= alertMinCalls && fails*100 > calls*config().AlertErrorRate {
+		raiseAlert(alertErrorRate, fmt.Sprintf("**Elevated agent error rate**: %d of the last %d requests failed (%d%%) in under %s.",
+			fails, calls, fails*100/calls, config().AlertWindow))
+	}
+}
+
+// noteQueueDepth alerts when requests waiting on a pool's agents reach
+// LOAD_SHED_DEPTH, where chatter starts being dropped.
+func noteQueueDepth(pool string, depth int64) {
+	if config().LoadShedDepth > 0 && depth >= int64(config().LoadShedDepth) {
+		raiseAlert(alertQueueFull+":"+pool, fmt.Sprintf("**Agent queue saturated**: %d requests are waiting on the `%s` agents, so chatter in monitored channels is being dropped.", depth, pool))
+	}
+}
+
+// gatewayDisconnect starts the disconnect watch: a gateway that isn't back
+// within ALERT_DISCONNECT_AFTER raises an alert. Short drops that resume on
+// their own, which happen routinely, stay quiet.
+func gatewayDisconnect(s *discordgo.Session, event *discordgo.Disconnect) {
+	disconnectMu.Lock()
+	defer disconnectMu.Unlock()
+	if alertsStopped || disconnectTimer != nil {
+		return
+	}
+	metrics.Inc("gateway_disconnects_total")
+	disconnectedAt = time.Now()
+	shard := s.ShardID
+	disconnectTimer = time.AfterFunc(config().AlertDisconnectAfter, func() {
+		raiseAlert(alertDisconnect, fmt.Sprintf("**Disconnected from Discord**: shard %d has been down for %s and is still reconnecting.", shard, config().AlertDisconnectAfter))
+	})
+}
+
+// gatewayReconnect ends the disconnect watch once the gateway is back.
+func gatewayReconnect(s *discordgo.Session) {
+	disconnectMu.Lock()
+	defer disconnectMu.Unlock()
+	if disconnectTimer == nil {
+		return
+	}
+	disconnectTimer.Stop()
+	disconnectTimer = nil
+	resolveAlert(alertDisconnect, fmt.Sprintf("Shard %d reconnected to Discord after %s.", s.ShardID, time.Since(disconnectedAt).Round(time.Second)))
+}
+
+func gatewayConnect(s *discordgo.Session, event *discordgo.Connect) { gatewayReconnect(s) }
+
+func gatewayResumed(s *discordgo.Session, event *discordgo.Resumed) { gatewayReconnect(s) }
+
+// stopAlerts ends the disconnect watch before a planned disconnect on
+// shutdown.
+func stopAlerts() {
+	disconnectMu.Lock()
+	defer disconnectMu.Unlock()
+	alertsStopped = true
+	if disconnectTimer != nil {
+		disconnectTimer.Stop()
+		disconnectTimer = nil
+	}
+}
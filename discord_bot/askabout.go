@@ -0,0 +1,156 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	askAboutCommand = "Ask Elsie about this"
+
+	askAboutEphemeral = "ephemeral"
+	askAboutChannel   = "channel"
+)
+
+func init() {
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name: askAboutCommand,
+		Type: discordgo.MessageApplicationCommand,
+	}, askAboutMessage)
+}
+
+// askAboutMessage serves the message context menu: the right-clicked
+// message goes to the agent with an "ask about" intent, and the answer is
+// shown to the invoker alone or to the channel, per ASK_ABOUT_REPLY.
+func askAboutMessage(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	var target *discordgo.Message
+	if data.Resolved != nil {
+		target = data.Resolved.Messages[data.TargetID]
+	}
+	if target == nil || strings.TrimSpace(target.Content) == "" {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "empty"))
+		respondEphemeral(s, i, tr(i.GuildID, "*squints at the message* There's nothing there I can read — I can only talk about text."))
+		return
+	}
+	if contentProcessingDisabled(i.GuildID) {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "denied"))
+		respondEphemeral(s, i, tr(i.GuildID, "*shakes head* This server has asked me not to read messages."))
+		return
+	}
+	if refusesChannel(s, i.GuildID, i.ChannelID) {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "denied"))
+		respondEphemeral(s, i, themePhrase(i.GuildID, "nsfw_refusal", nil))
+		return
+	}
+	if target.Author != nil && userExclusion(i.GuildID, target.Author.ID) != "" {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "excluded"))
+		respondEphemeral(s, i, tr(i.GuildID, "*shakes head* Its author asked me to leave their messages alone."))
+		return
+	}
+
+	user := interactionUser(i)
+	content, allowed := screenContent(s, i.GuildID, i.ChannelID, user.ID, "inbound", target.Content)
+	if !allowed {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "blocked"))
+		respondEphemeral(s, i, themePhrase(i.GuildID, "outbound_blocked", nil))
+		return
+	}
+
+	var flags discordgo.MessageFlags
+	if config().AskAboutReply == askAboutEphemeral {
+		flags = discordgo.MessageFlagsEphemeral
+	}
+	// The agent can take longer than the three seconds Discord allows for
+	// an answer, so the reply is deferred and edited in afterwards
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: flags},
+	})
+	if err != nil {
+		metrics.Inc(metricLabel("ask_about_total", "outcome", "send_error"))
+		log.Printf("Error deferring ask about reply: %v", err)
+		return
+	}
+
+	rlog := requestLog{id: newRequestID()}
+	rlog.Printf("💭 %s asked about message %s", logUser(user.Username, user.ID), target.ID)
+	noteChannelActivity(i.GuildID, i.ChannelID)
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        target.ID,
+		ChannelID: i.ChannelID,
+		GuildID:   i.GuildID,
+		Author:    user,
+		Content:   content,
+	}}
+	author := ""
+	if target.Author != nil {
+		author = target.Author.Username
+	}
+	extra := map[string]interface{}{
+		"intent": "ask_about_message",
+		"target_message": map[string]interface{}{
+			"id":      target.ID,
+			"author":  author,
+			"content": content,
+		},
+	}
+	p := channelPersona(s, i.GuildID, i.ChannelID)
+	exchange := exchangeRecord{
+		Time:           time.Now(),
+		RequestID:      rlog.id,
+		GuildID:        i.GuildID,
+		ChannelID:      i.ChannelID,
+		MessageID:      target.ID,
+		AuthorID:       user.ID,
+		Persona:        p.ID,
+		AgentSessionID: p.sessionID(i.ChannelID),
+		Outcome:        exchangeNoResponse,
+	}
+	defer func() { exchanges.record(exchange) }()
+
+	guildStats.recordMessage(i.GuildID, i.ChannelID)
+	resp := processWithAIEnhanced(content, s, m, p, extra, rlog)
+	response := ""
+	if resp != nil {
+		response = resp.Response
+	}
+	guildStats.recordAgentCall(i.GuildID, resp == nil, len(response))
+	outcome := "answered"
+	switch {
+	case resp == nil:
+		exchange.Outcome, outcome = exchangeFallback, exchangeFallback
+		response = fallbackResponse(p, content)
+	case resp.silent() || strings.TrimSpace(response) == "":
+		outcome = exchangeNoResponse
+		response = tr(i.GuildID, "*Elsie reads it over and shrugs* I don't have anything to add.")
+	default:
+		recordForwarded(p, p.sessionID(i.ChannelID))
+		var ok bool
+		if response, ok = screenContent(s, i.GuildID, i.ChannelID, user.ID, "outbound", response); !ok {
+			response = themePhrase(i.GuildID, "outbound_blocked", nil)
+		}
+		exchange.Outcome = exchangeSent
+	}
+
+	var sent []*discordgo.Message
+	for n, chunk := range messageChunks(postProcess(s, i.ChannelID, response)) {
+		var msg *discordgo.Message
+		if n == 0 {
+			msg, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &chunk})
+		} else {
+			msg, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{Content: chunk, Flags: flags})
+		}
+		if err != nil {
+			rlog.Printf("Error sending ask about reply: %v", err)
+			exchange.Outcome, outcome = exchangeSendError, exchangeSendError
+			break
+		}
+		sent = append(sent, msg)
+	}
+	exchange.ResponseMessageIDs = messageIDs(sent)
+	metrics.Inc(metricLabel("ask_about_total", "outcome", outcome))
+}
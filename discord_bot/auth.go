@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authenticator verifies a request for a named endpoint and returns the
+// authenticated principal for logging.
+type authenticator interface {
+	authenticate(r *http.Request, endpoint string) (principal string, err error)
+}
+
+var errNoCredentials = errors.New("no credentials presented")
+
+// authenticators are selected by name in HTTP_AUTH_<ENDPOINT> policies.
+var authenticators = map[string]authenticator{
+	"token": tokenAuth{},
+	"mtls":  mtlsAuth{},
+	"oidc":  &oidcAuth{},
+}
+
+// requireAuth wraps handler so a request must satisfy at least one of the
+// authenticators in policy. "none" disables auth for the endpoint.
+func requireAuth(endpoint string, policy []string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var failures []string
+		for _, name := range policy {
+			if name == "none" {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			auth, ok := authenticators[name]
+			if !ok {
+				failures = append(failures, name+": unknown authenticator")
+				continue
+			}
+			principal, err := auth.authenticate(r, endpoint)
+			if err == nil {
+				log.Printf("🔐 %s %s authorized via %s as %s", r.Method, r.URL.Path, name, principal)
+				handler.ServeHTTP(w, r)
+				return
+			}
+			failures = append(failures, name+": "+err.Error())
+		}
+		log.Printf("🔐 Denied %s %s from %s (%s)", r.Method, r.URL.Path, r.RemoteAddr, strings.Join(failures, "; "))
+		metrics.Inc(metricLabel("http_auth_denied", "endpoint", endpoint))
+		w.Header().Set("WWW-Authenticate", `Bearer realm="elsie"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+// endpointSetting reads a per-endpoint variable such as HTTP_TOKEN_METRICS,
+// falling back to the shared variable (HTTP_TOKEN) when unset.
+func endpointSetting(prefix, endpoint string) string {
+	if v := strings.TrimSpace(os.Getenv(prefix + "_" + strings.ToUpper(endpoint))); v != "" {
+		return v
+	}
+	return strings.TrimSpace(os.Getenv(prefix))
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// tokenAuth accepts a static bearer token from HTTP_TOKEN_<ENDPOINT> or the
+// shared HTTP_TOKEN.
+type tokenAuth struct{}
+
+func (tokenAuth) authenticate(r *http.Request, endpoint string) (string, error) {
+	want := endpointSetting("HTTP_TOKEN", endpoint)
+	if want == "" {
+		return "", errors.New("no token configured")
+	}
+	got := bearerToken(r)
+	if got == "" {
+		return "", errNoCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return "", errors.New("invalid token")
+	}
+	return "static-token", nil
+}
+
+// mtlsAuth accepts a client certificate verified against HTTP_CLIENT_CA,
+// optionally restricted to the common names in HTTP_MTLS_ALLOWED_<ENDPOINT>.
+type mtlsAuth struct{}
+
+func (mtlsAuth) authenticate(r *http.Request, endpoint string) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", errNoCredentials
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if allowed := endpointSetting("HTTP_MTLS_ALLOWED", endpoint); allowed != "" && !listContains(allowed, cn) {
+		return "", fmt.Errorf("certificate %q not allowed", cn)
+	}
+	return "cert:" + cn, nil
+}
+
+// oidcAuth accepts RS256 ID/access tokens issued by OIDC_ISSUER for
+// OIDC_AUDIENCE, optionally restricted to the subjects or emails in
+// OIDC_ALLOWED_<ENDPOINT>.
+type oidcAuth struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+type jwtClaims struct {
+	Issuer   string          `json:"iss"`
+	Subject  string          `json:"sub"`
+	Email    string          `json:"email"`
+	Audience json.RawMessage `json:"aud"`
+	Expiry   int64           `json:"exp"`
+}
+
+func (a *oidcAuth) authenticate(r *http.Request, endpoint string) (string, error) {
+	if config().OIDCIssuer == "" {
+		return "", errors.New("OIDC not configured")
+	}
+	token := bearerToken(r)
+	if token == "" {
+		return "", errNoCredentials
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return "", err
+	}
+	principal := claims.Subject
+	if claims.Email != "" {
+		principal = claims.Email
+	}
+	if allowed := endpointSetting("OIDC_ALLOWED", endpoint); allowed != "" &&
+		!listContains(allowed, claims.Subject) && !listContains(allowed, claims.Email) {
+		return "", fmt.Errorf("%s not allowed", principal)
+	}
+	return "oidc:" + principal, nil
+}
+
+func (a *oidcAuth) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+	key, err := a.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(config().OIDCIssuer, "/") {
+		return nil, errors.New("wrong issuer")
+	}
+	if time.Now().Unix() >= claims.Expiry {
+		return nil, errors.New("token expired")
+	}
+	if config().OIDCAudience != "" && !audienceContains(claims.Audience, config().OIDCAudience) {
+		return nil, errors.New("wrong audience")
+	}
+	return &claims, nil
+}
+
+// key returns the signing key for kid, refreshing the issuer's JWKS at most
+// once a minute so unknown key IDs can't be used to hammer the issuer.
+func (a *oidcAuth) key(kid string) (*rsa.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(a.fetched) < time.Minute {
+		return nil, errors.New("unknown signing key")
+	}
+	a.fetched = time.Now()
+	keys, err := fetchJWKS(config().OIDCIssuer)
+	if err != nil {
+		log.Printf("Error fetching OIDC keys: %v", err)
+		return nil, errors.New("signing keys unavailable")
+	}
+	a.keys = keys
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func fetchJWKS(issuer string) (map[string]*rsa.PublicKey, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := getJSON(client, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(client, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// audienceContains handles the JWT "aud" claim being a string or an array.
+func audienceContains(raw json.RawMessage, want string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == want
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		for _, aud := range many {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listContains reports whether a comma-separated list contains value.
+func listContains(list, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
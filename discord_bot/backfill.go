@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// threadBackfillBucket records agent sessions in threads that have been
+// backfilled, or that the agent followed from the start, by session ID.
+const threadBackfillBucket = "thread_backfills"
+
+// threadsSeen caches the bucket so most messages skip the store.
+var threadsSeen = newLRUCache[string, bool]("threads_seen", 5000, time.Hour)
+
+func init() {
+	trackCache(threadsSeen)
+	registerDataEraser(dataEraser{name: "thread backfills", guild: eraseChannelKeys(threadBackfillBucket)})
+}
+
+// threadBackfill returns the thread's earlier conversation when Elsie is
+// mentioned in a thread her agent session hasn't seen yet, so she can join
+// a running scene knowing what happened. The first message of any kind
+// marks the session seen; only a mention fetches history.
+func threadBackfill(s *discordgo.Session, m *discordgo.MessageCreate, p *persona, mentioned bool, rlog requestLog) []transcriptMessage {
+	if config().BackfillMaxMessages <= 0 || m.GuildID == "" {
+		return nil
+	}
+	sessionID := p.sessionID(m.ChannelID)
+	if threadsSeen.Contains(sessionID) {
+		return nil
+	}
+	channel, err := getChannel(s, m.ChannelID)
+	if err != nil || !channel.IsThread() {
+		return nil
+	}
+	threadsSeen.Add(sessionID, true)
+	var seen time.Time
+	if ok, err := store.Get(threadBackfillBucket, sessionID, &seen); err != nil || ok {
+		return nil
+	}
+	if err := store.Put(threadBackfillBucket, sessionID, time.Now()); err != nil {
+		log.Printf("Error recording thread backfill for %s: %v", sessionID, err)
+	}
+	if !mentioned {
+		return nil
+	}
+
+	history, err := readRecentHistory(s, m.ChannelID, m.ID, config().BackfillMaxMessages)
+	if err != nil {
+		rlog.Printf("Error reading thread history to backfill: %v", err)
+		return nil
+	}
+	transcript := summaryTranscript(s, m.GuildID, history)
+	for i := range transcript {
+		transcript[i].Content = truncateText(transcript[i].Content, 1000)
+	}
+	if len(transcript) > 0 {
+		rlog.Printf("🧵 Backfilling %d earlier messages from thread %s", len(transcript), m.ChannelID)
+		metrics.Inc("thread_backfills_total")
+	}
+	return transcript
+}
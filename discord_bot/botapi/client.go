@@ -0,0 +1,173 @@
+// Package botapi is a typed client for the Elsie bot's HTTP endpoints, as
+// described by the bot's /openapi.json. Its types mirror the bot's JSON
+// wire format; when an endpoint changes, update both here and bump the
+// bot's apiVersion.
+package botapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIVersion is the version of the bot's HTTP contract this client was
+// written against, matching info.version in /openapi.json.
+const APIVersion = "1.0.0"
+
+// Client calls one bot instance. Token is sent as a bearer token, which
+// covers the "token" and "oidc" auth policies; for mTLS, give HTTPClient a
+// transport with the client certificate.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New returns a client for the bot at baseURL, e.g. "http://elsie:9090".
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is a non-2xx answer from the bot.
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bot returned %d: %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// BroadcastResult is the response to POST /broadcast.
+type BroadcastResult struct {
+	Sent      int `json:"sent"`
+	OptedOut  int `json:"opted_out"`
+	NoChannel int `json:"no_channel"`
+	Failed    int `json:"failed"`
+}
+
+// Exchange links a player's message to the agent request it triggered and
+// the bot's replies, as returned by GET /trace.
+type Exchange struct {
+	Time               time.Time `json:"time"`
+	RequestID          string    `json:"request_id"`
+	GuildID            string    `json:"guild_id,omitempty"`
+	ChannelID          string    `json:"channel_id"`
+	MessageID          string    `json:"message_id"`
+	MergedMessageIDs   []string  `json:"merged_message_ids,omitempty"`
+	AuthorID           string    `json:"author_id"`
+	Persona            string    `json:"persona"`
+	AgentSessionID     string    `json:"agent_session_id,omitempty"`
+	ResponseMessageIDs []string  `json:"response_message_ids,omitempty"`
+	Outcome            string    `json:"outcome"`
+}
+
+// Broadcast posts an announcement to every server that hasn't opted out.
+func (c *Client) Broadcast(ctx context.Context, message string) (*BroadcastResult, error) {
+	var result BroadcastResult
+	body := map[string]string{"message": message}
+	if err := c.do(ctx, http.MethodPost, "/broadcast", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Trace finds the exchanges behind a request ID, message ID or message
+// link. guildID limits the search to one guild when set.
+func (c *Client) Trace(ctx context.Context, id, guildID string) ([]Exchange, error) {
+	query := url.Values{"id": {id}}
+	if guildID != "" {
+		query.Set("guild_id", guildID)
+	}
+	var records []Exchange
+	if err := c.do(ctx, http.MethodGet, "/trace", query, nil, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// InjectFault queues a test fault (agent-timeout, discord-429,
+// gateway-drop or clear) on a bot running with CHAOS_ENABLED.
+func (c *Client) InjectFault(ctx context.Context, fault string, count int) (string, error) {
+	query := url.Values{"fault": {fault}, "count": {strconv.Itoa(count)}}
+	var text string
+	err := c.do(ctx, http.MethodPost, "/chaos", query, nil, &text)
+	return strings.TrimSpace(text), err
+}
+
+// Metrics returns the bot's metrics in Prometheus text format.
+func (c *Client) Metrics(ctx context.Context) (string, error) {
+	var text string
+	err := c.do(ctx, http.MethodGet, "/metrics", nil, nil, &text)
+	return text, err
+}
+
+// OpenAPI returns the bot's OpenAPI document.
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, &doc)
+	return doc, err
+}
+
+// do sends the request and decodes the response into out: a *string takes
+// the raw body, anything else is decoded as JSON.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &Error{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	if text, ok := out.(*string); ok {
+		*text = string(data)
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
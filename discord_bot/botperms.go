@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// botPermission is a channel permission Elsie uses and what breaks without
+// it. Required permissions are checked by the startup self-test.
+type botPermission struct {
+	name     string
+	bit      int64
+	purpose  string
+	required bool
+}
+
+// botPermissions are everything Elsie can use in a channel. The invite URL
+// requests exactly these.
+var botPermissions = []botPermission{
+	{"View Channel", discordgo.PermissionViewChannel, "see the channel at all", true},
+	{"Send Messages", discordgo.PermissionSendMessages, "reply", true},
+	{"Send Messages in Threads", discordgo.PermissionSendMessagesInThreads, "reply in threads", false},
+	{"Embed Links", discordgo.PermissionEmbedLinks, "show menus, recaps and initiative", true},
+	{"Read Message History", discordgo.PermissionReadMessageHistory, "follow reply chains and recaps", true},
+	{"Add Reactions", discordgo.PermissionAddReactions, "react to messages", false},
+	{"Attach Files", discordgo.PermissionAttachFiles, "post images from the agent", false},
+	{"Manage Webhooks", discordgo.PermissionManageWebhooks, "speak as personas", false},
+	{"Create Public Threads", discordgo.PermissionCreatePublicThreads, "open threads", false},
+	{"Manage Messages", discordgo.PermissionManageMessages, "retract replies, pin trackers and move OOC chatter out of scenes", false},
+	{"Manage Threads", discordgo.PermissionManageThreads, "archive and lock threads when a scene closes", false},
+	{"Manage Roles", discordgo.PermissionManageRoles, "grant whitelisted roles when the agent asks", false},
+	{"Manage Events", discordgo.PermissionManageEvents, "schedule RP sessions as server events", false},
+	{"Connect", discordgo.PermissionVoiceConnect, "join voice channels to listen", false},
+	{"Speak", discordgo.PermissionVoiceSpeak, "speak in the voice channels it joins", false},
+	{"Send TTS Messages", discordgo.PermissionSendTTSMessages, "have voice answers read aloud with VOICE_REPLY_TTS", false},
+}
+
+// missingPermissions returns the entries of want that perms lacks.
+// Administrator grants everything.
+func missingPermissions(perms int64, want []botPermission) []botPermission {
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return nil
+	}
+	var missing []botPermission
+	for _, p := range want {
+		if perms&p.bit == 0 {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// requiredPermissions returns the permissions Elsie can't work without.
+func requiredPermissions() []botPermission {
+	var required []botPermission
+	for _, p := range botPermissions {
+		if p.required {
+			required = append(required, p)
+		}
+	}
+	return required
+}
+
+// inviteURL builds an OAuth2 invite for the bot with the permissions it
+// uses and the scope slash commands need.
+func inviteURL(applicationID string) string {
+	var perms int64
+	for _, p := range botPermissions {
+		perms |= p.bit
+	}
+	return fmt.Sprintf("https://discord.com/oauth2/authorize?client_id=%s&scope=bot+applications.commands&permissions=%d", applicationID, perms)
+}
+
+func init() {
+	registerCommand(command{name: "permissions", handler: permissionsCommand})
+	registerCommand(command{name: "invite", handler: inviteCommand})
+}
+
+// permissionsCommand is `!elsie permissions`: the bot's effective
+// permissions in this channel and what's missing.
+func permissionsCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Permissions only apply in server channels — run this there.")
+		return
+	}
+	perms, err := ctx.s.UserChannelPermissions(ctx.s.State.User.ID, ctx.m.ChannelID)
+	if err != nil {
+		log.Printf("Error resolving bot permissions in %s: %v", ctx.m.ChannelID, err)
+		ctx.reply("*holographic matrix flickers* I couldn't work out my permissions here.")
+		return
+	}
+	missing := missingPermissions(perms, botPermissions)
+	if len(missing) == 0 {
+		ctx.reply("✅ I have every permission I use in this channel.")
+		return
+	}
+	var b strings.Builder
+	b.WriteString("🔧 **Missing permissions in this channel:**\n")
+	for _, p := range missing {
+		fmt.Fprintf(&b, "• **%s** — needed to %s\n", p.name, p.purpose)
+	}
+	b.WriteString("Grant these to my role, or check this channel's permission overrides.")
+	ctx.reply(b.String())
+}
+
+// inviteCommand is the owner-only `!elsie invite`.
+func inviteCommand(ctx *commandContext) {
+	if !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*shakes head* Only my operators can hand out invites.")
+		return
+	}
+	ctx.reply("🔗 Invite me with the permissions I use:\n<" + inviteURL(ctx.s.State.User.ID) + ">")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Which bot accounts and webhooks Elsie answers, besides the allowed ones.
+// Webhooks are usually proxied RP characters typed by people, so they're
+// answered by default.
+const (
+	botPolicyIgnore   = "ignore"
+	botPolicyWebhooks = "webhooks"
+	botPolicyAll      = "all"
+)
+
+func validBotPolicy(policy string) bool {
+	return policy == botPolicyIgnore || policy == botPolicyWebhooks || policy == botPolicyAll
+}
+
+// botLoop is how often Elsie answered bots in a channel lately.
+type botLoop struct {
+	replies      []time.Time
+	trippedUntil time.Time
+}
+
+var (
+	// botLoopsMu guards the entries of botLoops.
+	botLoopsMu sync.Mutex
+	botLoops   = newLRUCache[string, *botLoop]("bot_loops", 5000, time.Hour)
+)
+
+func init() {
+	trackCache(botLoops)
+	registerCommand(command{name: "bots", handler: botsCommand})
+}
+
+// guildBotPolicy is the guild's bot policy, or BOT_POLICY.
+func guildBotPolicy(guildID string) string {
+	if guildID != "" {
+		if policy := loadGuildConfig(guildID).BotPolicy; policy != "" {
+			return policy
+		}
+	}
+	return config().BotPolicy
+}
+
+// botAuthorAllowed reports whether a message from a bot account or webhook
+// may be processed in the guild. Allowed IDs, from ALLOWED_BOT_IDS or the
+// guild's list, pass whatever the policy.
+func botAuthorAllowed(guildID, authorID string, webhook bool) bool {
+	if slices.Contains(config().AllowedBotIDs, authorID) {
+		return true
+	}
+	if guildID != "" && slices.Contains(loadGuildConfig(guildID).AllowedBots, authorID) {
+		return true
+	}
+	switch guildBotPolicy(guildID) {
+	case botPolicyAll:
+		return true
+	case botPolicyWebhooks:
+		return webhook
+	default:
+		return false
+	}
+}
+
+// botLoopTripped counts a bot or webhook message Elsie is about to answer
+// in the channel, and reports whether she should stay out of it instead.
+// Answering BOT_LOOP_MAX_REPLIES of them within BOT_LOOP_WINDOW means two
+// bots are likely talking each other in circles, so bots in the channel go
+// unanswered for BOT_LOOP_COOLDOWN, or until a person posts there.
+func botLoopTripped(channelID string) bool {
+	c := config()
+	if c.BotLoopMaxReplies <= 0 {
+		return false
+	}
+	botLoopsMu.Lock()
+	loop, _ := botLoops.Get(channelID)
+	if loop == nil {
+		loop = &botLoop{}
+		botLoops.Add(channelID, loop)
+	}
+	now := time.Now()
+	if now.Before(loop.trippedUntil) {
+		botLoopsMu.Unlock()
+		return true
+	}
+	loop.replies = slices.DeleteFunc(loop.replies, func(t time.Time) bool { return now.Sub(t) > c.BotLoopWindow })
+	loop.replies = append(loop.replies, now)
+	tripped := len(loop.replies) > c.BotLoopMaxReplies
+	if tripped {
+		loop.replies, loop.trippedUntil = nil, now.Add(c.BotLoopCooldown)
+	}
+	botLoopsMu.Unlock()
+
+	if tripped {
+		log.Printf("🔁 Broke a bot loop in %s: %d bot messages answered within %s", channelID, c.BotLoopMaxReplies, c.BotLoopWindow)
+		metrics.Inc("bot_loops_broken_total")
+		raiseAlert(alertBotLoop+":"+channelID, fmt.Sprintf("**Bot loop broken** in <#%s>: I answered %d bot messages within %s, so I'm ignoring bots there for %s or until someone posts.",
+			channelID, c.BotLoopMaxReplies, c.BotLoopWindow, c.BotLoopCooldown))
+	}
+	return tripped
+}
+
+// noteHumanMessage ends any bot loop cooldown in the channel: with a person
+// in the conversation, answering again is safe.
+func noteHumanMessage(channelID string) {
+	botLoopsMu.Lock()
+	defer botLoopsMu.Unlock()
+	if loop, ok := botLoops.Get(channelID); ok && (len(loop.replies) > 0 || !loop.trippedUntil.IsZero()) {
+		botLoops.Remove(channelID)
+	}
+}
+
+// botsCommand is `!elsie bots [ignore|webhooks|all|default]` and `!elsie
+// bots allow|deny <@bot|ID...>`: which bots and webhooks Elsie answers in
+// the server. Without arguments it shows the policy.
+func botsCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie bots`, `!elsie bots ignore|webhooks|all|default`, `!elsie bots allow <@bot|ID...>`, `!elsie bots deny <@bot|ID...>`"
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Bot policies are per server — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change which bots I answer."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		ctx.replyQuietly(describeBotPolicy(ctx))
+		return
+	}
+
+	sub := strings.ToLower(ctx.args[0])
+	var ids []string
+	switch sub {
+	case botPolicyIgnore, botPolicyWebhooks, botPolicyAll, "default":
+	case "allow", "deny":
+		for _, u := range ctx.m.Mentions {
+			ids = append(ids, u.ID)
+		}
+		for _, arg := range ctx.args[1:] {
+			if _, err := strconv.ParseUint(arg, 10, 64); err == nil {
+				ids = append(ids, arg)
+			}
+		}
+		if len(ids) == 0 {
+			ctx.reply(ctx.tr("Mention the bot or give its ID, e.g. `!elsie bots allow @Quark`."))
+			return
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		switch sub {
+		case "default":
+			cfg.BotPolicy = ""
+		case "allow", "deny":
+			cfg.AllowedBots = slices.DeleteFunc(cfg.AllowedBots, func(id string) bool { return slices.Contains(ids, id) })
+			if sub == "allow" {
+				cfg.AllowedBots = append(cfg.AllowedBots, ids...)
+			}
+		default:
+			cfg.BotPolicy = sub
+		}
+	})
+	if err != nil {
+		log.Printf("Error saving bot policy: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.replyQuietly(describeBotPolicy(ctx))
+}
+
+// describeBotPolicy explains which bots and webhooks Elsie answers in the
+// guild.
+func describeBotPolicy(ctx *commandContext) string {
+	var b strings.Builder
+	switch guildBotPolicy(ctx.m.GuildID) {
+	case botPolicyIgnore:
+		b.WriteString(ctx.tr("🤖 I ignore other bots and webhooks here."))
+	case botPolicyAll:
+		b.WriteString(ctx.tr("🤖 I answer other bots and webhooks here like anyone else."))
+	default:
+		b.WriteString(ctx.tr("🤖 I answer webhooks here, such as proxied characters, but ignore other bots."))
+	}
+	allowed := loadGuildConfig(ctx.m.GuildID).AllowedBots
+	if len(allowed) > 0 {
+		mentions := make([]string, len(allowed))
+		for i, id := range allowed {
+			mentions[i] = "<@" + id + ">"
+		}
+		b.WriteString("\n" + ctx.tr("Always answered: %s", strings.Join(mentions, ", ")))
+	}
+	if config().BotLoopMaxReplies > 0 {
+		b.WriteString("\n" + ctx.tr("Once I've answered %d bot messages in a channel within %s, I stop answering bots there for %s or until someone posts.", config().BotLoopMaxReplies, config().BotLoopWindow, config().BotLoopCooldown))
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// broadcastRequest is the body of POST /broadcast.
+type broadcastRequest struct {
+	Message string `json:"message"`
+}
+
+// broadcastResult summarizes one broadcast across all guilds.
+type broadcastResult struct {
+	Sent      int `json:"sent"`
+	OptedOut  int `json:"opted_out"`
+	NoChannel int `json:"no_channel"`
+	Failed    int `json:"failed"`
+}
+
+func (r broadcastResult) String() string {
+	return fmt.Sprintf("sent to %d, opted out %d, no channel %d, failed %d", r.Sent, r.OptedOut, r.NoChannel, r.Failed)
+}
+
+// announcementChannel returns where broadcasts go in a guild: the configured
+// announcement channel, else the guild's system channel.
+func announcementChannel(guild *discordgo.Guild, cfg *GuildConfig) string {
+	if cfg.AnnouncementChannelID != "" {
+		return cfg.AnnouncementChannelID
+	}
+	return guild.SystemChannelID
+}
+
+// broadcast posts text to the announcement channel of every guild that
+// hasn't opted out. Sends are paced to stay clear of rate limits.
+func broadcast(s *discordgo.Session, text string) broadcastResult {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Announcement from the bar",
+		Description: text,
+	}
+
+	var result broadcastResult
+	for _, guild := range stateGuilds(s) {
+		cfg := loadGuildConfig(guild.ID)
+		if cfg.BroadcastOptOut {
+			result.OptedOut++
+			continue
+		}
+		channelID := announcementChannel(guild, cfg)
+		if channelID == "" {
+			result.NoChannel++
+			continue
+		}
+		themed := *embed
+		themed.Title = themeEmoji(guild.ID, "announcement") + " " + embed.Title
+		themed.Color = themeColor(guild.ID, "info")
+		if _, err := s.ChannelMessageSendEmbed(channelID, &themed); err != nil {
+			log.Printf("Error broadcasting to guild %s channel %s: %v", guild.ID, channelID, err)
+			result.Failed++
+		} else {
+			result.Sent++
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	log.Printf("📢 BROADCAST: %s", result)
+	metrics.Add("broadcast_messages_total", float64(result.Sent))
+	return result
+}
+
+func init() {
+	registerCommand(command{name: "broadcast", handler: broadcastCommand})
+	registerCommand(command{name: "announcements", handler: announcementsCommand})
+
+	// POST /broadcast {"message": "..."}
+	registerEndpoint("broadcast", "/broadcast", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req broadcastRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Message) == "" {
+			http.Error(w, `expected {"message": "..."}`, http.StatusBadRequest)
+			return
+		}
+		result := broadcast(botSession, req.Message)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}), apiOperation{
+		Method:   http.MethodPost,
+		Summary:  "Post an announcement to every server that hasn't opted out",
+		Request:  broadcastRequest{},
+		Response: broadcastResult{},
+	})
+}
+
+// broadcastCommand is the owner-only `!elsie broadcast <message>`.
+func broadcastCommand(ctx *commandContext) {
+	if !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*shakes head* Only my operators can make fleet-wide announcements.")
+		return
+	}
+	if ctx.raw == "" {
+		ctx.reply("Usage: `!elsie broadcast <message>`")
+		return
+	}
+	ctx.reply(fmt.Sprintf("📢 Broadcasting to %d servers...", len(ctx.s.State.Guilds)))
+	result := broadcast(ctx.s, ctx.raw)
+	ctx.reply("📢 Broadcast complete: " + result.String())
+}
+
+// announcementsCommand lets guild admins choose where broadcasts land or
+// opt out: `!elsie announcements [on|off|channel <#channel>]`.
+func announcementsCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Announcement settings are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change announcement settings.")
+		return
+	}
+
+	if len(ctx.args) == 0 {
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		state := "on"
+		if cfg.BroadcastOptOut {
+			state = "off"
+		}
+		channel := "system channel"
+		if cfg.AnnouncementChannelID != "" {
+			channel = fmt.Sprintf("<#%s>", cfg.AnnouncementChannelID)
+		}
+		ctx.reply(fmt.Sprintf("📢 **Announcements:** %s • Channel: %s\nUsage: `!elsie announcements on|off`, `!elsie announcements channel #channel`", state, channel))
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	var confirmation string
+	switch strings.ToLower(ctx.args[0]) {
+	case "on":
+		apply = func(cfg *GuildConfig) { cfg.BroadcastOptOut = false }
+		confirmation = "📢 Operator announcements are **on** for this server."
+	case "off":
+		apply = func(cfg *GuildConfig) { cfg.BroadcastOptOut = true }
+		confirmation = "📢 Operator announcements are **off** for this server."
+	case "channel":
+		if len(ctx.args) < 2 || parseChannelMention(ctx.args[1]) == "" {
+			ctx.reply("Mention the channel, e.g. `!elsie announcements channel #announcements`.")
+			return
+		}
+		channelID := parseChannelMention(ctx.args[1])
+		apply = func(cfg *GuildConfig) { cfg.AnnouncementChannelID = channelID }
+		confirmation = fmt.Sprintf("📢 Operator announcements will be posted in <#%s>.", channelID)
+	default:
+		ctx.reply("Usage: `!elsie announcements [on|off|channel #channel]`")
+		return
+	}
+
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving announcement config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(confirmation)
+}
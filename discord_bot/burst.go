@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// burst collects consecutive messages from one author in a monitored
+// channel, so multi-message narration reaches the agent as one request.
+type burst struct {
+	authorID   string
+	parts      []string
+	messageIDs []string
+	lastAt     time.Time
+	closed     bool
+}
+
+var (
+	burstsMu sync.Mutex
+	bursts   = map[string]*burst{} // by channel ID
+)
+
+// coalesceBurst adds a monitored message to its channel's burst. The message
+// that opens a burst waits until the author has been quiet for BurstWindow
+// (or BurstMaxMessages arrive) and gets back the merged content and every
+// merged message ID with ok set. Messages absorbed into an open burst get ok
+// false and should not be processed further. A message from a different
+// author closes the open burst early, since the narration was interrupted.
+func coalesceBurst(m *discordgo.MessageCreate, content string) (merged string, messageIDs []string, ok bool) {
+	if config().BurstWindow <= 0 {
+		return content, []string{m.ID}, true
+	}
+
+	burstsMu.Lock()
+	if b := bursts[m.ChannelID]; b != nil && !b.closed {
+		if b.authorID == m.Author.ID {
+			b.parts = append(b.parts, content)
+			b.messageIDs = append(b.messageIDs, m.ID)
+			b.lastAt = time.Now()
+			if len(b.parts) >= config().BurstMaxMessages {
+				b.closed = true
+			}
+			burstsMu.Unlock()
+			metrics.Inc("burst_messages_merged_total")
+			return "", nil, false
+		}
+		b.closed = true
+	}
+	b := &burst{authorID: m.Author.ID, parts: []string{content}, messageIDs: []string{m.ID}, lastAt: time.Now()}
+	bursts[m.ChannelID] = b
+	burstsMu.Unlock()
+
+	for {
+		burstsMu.Lock()
+		wait := config().BurstWindow - time.Since(b.lastAt)
+		if b.closed || wait <= 0 {
+			b.closed = true
+			if bursts[m.ChannelID] == b {
+				delete(bursts, m.ChannelID)
+			}
+			merged, messageIDs = strings.Join(b.parts, "\n"), b.messageIDs
+			burstsMu.Unlock()
+			return merged, messageIDs, true
+		}
+		burstsMu.Unlock()
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// agentSchemaVersion is the /process payload version this bot speaks. It is
+// sent with every request as schema_version; bump it when the payload
+// changes in a way an agent needs to know about.
+const agentSchemaVersion = 2
+
+// Agent features the bot negotiates through GET /capabilities.
+const (
+	featureActions    = "actions"
+	featureFollowUp   = "follow_up"
+	featureFeedback   = "feedback"
+	featureLoadHints  = "load_hints"
+	featurePersonas   = "personas"
+	featureSummarize  = "summarize"
+	featureEnvelope   = "envelope"
+	featureForget     = "forget"
+	featureTranscribe = "transcribe"
+	featureMissionLog = "mission_log"
+	featureImages     = "images"
+)
+
+// agentCapabilities is an agent's answer to GET /capabilities.
+type agentCapabilities struct {
+	SchemaVersion int      `json:"schema_version"`
+	Features      []string `json:"features"`
+}
+
+// botFeatures are sent with the handshake so the agent knows what the bot
+// can handle.
+var botFeatures = []string{featureActions, featureFollowUp, featureFeedback, featureLoadHints, featurePersonas, featureSummarize, featureEnvelope, featureForget, featureTranscribe, featureMissionLog, featureImages}
+
+// fetchCapabilities asks b for its capabilities. An agent without the
+// endpoint predates the handshake; it keeps nil capabilities and is
+// assumed to support everything, as before.
+func fetchCapabilities(b *agentBackend) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := fmt.Sprintf("%s/capabilities?schema_version=%d&features=%s", b.url, agentSchemaVersion, strings.Join(botFeatures, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	authorizeAgentRequest(req)
+	resp, err := agentClient.Do(req)
+	if err != nil {
+		log.Printf("Capability handshake with %s failed: %v", b.url, err)
+		return
+	}
+	defer closeAgentBody(resp)
+	checkAgentAuth(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		b.setCapabilities(nil)
+		log.Printf("🤝 AI agent %s has no /capabilities; assuming a legacy agent", b.url)
+		return
+	}
+	if resp.StatusCode >= 300 {
+		log.Printf("Capability handshake with %s failed: %s", b.url, resp.Status)
+		return
+	}
+	var caps agentCapabilities
+	if err := json.NewDecoder(resp.Body).Decode(&caps); err != nil {
+		log.Printf("Invalid capabilities from %s: %v", b.url, err)
+		return
+	}
+	b.setCapabilities(&caps)
+	log.Printf("🤝 AI agent %s speaks schema v%d with features %v", b.url, caps.SchemaVersion, caps.Features)
+	if caps.SchemaVersion > 0 && caps.SchemaVersion < agentSchemaVersion {
+		log.Printf("⚠️  AI agent %s is on an older schema (v%d < v%d); newer features are disabled for it",
+			b.url, caps.SchemaVersion, agentSchemaVersion)
+	}
+}
+
+func (b *agentBackend) setCapabilities(caps *agentCapabilities) {
+	b.mu.Lock()
+	b.caps = caps
+	b.mu.Unlock()
+	version := 0.0
+	if caps != nil {
+		version = float64(caps.SchemaVersion)
+	}
+	metrics.Set(metricLabel("agent_schema_version", "url", b.url), version)
+}
+
+// supports reports whether the agent advertised feature. Agents that
+// haven't answered the handshake are assumed to support everything.
+func (b *agentBackend) supports(feature string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.caps == nil || slices.Contains(b.caps.Features, feature)
+}
+
+// supports reports whether the agent a request is about to go to supports
+// feature.
+func (p *agentPool) supports(feature string) bool {
+	order := p.callOrder()
+	return len(order) == 0 || order[0].supports(feature)
+}
+
+// degradeResponse drops response fields the answering agent didn't
+// negotiate, so a half-implemented feature on an older agent is ignored.
+func degradeResponse(b *agentBackend, resp *AIResponse, rlog requestLog) {
+	if b == nil {
+		return
+	}
+	if len(resp.Actions) > 0 && !b.supports(featureActions) {
+		rlog.Printf("Ignoring actions from %s, which didn't negotiate them", b.url)
+		resp.Actions = nil
+	}
+	if resp.FollowUp != nil && !b.supports(featureFollowUp) {
+		rlog.Printf("Ignoring follow_up_after from %s, which didn't negotiate it", b.url)
+		resp.FollowUp = nil
+	}
+	if len(resp.Images) > 0 && !b.supports(featureImages) {
+		rlog.Printf("Ignoring images from %s, which didn't negotiate them", b.url)
+		resp.Images = nil
+	}
+	if resp.SlowDown != nil && !b.supports(featureLoadHints) {
+		resp.SlowDown = nil
+	}
+}
+
+// runCapabilityHandshake fetches every agent's capabilities at startup.
+// Agents that recover later are asked again by setHealthy, since they may
+// have been upgraded while down.
+func runCapabilityHandshake() {
+	for _, b := range allAgentBackends() {
+		fetchCapabilities(b)
+	}
+}
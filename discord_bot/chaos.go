@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Failure injection for resilience testing. Everything here is inert unless
+// CHAOS_ENABLED=true, so it can never fire in a normal deployment.
+
+const (
+	faultAgentTimeout = "agent-timeout"
+	faultDiscord429   = "discord-429"
+	faultGatewayDrop  = "gateway-drop"
+)
+
+// chaosCloseCode is the websocket close code used for simulated gateway
+// drops; 4000 is Discord's "unknown error, reconnect" code.
+const chaosCloseCode = 4000
+
+// chaosState counts how many more times each fault should fire.
+type chaosState struct {
+	mu      sync.Mutex
+	pending map[string]int
+}
+
+var chaos = &chaosState{pending: make(map[string]int)}
+
+func (c *chaosState) arm(fault string, count int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[fault] += count
+	log.Printf("💥 CHAOS: armed %s x%d", fault, count)
+}
+
+// consume reports whether fault should fire now, using up one charge.
+func (c *chaosState) consume(fault string) bool {
+	if !config().ChaosEnabled {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[fault] <= 0 {
+		return false
+	}
+	c.pending[fault]--
+	metrics.Inc(metricLabel("chaos_injected_total", "fault", fault))
+	log.Printf("💥 CHAOS: injecting %s (%d remaining)", fault, c.pending[fault])
+	return true
+}
+
+func (c *chaosState) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = make(map[string]int)
+}
+
+func (c *chaosState) describe() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("%s: %d • %s: %d", faultAgentTimeout, c.pending[faultAgentTimeout],
+		faultDiscord429, c.pending[faultDiscord429])
+}
+
+// injectAgentTimeout blocks until ctx expires when an agent timeout is armed,
+// so the request fails exactly like a hung agent would.
+func injectAgentTimeout(ctx context.Context) error {
+	if !chaos.consume(faultAgentTimeout) {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// chaosTransport answers Discord REST calls with a synthetic 429 when armed,
+// exercising discordgo's rate-limit handling.
+type chaosTransport struct {
+	next http.RoundTripper
+}
+
+func (t chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if chaos.consume(faultDiscord429) {
+		body := `{"message": "You are being rate limited.", "retry_after": 1.0, "global": false}`
+		header := http.Header{}
+		header.Set("Content-Type", "application/json")
+		header.Set("Retry-After", "1")
+		header.Set("X-RateLimit-Remaining", "0")
+		header.Set("X-RateLimit-Reset-After", "1.0")
+		return &http.Response{
+			Status:        "429 Too Many Requests",
+			StatusCode:    http.StatusTooManyRequests,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+// initChaos installs the fault-injecting transport on the Discord session.
+func initChaos(dg *discordgo.Session) {
+	if !config().ChaosEnabled {
+		return
+	}
+	next := dg.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	dg.Client.Transport = chaosTransport{next: next}
+	log.Printf("💥 CHAOS: failure injection is ENABLED — do not run this in production")
+}
+
+// dropGateway closes the gateway connection abruptly and reconnects, the
+// way a network blip would.
+func dropGateway(s *discordgo.Session) {
+	metrics.Inc(metricLabel("chaos_injected_total", "fault", faultGatewayDrop))
+	log.Printf("💥 CHAOS: dropping gateway connection")
+	if err := s.CloseWithCode(chaosCloseCode); err != nil {
+		log.Printf("💥 CHAOS: error closing gateway: %v", err)
+	}
+	time.Sleep(2 * time.Second)
+	if err := s.Open(); err != nil {
+		log.Printf("💥 CHAOS: error reopening gateway: %v", err)
+	}
+}
+
+// applyFault arms or triggers a fault by name.
+func applyFault(s *discordgo.Session, fault string, count int) error {
+	switch fault {
+	case faultAgentTimeout, faultDiscord429:
+		chaos.arm(fault, count)
+	case faultGatewayDrop:
+		go dropGateway(s)
+	case "clear":
+		chaos.clear()
+	default:
+		return fmt.Errorf("unknown fault %q", fault)
+	}
+	return nil
+}
+
+func init() {
+	registerCommand(command{name: "chaos", handler: chaosCommand})
+
+	// POST /chaos?fault=agent-timeout&count=3
+	registerEndpoint("chaos", "/chaos", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config().ChaosEnabled {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil || count < 1 {
+			count = 1
+		}
+		if err := applyFault(botSession, r.URL.Query().Get("fault"), count); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "ok: %s\n", chaos.describe())
+	}), apiOperation{
+		Method:  http.MethodPost,
+		Summary: "Inject a fault for testing (404 unless CHAOS_ENABLED)",
+		Query: []apiParam{
+			{Name: "fault", Type: "string", Required: true, Description: "agent-timeout, discord-429, gateway-drop or clear"},
+			{Name: "count", Type: "integer", Description: "How many times the fault fires (default 1)"},
+		},
+		ResponseType: "text/plain",
+	})
+}
+
+// chaosCommand is `!elsie chaos <agent-timeout|discord-429|gateway-drop|clear|status> [count]`,
+// available to bot owners only when CHAOS_ENABLED is set.
+func chaosCommand(ctx *commandContext) {
+	if !config().ChaosEnabled || !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*blinks* I don't know that command.")
+		return
+	}
+	if len(ctx.args) == 0 || strings.EqualFold(ctx.args[0], "status") {
+		ctx.reply("💥 **Pending faults** — " + chaos.describe())
+		return
+	}
+	count := 1
+	if len(ctx.args) > 1 {
+		if n, err := strconv.Atoi(ctx.args[1]); err == nil && n > 0 {
+			count = n
+		}
+	}
+	fault := strings.ToLower(ctx.args[0])
+	if err := applyFault(ctx.s, fault, count); err != nil {
+		ctx.reply("Usage: `!elsie chaos <agent-timeout|discord-429|gateway-drop|clear|status> [count]`")
+		return
+	}
+	ctx.reply(fmt.Sprintf("💥 Fault `%s` applied. Pending — %s", fault, chaos.describe()))
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ChannelIgnoreRules stop Elsie from monitoring channels that would
+// otherwise match an allow rule. Mentions and commands still work there.
+type ChannelIgnoreRules struct {
+	// Categories are category channel IDs whose channels (and threads under
+	// them) are never monitored.
+	Categories []string `json:"categories,omitempty"`
+	// OlderThanJoin ignores channels created before Elsie joined the guild.
+	OlderThanJoin bool `json:"older_than_join,omitempty"`
+	// MaxAge ignores channels created longer ago than this, e.g. "720h".
+	MaxAge string `json:"max_age,omitempty"`
+	// Archived ignores archived threads.
+	Archived bool `json:"archived,omitempty"`
+}
+
+func (r ChannelIgnoreRules) isEmpty() bool {
+	return len(r.Categories) == 0 && !r.OlderThanJoin && r.MaxAge == "" && !r.Archived
+}
+
+// channelClass is the classifier's verdict for a channel.
+type channelClass struct {
+	// Monitored channels have every message forwarded, not just mentions.
+	Monitored bool
+	// AllowedBy lists the allow rules that matched ("thread", "rp_channel",
+	// "configured").
+	AllowedBy []string
+	// IgnoredBy is the ignore rule that overrode them, if any.
+	IgnoredBy string
+}
+
+// classifyChannel decides whether a guild channel is monitored: allow rules
+// (threads, channels named for roleplay, channels picked in setup) are
+// evaluated first, then the
+// guild's ignore rules can veto them.
+func classifyChannel(s *discordgo.Session, guildID string, channel *discordgo.Channel) channelClass {
+	var class channelClass
+	if isThreadChannel(channel) {
+		class.AllowedBy = append(class.AllowedBy, "thread")
+	}
+	name := strings.ToLower(channel.Name)
+	if strings.Contains(name, "rp") || strings.Contains(name, "roleplay") {
+		class.AllowedBy = append(class.AllowedBy, "rp_channel")
+	}
+	cfg := loadGuildConfig(guildID)
+	if slices.Contains(cfg.MonitoredChannels, channel.ID) || (isThreadChannel(channel) && slices.Contains(cfg.MonitoredChannels, channel.ParentID)) {
+		class.AllowedBy = append(class.AllowedBy, "configured")
+	}
+	if len(class.AllowedBy) == 0 {
+		return class
+	}
+	class.Monitored = true
+
+	rules := cfg.IgnoreRules
+	if rules.isEmpty() {
+		return class
+	}
+	if reason := rules.match(s, guildID, channel); reason != "" {
+		class.Monitored = false
+		class.IgnoredBy = reason
+	}
+	return class
+}
+
+// match returns the first ignore rule the channel falls under, or "".
+func (r ChannelIgnoreRules) match(s *discordgo.Session, guildID string, channel *discordgo.Channel) string {
+	if r.Archived && channel.ThreadMetadata != nil && channel.ThreadMetadata.Archived {
+		return "archived"
+	}
+	if len(r.Categories) > 0 {
+		if categoryID := channelCategory(s, channel); categoryID != "" {
+			for _, id := range r.Categories {
+				if id == categoryID {
+					return "category"
+				}
+			}
+		}
+	}
+	created, err := discordgo.SnowflakeTimestamp(channel.ID)
+	if err != nil {
+		return ""
+	}
+	if r.MaxAge != "" {
+		if maxAge, err := parseAge(r.MaxAge); err == nil && time.Since(created) > maxAge {
+			return "age"
+		}
+	}
+	if r.OlderThanJoin {
+		if joined := botJoinedAt(s, guildID); !joined.IsZero() && created.Before(joined) {
+			return "older_than_join"
+		}
+	}
+	return ""
+}
+
+// channelCategory returns the category a channel sits in; threads use their
+// parent channel's category.
+func channelCategory(s *discordgo.Session, channel *discordgo.Channel) string {
+	if !isThreadChannel(channel) {
+		return channel.ParentID
+	}
+	parent, err := getChannel(s, channel.ParentID)
+	if err != nil {
+		return ""
+	}
+	return parent.ParentID
+}
+
+// botJoinedAt returns when Elsie joined the guild, from the gateway state or
+// her member record.
+func botJoinedAt(s *discordgo.Session, guildID string) time.Time {
+	if guild, err := getGuild(s, guildID); err == nil && !guild.JoinedAt.IsZero() {
+		return guild.JoinedAt
+	}
+	if member, err := getMember(s, guildID, s.State.User.ID); err == nil {
+		return member.JoinedAt
+	}
+	return time.Time{}
+}
+
+// parseAge accepts Go durations plus a day suffix, e.g. "90d".
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return d, nil
+}
+
+func init() {
+	registerCommand(command{name: "ignore", handler: ignoreCommand})
+}
+
+// ignoreCommand manages the guild's channel ignore rules:
+// `!elsie ignore [category <#channel|id>|older-than-join on|off|older-than <90d|off>|archived on|off]`.
+func ignoreCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Ignore rules are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change which channels I monitor.")
+		return
+	}
+	usage := "Usage: `!elsie ignore category <id>`, `!elsie ignore older-than-join on|off`, `!elsie ignore older-than <90d|off>`, `!elsie ignore archived on|off`"
+	if len(ctx.args) == 0 {
+		ctx.reply(describeIgnoreRules(loadGuildConfig(ctx.m.GuildID).IgnoreRules) + "\n" + usage)
+		return
+	}
+	if len(ctx.args) < 2 {
+		ctx.reply(usage)
+		return
+	}
+
+	value := strings.ToLower(ctx.args[1])
+	var apply func(r *ChannelIgnoreRules)
+	switch strings.ToLower(ctx.args[0]) {
+	case "category":
+		categoryID := parseChannelMention(ctx.args[1])
+		if categoryID == "" {
+			ctx.reply("Give the category's ID (right-click the category → Copy Channel ID).")
+			return
+		}
+		if category, err := getChannel(ctx.s, categoryID); err != nil || category.Type != discordgo.ChannelTypeGuildCategory || category.GuildID != ctx.m.GuildID {
+			ctx.reply("*squints* That isn't a category in this server.")
+			return
+		}
+		apply = func(r *ChannelIgnoreRules) {
+			for i, id := range r.Categories {
+				if id == categoryID {
+					r.Categories = append(r.Categories[:i], r.Categories[i+1:]...)
+					return
+				}
+			}
+			r.Categories = append(r.Categories, categoryID)
+		}
+	case "older-than-join":
+		if value != "on" && value != "off" {
+			ctx.reply(usage)
+			return
+		}
+		apply = func(r *ChannelIgnoreRules) { r.OlderThanJoin = value == "on" }
+	case "older-than":
+		if value != "off" {
+			if _, err := parseAge(value); err != nil {
+				ctx.reply("Give an age like `90d` or `720h`, or `off`.")
+				return
+			}
+		}
+		apply = func(r *ChannelIgnoreRules) {
+			r.MaxAge = value
+			if value == "off" {
+				r.MaxAge = ""
+			}
+		}
+	case "archived":
+		if value != "on" && value != "off" {
+			ctx.reply(usage)
+			return
+		}
+		apply = func(r *ChannelIgnoreRules) { r.Archived = value == "on" }
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	var rules ChannelIgnoreRules
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		apply(&cfg.IgnoreRules)
+		rules = cfg.IgnoreRules
+	})
+	if err != nil {
+		log.Printf("Error saving ignore rules: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(describeIgnoreRules(rules))
+}
+
+func describeIgnoreRules(r ChannelIgnoreRules) string {
+	if r.isEmpty() {
+		return "🙈 **Ignore rules:** none. I monitor every thread and roleplay channel."
+	}
+	var lines []string
+	for _, id := range r.Categories {
+		lines = append(lines, fmt.Sprintf("• Channels in category <#%s>", id))
+	}
+	if r.OlderThanJoin {
+		lines = append(lines, "• Channels created before I joined")
+	}
+	if r.MaxAge != "" {
+		lines = append(lines, fmt.Sprintf("• Channels older than %s", r.MaxAge))
+	}
+	if r.Archived {
+		lines = append(lines, "• Archived threads")
+	}
+	return "🙈 **Ignore rules** (mentions and commands still work):\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// CommandGate limits a command in one guild to members with one of Roles,
+// or with Permission. Gates add to a command's own checks; they never let
+// anyone past them.
+type CommandGate struct {
+	Roles      []string `json:"roles,omitempty"`
+	Permission string   `json:"permission,omitempty"`
+}
+
+// gatePermissions are the Discord permissions a gate can require, by the
+// name used in `!elsie access`.
+var gatePermissions = map[string]int64{
+	"administrator":    discordgo.PermissionAdministrator,
+	"manage_server":    discordgo.PermissionManageServer,
+	"manage_channels":  discordgo.PermissionManageChannels,
+	"manage_roles":     discordgo.PermissionManageRoles,
+	"manage_messages":  discordgo.PermissionManageMessages,
+	"manage_threads":   discordgo.PermissionManageThreads,
+	"manage_events":    discordgo.PermissionManageEvents,
+	"moderate_members": discordgo.PermissionModerateMembers,
+	"kick_members":     discordgo.PermissionKickMembers,
+	"ban_members":      discordgo.PermissionBanMembers,
+	"mention_everyone": discordgo.PermissionMentionEveryone,
+}
+
+func init() {
+	registerCommand(command{name: "access", handler: accessCommand})
+}
+
+// commandGate finds the gate for a command, preferring one on its
+// subcommand, e.g. "scene start" over "scene". Slash commands are keyed
+// with their slash, e.g. "/prefix".
+func commandGate(guildID, name string, args []string) (string, CommandGate, bool) {
+	if guildID == "" {
+		return "", CommandGate{}, false
+	}
+	gates := loadGuildConfig(guildID).CommandGates
+	if len(args) > 0 {
+		key := name + " " + strings.ToLower(args[0])
+		if gate, ok := gates[key]; ok {
+			return key, gate, true
+		}
+	}
+	gate, ok := gates[name]
+	return name, gate, ok
+}
+
+// gateAllows reports whether a member with roles and perms passes gate.
+func gateAllows(gate CommandGate, roles []string, perms int64) bool {
+	for _, id := range gate.Roles {
+		if slices.Contains(roles, id) {
+			return true
+		}
+	}
+	bit, ok := gatePermissions[gate.Permission]
+	return ok && perms&bit != 0
+}
+
+// gateDenial explains what a gated command needs, naming roles rather than
+// mentioning them so nobody is pinged.
+func gateDenial(s *discordgo.Session, guildID, key string, gate CommandGate) string {
+	shown := "`!elsie " + key + "`"
+	if strings.HasPrefix(key, "/") {
+		shown = "`" + key + "`"
+	}
+	var needs []string
+	if names := roleNames(s, guildID, gate.Roles); len(names) > 0 {
+		needs = append(needs, tr(guildID, "the %s role", strings.Join(names, " / ")))
+	}
+	if gate.Permission != "" {
+		needs = append(needs, tr(guildID, "the %s permission", permissionLabel(gate.Permission)))
+	}
+	return tr(guildID, "🔒 %s is limited to members with %s in this server. Ask an admin if you think you should have access.", shown, strings.Join(needs, tr(guildID, " or ")))
+}
+
+// roleNames returns the names of the roles, in bold, skipping deleted ones.
+func roleNames(s *discordgo.Session, guildID string, roleIDs []string) []string {
+	guild, err := getGuild(s, guildID)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, role := range guild.Roles {
+		if slices.Contains(roleIDs, role.ID) {
+			names = append(names, "**"+role.Name+"**")
+		}
+	}
+	return names
+}
+
+// permissionLabel turns "manage_messages" into "Manage Messages".
+func permissionLabel(name string) string {
+	words := strings.Split(name, "_")
+	for i, w := range words {
+		if w != "" {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// commandAllowed enforces the guild's gate on a `!elsie` command, replying
+// with what it needs when the author lacks it. Admins always pass, so a
+// bad gate can't lock them out.
+func commandAllowed(ctx *commandContext, name string) bool {
+	key, gate, ok := commandGate(ctx.m.GuildID, name, ctx.args)
+	if !ok || isGuildAdmin(ctx.s, ctx.m) {
+		return true
+	}
+	var roles []string
+	if ctx.m.Member != nil {
+		roles = ctx.m.Member.Roles
+	}
+	if gateAllows(gate, roles, authorPermissions(ctx.s, ctx.m)) {
+		return true
+	}
+	metrics.Inc(metricLabel("command_gate_denials_total", "command", key))
+	ctx.reply(gateDenial(ctx.s, ctx.m.GuildID, key, gate))
+	return false
+}
+
+// slashCommandAllowed is commandAllowed for slash commands. It returns the
+// denial to show when the member lacks access.
+func slashCommandAllowed(s *discordgo.Session, i *discordgo.InteractionCreate, name string) (string, bool) {
+	if i.Member == nil {
+		return "", true
+	}
+	key, gate, ok := commandGate(i.GuildID, "/"+name, nil)
+	if !ok || isBotOwner(interactionUser(i).ID) {
+		return "", true
+	}
+	perms := i.Member.Permissions
+	if perms&(discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0 || gateAllows(gate, i.Member.Roles, perms) {
+		return "", true
+	}
+	metrics.Inc(metricLabel("command_gate_denials_total", "command", key))
+	return gateDenial(s, i.GuildID, key, gate), false
+}
+
+// accessCommand is `!elsie access [<command> [sub] role <roles>|permission
+// <name>|open]`.
+func accessCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie access <command> [subcommand] role <@role or name>`, `!elsie access <command> permission <name>` or `!elsie access <command> open`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Command access is per server — use this command in a server channel."))
+		return
+	}
+	cfg := loadGuildConfig(ctx.m.GuildID)
+	if len(ctx.args) == 0 {
+		ctx.reply(accessList(ctx.s, ctx.m.GuildID, cfg.CommandGates) + "\n" + usage)
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change who may use commands."))
+		return
+	}
+
+	verb := slices.IndexFunc(ctx.args, func(a string) bool {
+		switch strings.ToLower(a) {
+		case "role", "roles", "permission", "open":
+			return true
+		}
+		return false
+	})
+	if verb < 1 || verb > 2 {
+		ctx.reply(usage)
+		return
+	}
+	key := strings.ToLower(strings.Join(ctx.args[:verb], " "))
+	name, _, _ := strings.Cut(key, " ")
+	if _, ok := commands[name]; !ok {
+		if _, ok := slashCommands[strings.TrimPrefix(name, "/")]; !ok || !strings.HasPrefix(name, "/") {
+			ctx.reply(ctx.tr("*squints* I don't know a command called `%s`. Slash commands start with `/`, e.g. `/prefix`.", name))
+			return
+		}
+	}
+	if name == "access" {
+		ctx.reply(ctx.tr("*shakes head* `!elsie access` is always limited to admins."))
+		return
+	}
+	rest := ctx.args[verb+1:]
+
+	var gate CommandGate
+	switch strings.ToLower(ctx.args[verb]) {
+	case "open":
+	case "permission":
+		if len(rest) != 1 {
+			ctx.reply(usage)
+			return
+		}
+		perm := strings.ToLower(strings.ReplaceAll(rest[0], "-", "_"))
+		if _, ok := gatePermissions[perm]; !ok {
+			names := make([]string, 0, len(gatePermissions))
+			for n := range gatePermissions {
+				names = append(names, "`"+n+"`")
+			}
+			sort.Strings(names)
+			ctx.reply(ctx.tr("*squints* Unknown permission. Pick one of %s.", strings.Join(names, ", ")))
+			return
+		}
+		gate.Permission = perm
+	default:
+		roles, ok := parseRoles(ctx.s, ctx.m, rest)
+		if !ok {
+			ctx.reply(ctx.tr("*squints* I couldn't find those roles. Mention them, or give their IDs or exact names."))
+			return
+		}
+		gate.Roles = roles
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		if len(gate.Roles) == 0 && gate.Permission == "" {
+			delete(cfg.CommandGates, key)
+			return
+		}
+		if cfg.CommandGates == nil {
+			cfg.CommandGates = map[string]CommandGate{}
+		}
+		// Roles and a permission can be combined by setting each in turn
+		current := cfg.CommandGates[key]
+		if gate.Roles == nil {
+			gate.Roles = current.Roles
+		}
+		if gate.Permission == "" {
+			gate.Permission = current.Permission
+		}
+		cfg.CommandGates[key] = gate
+	})
+	if err != nil {
+		log.Printf("Error saving command access: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	log.Printf("🔒 Access to %q in guild %s changed by %s", key, ctx.m.GuildID, ctx.m.Author.ID)
+	if len(gate.Roles) == 0 && gate.Permission == "" {
+		ctx.reply(ctx.tr("🔓 `%s` is open to everyone again.", key))
+		return
+	}
+	ctx.reply(accessList(ctx.s, ctx.m.GuildID, loadGuildConfig(ctx.m.GuildID).CommandGates))
+}
+
+// parseRoles resolves role mentions, IDs or a role name from args.
+func parseRoles(s *discordgo.Session, m *discordgo.MessageCreate, args []string) ([]string, bool) {
+	if len(m.MentionRoles) > 0 {
+		return m.MentionRoles, true
+	}
+	guild, err := getGuild(s, m.GuildID)
+	if err != nil || len(args) == 0 {
+		return nil, false
+	}
+	var ids []string
+	for _, arg := range args {
+		for _, role := range guild.Roles {
+			if role.ID == arg {
+				ids = append(ids, role.ID)
+			}
+		}
+	}
+	if len(ids) == len(args) {
+		return ids, true
+	}
+	name := strings.Join(args, " ")
+	for _, role := range guild.Roles {
+		if strings.EqualFold(role.Name, name) {
+			return []string{role.ID}, true
+		}
+	}
+	return nil, false
+}
+
+// accessList describes the guild's command gates.
+func accessList(s *discordgo.Session, guildID string, gates map[string]CommandGate) string {
+	if len(gates) == 0 {
+		return tr(guildID, "🔓 Every command is open to everyone allowed to use it.")
+	}
+	keys := make([]string, 0, len(gates))
+	for key := range gates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(tr(guildID, "🔒 **Command access**") + "\n")
+	for _, key := range keys {
+		gate := gates[key]
+		var needs []string
+		if names := roleNames(s, guildID, gate.Roles); len(names) > 0 {
+			needs = append(needs, strings.Join(names, ", "))
+		}
+		if gate.Permission != "" {
+			needs = append(needs, permissionLabel(gate.Permission))
+		}
+		fmt.Fprintf(&b, "• `%s` — %s\n", key, strings.Join(needs, tr(guildID, " or ")))
+	}
+	return b.String()
+}
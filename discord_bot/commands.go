@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandContext carries everything a command handler needs.
+type commandContext struct {
+	s    *discordgo.Session
+	m    *discordgo.MessageCreate
+	name string   // command name as typed, lowercased
+	args []string // whitespace-separated arguments after the name
+	raw  string   // everything after the name, untouched
+}
+
+// command is a locally handled `!elsie <name>` command.
+type command struct {
+	name string
+	// exact commands also fire when the whole message is just the name, e.g.
+	// "@Elsie ping", and never take arguments.
+	exact   bool
+	handler func(ctx *commandContext)
+}
+
+var commands = map[string]command{}
+
+var startTime = time.Now()
+
+// registerCommand adds a command to the dispatcher. Feature files call this
+// from their init functions.
+func registerCommand(c command) {
+	commands[c.name] = c
+}
+
+// dispatchCommand runs a local command if content names one. explicit is true
+// when the message used the command prefix. It reports whether the message
+// was handled.
+func dispatchCommand(s *discordgo.Session, m *discordgo.MessageCreate, content string, explicit bool) bool {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return false
+	}
+	name := strings.ToLower(fields[0])
+	cmd, ok := commands[name]
+	if !ok {
+		// Localized aliases, e.g. "hilfe" for "help"
+		canonical, found := resolveCommandAlias(name, userLanguage(m.Author.ID))
+		if !found {
+			return false
+		}
+		cmd = commands[canonical]
+	}
+	if cmd.exact && len(fields) > 1 {
+		return false
+	}
+	if !cmd.exact && !explicit {
+		return false
+	}
+
+	ctx := &commandContext{
+		s:    s,
+		m:    m,
+		name: name,
+		args: fields[1:],
+		raw:  strings.TrimSpace(content[len(fields[0]):]),
+	}
+	if !commandAllowed(ctx, cmd.name) {
+		return true
+	}
+	cmd.handler(ctx)
+	return true
+}
+
+// defaultCommandPrefix works unless a guild replaced it or turned prefix
+// commands off; see prefix.go.
+const defaultCommandPrefix = "!elsie"
+
+const maxPrefixLength = 16
+
+// trimCommandPrefix strips one of the guild's command prefixes from
+// content, reporting whether one was present.
+func trimCommandPrefix(guildID, content string) (string, bool) {
+	for _, prefix := range commandPrefixes(guildID) {
+		if rest, ok := strings.CutPrefix(content, prefix); ok {
+			return rest, true
+		}
+	}
+	return content, false
+}
+
+// validatePrefix checks a guild's custom prefix: a short single word that
+// doesn't collide with a persona's prefix. Empty clears it.
+func validatePrefix(prefix string) error {
+	if prefix == "" || prefix == defaultCommandPrefix {
+		return nil
+	}
+	if len(prefix) > maxPrefixLength || strings.ContainsAny(prefix, " \t\n`") {
+		return fmt.Errorf("a prefix is one word of up to %d characters", maxPrefixLength)
+	}
+	for _, p := range personas {
+		if p.Prefix != "" && (strings.HasPrefix(p.Prefix, prefix) || strings.HasPrefix(prefix, p.Prefix)) {
+			return fmt.Errorf("`%s` would clash with %s's `%s`", prefix, p.Name, p.Prefix)
+		}
+	}
+	return nil
+}
+
+// reply sends a plain message to the command's channel.
+func (ctx *commandContext) reply(text string) {
+	ctx.s.ChannelMessageSend(ctx.m.ChannelID, text)
+}
+
+// replyQuietly is reply for text that names players or roles: the
+// mentions show, but nobody is pinged.
+func (ctx *commandContext) replyQuietly(text string) {
+	ctx.s.ChannelMessageSendComplex(ctx.m.ChannelID, &discordgo.MessageSend{Content: text, AllowedMentions: noPings})
+}
+
+// hasFlag reports whether a `--flag` style argument was passed.
+func (ctx *commandContext) hasFlag(flag string) bool {
+	for _, arg := range ctx.args {
+		if strings.EqualFold(arg, "--"+flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChannelMention extracts the ID from a `<#id>` channel mention or a
+// bare ID.
+func parseChannelMention(arg string) string {
+	id := strings.TrimSuffix(strings.TrimPrefix(arg, "<#"), ">")
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return ""
+	}
+	return id
+}
+
+// truncateText shortens text to at most limit runes, marking the cut.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// helpLine is one command in the help message. desc is translated.
+type helpLine struct {
+	usage, desc string
+}
+
+// helpCommands are listed by `!elsie help`. Owner-only commands are left out.
+var helpCommands = []helpLine{
+	{"`!elsie [message]`", "Chat with Elsie"},
+	{"`@Elsie [message]`", "Mention me to chat"},
+	{"`!computer [request]`", "Ask the Ship's Computer instead"},
+	{"`!elsie menu`", "View the galactic drink menu"},
+	{"`!elsie dotd [channel #channel|off]`", "Today's drink of the day, and where I announce it (admins)"},
+	{"`!elsie help`", "Show this help message"},
+	{"`!elsie ping`", "Test if I'm online"},
+	{"`!elsie status [--memory]`", "Show my system status"},
+	{"`!elsie remember <name|pronouns|drink|timezone|language> <value>`", "Tell me about yourself"},
+	{"`!elsie forget [me|field]`", "Make me forget what I know about you, or everything I store about you"},
+	{"`!elsie dms [on|off]`", "Whether I DM you answers I couldn't post in a channel"},
+	{"`!elsie optout` / `!elsie optin`", "Have me ignore your messages everywhere, or undo that"},
+	{"`!elsie remind me|dm in 2h to ...` / `!elsie remind list|cancel <id>`", "Set a reminder, here or by DM"},
+	{"`!elsie poll \"question?\" \"option\" \"option\" ... [--for 2h]`", "Start a reaction poll; `list` and `close <id>` manage them"},
+	{"`!elsie stardate [now|YYYY-MM-DD|<stardate>]`", "Stardate lookups"},
+	{"`!elsie stardate calendar [offset <years>|set <stardate> [<units a day>]|reset]`", "Show or change this server's stardate calendar (admins)"},
+	{"`!elsie convert 5 lightyears to km`", "Unit conversions"},
+	{"`!elsie init [add <name> [roll]|remove <name>|next|end]`", "Track combat turn order"},
+	{"`!elsie trivia [start [rounds] [set|topic]|stop|top|sets]`", "Play bar trivia"},
+	{"`!elsie roll [dice]`", "Roll dice, e.g. `2d6+1` or `4dF`"},
+	{"`!elsie scene rules [fate|d20|custom <dice>|off]`", "Set a scene's rules profile (moderators)"},
+	{"`!elsie scene start [--ooc] [title]` / `!elsie scene close`", "Run a scene, with an optional paired OOC thread"},
+	{"`!elsie scene pairing on|off`", "Pair an OOC thread with every scene by default (admins)"},
+	{"`!elsie scene autoarchive bump|marker|off|default`", "Keep quiet scene threads open, or mark them paused (admins)"},
+	{"`!elsie scene stats [n]`", "Spotlight stats over the last closed scenes (moderators)"},
+	{"`!elsie log mission [#thread]`", "File a finished thread's mission report in the log channel (moderators)"},
+	{"`!elsie log channel [#channel|off]`", "Pick the forum or text channel for mission reports (admins)"},
+	{"`!elsie summarize [n]`", "Recap the last messages, or the whole thread"},
+	{"`!elsie filter`", "View or change the content filter (admins)"},
+	{"`!elsie retract [--edit] [reason]`", "Reply to one of my messages to take it down (moderators)"},
+	{"`!elsie announcements [on|off|channel #channel]`", "Where operator announcements go (admins)"},
+	{"`!elsie digest [on|off|preview|channel #channel|dm]`", "Weekly usage digest (admins)"},
+	{"`!elsie nsfw [respond|refuse]`", "Whether I answer in age-restricted channels (admins)"},
+	{"`!elsie access [<command> role <roles>|permission <name>|open]`", "Limit commands to roles or permissions (admins)"},
+	{"`!elsie content-processing [on|off]`", "Stop reading messages in this server; slash commands only (admins)"},
+	{"`!elsie prefix [<prefix> [only]|default|off]`", "Change or turn off the command prefix; `/prefix` always works (admins)"},
+	{"`!elsie telemetry [on|off]`", "Whether I log exchanges and usage stats for this server (admins)"},
+	{"`!elsie purge-data confirm`", "Delete everything I store about this server (admins)"},
+	{"`!elsie permissions`", "Check which of my permissions are missing in this channel"},
+	{"`!elsie setup`", "Pick monitored channels, persona, prefix and rate limit (admins)"},
+	{"`!elsie stage [link <stage> #channel|unlink <stage>]`", "Announce live stages in a text channel (admins)"},
+	{"`!elsie starboard [#channel|threshold <n>|off]`", "Repost messages with enough ⭐ to a best-of channel (admins)"},
+	{"`!elsie persona [list|set <persona>|clear] [#channel]`", "Who answers in a channel (admins)"},
+	{"`!elsie quota [set|reset|exempt]`", "Agent usage quotas (admins)"},
+	{"`!elsie postprocess [on|off <stage>|actions <style>|replace ...]`", "How my responses are cleaned up before sending (admins)"},
+	{"`!elsie trace <message|request ID>`", "Trace an exchange with the agent (admins)"},
+	{"`!elsie audit [#channel|@user|id] [count]`", "Show how recent messages were routed (admins)"},
+	{"`!elsie config history|rollback <version>`", "Review or revert server setting changes (admins)"},
+	{"`!elsie export [dm]` / `!elsie import`", "Save this server's settings and data to a file, or restore one (admins)"},
+	{"`!elsie schedule [add|remove|run|timezone] ...`", "Schedule happy hours, trivia and last call (admins)"},
+	{"`!elsie session [schedule|cancel] ...`", "Plan RP sessions as server events, with reminders (admins)"},
+	{"`!elsie voicegreet <voice channel>|off`", "Greet the first arrival in the bar's voice channel (admins)"},
+	{"`!elsie voice join [voice channel]|leave`", "Listen in a voice channel and answer spoken requests (admins)"},
+	{"`!elsie theme [name]`", "Show or pick the server's theme for system messages (admins)"},
+	{"`!elsie language [code|default]`", "Pick the server's language for my messages and replies (admins)"},
+	{"`!elsie tab [clear|top]`", "Show or settle your bar tab, or see the best customers"},
+	{"`!elsie tip @user [amount]`", "Tip a member karma"},
+	{"`!elsie karma [@user|top]`", "Show karma, or the karma leaderboard"},
+	{"`!elsie reports [channel #channel|off|anonymous on|off]`", "Forward DM and /report reports to staff (admins)"},
+	{"`!elsie ignore [category|older-than-join|older-than|archived] ...`", "Exclude channels from monitoring (admins)"},
+	{"`!elsie ack [#channel] [<emoji>|off]`", "React to messages I hear but don't answer in a channel, e.g. with 👀 or 🍺 (admins)"},
+	{"`!elsie bots [ignore|webhooks|all|default|allow|deny] ...`", "Choose which other bots and webhooks I answer (admins)"},
+	{"`!elsie block [@member...]` / `!elsie unblock @member...`", "Have me ignore members in this server, or list who is blocked (admins)"},
+	{"`!elsie listen|speak [#channel]`", "Only observe a channel, or join in again (admins)"},
+	{"`!elsie length [#channel] [<characters>|off]`", "Keep replies in a channel short, with the rest behind a More button (admins)"},
+	{"`!elsie topic [list|new <name>|switch <name>|delete <name>]`", "Keep separate conversations with Elsie in DMs"},
+	{"`!elsie ooc [skip|tag]`", "Skip or tag `((...))` and `ooc:` messages in RP channels"},
+	{"`!elsie actions [enable|disable <type>|role @role]`", "Control which Discord actions the agent may take (admins)"},
+}
+
+var helpSlashCommands = []helpLine{
+	{"`/order`", "Pick a drink from the menu"},
+	{"`/replicate`", "Order Klingon, Vulcan or human comfort food from the replicator"},
+	{"`/poll`", "Start a reaction poll"},
+	{"`Ask Elsie about this`", "Right-click a message, then Apps, to ask Elsie about it"},
+}
+
+var helpDrinks = []helpLine{
+	{"\"Romulan Ale\"", "Blue and mysterious"},
+	{"\"Earl Grey Hot\"", "The Captain's favorite"},
+	{"\"Blood Wine\"", "For Klingon warriors"},
+	{"\"Synthehol\"", "No hangover guaranteed!"},
+}
+
+// helpMessage renders the help message in lang.
+func helpMessage(lang string) string {
+	t := func(msgid string) string { return translate(lang, msgid) }
+	var b strings.Builder
+	section := func(title string, lines []helpLine) {
+		fmt.Fprintf(&b, "**%s**\n", t(title))
+		for _, l := range lines {
+			fmt.Fprintf(&b, "• %s - %s\n", l.usage, t(l.desc))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "🍺 **%s** 🍺\n\n", t("ELSIE - HOLOGRAPHIC BARTENDER"))
+	section("Commands:", helpCommands)
+	section("Slash Commands:", helpSlashCommands)
+	fmt.Fprintf(&b, "**%s**\n%s\n\n", t("Direct Messages:"), t("You can also chat with me privately by sending me a direct message! I'll respond to any message you send."))
+	section("Example Drinks to Order:", helpDrinks)
+	fmt.Fprintf(&b, "*%s*", t("I'm programmed with the finest bartending subroutines in the quadrant!"))
+	return b.String()
+}
+
+func init() {
+	registerCommand(command{
+		name:  "ping",
+		exact: true,
+		handler: func(ctx *commandContext) {
+			ctx.reply(ctx.tr("🍺 *holographic matrix responds* Pong! All systems operational!"))
+		},
+	})
+	registerCommand(command{
+		name:  "help",
+		exact: true,
+		handler: func(ctx *commandContext) {
+			ctx.reply(helpMessage(ctx.lang()) + aliasHelp(userLanguage(ctx.m.Author.ID)))
+		},
+	})
+	registerCommand(command{name: "status", handler: statusCommand})
+}
+
+func statusCommand(ctx *commandContext) {
+	if ctx.hasFlag("memory") {
+		ctx.reply(memoryReport())
+		return
+	}
+	ctx.reply(ctx.tr("🍺 **Holographic Matrix Status**\n"+
+		"• Uptime: %s\n"+
+		"• Guilds served: %d\n"+
+		"*Use `!elsie status --memory` for memory bank details.*",
+		time.Since(startTime).Round(time.Second), len(ctx.s.State.Guilds)))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	memoryCheckpointBucket = "memory_checkpoints"
+	// checkpointPersistEvery bounds how many counted messages a restart can
+	// lose without writing the store on every message.
+	checkpointPersistEvery = 10
+
+	// A failed compaction is retried after compactionBackoff, doubling with
+	// each failure in a row up to maxCompactionBackoff, so a broken agent
+	// isn't asked again on every message.
+	compactionBackoff    = time.Minute
+	maxCompactionBackoff = time.Hour
+)
+
+// MemoryCheckpoint tracks how much of a session the agent has had to
+// remember since it last compacted its memory.
+type MemoryCheckpoint struct {
+	PersonaID     string    `json:"persona_id"`
+	SinceCompact  int       `json:"since_compact"`
+	Total         int       `json:"total"`
+	Compactions   int       `json:"compactions"`
+	LastCompacted time.Time `json:"last_compacted,omitempty"`
+	CheckpointID  string    `json:"checkpoint_id,omitempty"`
+}
+
+// compactResponse is the agent's answer to POST /compact.
+type compactResponse struct {
+	CheckpointID string `json:"checkpoint_id"`
+}
+
+// compactionFailure is a session's run of failed compactions.
+type compactionFailure struct {
+	count   int
+	retryAt time.Time
+}
+
+var (
+	checkpointMu       sync.Mutex
+	checkpoints        = make(map[string]*MemoryCheckpoint)
+	compacting         = make(map[string]bool)
+	compactionFailures = make(map[string]compactionFailure)
+)
+
+// checkpoint returns the live checkpoint for sessionID, loading it on first
+// use. Callers must hold checkpointMu.
+func checkpoint(sessionID string) *MemoryCheckpoint {
+	if cp, ok := checkpoints[sessionID]; ok {
+		return cp
+	}
+	cp := &MemoryCheckpoint{}
+	if _, err := store.Get(memoryCheckpointBucket, sessionID, cp); err != nil {
+		log.Printf("Error loading memory checkpoint for %s: %v", sessionID, err)
+	}
+	checkpoints[sessionID] = cp
+	return cp
+}
+
+// recordForwarded counts a message the agent answered in sessionID and asks
+// the agent to compact its memory once COMPACTION_THRESHOLD is reached.
+func recordForwarded(p *persona, sessionID string) {
+	if config().CompactionThreshold <= 0 {
+		return
+	}
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	cp := checkpoint(sessionID)
+	cp.PersonaID = p.ID
+	cp.SinceCompact++
+	cp.Total++
+	due := cp.SinceCompact >= config().CompactionThreshold && time.Now().After(compactionFailures[sessionID].retryAt)
+	if due && !compacting[sessionID] {
+		compacting[sessionID] = true
+		go compactSession(sessionID, *cp)
+		return
+	}
+	if cp.Total%checkpointPersistEvery == 0 {
+		if err := store.Put(memoryCheckpointBucket, sessionID, cp); err != nil {
+			log.Printf("Error saving memory checkpoint for %s: %v", sessionID, err)
+		}
+	}
+}
+
+// compactSession asks the agent serving the session to summarize and trim
+// its memory, then records the checkpoint.
+func compactSession(sessionID string, cp MemoryCheckpoint) {
+	requestID := newRequestID()
+	rlog := requestLog{id: requestID}
+	rlog.Printf("🗜️ Requesting memory compaction for session %s after %d messages", sessionID, cp.SinceCompact)
+
+	payload := map[string]interface{}{
+		"session_id":    sessionID,
+		"persona":       cp.PersonaID,
+		"message_count": cp.SinceCompact,
+		"request_id":    requestID,
+	}
+	body, err := poolFor(cp.PersonaID).call(requestID, "/compact", payload)
+
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	delete(compacting, sessionID)
+	live := checkpoint(sessionID)
+
+	var rejected *agentRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		// The agent can't compact; start counting again rather than asking
+		// on every message.
+		rlog.Printf("Agent does not support compaction: %v", err)
+		live.SinceCompact = 0
+		delete(compactionFailures, sessionID)
+	case err != nil:
+		failure := compactionFailures[sessionID]
+		failure.count++
+		wait := compactionBackoff
+		for i := 1; i < failure.count && wait < maxCompactionBackoff; i++ {
+			wait *= 2
+		}
+		wait = min(wait, maxCompactionBackoff)
+		failure.retryAt = time.Now().Add(wait)
+		compactionFailures[sessionID] = failure
+		metrics.Inc("memory_compaction_failures_total")
+		rlog.Printf("Error compacting session %s, will retry in %s: %v", sessionID, wait, err)
+		return
+	default:
+		var resp compactResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			rlog.Printf("DEBUG: Could not parse compaction response: %v", err)
+		}
+		live.SinceCompact = 0
+		delete(compactionFailures, sessionID)
+		live.Compactions++
+		live.LastCompacted = time.Now()
+		live.CheckpointID = resp.CheckpointID
+		metrics.Inc("memory_compactions_total")
+		rlog.Printf("🗜️ Session %s compacted (checkpoint %q)", sessionID, resp.CheckpointID)
+	}
+	if err := store.Put(memoryCheckpointBucket, sessionID, live); err != nil {
+		rlog.Printf("Error saving memory checkpoint for %s: %v", sessionID, err)
+	}
+}
+
+// checkpointContext tells the agent about the session's last compaction so
+// it can limit the history it replays, or returns nil if there was none.
+func checkpointContext(sessionID string) map[string]interface{} {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	cp := checkpoint(sessionID)
+	if cp.Compactions == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"checkpoint_id":  cp.CheckpointID,
+		"compacted_at":   cp.LastCompacted.UTC().Format(time.RFC3339),
+		"messages_since": cp.SinceCompact,
+		"history_limit":  config().CompactionHistoryLimit,
+	}
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Config holds the settings read from the environment. Readers get the
+// current one from config() and never modify it: a reload builds a whole
+// new Config and swaps it in, so nobody sees a half-applied reload.
+type Config struct {
+	// Memory guardrails
+	MaxCachedChannels  int
+	MaxCachedGuilds    int
+	MaxCachedMembers   int
+	CacheTTL           time.Duration
+	CacheSweepInterval time.Duration
+	DedupWindow        time.Duration
+	DedupMaxMessages   int
+
+	// AI agent calls. AIAgentURL is the primary of AIAgentURLs; the rest
+	// are failover targets.
+	AIAgentURL          string
+	AIAgentURLs         []string
+	AgentTimeout        time.Duration
+	LoadHintsEnabled    bool
+	LoadSlowDownMax     time.Duration
+	LoadShedDepth       int
+	LoadShedReaction    string
+	AgentHealthInterval time.Duration
+	PersonaAgentURLs    map[string][]string
+
+	// Authentication to the AI agents
+	AgentAPIKey     string
+	AgentAuthHeader string
+	AgentTLSCert    string
+	AgentTLSKey     string
+	AgentTLSCA      string
+
+	// Connections to the AI agents
+	AgentMaxIdleConns    int
+	AgentIdleConnTimeout time.Duration
+	AgentHTTP2           bool
+
+	// Discord actions the agent may request
+	AgentActions []string
+
+	// Canned replies when no agent is reachable
+	FallbackResponsesFile string
+
+	// Operators
+	BotOwnerIDs    []string
+	AdminChannelID string
+	ErrorChannelID string
+
+	// Operational alerts; see alerts.go
+	AlertChannelID       string
+	AlertInterval        time.Duration
+	AlertWindow          time.Duration
+	AlertErrorRate       int
+	AlertDisconnectAfter time.Duration
+
+	// Other bots and webhooks; see botpolicy.go
+	BotPolicy         string
+	AllowedBotIDs     []string
+	BotLoopMaxReplies int
+	BotLoopWindow     time.Duration
+	BotLoopCooldown   time.Duration
+
+	ShutdownNoticeWindow time.Duration
+	ShutdownNoticeMax    int
+
+	// ReplyChainChunks sends the parts of a split message as replies to the
+	// first part.
+	ReplyChainChunks bool
+
+	// DM fallback when a channel send fails
+	DMFallbackEnabled bool
+	DMFallbackQuota   quotaLimit
+
+	// DMTopicMax caps the parallel conversations a player keeps in DMs.
+	DMTopicMax int
+
+	// Response post-processing
+	PostProcessors    []string
+	ResponseMaxLength int
+	// ResponseMaxDelay caps how long an agent may defer a reply.
+	ResponseMaxDelay time.Duration
+	// ThinkingPlaceholderAfter is how long a reply can take before Elsie
+	// posts a placeholder that the reply then replaces.
+	ThinkingPlaceholderAfter time.Duration
+
+	// Images in agent responses
+	ImageMaxBytes     int
+	ImageMaxCount     int
+	ImageFetchTimeout time.Duration
+
+	// Trivia
+	TriviaPackFile      string
+	TriviaAnswerWindow  time.Duration
+	TriviaDefaultRounds int
+
+	// StarboardDefaultThreshold is the star count for the starboard in
+	// guilds that didn't pick one.
+	StarboardDefaultThreshold int
+
+	// Karma
+	KarmaCooldown  time.Duration
+	KarmaMaxTip    int
+	KarmaBigTipper int
+
+	// Scene threads about to auto-archive
+	ThreadArchiveMode    string
+	ThreadArchiveWarning time.Duration
+
+	// Reminders
+	ReminderMaxDelay   time.Duration
+	ReminderMaxPerUser int
+
+	// Polls
+	PollDefaultDuration time.Duration
+	PollMaxDuration     time.Duration
+
+	// Conversation summaries
+	SummarizeDefaultMessages int
+	SummarizeMaxMessages     int
+	SummarizeCooldown        time.Duration
+
+	// BackfillMaxMessages caps the thread history sent when Elsie joins a
+	// thread.
+	BackfillMaxMessages int
+
+	// SpeakerContextMax caps the messages since Elsie's last post that are
+	// sent with a request in a monitored channel.
+	SpeakerContextMax int
+
+	// Duplicate instance detection
+	InstanceLockEnabled       bool
+	InstanceHeartbeatInterval time.Duration
+	InstanceLockStale         time.Duration
+
+	// Safe mode after repeated crashes
+	SafeModeThreshold   int
+	SafeModeWindow      time.Duration
+	SafeModeStableAfter time.Duration
+
+	// Startup checks and self-test
+	StartupChecksEnabled  bool
+	StartupRequireAgent   bool
+	SelfTestEnabled       bool
+	SelfTestChannels      []string
+	SelfTestSkip          []string
+	SelfTestRetryInterval time.Duration
+
+	// Content filter
+	FilterWordlistFile  string
+	FilterRegexFile     string
+	FilterDefaultLevel  string
+	FilterDefaultAction string
+
+	// Slash commands and the bar menu
+	SlashCommandGuildID string
+	DrinkCatalogFile    string
+	FoodCatalogFile     string
+	ThemePacksFile      string
+
+	// Voice listening
+	VoiceListenEnabled bool
+	VoiceWakeWord      string
+	VoiceSilenceGap    time.Duration
+	VoiceMinUtterance  time.Duration
+	VoiceMaxUtterance  time.Duration
+	VoiceReplyTTS      bool
+	STTProvider        string
+	STTURL             string
+	STTAPIKey          string
+	STTModel           string
+
+	// Error reporting to a Sentry-compatible service
+	SentryDSN         string
+	SentryEnvironment string
+	SentryRelease     string
+
+	// TelemetryEnabled allows the exchange log and usage stats.
+	TelemetryEnabled bool
+
+	// How much of the exchange log is kept
+	ExchangeLogRetention time.Duration
+	ExchangeLogMaxBytes  int
+
+	// Localization
+	DefaultLanguage string
+	LocalesDir      string
+
+	// Privacy
+	PrivacyLogging        string
+	PrivacyLogSalt        string
+	ContentDisabledGuilds []string
+
+	// Cooldowns and quotas
+	UserQuota            quotaLimit
+	ChannelQuota         quotaLimit
+	GuildQuota           quotaLimit
+	SlashCommandCooldown time.Duration
+
+	// Routing decisions kept for `!elsie audit`
+	DecisionLogSize int
+
+	// Burst coalescing in monitored channels
+	BurstWindow      time.Duration
+	BurstMaxMessages int
+
+	// Reaction feedback
+	FeedbackReactionsEnabled bool
+
+	// Agent-scheduled follow-ups
+	FollowUpsEnabled bool
+	FollowUpMaxDelay time.Duration
+
+	// Agent memory compaction
+	CompactionThreshold    int
+	CompactionHistoryLimit int
+
+	// Scenes
+	AutoPinRecaps      bool
+	StardateYearOffset int
+
+	// Usage reporting
+	WeeklyDigestEnabled bool
+	WeeklyAgentBudget   int
+
+	// Failure injection (testing only)
+	ChaosEnabled bool
+
+	// HTTP surfaces
+	HTTPAddr     string
+	HTTPTLSCert  string
+	HTTPTLSKey   string
+	HTTPClientCA string
+	OIDCIssuer   string
+	OIDCAudience string
+
+	// Presence rotation
+	PresenceStatuses []string
+	PresenceInterval time.Duration
+
+	// RP sessions
+	RPSessionReminder time.Duration
+	RPSessionDuration time.Duration
+
+	// Drink of the day
+	DrinkOfTheDayEnabled bool
+	DrinkOfTheDayHour    int
+	DrinkOfTheDaySource  string
+
+	// Admin dashboard
+	DashboardAddr          string
+	DashboardURL           string
+	DashboardClientID      string
+	DashboardClientSecret  string
+	DashboardSessionSecret string
+	DashboardSessionTTL    time.Duration
+	DashboardUsers         []string
+
+	// "Ask Elsie about this" message command
+	AskAboutReply string
+}
+
+// currentConfig is published by main and reloadConfig.
+var currentConfig atomic.Pointer[Config]
+
+// config returns the settings in effect.
+func config() *Config {
+	return currentConfig.Load()
+}
+
+// catalogSet holds the menus and packs read from the files Config names.
+// Like Config, it's replaced whole on a reload.
+type catalogSet struct {
+	drinks    []Drink
+	food      []Dish
+	trivia    []TriviaQuestion
+	fallbacks []fallbackIntent
+}
+
+var currentCatalogs atomic.Pointer[catalogSet]
+
+// loadCatalogs reads the catalog files c names.
+func loadCatalogs(c *Config) *catalogSet {
+	return &catalogSet{
+		drinks:    loadDrinkCatalog(c.DrinkCatalogFile),
+		food:      loadFoodCatalog(c.FoodCatalogFile),
+		trivia:    loadTriviaPack(c.TriviaPackFile),
+		fallbacks: loadFallbacks(c.FallbackResponsesFile),
+	}
+}
+
+// loadConfig reads the settings from the environment. It runs after the
+// .env file has been loaded in init(), and again on every reload.
+func loadConfig() *Config {
+	c := &Config{}
+	// AI_AGENT_URL may list several agents; the first is the primary
+	c.AIAgentURLs = envList("AI_AGENT_URL")
+	if len(c.AIAgentURLs) == 0 {
+		c.AIAgentURLs = []string{"http://localhost:8000"}
+	}
+	c.AIAgentURL = c.AIAgentURLs[0]
+
+	c.MaxCachedChannels = envInt("MAX_CACHED_CHANNELS", 5000)
+	c.MaxCachedGuilds = envInt("MAX_CACHED_GUILDS", 500)
+	c.MaxCachedMembers = envInt("MAX_CACHED_MEMBERS", 10000)
+	c.CacheTTL = envDuration("CACHE_TTL", 5*time.Minute)
+	c.DedupWindow = envDuration("DEDUP_WINDOW", 10*time.Minute)
+	c.DedupMaxMessages = envInt("DEDUP_MAX_MESSAGES", 20000)
+	c.CacheSweepInterval = envDuration("CACHE_SWEEP_INTERVAL", time.Minute)
+
+	c.AgentTimeout = envDuration("AGENT_TIMEOUT", 60*time.Second)
+	c.LoadHintsEnabled = envBool("AGENT_LOAD_HINTS_ENABLED", true)
+	c.LoadSlowDownMax = envDuration("LOAD_SLOWDOWN_MAX", 10*time.Minute)
+	c.LoadShedDepth = envInt("LOAD_SHED_DEPTH", 20)
+	c.LoadShedReaction = envString("LOAD_SHED_REACTION", "⏳")
+	c.AgentHealthInterval = envDuration("AGENT_HEALTH_INTERVAL", 30*time.Second)
+	c.AgentAPIKey = envString("AGENT_API_KEY", "")
+	c.AgentAuthHeader = envString("AGENT_AUTH_HEADER", "Authorization")
+	c.AgentTLSCert = envString("AGENT_TLS_CERT", "")
+	c.AgentTLSKey = envString("AGENT_TLS_KEY", "")
+	c.AgentTLSCA = envString("AGENT_TLS_CA", "")
+	c.AgentMaxIdleConns = envInt("AGENT_MAX_IDLE_CONNS", 100)
+	if c.AgentMaxIdleConns < 1 {
+		log.Printf("Invalid AGENT_MAX_IDLE_CONNS=%d, using 100", c.AgentMaxIdleConns)
+		c.AgentMaxIdleConns = 100
+	}
+	c.AgentIdleConnTimeout = envDuration("AGENT_IDLE_CONN_TIMEOUT", 90*time.Second)
+	c.AgentHTTP2 = envBool("AGENT_HTTP2", true)
+	c.PersonaAgentURLs = make(map[string][]string)
+	for _, p := range personas {
+		if urls := envList(p.URLEnv); p.URLEnv != "" && len(urls) > 0 {
+			c.PersonaAgentURLs[p.ID] = urls
+		}
+	}
+
+	c.AgentActions = nil
+	for _, a := range strings.Split(envString("AGENT_ACTIONS", strings.Join(agentActionTypes, ",")), ",") {
+		c.AgentActions = append(c.AgentActions, strings.ToLower(strings.TrimSpace(a)))
+	}
+
+	c.FallbackResponsesFile = envString("FALLBACK_RESPONSES_FILE", "")
+
+	c.BotOwnerIDs = envList("BOT_OWNER_IDS")
+	c.AdminChannelID = envString("ADMIN_CHANNEL_ID", "")
+	c.ErrorChannelID = envString("ERROR_CHANNEL_ID", "")
+	c.AlertChannelID = envString("ALERT_CHANNEL_ID", "")
+	c.AlertInterval = envDuration("ALERT_INTERVAL", 15*time.Minute)
+	c.AlertWindow = envDuration("ALERT_WINDOW", 5*time.Minute)
+	if c.AlertWindow <= 0 {
+		log.Printf("Invalid ALERT_WINDOW=%s, using 5m", c.AlertWindow)
+		c.AlertWindow = 5 * time.Minute
+	}
+	c.AlertErrorRate = envInt("ALERT_ERROR_RATE", 25)
+	if c.AlertErrorRate > 100 {
+		log.Printf("Invalid ALERT_ERROR_RATE=%d, using 25", c.AlertErrorRate)
+		c.AlertErrorRate = 25
+	}
+	c.AlertDisconnectAfter = envDuration("ALERT_DISCONNECT_AFTER", time.Minute)
+	c.BotPolicy = strings.ToLower(envString("BOT_POLICY", botPolicyWebhooks))
+	if !validBotPolicy(c.BotPolicy) {
+		log.Printf("Invalid BOT_POLICY=%q, using %s", c.BotPolicy, botPolicyWebhooks)
+		c.BotPolicy = botPolicyWebhooks
+	}
+	c.AllowedBotIDs = envList("ALLOWED_BOT_IDS")
+	c.BotLoopMaxReplies = envInt("BOT_LOOP_MAX_REPLIES", 5)
+	c.BotLoopWindow = envDuration("BOT_LOOP_WINDOW", time.Minute)
+	c.BotLoopCooldown = envDuration("BOT_LOOP_COOLDOWN", 10*time.Minute)
+	c.ShutdownNoticeWindow = envDuration("SHUTDOWN_NOTICE_WINDOW", 15*time.Minute)
+	c.ShutdownNoticeMax = envInt("SHUTDOWN_NOTICE_MAX", 25)
+
+	c.ReplyChainChunks = envBool("REPLY_CHAIN_CHUNKS", true)
+
+	c.DMFallbackEnabled = envBool("DM_FALLBACK_ENABLED", true)
+	c.DMFallbackQuota = envQuota("DM_FALLBACK_QUOTA", "3/1h")
+	c.DMTopicMax = envInt("DM_TOPIC_MAX", 10)
+
+	c.PostProcessors = envList("POST_PROCESSORS")
+	c.ResponseMaxLength = envInt("RESPONSE_MAX_LENGTH", 0)
+	c.ResponseMaxDelay = envDuration("RESPONSE_MAX_DELAY", 30*time.Second)
+	c.ThinkingPlaceholderAfter = envDuration("THINKING_PLACEHOLDER_AFTER", 8*time.Second)
+
+	c.ImageMaxBytes = envInt("IMAGE_MAX_BYTES", 8<<20)
+	c.ImageMaxCount = envInt("IMAGE_MAX_COUNT", 4)
+	c.ImageFetchTimeout = envDuration("IMAGE_FETCH_TIMEOUT", 10*time.Second)
+
+	c.TriviaPackFile = envString("TRIVIA_PACK_FILE", "")
+	c.TriviaAnswerWindow = envDuration("TRIVIA_ANSWER_WINDOW", 30*time.Second)
+	c.TriviaDefaultRounds = envInt("TRIVIA_DEFAULT_ROUNDS", 5)
+
+	c.StarboardDefaultThreshold = envInt("STARBOARD_DEFAULT_THRESHOLD", 3)
+
+	c.KarmaCooldown = envDuration("KARMA_COOLDOWN", time.Hour)
+	c.KarmaMaxTip = envInt("KARMA_MAX_TIP", 5)
+	c.KarmaBigTipper = envInt("KARMA_BIG_TIPPER", 25)
+
+	c.ThreadArchiveMode = strings.ToLower(envString("THREAD_ARCHIVE_MODE", threadArchiveMarker))
+	switch c.ThreadArchiveMode {
+	case threadArchiveBump, threadArchiveMarker, threadArchiveOff:
+	default:
+		log.Printf("Unknown THREAD_ARCHIVE_MODE %q, using %q", c.ThreadArchiveMode, threadArchiveMarker)
+		c.ThreadArchiveMode = threadArchiveMarker
+	}
+	c.ThreadArchiveWarning = envDuration("THREAD_ARCHIVE_WARNING", time.Hour)
+
+	c.ReminderMaxDelay = envDuration("REMINDER_MAX_DELAY", 30*24*time.Hour)
+	c.ReminderMaxPerUser = envInt("REMINDER_MAX_PER_USER", 10)
+	c.PollDefaultDuration = envDuration("POLL_DEFAULT_DURATION", 24*time.Hour)
+	c.PollMaxDuration = envDuration("POLL_MAX_DURATION", 7*24*time.Hour)
+
+	c.SummarizeDefaultMessages = envInt("SUMMARIZE_DEFAULT_MESSAGES", 100)
+	c.SummarizeMaxMessages = envInt("SUMMARIZE_MAX_MESSAGES", 500)
+	c.SummarizeCooldown = envDuration("SUMMARIZE_COOLDOWN", 2*time.Minute)
+
+	c.BackfillMaxMessages = envInt("THREAD_BACKFILL_MAX_MESSAGES", 50)
+	c.SpeakerContextMax = envInt("SPEAKER_CONTEXT_MAX", 20)
+
+	c.InstanceLockEnabled = envBool("INSTANCE_LOCK_ENABLED", true)
+	c.InstanceHeartbeatInterval = envDuration("INSTANCE_HEARTBEAT_INTERVAL", 15*time.Second)
+	c.InstanceLockStale = envDuration("INSTANCE_LOCK_STALE", time.Minute)
+
+	c.SafeModeThreshold = envInt("SAFE_MODE_THRESHOLD", 3)
+	c.SafeModeWindow = envDuration("SAFE_MODE_WINDOW", 15*time.Minute)
+	c.SafeModeStableAfter = envDuration("SAFE_MODE_STABLE_AFTER", 10*time.Minute)
+
+	c.StartupChecksEnabled = envBool("STARTUP_CHECKS_ENABLED", true)
+	c.StartupRequireAgent = envBool("STARTUP_REQUIRE_AGENT", false)
+	c.SelfTestEnabled = envBool("SELFTEST_ENABLED", true)
+	c.SelfTestChannels = envList("SELFTEST_CHANNELS")
+	c.SelfTestSkip = envList("SELFTEST_SKIP")
+	c.SelfTestRetryInterval = envDuration("SELFTEST_RETRY_INTERVAL", 30*time.Second)
+
+	c.FilterWordlistFile = envString("FILTER_WORDLIST_FILE", "")
+	c.FilterRegexFile = envString("FILTER_REGEX_FILE", "")
+	c.FilterDefaultLevel = strings.ToLower(envString("FILTER_DEFAULT_LEVEL", "low"))
+	c.FilterDefaultAction = strings.ToLower(envString("FILTER_DEFAULT_ACTION", "redact"))
+
+	c.SlashCommandGuildID = envString("SLASH_COMMAND_GUILD_ID", "")
+	c.DrinkCatalogFile = envString("DRINK_CATALOG_FILE", "")
+	c.FoodCatalogFile = envString("FOOD_CATALOG_FILE", "")
+	c.PresenceStatuses = parsePresenceStatuses(os.Getenv("PRESENCE_STATUSES"))
+	c.PresenceInterval = envDuration("PRESENCE_INTERVAL", 10*time.Minute)
+	if c.PresenceInterval < time.Minute {
+		log.Printf("PRESENCE_INTERVAL=%s is too short for Discord's presence limits, using 1m", c.PresenceInterval)
+		c.PresenceInterval = time.Minute
+	}
+	c.RPSessionReminder = envDuration("RP_SESSION_REMINDER", 30*time.Minute)
+	c.RPSessionDuration = envDuration("RP_SESSION_DURATION", 3*time.Hour)
+	if c.RPSessionDuration <= 0 {
+		log.Printf("Invalid RP_SESSION_DURATION=%s, using 3h", c.RPSessionDuration)
+		c.RPSessionDuration = 3 * time.Hour
+	}
+	c.DrinkOfTheDayEnabled = envBool("DRINK_OF_THE_DAY_ENABLED", true)
+	c.DrinkOfTheDayHour = envInt("DRINK_OF_THE_DAY_HOUR", 9)
+	if c.DrinkOfTheDayHour < 0 || c.DrinkOfTheDayHour > 23 {
+		log.Printf("Invalid DRINK_OF_THE_DAY_HOUR=%d, using 9", c.DrinkOfTheDayHour)
+		c.DrinkOfTheDayHour = 9
+	}
+	c.DrinkOfTheDaySource = strings.ToLower(envString("DRINK_OF_THE_DAY_SOURCE", drinkOfTheDayAgent))
+	if c.DrinkOfTheDaySource != drinkOfTheDayAgent && c.DrinkOfTheDaySource != drinkOfTheDayCatalog {
+		log.Printf("Invalid DRINK_OF_THE_DAY_SOURCE=%q, using %s", c.DrinkOfTheDaySource, drinkOfTheDayAgent)
+		c.DrinkOfTheDaySource = drinkOfTheDayAgent
+	}
+	c.ThemePacksFile = envString("THEME_PACKS_FILE", "")
+
+	c.VoiceListenEnabled = envBool("VOICE_LISTEN_ENABLED", false)
+	c.VoiceWakeWord = strings.ToLower(envString("VOICE_WAKE_WORD", "elsie"))
+	if c.VoiceWakeWord == "off" || c.VoiceWakeWord == "none" {
+		c.VoiceWakeWord = ""
+	}
+	c.VoiceSilenceGap = envDuration("VOICE_SILENCE_GAP", time.Second)
+	c.VoiceMinUtterance = envDuration("VOICE_MIN_UTTERANCE", 500*time.Millisecond)
+	c.VoiceMaxUtterance = envDuration("VOICE_MAX_UTTERANCE", 30*time.Second)
+	c.VoiceReplyTTS = envBool("VOICE_REPLY_TTS", false)
+	c.STTProvider = strings.ToLower(envString("STT_PROVIDER", sttAgent))
+	if _, ok := sttProviders[c.STTProvider]; !ok {
+		log.Printf("Invalid STT_PROVIDER=%q, using %s", c.STTProvider, sttAgent)
+		c.STTProvider = sttAgent
+	}
+	c.STTURL = envString("STT_URL", defaultSTTURL)
+	c.STTAPIKey = envString("STT_API_KEY", "")
+	c.STTModel = envString("STT_MODEL", "whisper-1")
+
+	c.SentryDSN = envString("SENTRY_DSN", "")
+	c.SentryEnvironment = envString("SENTRY_ENVIRONMENT", "")
+	c.SentryRelease = envString("SENTRY_RELEASE", "")
+
+	c.TelemetryEnabled = envBool("TELEMETRY_ENABLED", true)
+	c.ExchangeLogRetention = envDuration("EXCHANGE_LOG_RETENTION", 90*24*time.Hour)
+	c.ExchangeLogMaxBytes = envInt("EXCHANGE_LOG_MAX_BYTES", 64<<20)
+
+	c.DefaultLanguage = strings.ToLower(envString("DEFAULT_LANGUAGE", defaultLanguage))
+	c.LocalesDir = envString("LOCALES_DIR", "")
+
+	c.PrivacyLogging = strings.ToLower(envString("PRIVACY_LOGGING", privacyOff))
+	if c.PrivacyLogging != privacyOff && c.PrivacyLogging != privacyTruncate && c.PrivacyLogging != privacyHash {
+		log.Printf("Invalid PRIVACY_LOGGING=%q, using %s", c.PrivacyLogging, privacyHash)
+		c.PrivacyLogging = privacyHash
+	}
+	c.PrivacyLogSalt = envString("PRIVACY_LOG_SALT", "")
+	c.ContentDisabledGuilds = envList("CONTENT_DISABLED_GUILDS")
+
+	c.UserQuota = envQuota("QUOTA_USER", "off")
+	c.ChannelQuota = envQuota("QUOTA_CHANNEL", "off")
+	c.GuildQuota = envQuota("QUOTA_GUILD", "off")
+	c.SlashCommandCooldown = envDuration("SLASH_COMMAND_COOLDOWN", 0)
+
+	c.DecisionLogSize = envInt("DECISION_LOG_SIZE", 2000)
+
+	c.BurstWindow = envDuration("BURST_WINDOW", 3*time.Second)
+	c.BurstMaxMessages = envInt("BURST_MAX_MESSAGES", 4)
+
+	c.FeedbackReactionsEnabled = envBool("FEEDBACK_REACTIONS_ENABLED", true)
+
+	c.FollowUpsEnabled = envBool("FOLLOW_UPS_ENABLED", true)
+	c.FollowUpMaxDelay = envDuration("FOLLOW_UP_MAX_DELAY", 24*time.Hour)
+
+	c.CompactionThreshold = envInt("COMPACTION_THRESHOLD", 500)
+	c.CompactionHistoryLimit = envInt("COMPACTION_HISTORY_LIMIT", 50)
+
+	c.AutoPinRecaps = envBool("AUTO_PIN_RECAPS", true)
+	c.StardateYearOffset = envInt("STARDATE_YEAR_OFFSET", 375)
+
+	c.WeeklyDigestEnabled = envBool("WEEKLY_DIGEST_ENABLED", false)
+	c.WeeklyAgentBudget = envInt("WEEKLY_AGENT_BUDGET", 0)
+
+	c.ChaosEnabled = envBool("CHAOS_ENABLED", false)
+
+	c.HTTPAddr = envString("HTTP_ADDR", "")
+	c.HTTPTLSCert = envString("HTTP_TLS_CERT", "")
+	c.HTTPTLSKey = envString("HTTP_TLS_KEY", "")
+	c.HTTPClientCA = envString("HTTP_CLIENT_CA", "")
+	c.OIDCIssuer = envString("OIDC_ISSUER", "")
+	c.OIDCAudience = envString("OIDC_AUDIENCE", "")
+
+	c.DashboardAddr = envString("DASHBOARD_ADDR", "")
+	c.DashboardURL = strings.TrimSuffix(envString("DASHBOARD_URL", ""), "/")
+	c.DashboardClientID = envString("DASHBOARD_CLIENT_ID", "")
+	c.DashboardClientSecret = envString("DASHBOARD_CLIENT_SECRET", "")
+	c.DashboardSessionSecret = envString("DASHBOARD_SESSION_SECRET", "")
+	c.DashboardSessionTTL = envDuration("DASHBOARD_SESSION_TTL", 12*time.Hour)
+	c.DashboardUsers = envList("DASHBOARD_USERS")
+
+	c.AskAboutReply = strings.ToLower(envString("ASK_ABOUT_REPLY", askAboutEphemeral))
+	if c.AskAboutReply != askAboutEphemeral && c.AskAboutReply != askAboutChannel {
+		log.Printf("Invalid ASK_ABOUT_REPLY=%q, using %s", c.AskAboutReply, askAboutEphemeral)
+		c.AskAboutReply = askAboutEphemeral
+	}
+	return c
+}
+
+func envString(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(name string, def int) int {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+func envBool(name string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %v", name, v, def)
+		return def
+	}
+	return b
+}
+
+// envList splits a comma-separated variable into trimmed, non-empty values.
+func envList(name string) []string {
+	var out []string
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	guildConfigHistoryBucket = "guild_config_history"
+	maxConfigVersions        = 50
+)
+
+// configChange is one setting that changed between two versions, as JSON.
+type configChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// configVersion is a guild config as it stood after one change.
+type configVersion struct {
+	Version int            `json:"version"`
+	Time    time.Time      `json:"time"`
+	ActorID string         `json:"actor_id"`
+	Changes []configChange `json:"changes"`
+	Config  GuildConfig    `json:"config"`
+	// RollbackOf is set when this version restored an earlier one; 0 means
+	// the defaults.
+	RollbackOf *int `json:"rollback_of,omitempty"`
+}
+
+func loadConfigHistory(guildID string) []configVersion {
+	var history []configVersion
+	if _, err := store.Get(guildConfigHistoryBucket, guildID, &history); err != nil {
+		log.Printf("Error loading config history for guild %s: %v", guildID, err)
+	}
+	return history
+}
+
+// diffGuildConfig lists the top-level settings that differ between two
+// configs, compared by their JSON encoding.
+func diffGuildConfig(before, after *GuildConfig) []configChange {
+	fields := func(cfg *GuildConfig) map[string]json.RawMessage {
+		out := map[string]json.RawMessage{}
+		if data, err := json.Marshal(cfg); err == nil {
+			json.Unmarshal(data, &out)
+		}
+		return out
+	}
+	old, cur := fields(before), fields(after)
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range cur {
+		names[name] = true
+	}
+
+	var changes []configChange
+	for _, name := range sortedKeys(names) {
+		if !bytes.Equal(old[name], cur[name]) {
+			changes = append(changes, configChange{Field: name, Old: string(old[name]), New: string(cur[name])})
+		}
+	}
+	return changes
+}
+
+// recordConfigVersion appends a version to the guild's history. Callers
+// hold guildConfigMu.
+func recordConfigVersion(guildID, actorID string, before, after *GuildConfig, rollbackOf *int) {
+	changes := diffGuildConfig(before, after)
+	if len(changes) == 0 && rollbackOf == nil {
+		return
+	}
+	history := loadConfigHistory(guildID)
+	next := 1
+	if len(history) > 0 {
+		next = history[len(history)-1].Version + 1
+	}
+	history = append(history, configVersion{
+		Version:    next,
+		Time:       time.Now(),
+		ActorID:    actorID,
+		Changes:    changes,
+		Config:     *after,
+		RollbackOf: rollbackOf,
+	})
+	if len(history) > maxConfigVersions {
+		history = history[len(history)-maxConfigVersions:]
+	}
+	if err := store.Put(guildConfigHistoryBucket, guildID, history); err != nil {
+		log.Printf("Error saving config history for guild %s: %v", guildID, err)
+	}
+}
+
+// rollbackGuildConfig restores the config as it stood after version, or
+// the defaults for version 0, and records the rollback as a new version.
+func rollbackGuildConfig(guildID, actorID string, version int) error {
+	guildConfigMu.Lock()
+	defer guildConfigMu.Unlock()
+
+	target := GuildConfig{}
+	if version > 0 {
+		found := false
+		for _, v := range loadConfigHistory(guildID) {
+			if v.Version == version {
+				target, found = v.Config, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("version %d is not in the history", version)
+		}
+	}
+	before := loadGuildConfig(guildID)
+	if err := store.Put(guildConfigBucket, guildID, &target); err != nil {
+		return err
+	}
+	guildConfigChanged(guildID)
+	recordConfigVersion(guildID, actorID, before, &target, &version)
+	log.Printf("⚙️  Guild %s config rolled back to version %d by %s", guildID, version, actorID)
+	return nil
+}
+
+func init() {
+	registerCommand(command{name: "config", handler: configCommand})
+}
+
+// configCommand is `!elsie config history [count]` and
+// `!elsie config rollback <version>`.
+func configCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Configuration is per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can view or roll back my configuration.")
+		return
+	}
+	usage := "Usage: `!elsie config history [count]`, `!elsie config rollback <version>`"
+	if len(ctx.args) == 0 {
+		ctx.reply(usage)
+		return
+	}
+
+	switch strings.ToLower(ctx.args[0]) {
+	case "history":
+		limit := 10
+		if len(ctx.args) > 1 {
+			if n, err := strconv.Atoi(ctx.args[1]); err == nil && n > 0 {
+				limit = n
+			}
+		}
+		history := loadConfigHistory(ctx.m.GuildID)
+		if len(history) == 0 {
+			ctx.reply("⚙️ No configuration changes recorded for this server yet.")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("⚙️ **Configuration history** (newest first)\n")
+		for i := len(history) - 1; i >= 0 && i >= len(history)-limit; i-- {
+			b.WriteString(formatConfigVersion(history[i]))
+		}
+		b.WriteString("Roll back with `!elsie config rollback <version>` (`0` restores the defaults).")
+		if _, err := sendChunksQuietly(ctx.s, ctx.m.ChannelID, b.String()); err != nil {
+			log.Printf("Error sending config history: %v", err)
+		}
+	case "rollback":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(ctx.args[1], "v"))
+		if err != nil || version < 0 {
+			ctx.reply(usage)
+			return
+		}
+		if err := rollbackGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, version); err != nil {
+			log.Printf("Error rolling back config: %v", err)
+			ctx.reply(fmt.Sprintf("*holographic matrix flickers* I couldn't roll back: %v.", err))
+			return
+		}
+		if version == 0 {
+			ctx.reply("⚙️ Configuration restored to the defaults.")
+			return
+		}
+		ctx.reply(fmt.Sprintf("⚙️ Configuration rolled back to version %d.", version))
+	default:
+		ctx.reply(usage)
+	}
+}
+
+func formatConfigVersion(v configVersion) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**v%d** • %s • by <@%s>", v.Version, v.Time.UTC().Format("Jan 2 15:04 MST"), v.ActorID)
+	switch {
+	case v.RollbackOf == nil:
+	case *v.RollbackOf == 0:
+		b.WriteString(" • reset to defaults")
+	default:
+		fmt.Fprintf(&b, " • rollback to v%d", *v.RollbackOf)
+	}
+	b.WriteString("\n")
+	for _, c := range v.Changes {
+		fmt.Fprintf(&b, "  `%s`: %s → %s\n", c.Field, orUnset(c.Old), orUnset(c.New))
+	}
+	return b.String()
+}
+
+func orUnset(value string) string {
+	if value == "" {
+		return "_unset_"
+	}
+	return "`" + truncateText(value, 80) + "`"
+}
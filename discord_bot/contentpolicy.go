@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// contentProcessingDisabled reports whether a guild has opted out of message
+// content processing, either by its admins or by the operator through
+// CONTENT_DISABLED_GUILDS. In those guilds Elsie ignores message events
+// entirely and only answers slash commands.
+func contentProcessingDisabled(guildID string) bool {
+	if guildID == "" {
+		return false
+	}
+	for _, id := range config().ContentDisabledGuilds {
+		if id == guildID {
+			return true
+		}
+	}
+	return loadGuildConfig(guildID).ContentProcessingOff
+}
+
+func init() {
+	registerCommand(command{name: "content-processing", handler: contentProcessingCommand})
+
+	manageServer := int64(discordgo.PermissionManageServer)
+	dmPermission := false
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:                     "content-processing",
+		Description:              "Turn message content processing on or off for this server",
+		DefaultMemberPermissions: &manageServer,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "enabled",
+			Description: "Whether Elsie may read and forward messages in this server",
+			Required:    true,
+		}},
+	}, contentProcessingSlash)
+}
+
+// contentProcessingStatus describes the guild's current setting.
+func contentProcessingStatus(guildID string) string {
+	for _, id := range config().ContentDisabledGuilds {
+		if id == guildID {
+			return "🔒 Message content processing is **off** for this server (set by the bot operator)."
+		}
+	}
+	if loadGuildConfig(guildID).ContentProcessingOff {
+		return "🔒 Message content processing is **off**. I only answer slash commands here."
+	}
+	return "🔓 Message content processing is **on**."
+}
+
+// setContentProcessing stores the guild's choice and returns the reply.
+func setContentProcessing(guildID, actorID string, enabled bool) string {
+	err := updateGuildConfig(guildID, actorID, func(cfg *GuildConfig) { cfg.ContentProcessingOff = !enabled })
+	if err != nil {
+		log.Printf("Error saving content processing setting: %v", err)
+		return themePhrase(guildID, "save_failed", nil)
+	}
+	log.Printf("🔒 Content processing for guild %s set to %t by %s", guildID, enabled, actorID)
+	if !enabled {
+		stopDisabledVoiceListeners()
+		return "🔒 Message content processing is now **off**. I won't read or forward messages in this server, and I'll only answer slash commands. Use `/content-processing enabled:True` to turn it back on."
+	}
+	msg := "🔓 Message content processing is now **on**."
+	for _, id := range config().ContentDisabledGuilds {
+		if id == guildID {
+			msg += " The bot operator still has it disabled for this server, so nothing changes until they lift that."
+		}
+	}
+	return msg
+}
+
+// contentProcessingCommand is `!elsie content-processing [on|off]`. Bot
+// owners can pass a guild ID to change another server, e.g. from a DM.
+// Once processing is off this command can't be read in that guild, so it is
+// turned back on with /content-processing.
+func contentProcessingCommand(ctx *commandContext) {
+	guildID := ctx.m.GuildID
+	args := ctx.args
+	if len(args) > 0 && isBotOwner(ctx.m.Author.ID) && isSnowflake(args[0]) {
+		guildID, args = args[0], args[1:]
+	}
+	if guildID == "" {
+		ctx.reply("Content processing is per server — use this command in a server channel, or pass a guild ID if you're a bot owner.")
+		return
+	}
+	if guildID == ctx.m.GuildID && !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change content processing.")
+		return
+	}
+	if len(args) == 0 {
+		ctx.reply(contentProcessingStatus(guildID) + "\nUsage: `!elsie content-processing on|off`")
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		ctx.reply(setContentProcessing(guildID, ctx.m.Author.ID, true))
+	case "off":
+		ctx.reply(setContentProcessing(guildID, ctx.m.Author.ID, false))
+	default:
+		ctx.reply("Usage: `!elsie content-processing on|off`")
+	}
+}
+
+// contentProcessingSlash serves /content-processing. Discord hides it from
+// members without Manage Server; the check is repeated here in case a
+// server overrides that.
+func contentProcessingSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	if i.GuildID == "" || i.Member == nil {
+		respondEphemeral(s, i, "Content processing is per server — use this command in a server.")
+		return
+	}
+	perms := i.Member.Permissions
+	if !isBotOwner(user.ID) && perms&discordgo.PermissionManageServer == 0 && perms&discordgo.PermissionAdministrator == 0 {
+		respondEphemeral(s, i, "*shakes head* Only server admins can change content processing.")
+		return
+	}
+	enabled := i.ApplicationCommandData().Options[0].BoolValue()
+	respondEphemeral(s, i, setContentProcessing(i.GuildID, user.ID, enabled))
+}
+
+// isSnowflake reports whether s looks like a Discord ID.
+func isSnowflake(s string) bool {
+	return len(s) >= 15 && len(s) <= 21 && strings.Trim(s, "0123456789") == ""
+}
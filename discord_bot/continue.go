@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// continueTailLength is how much of the end of a cut-off reply goes back to
+// the agent, so it knows where to pick up.
+const continueTailLength = 500
+
+// cutReply is a reply that ended before it was done, kept so "Continue"
+// can ask the agent for the next part in the same session.
+type cutReply struct {
+	guildID   string
+	channelID string
+	persona   *persona
+	tail      string
+}
+
+// cutReplies are the cut-off replies by button ID; the button stops
+// working after an hour.
+var cutReplies = newLRUCache[string, cutReply]("cut_replies", 1000, time.Hour)
+
+func init() {
+	trackCache(cutReplies)
+	registerComponentHandler("continue", continueComponent)
+}
+
+// replyTrimmed reports whether the trim stage cuts text in the guild at
+// RESPONSE_MAX_LENGTH.
+func replyTrimmed(guildID, text string) bool {
+	return config().ResponseMaxLength > 0 && len([]rune(text)) > config().ResponseMaxLength &&
+		postProcessEnabled(loadGuildConfig(guildID), "trim")
+}
+
+// offerNextPart ends a posted reply part: what the channel's budget held
+// back waits behind More, and a reply that was cut off, by the agent or by
+// RESPONSE_MAX_LENGTH, gets a Continue button once nothing is held back.
+func offerNextPart(s *discordgo.Session, guildID, channelID string, p *persona, sent []*discordgo.Message, shown, rest string, truncated bool) {
+	switch {
+	case rest != "":
+		holdRest(s, guildID, channelID, p, sent, rest, truncated)
+	case truncated || replyTrimmed(guildID, shown):
+		offerContinue(s, guildID, channelID, p, sent, trimResponse(s, guildID, shown))
+	}
+}
+
+func offerContinue(s *discordgo.Session, guildID, channelID string, p *persona, sent []*discordgo.Message, shown string) {
+	if len(sent) == 0 {
+		return
+	}
+	tail := []rune(shown)
+	if len(tail) > continueTailLength {
+		tail = tail[len(tail)-continueTailLength:]
+	}
+	id := newRequestID()
+	cutReplies.Add(id, cutReply{guildID: guildID, channelID: channelID, persona: p, tail: string(tail)})
+	metrics.Inc("responses_cut_total")
+	attachButton(s, guildID, channelID, sent, tr(guildID, "(cut short — Continue asks for the rest)"), discordgo.Button{
+		Label:    tr(guildID, "Continue"),
+		Style:    discordgo.SecondaryButton,
+		CustomID: "continue:" + id,
+		Emoji:    discordgo.ComponentEmoji{Name: "⏩"},
+	})
+}
+
+// continueComponent asks the agent to go on from where a cut-off reply
+// stopped, in the same session, and posts the next part under it.
+func continueComponent(s *discordgo.Session, i *discordgo.InteractionCreate, payload string) {
+	cut, ok := cutReplies.Get(payload)
+	if !ok {
+		respondEphemeral(s, i, tr(i.GuildID, "⏩ That reply can't be continued anymore — ask me again."))
+		return
+	}
+	if contentProcessingDisabled(cut.guildID) {
+		respondEphemeral(s, i, tr(i.GuildID, "*shakes head* This server has asked me not to read messages."))
+		return
+	}
+	// Each click is another agent request, so it counts like a message
+	user := interactionUser(i)
+	if ok, scope, wait := consumeQuota(cut.guildID, cut.channelID, user.ID); !ok {
+		respondEphemeral(s, i, quotaMessage(i.GuildID, scope, wait))
+		return
+	}
+	cutReplies.Remove(payload)
+	removeButtons(s, i)
+
+	rlog := requestLog{id: newRequestID()}
+	rlog.Printf("⏩ %s asked to continue a reply in %s", logUser(user.Username, user.ID), cut.channelID)
+	noteChannelActivity(cut.guildID, cut.channelID)
+	s.ChannelTyping(cut.channelID)
+	content := "continue"
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        i.Message.ID,
+		ChannelID: cut.channelID,
+		GuildID:   cut.guildID,
+		Author:    user,
+		Content:   content,
+	}}
+	extra := map[string]interface{}{
+		"intent":        "continue",
+		"previous_tail": cut.tail,
+	}
+	budget := channelLengthBudget(s, cut.guildID, cut.channelID)
+	if budget > 0 {
+		extra["length_budget"] = budget
+	}
+	exchange := exchangeRecord{
+		Time:           time.Now(),
+		RequestID:      rlog.id,
+		GuildID:        cut.guildID,
+		ChannelID:      cut.channelID,
+		MessageID:      i.Message.ID,
+		AuthorID:       user.ID,
+		Persona:        cut.persona.ID,
+		AgentSessionID: cut.persona.sessionID(cut.channelID),
+		Outcome:        exchangeNoResponse,
+	}
+	defer func() { exchanges.record(exchange) }()
+
+	guildStats.recordMessage(cut.guildID, cut.channelID)
+	resp := processWithAIEnhanced(content, s, m, cut.persona, extra, rlog)
+	response := ""
+	if resp != nil {
+		response = resp.Response
+	}
+	guildStats.recordAgentCall(cut.guildID, resp == nil, len(response))
+	if resp == nil || resp.silent() || strings.TrimSpace(response) == "" {
+		metrics.Inc(metricLabel("responses_continued_total", "source", "empty"))
+		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: tr(cut.guildID, "⏩ I couldn't pick that back up — ask me again."),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
+		return
+	}
+	recordForwarded(cut.persona, cut.persona.sessionID(cut.channelID))
+	if response, ok = screenContent(s, cut.guildID, cut.channelID, user.ID, "outbound", response); !ok {
+		response = themePhrase(cut.guildID, "outbound_blocked", nil)
+	}
+
+	response, rest := splitAtBudget(response, budget)
+	sent, err := sendAs(s, cut.channelID, cut.persona, response)
+	exchange.ResponseMessageIDs = messageIDs(sent)
+	if err != nil {
+		rlog.Printf("Error sending the continued reply: %v", err)
+		exchange.Outcome = exchangeSendError
+		return
+	}
+	exchange.Outcome = exchangeSent
+	metrics.Inc(metricLabel("responses_continued_total", "source", "agent"))
+	offerNextPart(s, cut.guildID, cut.channelID, cut.persona, sent, response, rest, resp.Truncated)
+}
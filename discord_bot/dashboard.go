@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	dashboardSessionCookie = "elsie_dashboard"
+	dashboardStateCookie   = "elsie_dashboard_state"
+)
+
+// dashboardSecret signs dashboard sessions. Without DASHBOARD_SESSION_SECRET
+// it is random, so a restart signs everyone out.
+var dashboardSecret []byte
+
+func init() {
+	authenticators["discord"] = discordSessionAuth{}
+}
+
+// startDashboard serves the admin dashboard on DASHBOARD_ADDR. It is
+// protected by Discord login unless HTTP_AUTH_DASHBOARD picks other
+// authenticators, and does nothing when DASHBOARD_ADDR is unset.
+func startDashboard() {
+	c := config()
+	if c.DashboardAddr == "" {
+		return
+	}
+	policy := []string{"discord"}
+	if os.Getenv("HTTP_AUTH_DASHBOARD") != "" {
+		policy = endpointPolicy("dashboard")
+	}
+	if slices.Contains(policy, "discord") && (c.DashboardURL == "" || c.DashboardClientSecret == "") {
+		log.Printf("⚠️  Dashboard disabled: Discord login needs DASHBOARD_URL and DASHBOARD_CLIENT_SECRET")
+		return
+	}
+	dashboardSecret = []byte(c.DashboardSessionSecret)
+	if len(dashboardSecret) == 0 {
+		dashboardSecret = []byte(randomToken())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", dashboardLogin)
+	mux.HandleFunc("GET /callback", dashboardCallback)
+	mux.HandleFunc("GET /logout", dashboardLogout)
+	protect := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, dashboardAuth(policy, handler))
+	}
+	protect("GET /{$}", dashboardHome)
+	protect("GET /metrics", dashboardMetrics)
+	protect("GET /guild", dashboardGuild)
+	protect("POST /guild", dashboardSaveGuild)
+	protect("GET /sessions", dashboardSessions)
+	protect("POST /send", dashboardSend)
+
+	srv := &http.Server{
+		Addr:              c.DashboardAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	tlsEnabled := c.HTTPTLSCert != "" && c.HTTPTLSKey != ""
+	if tlsEnabled {
+		tlsConfig, err := serverTLSConfig()
+		if err != nil {
+			log.Fatal("Error configuring dashboard TLS: ", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	go func() {
+		var err error
+		log.Printf("📊 Admin dashboard listening on %s, protected by: %s", c.DashboardAddr, policy)
+		if tlsEnabled {
+			err = srv.ListenAndServeTLS(c.HTTPTLSCert, c.HTTPTLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Dashboard server error: %v", err)
+		}
+	}()
+}
+
+// dashboardAuth sends browsers without credentials to the Discord login,
+// and checks the form token on changes made with a session cookie.
+func dashboardAuth(policy []string, handler http.Handler) http.Handler {
+	protected := requireAuth("dashboard", policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if c, err := r.Cookie(dashboardSessionCookie); err == nil && !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(dashboardCSRF(c.Value))) {
+				http.Error(w, "invalid form token; reload the page", http.StatusForbidden)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(policy, "discord") && r.Method == http.MethodGet && !hasDashboardSession(r) &&
+			r.Header.Get("Authorization") == "" && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+func hasDashboardSession(r *http.Request) bool {
+	c, err := r.Cookie(dashboardSessionCookie)
+	if err != nil {
+		return false
+	}
+	_, err = verifyDashboardSession(c.Value)
+	return err == nil
+}
+
+// discordSessionAuth accepts the session cookie set by the dashboard's
+// Discord login, for bot owners and the users in DASHBOARD_USERS.
+type discordSessionAuth struct{}
+
+func (discordSessionAuth) authenticate(r *http.Request, endpoint string) (string, error) {
+	c, err := r.Cookie(dashboardSessionCookie)
+	if err != nil {
+		return "", errNoCredentials
+	}
+	userID, err := verifyDashboardSession(c.Value)
+	if err != nil {
+		return "", err
+	}
+	if !dashboardAllowed(userID) {
+		return "", fmt.Errorf("user %s not allowed", userID)
+	}
+	return "discord:" + userID, nil
+}
+
+func dashboardAllowed(userID string) bool {
+	return isBotOwner(userID) || slices.Contains(config().DashboardUsers, userID)
+}
+
+// signDashboardSession makes a cookie value naming userID until expires.
+func signDashboardSession(userID string, expires time.Time) string {
+	payload := userID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + dashboardMAC(payload)
+}
+
+func verifyDashboardSession(value string) (string, error) {
+	if len(dashboardSecret) == 0 {
+		return "", errors.New("dashboard sessions not configured")
+	}
+	encoded, mac, ok := strings.Cut(value, ".")
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if !ok || err != nil || !hmac.Equal([]byte(mac), []byte(dashboardMAC(string(data)))) {
+		return "", errors.New("invalid session")
+	}
+	userID, expiry, _ := strings.Cut(string(data), "|")
+	if unix, err := strconv.ParseInt(expiry, 10, 64); err != nil || time.Now().Unix() >= unix {
+		return "", errors.New("session expired")
+	}
+	return userID, nil
+}
+
+func dashboardMAC(payload string) string {
+	mac := hmac.New(sha256.New, dashboardSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// dashboardCSRF is the form token for a session, tied to its cookie so it
+// can't be replayed from another session.
+func dashboardCSRF(session string) string {
+	return dashboardMAC("csrf|" + session)
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func dashboardCookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config().DashboardURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// dashboardClientID is the OAuth application, by default the bot's own.
+func dashboardClientID() string {
+	if config().DashboardClientID != "" || botSession == nil || botSession.State.User == nil {
+		return config().DashboardClientID
+	}
+	return botSession.State.User.ID
+}
+
+func dashboardLogin(w http.ResponseWriter, r *http.Request) {
+	state := randomToken()
+	http.SetCookie(w, dashboardCookie(dashboardStateCookie, state, time.Now().Add(10*time.Minute)))
+	q := url.Values{
+		"client_id":     {dashboardClientID()},
+		"redirect_uri":  {config().DashboardURL + "/callback"},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, "https://discord.com/oauth2/authorize?"+q.Encode(), http.StatusFound)
+}
+
+func dashboardCallback(w http.ResponseWriter, r *http.Request) {
+	state, err := r.Cookie(dashboardStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.Value), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(w, "login expired; try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, dashboardCookie(dashboardStateCookie, "", time.Unix(0, 0)))
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "login was cancelled", http.StatusBadRequest)
+		return
+	}
+	userID, err := discordOAuthUser(code)
+	if err != nil {
+		log.Printf("Error completing dashboard login: %v", err)
+		http.Error(w, "Discord login failed", http.StatusBadGateway)
+		return
+	}
+	if !dashboardAllowed(userID) {
+		log.Printf("🔐 Denied dashboard login for %s", logUser("", userID))
+		metrics.Inc(metricLabel("http_auth_denied", "endpoint", "dashboard"))
+		http.Error(w, "your Discord account is not allowed to use this dashboard", http.StatusForbidden)
+		return
+	}
+	expires := time.Now().Add(config().DashboardSessionTTL)
+	http.SetCookie(w, dashboardCookie(dashboardSessionCookie, signDashboardSession(userID, expires), expires))
+	log.Printf("🔐 Dashboard login by %s", logUser("", userID))
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func dashboardLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, dashboardCookie(dashboardSessionCookie, "", time.Unix(0, 0)))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Signed out.")
+}
+
+// discordOAuthUser trades a login code for the ID of the Discord user who
+// signed in.
+func discordOAuthUser(code string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(discordgo.EndpointAPI+"oauth2/token", url.Values{
+		"client_id":     {dashboardClientID()},
+		"client_secret": {config().DashboardClientSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config().DashboardURL + "/callback"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange: %s", resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, discordgo.EndpointUser("@me"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	me, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer me.Body.Close()
+	if me.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching user: %s", me.Status)
+	}
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(me.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	if user.ID == "" {
+		return "", errors.New("no user ID in response")
+	}
+	return user.ID, nil
+}
+
+// dashboardActor is who config changes from the dashboard are attributed
+// to: the signed-in user, or the bot itself for token and certificate
+// access.
+func dashboardActor(r *http.Request) string {
+	if c, err := r.Cookie(dashboardSessionCookie); err == nil {
+		if userID, err := verifyDashboardSession(c.Value); err == nil {
+			return userID
+		}
+	}
+	return botSession.State.User.ID
+}
+
+func renderDashboard(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	if c, err := r.Cookie(dashboardSessionCookie); err == nil {
+		data["CSRF"] = dashboardCSRF(c.Value)
+		data["SignedIn"] = true
+	}
+	data["Notice"] = r.URL.Query().Get("notice")
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("Error rendering dashboard page %s: %v", name, err)
+		http.Error(w, "rendering failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+type dashboardGuildRow struct {
+	ID, Name string
+	Members  int
+}
+
+func dashboardHome(w http.ResponseWriter, r *http.Request) {
+	botSession.State.RLock()
+	guilds := make([]dashboardGuildRow, 0, len(botSession.State.Guilds))
+	for _, g := range botSession.State.Guilds {
+		guilds = append(guilds, dashboardGuildRow{ID: g.ID, Name: g.Name, Members: g.MemberCount})
+	}
+	botSession.State.RUnlock()
+	sort.Slice(guilds, func(i, j int) bool { return strings.ToLower(guilds[i].Name) < strings.ToLower(guilds[j].Name) })
+
+	type agentRow struct {
+		URL     string
+		Healthy bool
+	}
+	var agents []agentRow
+	for _, b := range allAgentBackends() {
+		agents = append(agents, agentRow{URL: b.url, Healthy: b.isHealthy()})
+	}
+	var metricsText bytes.Buffer
+	metrics.WriteText(&metricsText)
+	renderDashboard(w, r, "home", map[string]interface{}{
+		"Guilds":  guilds,
+		"Agents":  agents,
+		"Metrics": metricsText.String(),
+	})
+}
+
+func dashboardMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.WriteText(w)
+}
+
+func dashboardGuild(w http.ResponseWriter, r *http.Request) {
+	guildID := r.URL.Query().Get("id")
+	guild, err := botSession.State.Guild(guildID)
+	if err != nil {
+		http.Error(w, "unknown guild", http.StatusNotFound)
+		return
+	}
+	cfg, err := json.MarshalIndent(loadGuildConfig(guildID), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	history := loadConfigHistory(guildID)
+	if len(history) > 10 {
+		history = history[len(history)-10:]
+	}
+	slices.Reverse(history)
+	renderDashboard(w, r, "guild", map[string]interface{}{
+		"Guild":   dashboardGuildRow{ID: guild.ID, Name: guild.Name, Members: guild.MemberCount},
+		"Config":  string(cfg),
+		"History": history,
+	})
+}
+
+// dashboardSaveGuild replaces a guild's config with the edited JSON. It is
+// versioned like any other change, so `!elsie config rollback` undoes it.
+func dashboardSaveGuild(w http.ResponseWriter, r *http.Request) {
+	guildID := r.PostFormValue("id")
+	if _, err := botSession.State.Guild(guildID); err != nil {
+		http.Error(w, "unknown guild", http.StatusNotFound)
+		return
+	}
+	var edited GuildConfig
+	dec := json.NewDecoder(strings.NewReader(r.PostFormValue("config")))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&edited); err != nil {
+		http.Error(w, "invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := updateGuildConfig(guildID, dashboardActor(r), func(cfg *GuildConfig) { *cfg = edited })
+	if err != nil {
+		log.Printf("Error saving guild config from the dashboard: %v", err)
+		http.Error(w, "saving failed", http.StatusInternalServerError)
+		return
+	}
+	metrics.Inc("dashboard_config_saves_total")
+	http.Redirect(w, r, "/guild?"+url.Values{"id": {guildID}, "notice": {"Config saved."}}.Encode(), http.StatusSeeOther)
+}
+
+// dashboardSessions lists recent exchanges, optionally for one guild,
+// channel or agent session.
+func dashboardSessions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	guildID, channelID, sessionID := q.Get("guild_id"), q.Get("channel_id"), q.Get("session")
+	records, err := exchanges.recent(100, func(rec exchangeRecord) bool {
+		return (guildID == "" || rec.GuildID == guildID) &&
+			(channelID == "" || rec.ChannelID == channelID) &&
+			(sessionID == "" || rec.AgentSessionID == sessionID)
+	})
+	if err != nil {
+		log.Printf("Error reading exchange log for the dashboard: %v", err)
+		http.Error(w, "reading the exchange log failed", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Records":   records,
+		"GuildID":   guildID,
+		"ChannelID": channelID,
+		"Session":   sessionID,
+	}
+	if sessionID != "" {
+		var cp MemoryCheckpoint
+		if ok, _ := store.Get(memoryCheckpointBucket, sessionID, &cp); ok {
+			data["Checkpoint"] = cp
+		}
+	}
+	renderDashboard(w, r, "sessions", data)
+}
+
+// dashboardSend posts a message as the bot.
+func dashboardSend(w http.ResponseWriter, r *http.Request) {
+	channelID := strings.TrimSpace(r.PostFormValue("channel_id"))
+	content := strings.TrimSpace(r.PostFormValue("content"))
+	if channelID == "" || content == "" {
+		http.Error(w, "channel and message are required", http.StatusBadRequest)
+		return
+	}
+	if _, err := getChannel(botSession, channelID); err != nil {
+		http.Error(w, "unknown channel", http.StatusNotFound)
+		return
+	}
+	if _, err := sendChunks(botSession, channelID, content); err != nil {
+		log.Printf("Error sending dashboard message to %s: %v", channelID, err)
+		http.Error(w, "sending failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	log.Printf("📨 Dashboard message sent to %s by %s", channelID, logUser("", dashboardActor(r)))
+	metrics.Inc("dashboard_messages_sent_total")
+	http.Redirect(w, r, "/?"+url.Values{"notice": {"Message sent."}}.Encode(), http.StatusSeeOther)
+}
+
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"when": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+}).Parse(`
+{{define "head"}}<!doctype html>
+<html><head><meta charset="utf-8"><title>Elsie dashboard</title>
+<style>
+body{font-family:sans-serif;margin:2em;max-width:70em}
+table{border-collapse:collapse}td,th{padding:.2em .8em;text-align:left;border-bottom:1px solid #ddd}
+pre{background:#f4f4f4;padding:1em;max-height:30em;overflow:auto}
+textarea{width:100%;font-family:monospace}.notice{background:#e6f4ea;padding:.5em}
+</style></head><body>
+<nav><a href="/">Overview</a> · <a href="/sessions">Sessions</a>{{if .SignedIn}} · <a href="/logout">Sign out</a>{{end}}</nav>
+{{if .Notice}}<p class="notice">{{.Notice}}</p>{{end}}
+{{end}}
+
+{{define "foot"}}</body></html>{{end}}
+
+{{define "home"}}{{template "head" .}}
+<h1>🍺 Elsie</h1>
+<h2>Servers</h2>
+<table><tr><th>Name</th><th>Members</th><th></th></tr>
+{{range .Guilds}}<tr><td><a href="/guild?id={{.ID}}">{{.Name}}</a></td><td>{{.Members}}</td><td><a href="/sessions?guild_id={{.ID}}">sessions</a></td></tr>
+{{end}}</table>
+<h2>Agents</h2>
+<table>{{range .Agents}}<tr><td>{{.URL}}</td><td>{{if .Healthy}}💚 healthy{{else}}💔 down{{end}}</td></tr>{{end}}</table>
+<h2>Send a message</h2>
+<form method="post" action="/send"><input type="hidden" name="csrf" value="{{.CSRF}}">
+<p><input name="channel_id" placeholder="Channel ID" required></p>
+<p><textarea name="content" rows="4" required></textarea></p>
+<p><button>Send as Elsie</button></p></form>
+<h2>Metrics</h2>
+<pre id="metrics">{{.Metrics}}</pre>
+<script>
+setInterval(function () {
+  fetch("/metrics").then(function (r) { return r.text(); }).then(function (t) {
+    document.getElementById("metrics").textContent = t;
+  });
+}, 5000);
+</script>
+{{template "foot"}}{{end}}
+
+{{define "guild"}}{{template "head" .}}
+<h1>{{.Guild.Name}}</h1>
+<p><a href="/sessions?guild_id={{.Guild.ID}}">Recent sessions</a></p>
+<h2>Config</h2>
+<form method="post" action="/guild"><input type="hidden" name="csrf" value="{{.CSRF}}"><input type="hidden" name="id" value="{{.Guild.ID}}">
+<textarea name="config" rows="25">{{.Config}}</textarea>
+<p><button>Save</button> Changes are versioned; <code>!elsie config rollback</code> undoes them.</p></form>
+<h2>History</h2>
+<table>{{range .History}}<tr><td>v{{.Version}}</td><td>{{when .Time}}</td><td>{{.ActorID}}</td><td>{{range .Changes}}<code>{{.Field}}</code> {{end}}</td></tr>{{end}}</table>
+{{template "foot"}}{{end}}
+
+{{define "sessions"}}{{template "head" .}}
+<h1>Sessions</h1>
+<form method="get" action="/sessions">
+<input name="guild_id" placeholder="Guild ID" value="{{.GuildID}}">
+<input name="channel_id" placeholder="Channel ID" value="{{.ChannelID}}">
+<input name="session" placeholder="Agent session" value="{{.Session}}">
+<button>Filter</button></form>
+{{with .Checkpoint}}<p>Session memory: {{.Total}} exchanges, {{.SinceCompact}} since the last of {{.Compactions}} compactions.</p>{{end}}
+<table><tr><th>Time (UTC)</th><th>Message</th><th>Author</th><th>Persona</th><th>Session</th><th>Outcome</th><th>Replies</th></tr>
+{{range .Records}}<tr><td>{{when .Time}}</td>
+<td><a href="https://discord.com/channels/{{or .GuildID "@me"}}/{{.ChannelID}}/{{.MessageID}}">{{.MessageID}}</a></td>
+<td>{{.AuthorID}}</td><td>{{.Persona}}</td>
+<td><a href="/sessions?session={{.AgentSessionID}}">{{.AgentSessionID}}</a></td>
+<td>{{.Outcome}}</td><td>{{len .ResponseMessageIDs}}</td></tr>
+{{else}}<tr><td colspan="7">No exchanges found.</td></tr>{{end}}</table>
+{{template "foot"}}{{end}}
+`))
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Values for the decision record's fields.
+const (
+	mentionNone    = "none"
+	mentionUser    = "user"
+	mentionRole    = "role"
+	mentionCommand = "command"
+	mentionPersona = "persona"
+
+	policyAllowed       = "allowed"
+	policyNSFWRefused   = "nsfw_refused"
+	policyFilterBlocked = "filter_blocked"
+	policyQuotaExceeded = "quota_exceeded"
+	policyOOCSkipped    = "ooc_skipped"
+	policyLoadShed      = "load_shed"
+	policyBotIgnored    = "bot_ignored"
+	policyBotLoop       = "bot_loop"
+
+	pipelineIgnored = "ignored"
+	pipelineCommand = "command"
+	pipelineMerged  = "burst_merged"
+	pipelineAgent   = "agent"
+)
+
+// messageDecision records how one message was routed: which rules matched,
+// how the bot was addressed, why the channel is monitored, what policy said
+// and which pipeline handled it. One is logged per message.
+type messageDecision struct {
+	Time          time.Time `json:"time"`
+	RequestID     string    `json:"request_id"`
+	GuildID       string    `json:"guild_id,omitempty"`
+	ChannelID     string    `json:"channel_id"`
+	MessageID     string    `json:"message_id"`
+	AuthorID      string    `json:"author_id"`
+	MatchedRules  []string  `json:"matched_rules,omitempty"`
+	MentionType   string    `json:"mention_type"`
+	MonitorReason string    `json:"monitor_reason,omitempty"`
+	Policy        string    `json:"policy,omitempty"`
+	Pipeline      string    `json:"pipeline"`
+	Outcome       string    `json:"outcome,omitempty"`
+}
+
+func (d *messageDecision) match(rule string) {
+	d.MatchedRules = append(d.MatchedRules, rule)
+}
+
+// monitor records why the channel is monitored; the first reason wins.
+func (d *messageDecision) monitor(reason string) {
+	d.match(reason)
+	if d.MonitorReason == "" {
+		d.MonitorReason = reason
+	}
+}
+
+// emit logs the decision as a single JSON record and keeps it for
+// `!elsie audit`.
+func (d *messageDecision) emit(rlog requestLog) {
+	rec := *d
+	if config().PrivacyLogging != privacyOff {
+		rec.AuthorID = logUser("", rec.AuthorID)
+	}
+	if line, err := json.Marshal(rec); err == nil {
+		rlog.Printf("🧭 DECISION %s", line)
+	}
+	decisions.add(*d)
+	metrics.Inc(metricLabel("message_decisions_total", "pipeline", d.Pipeline))
+}
+
+// decisionRing keeps the most recent decisions in memory.
+type decisionRing struct {
+	mu    sync.Mutex
+	items []messageDecision
+	next  int
+}
+
+var decisions = &decisionRing{}
+
+func (r *decisionRing) add(d messageDecision) {
+	if config().DecisionLogSize <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) < config().DecisionLogSize {
+		r.items = append(r.items, d)
+		return
+	}
+	r.items[r.next] = d
+	r.next = (r.next + 1) % len(r.items)
+}
+
+// recent returns up to limit matching decisions, newest first.
+func (r *decisionRing) recent(limit int, keep func(messageDecision) bool) []messageDecision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []messageDecision
+	for i := 0; i < len(r.items) && len(out) < limit; i++ {
+		idx := (r.next - 1 - i + 2*len(r.items)) % len(r.items)
+		if d := r.items[idx]; keep(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func init() {
+	registerCommand(command{name: "audit", handler: auditCommand})
+}
+
+// auditCommand is `!elsie audit [#channel|@user|id] [count]`: recent
+// routing decisions in this server, optionally filtered to a channel, an
+// author, or a message or request ID.
+func auditCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" && !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("Audits are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can audit my decisions.")
+		return
+	}
+
+	limit := 10
+	filter := ""
+	for _, arg := range ctx.args {
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 && n <= 50 && len(arg) <= 2 {
+			limit = n
+			continue
+		}
+		filter = strings.Trim(arg, "<#@!>")
+	}
+	guildID := ctx.m.GuildID
+	found := decisions.recent(limit, func(d messageDecision) bool {
+		if guildID != "" && d.GuildID != guildID {
+			return false
+		}
+		return filter == "" || d.ChannelID == filter || d.AuthorID == filter ||
+			d.MessageID == filter || d.RequestID == filter
+	})
+	if len(found) == 0 {
+		ctx.reply("🧭 No recent decisions match. Only the last few thousand messages since startup are kept.")
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🧭 **Recent routing decisions** (newest first)\n")
+	for _, d := range found {
+		line := fmt.Sprintf("`%s` <#%s> <@%s> • mention **%s**", d.Time.Format("15:04:05"), d.ChannelID, d.AuthorID, d.MentionType)
+		if d.MonitorReason != "" {
+			line += " • monitor **" + d.MonitorReason + "**"
+		}
+		if d.Policy != "" {
+			line += " • policy **" + d.Policy + "**"
+		}
+		line += " • " + d.Pipeline
+		if d.Outcome != "" {
+			line += " → " + d.Outcome
+		}
+		if len(d.MatchedRules) > 0 {
+			line += " • rules: " + strings.Join(d.MatchedRules, ", ")
+		}
+		fmt.Fprintf(&b, "%s • req `%s`\n", line, d.RequestID)
+	}
+	if _, err := sendChunksQuietly(ctx.s, ctx.m.ChannelID, b.String()); err != nil {
+		log.Printf("Error sending audit: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "time"
+
+// processedMessages remembers message IDs the bot has already handled. After
+// a gateway resume Discord can deliver the same MESSAGE_CREATE again, and
+// without this Elsie would answer it twice.
+var processedMessages *lruCache[string, time.Time]
+
+// firstDelivery reports whether messageID is seen for the first time, and
+// marks it seen.
+func firstDelivery(messageID string) bool {
+	if processedMessages == nil || messageID == "" {
+		return true
+	}
+	if processedMessages.AddIfAbsent(messageID, time.Now()) {
+		return true
+	}
+	metrics.Inc("duplicate_messages_total")
+	return false
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rulesProfile sets a scene's dice defaults, roll formatting and the rules
+// hints passed to the agent.
+type rulesProfile struct {
+	Name        string
+	DefaultDice string
+	Hints       string
+}
+
+var rulesProfiles = map[string]rulesProfile{
+	"d20": {
+		Name:        "d20",
+		DefaultDice: "1d20",
+		Hints:       "d20 system: checks roll 1d20 plus a modifier against a difficulty class; a natural 20 is a critical success and a natural 1 a critical failure.",
+	},
+	"fate": {
+		Name:        "fate",
+		DefaultDice: "4dF",
+		Hints:       "Fate system: rolls are 4 Fate dice (-1, 0, +1 each) added to a skill and read on the ladder from Terrible (-2) to Legendary (+8); aspects can be invoked for +2 or a reroll.",
+	},
+	"custom": {
+		Name:        "custom",
+		DefaultDice: "1d20",
+	},
+}
+
+// sceneRules returns the scene's rules profile, with custom dice and hints
+// applied, and whether the scene has one.
+func sceneRules(channelID string) (rulesProfile, bool) {
+	sc := loadScene(channelID)
+	profile, ok := rulesProfiles[sc.RulesProfile]
+	if !ok {
+		return rulesProfiles["d20"], false
+	}
+	if sc.RulesProfile == "custom" {
+		if sc.CustomDice != "" {
+			profile.DefaultDice = sc.CustomDice
+		}
+		profile.Hints = sc.CustomHints
+	}
+	return profile, true
+}
+
+// rulesContext describes the scene's rules for the agent, or nil when the
+// scene has no profile.
+func rulesContext(channelID string) map[string]interface{} {
+	profile, ok := sceneRules(channelID)
+	if !ok {
+		return nil
+	}
+	ctx := map[string]interface{}{
+		"system":       profile.Name,
+		"default_dice": profile.DefaultDice,
+	}
+	if profile.Hints != "" {
+		ctx["hints"] = profile.Hints
+	}
+	return ctx
+}
+
+var diceExpr = regexp.MustCompile(`^(\d*)d(\d+|f)([+-]\d+)?$`)
+
+// diceRoll is one evaluated dice expression.
+type diceRoll struct {
+	expr     string
+	fate     bool
+	rolls    []int
+	modifier int
+	total    int
+}
+
+// maxDice bounds a single roll so `!elsie roll 100000d6` can't flood a
+// channel.
+const maxDice = 100
+
+// rollDice evaluates expressions like "2d6+3", "d20" or "4dF".
+func rollDice(expr string) (*diceRoll, error) {
+	expr = strings.ToLower(strings.ReplaceAll(expr, " ", ""))
+	parts := diceExpr.FindStringSubmatch(expr)
+	if parts == nil {
+		return nil, fmt.Errorf("I can't read %q as dice — try `2d6+1` or `4dF`", expr)
+	}
+	count := 1
+	if parts[1] != "" {
+		count, _ = strconv.Atoi(parts[1])
+	}
+	if count < 1 || count > maxDice {
+		return nil, fmt.Errorf("roll between 1 and %d dice at a time", maxDice)
+	}
+	r := &diceRoll{expr: expr, fate: parts[2] == "f"}
+	if parts[3] != "" {
+		r.modifier, _ = strconv.Atoi(parts[3])
+	}
+	sides := 0
+	if !r.fate {
+		sides, _ = strconv.Atoi(parts[2])
+		if sides < 2 || sides > 1000 {
+			return nil, fmt.Errorf("dice need between 2 and 1000 sides")
+		}
+	}
+	for i := 0; i < count; i++ {
+		var v int
+		if r.fate {
+			v = rand.Intn(3) - 1
+		} else {
+			v = rand.Intn(sides) + 1
+		}
+		r.rolls = append(r.rolls, v)
+		r.total += v
+	}
+	r.total += r.modifier
+	return r, nil
+}
+
+var fateLadder = map[int]string{
+	-2: "Terrible", -1: "Poor", 0: "Mediocre", 1: "Average", 2: "Fair",
+	3: "Good", 4: "Great", 5: "Superb", 6: "Fantastic", 7: "Epic", 8: "Legendary",
+}
+
+// format renders the roll the way the scene's rules system reads it.
+func (r *diceRoll) format(profile rulesProfile) string {
+	faces := make([]string, len(r.rolls))
+	for i, v := range r.rolls {
+		switch {
+		case r.fate && v > 0:
+			faces[i] = "+"
+		case r.fate && v < 0:
+			faces[i] = "−"
+		case r.fate:
+			faces[i] = "0"
+		default:
+			faces[i] = strconv.Itoa(v)
+		}
+	}
+	mod := ""
+	if r.modifier != 0 {
+		mod = fmt.Sprintf(" %+d", r.modifier)
+	}
+	out := fmt.Sprintf("🎲 `%s` → [%s]%s = **%d**", r.expr, strings.Join(faces, " "), mod, r.total)
+
+	switch {
+	case r.fate:
+		if rung, ok := fateLadder[r.total]; ok {
+			out += fmt.Sprintf(" (%s)", rung)
+		} else if r.total > 8 {
+			out += " (Beyond Legendary)"
+		} else {
+			out += " (Beyond Terrible)"
+		}
+	case profile.Name == "d20" && len(r.rolls) == 1 && strings.Contains(r.expr, "d20"):
+		if r.rolls[0] == 20 {
+			out += " — **natural 20!**"
+		} else if r.rolls[0] == 1 {
+			out += " — natural 1..."
+		}
+	}
+	return out
+}
+
+func init() {
+	registerCommand(command{name: "roll", handler: rollCommand})
+	registerSceneSubcommand("rules", sceneRulesCommand)
+}
+
+// rollCommand is `!elsie roll [dice]`, defaulting to the scene's dice.
+func rollCommand(ctx *commandContext) {
+	profile, _ := sceneRules(ctx.m.ChannelID)
+	expr := profile.DefaultDice
+	if ctx.raw != "" {
+		expr = ctx.raw
+	}
+	r, err := rollDice(expr)
+	if err != nil {
+		ctx.reply("*squints at the dice* " + err.Error())
+		return
+	}
+	result := fmt.Sprintf("<@%s> %s", ctx.m.Author.ID, r.format(profile))
+	rememberUtilityResult(ctx, fmt.Sprintf("%s rolled %s = %d", ctx.m.Author.Username, r.expr, r.total))
+	ctx.reply(result)
+}
+
+// sceneRulesCommand is `!elsie scene rules [fate|d20|custom <dice> [hints]|off]`.
+func sceneRulesCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie scene rules fate|d20|off` or `!elsie scene rules custom <dice> [rules hints for the narrator]`"
+	if len(ctx.args) == 0 {
+		profile, ok := sceneRules(ctx.m.ChannelID)
+		if !ok {
+			ctx.reply("📜 This scene has no rules profile; rolls default to d20.\n" + usage)
+			return
+		}
+		ctx.reply(fmt.Sprintf("📜 **Rules:** %s • Default dice: `%s`\n%s", profile.Name, profile.DefaultDice, profile.Hints))
+		return
+	}
+	if !isModerator(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only moderators can change a scene's rules.")
+		return
+	}
+
+	name := strings.ToLower(ctx.args[0])
+	var apply func(sc *Scene)
+	switch name {
+	case "d20", "fate":
+		apply = func(sc *Scene) { sc.RulesProfile, sc.CustomDice, sc.CustomHints = name, "", "" }
+	case "custom":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		dice := strings.ToLower(ctx.args[1])
+		if _, err := rollDice(dice); err != nil {
+			ctx.reply("*squints at the dice* " + err.Error())
+			return
+		}
+		hints := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(ctx.raw, ctx.args[0])), ctx.args[1]))
+		apply = func(sc *Scene) { sc.RulesProfile, sc.CustomDice, sc.CustomHints = name, dice, hints }
+	case "off":
+		apply = func(sc *Scene) { sc.RulesProfile, sc.CustomDice, sc.CustomHints = "", "", "" }
+	default:
+		ctx.reply(usage)
+		return
+	}
+	if err := updateScene(ctx.m.ChannelID, apply); err != nil {
+		log.Printf("Error saving scene rules: %v", err)
+		ctx.reply("*holographic matrix flickers* I couldn't save the scene rules. Please try again later.")
+		return
+	}
+	if name == "off" {
+		ctx.reply("📜 Rules profile cleared for this scene.")
+		return
+	}
+	profile, _ := sceneRules(ctx.m.ChannelID)
+	ctx.reply(fmt.Sprintf("📜 This scene now plays by **%s** rules (default dice `%s`).", profile.Name, profile.DefaultDice))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	digestBucket   = "digest_sent"
+	digestInterval = 7 * 24 * time.Hour
+)
+
+// buildDigest renders a guild's stats for the current period as an embed.
+func buildDigest(guild *discordgo.Guild, st GuildStats) *discordgo.MessageEmbed {
+	var top []string
+	for i, channelID := range st.topChannels(5) {
+		top = append(top, fmt.Sprintf("%d. <#%s> — %d messages", i+1, channelID, st.ChannelMessages[channelID]))
+	}
+	if len(top) == 0 {
+		top = []string{"No activity this week."}
+	}
+
+	budget := fmt.Sprintf("%d agent requests • ~%d tokens generated", st.AgentRequests, st.ResponseChars/4)
+	if config().WeeklyAgentBudget > 0 {
+		budget += fmt.Sprintf("\n%.0f%% of the %d request weekly budget",
+			100*float64(st.AgentRequests)/float64(config().WeeklyAgentBudget), config().WeeklyAgentBudget)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s Weekly bar report for %s", themeEmoji(guild.ID, "bar"), guild.Name),
+		Description: fmt.Sprintf("Activity since %s.", st.PeriodStart.Format("Mon Jan 2")),
+		Color:       themeColor(guild.ID, "highlight"),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Messages handled", Value: fmt.Sprintf("%d", st.Messages), Inline: true},
+			{Name: "Agent errors", Value: fmt.Sprintf("%d", st.AgentErrors), Inline: true},
+			{Name: "Send errors", Value: fmt.Sprintf("%d", st.SendErrors), Inline: true},
+			{Name: "Top channels", Value: strings.Join(top, "\n")},
+			{Name: "Budget", Value: budget},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: "Server admins can turn this off with !elsie digest off"},
+	}
+}
+
+// sendDigest delivers the digest to the guild's digest channel, or DMs the
+// guild owner when none is configured.
+func sendDigest(s *discordgo.Session, guild *discordgo.Guild) error {
+	cfg := loadGuildConfig(guild.ID)
+	channelID := cfg.DigestChannelID
+	if channelID == "" {
+		dm, err := s.UserChannelCreate(guild.OwnerID)
+		if err != nil {
+			return fmt.Errorf("opening DM with owner: %w", err)
+		}
+		channelID = dm.ID
+	}
+	guildStats.flush()
+	_, err := s.ChannelMessageSendEmbed(channelID, buildDigest(guild, guildStats.snapshot(guild.ID)))
+	return err
+}
+
+var digestSchedulerOnce sync.Once
+
+// startDigestScheduler starts the weekly digest job once, if the operator
+// enabled digests.
+func startDigestScheduler(s *discordgo.Session) {
+	if !config().WeeklyDigestEnabled {
+		return
+	}
+	digestSchedulerOnce.Do(func() { go runDigestScheduler(s) })
+}
+
+// runDigestScheduler checks hourly for guilds whose weekly digest is due.
+func runDigestScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, guild := range stateGuilds(s) {
+			if loadGuildConfig(guild.ID).DigestOptOut {
+				continue
+			}
+			var lastSent time.Time
+			if _, err := store.Get(digestBucket, guild.ID, &lastSent); err != nil {
+				log.Printf("Error loading digest schedule for guild %s: %v", guild.ID, err)
+				continue
+			}
+			if lastSent.IsZero() {
+				// First sighting: start the clock rather than sending a digest
+				// about a week we didn't observe.
+				store.Put(digestBucket, guild.ID, time.Now())
+				continue
+			}
+			if time.Since(lastSent) < digestInterval {
+				continue
+			}
+			if err := sendDigest(s, guild); err != nil {
+				log.Printf("Error sending digest for guild %s: %v", guild.ID, err)
+				continue
+			}
+			log.Printf("📊 Sent weekly digest for guild %s", guild.ID)
+			guildStats.reset(guild.ID)
+			store.Put(digestBucket, guild.ID, time.Now())
+		}
+	}
+}
+
+func init() {
+	registerCommand(command{name: "digest", handler: digestCommand})
+}
+
+// digestCommand is `!elsie digest [on|off|channel <#channel|dm>|preview]`.
+func digestCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Digests are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can manage the weekly digest.")
+		return
+	}
+
+	sub := ""
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	var apply func(cfg *GuildConfig)
+	var confirmation string
+	switch sub {
+	case "":
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		state, target := "on", "DM to the server owner"
+		if cfg.DigestOptOut {
+			state = "off"
+		}
+		if cfg.DigestChannelID != "" {
+			target = fmt.Sprintf("<#%s>", cfg.DigestChannelID)
+		}
+		if !config().WeeklyDigestEnabled {
+			state += " (digests are disabled by the bot operator)"
+		}
+		ctx.reply(fmt.Sprintf("📊 **Weekly digest:** %s • Delivered to: %s\nUsage: `!elsie digest on|off|preview`, `!elsie digest channel #channel|dm`", state, target))
+		return
+	case "preview":
+		guild, err := getGuild(ctx.s, ctx.m.GuildID)
+		if err != nil {
+			ctx.reply("*holographic matrix flickers* I couldn't load this server's details.")
+			return
+		}
+		ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, buildDigest(guild, guildStats.snapshot(ctx.m.GuildID)))
+		return
+	case "on":
+		apply = func(cfg *GuildConfig) { cfg.DigestOptOut = false }
+		confirmation = "📊 Weekly digest is **on**."
+	case "off":
+		apply = func(cfg *GuildConfig) { cfg.DigestOptOut = true }
+		confirmation = "📊 Weekly digest is **off**."
+	case "channel":
+		if len(ctx.args) < 2 {
+			ctx.reply("Usage: `!elsie digest channel #channel` or `!elsie digest channel dm`")
+			return
+		}
+		channelID := parseChannelMention(ctx.args[1])
+		if channelID == "" && !strings.EqualFold(ctx.args[1], "dm") {
+			ctx.reply("Mention the channel, e.g. `!elsie digest channel #admin`, or use `dm`.")
+			return
+		}
+		apply = func(cfg *GuildConfig) { cfg.DigestChannelID = channelID }
+		confirmation = "📊 Weekly digest will be sent to the server owner by DM."
+		if channelID != "" {
+			confirmation = fmt.Sprintf("📊 Weekly digest will be posted in <#%s>.", channelID)
+		}
+	default:
+		ctx.reply("Usage: `!elsie digest [on|off|preview|channel #channel|dm]`")
+		return
+	}
+
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving digest config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(confirmation)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	dmFallbackMu      sync.Mutex
+	dmFallbackWindows = newLRUCache[string, *quotaWindow]("dm_fallback", 5000, 24*time.Hour)
+)
+
+func init() {
+	trackCache(dmFallbackWindows)
+	registerCommand(command{name: "dms", handler: dmsCommand})
+}
+
+// sendFailureRecoverable reports whether a failed channel send is worth
+// retrying by DM: missing permissions, Discord outages and network errors.
+// Anything else, e.g. a rejected payload, would fail in a DM too.
+func sendFailureRecoverable(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return true
+	}
+	if restErr.Response == nil {
+		return true
+	}
+	code := restErr.Response.StatusCode
+	return code == http.StatusForbidden || code == http.StatusNotFound || code >= http.StatusInternalServerError
+}
+
+// takeDMFallback records one fallback DM for the user, reporting false if
+// they are over DM_FALLBACK_QUOTA.
+func takeDMFallback(userID string) bool {
+	if !config().DMFallbackQuota.enabled() {
+		return true
+	}
+	dmFallbackMu.Lock()
+	defer dmFallbackMu.Unlock()
+	w, ok := dmFallbackWindows.Get(userID)
+	if !ok || time.Since(w.start) >= config().DMFallbackQuota.Window {
+		w = &quotaWindow{start: time.Now()}
+		dmFallbackWindows.Add(userID, w)
+	}
+	if w.count >= config().DMFallbackQuota.Limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// dmFallback sends a response that couldn't be posted in the channel to
+// the user who asked for it. It honors the user's `!elsie dms off` and the
+// per-user rate cap, and reports whether the DM went out.
+func dmFallback(s *discordgo.Session, m *discordgo.MessageCreate, text string, sendErr error, rlog requestLog) bool {
+	if !config().DMFallbackEnabled || m.GuildID == "" || !sendFailureRecoverable(sendErr) {
+		return false
+	}
+	if p := loadProfile(m.Author.ID); p != nil && p.NoDMFallback {
+		metrics.Inc(metricLabel("dm_fallback_total", "result", "opted_out"))
+		return false
+	}
+	if !takeDMFallback(m.Author.ID) {
+		rlog.Printf("📭 DM fallback for %s skipped: over the rate cap", logUser("", m.Author.ID))
+		metrics.Inc(metricLabel("dm_fallback_total", "result", "throttled"))
+		return false
+	}
+
+	where := fmt.Sprintf("<#%s>", m.ChannelID)
+	if g, err := getGuild(s, m.GuildID); err == nil {
+		where += " in " + g.Name
+	}
+	dm, err := s.UserChannelCreate(m.Author.ID)
+	if err == nil {
+		note := fmt.Sprintf("📭 *I couldn't post in %s, so here's my answer to your message.* (`!elsie dms off` stops these.)", where)
+		if _, err = s.ChannelMessageSend(dm.ID, note); err == nil {
+			_, err = sendChunks(s, dm.ID, postProcess(s, m.ChannelID, text))
+		}
+	}
+	if err != nil {
+		rlog.Printf("DM fallback to %s failed: %v", logUser("", m.Author.ID), err)
+		metrics.Inc(metricLabel("dm_fallback_total", "result", "failed"))
+		return false
+	}
+	rlog.Printf("📭 Channel send failed, response DMed to %s", logUser("", m.Author.ID))
+	metrics.Inc(metricLabel("dm_fallback_total", "result", "sent"))
+	return true
+}
+
+// dmsCommand is `!elsie dms [on|off]`: whether Elsie may DM you her answer
+// when she can't post it in the channel.
+func dmsCommand(ctx *commandContext) {
+	p := loadProfile(ctx.m.Author.ID)
+	if p == nil {
+		p = &UserProfile{}
+	}
+	if len(ctx.args) == 0 {
+		state := "on"
+		if p.NoDMFallback {
+			state = "off"
+		}
+		ctx.reply(fmt.Sprintf("📭 **DM fallback:** %s. When I can't post an answer to you in a channel, I DM it instead.\nUsage: `!elsie dms on|off`", state))
+		return
+	}
+	switch strings.ToLower(ctx.args[0]) {
+	case "on":
+		p.NoDMFallback = false
+	case "off":
+		p.NoDMFallback = true
+	default:
+		ctx.reply("Usage: `!elsie dms on|off`")
+		return
+	}
+	if err := store.Put(profileBucket, ctx.m.Author.ID, p); err != nil {
+		log.Printf("Error saving profile for %s: %v", logUser("", ctx.m.Author.ID), err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if p.NoDMFallback {
+		ctx.reply("📭 Got it — I won't DM you answers I can't post in a channel.")
+	} else {
+		ctx.reply("📭 Got it — if I can't post an answer to you in a channel, I'll DM it to you.")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const dmTopicBucket = "dm_topics"
+
+// dmTopicMainName is how the DM's original conversation is named in
+// commands; its session is the DM channel's, as before topics.
+const dmTopicMainName = "main"
+
+// DMTopics are the parallel conversations in one DM channel, each with its
+// own agent session, and which one the player is in.
+type DMTopics struct {
+	Active string    `json:"active,omitempty"`
+	Topics []DMTopic `json:"topics,omitempty"`
+}
+
+type DMTopic struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	// dmTopicsMu serializes changes to DM topics.
+	dmTopicsMu sync.Mutex
+
+	// dmTopicName is what topic names are reduced to, so they are safe in
+	// session IDs.
+	dmTopicName = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+func init() {
+	registerCommand(command{name: "topic", handler: topicCommand})
+}
+
+func loadDMTopics(channelID string) DMTopics {
+	var topics DMTopics
+	if _, err := store.Get(dmTopicBucket, channelID, &topics); err != nil {
+		log.Printf("Error loading DM topics for %s: %v", channelID, err)
+	}
+	return topics
+}
+
+// activeDMTopic is the topic a DM channel is in, or "" for the main
+// conversation and for any other channel.
+func activeDMTopic(channelID string) string {
+	return loadDMTopics(channelID).Active
+}
+
+// dmTopicSessionID is the session of one topic in a DM channel.
+func dmTopicSessionID(channelID, topic string) string {
+	if topic == "" {
+		return channelID
+	}
+	return channelID + ":topic:" + topic
+}
+
+// normalizeDMTopic turns a name like "Plot Ideas!" into "plot-ideas".
+func normalizeDMTopic(name string) string {
+	name = strings.Trim(dmTopicName.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(name) > 32 {
+		name = strings.TrimRight(name[:32], "-")
+	}
+	return name
+}
+
+// topicCommand is `!elsie topic [list|new <name>|switch <name>|delete <name>]`.
+func topicCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie topic list`, `!elsie topic new <name>`, `!elsie topic switch <name|main>`, `!elsie topic delete <name>`")
+	if ctx.m.GuildID != "" {
+		ctx.reply(ctx.tr("Topics are for DMs — message me directly to keep separate conversations."))
+		return
+	}
+	sub := "list"
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	name := ""
+	if len(ctx.args) > 1 {
+		name = normalizeDMTopic(strings.Join(ctx.args[1:], " "))
+	}
+	channelID := ctx.m.ChannelID
+
+	switch sub {
+	case "list":
+		ctx.reply(describeDMTopics(ctx, loadDMTopics(channelID)) + "\n" + usage)
+		return
+	case "new", "switch", "delete":
+		if name == "" {
+			ctx.reply(usage)
+			return
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	dmTopicsMu.Lock()
+	defer dmTopicsMu.Unlock()
+	topics := loadDMTopics(channelID)
+	exists := slices.ContainsFunc(topics.Topics, func(t DMTopic) bool { return t.Name == name })
+	switch {
+	case sub == "new" && (exists || name == dmTopicMainName):
+		ctx.reply(ctx.tr("*Elsie flips through her notes* We already have a `%s` conversation. Use `!elsie topic switch %s`.", name, name))
+		return
+	case sub == "new" && config().DMTopicMax > 0 && len(topics.Topics) >= config().DMTopicMax:
+		ctx.reply(ctx.tr("*Elsie's notebook is full* You can keep up to %d topics. Delete one with `!elsie topic delete <name>` first.", config().DMTopicMax))
+		return
+	case sub != "new" && !exists && !(sub == "switch" && name == dmTopicMainName):
+		ctx.reply(ctx.tr("*Elsie flips through her notes* I don't have a topic called `%s`. Try `!elsie topic list`.", name))
+		return
+	}
+
+	reply := ""
+	switch sub {
+	case "new":
+		topics.Topics = append(topics.Topics, DMTopic{Name: name, CreatedAt: time.Now()})
+		topics.Active = name
+		reply = ctx.tr("🗂️ Started a fresh conversation: **%s**. I won't mix it up with our other chats. `!elsie topic switch main` takes us back.", name)
+	case "switch":
+		topics.Active = name
+		if name == dmTopicMainName {
+			topics.Active = ""
+		}
+		reply = ctx.tr("🗂️ Picking up our **%s** conversation where we left off.", name)
+	case "delete":
+		topics.Topics = slices.DeleteFunc(topics.Topics, func(t DMTopic) bool { return t.Name == name })
+		if topics.Active == name {
+			topics.Active = ""
+		}
+		forgetDMTopicSessions(channelID, name)
+		reply = ctx.tr("🗂️ Deleted the **%s** conversation. We're back in the main one.", name)
+		if topics.Active != "" {
+			reply = ctx.tr("🗂️ Deleted the **%s** conversation.", name)
+		}
+	}
+	var err error
+	if len(topics.Topics) == 0 {
+		err = store.Delete(dmTopicBucket, channelID)
+	} else {
+		err = store.Put(dmTopicBucket, channelID, topics)
+	}
+	if err != nil {
+		log.Printf("Error saving DM topics: %v", err)
+		ctx.reply(themePhrase("", "save_failed", nil))
+		return
+	}
+	metrics.Inc(metricLabel("dm_topic_changes_total", "action", sub))
+	ctx.reply(reply)
+}
+
+// describeDMTopics lists a DM's conversations, marking the current one.
+func describeDMTopics(ctx *commandContext, topics DMTopics) string {
+	var b strings.Builder
+	b.WriteString(ctx.tr("🗂️ **Our conversations**") + "\n")
+	line := func(name, since string, active bool) {
+		marker := "•"
+		if active {
+			marker = "▶️"
+		}
+		fmt.Fprintf(&b, "%s `%s`%s\n", marker, name, since)
+	}
+	line(dmTopicMainName, "", topics.Active == "")
+	for _, t := range topics.Topics {
+		line(t.Name, ctx.tr(" — since %s", t.CreatedAt.Format("2006-01-02")), topics.Active == t.Name)
+	}
+	return b.String()
+}
+
+// forgetDMTopicSessions drops a topic's memory checkpoints with every
+// persona. Callers hold dmTopicsMu.
+func forgetDMTopicSessions(channelID, topic string) int {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	n := 0
+	for _, p := range personas {
+		sessionID := p.sessionIDFor(dmTopicSessionID(channelID, topic))
+		delete(checkpoints, sessionID)
+		if ok, _ := store.Get(memoryCheckpointBucket, sessionID, &MemoryCheckpoint{}); !ok {
+			continue
+		}
+		if err := store.Delete(memoryCheckpointBucket, sessionID); err != nil {
+			log.Printf("Error forgetting DM topic session %s: %v", sessionID, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// eraseDMTopics drops the user's DM topics and their sessions' memory
+// checkpoints.
+func eraseDMTopics(s *discordgo.Session, userID string) (int, error) {
+	dm, err := s.UserChannelCreate(userID)
+	if err != nil {
+		return 0, err
+	}
+	dmTopicsMu.Lock()
+	defer dmTopicsMu.Unlock()
+	topics := loadDMTopics(dm.ID)
+	if len(topics.Topics) == 0 {
+		return 0, nil
+	}
+	n := 1
+	for _, t := range topics.Topics {
+		n += forgetDMTopicSessions(dm.ID, t.Name)
+	}
+	return n, store.Delete(dmTopicBucket, dm.ID)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	drinkOfTheDayBucket          = "drink_of_the_day"
+	drinkOfTheDayKey             = "current"
+	drinkOfTheDayAnnouncedBucket = "drink_of_the_day_announced"
+
+	drinkOfTheDayAgent   = "agent"
+	drinkOfTheDayCatalog = "catalog"
+)
+
+// DrinkOfTheDay is the day's featured drink, shared by every guild. Agent
+// picks may be off the menu, in which case the drink has no ID.
+type DrinkOfTheDay struct {
+	Date  string `json:"date"` // UTC day, 2006-01-02
+	Drink Drink  `json:"drink"`
+	// FromAgent is set when the agent picked the drink.
+	FromAgent bool `json:"from_agent,omitempty"`
+}
+
+var (
+	// drinkOfTheDayMu serializes picking and announcing.
+	drinkOfTheDayMu sync.Mutex
+
+	drinkOfTheDaySchedulerOnce sync.Once
+)
+
+func init() {
+	registerCommand(command{name: "dotd", handler: drinkOfTheDayCommand})
+	registerDataEraser(dataEraser{name: "drink of the day announcements", guild: eraseDrinkOfTheDayAnnouncements})
+}
+
+func loadDrinkOfTheDay() DrinkOfTheDay {
+	var d DrinkOfTheDay
+	if _, err := store.Get(drinkOfTheDayBucket, drinkOfTheDayKey, &d); err != nil {
+		log.Printf("Error loading drink of the day: %v", err)
+	}
+	return d
+}
+
+func drinkEmoji(d Drink) string {
+	if d.Emoji == "" {
+		return "🍹"
+	}
+	return d.Emoji
+}
+
+// startDrinkOfTheDayScheduler starts the daily pick once, if the operator
+// enabled it.
+func startDrinkOfTheDayScheduler(s *discordgo.Session) {
+	if !config().DrinkOfTheDayEnabled {
+		return
+	}
+	drinkOfTheDaySchedulerOnce.Do(func() { go runDrinkOfTheDayScheduler(s) })
+}
+
+// runDrinkOfTheDayScheduler picks a new drink once a day, after
+// DRINK_OF_THE_DAY_HOUR UTC, and announces it in every guild that asked
+// for announcements.
+func runDrinkOfTheDayScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		checkDrinkOfTheDay(s)
+		<-ticker.C
+	}
+}
+
+func checkDrinkOfTheDay(s *discordgo.Session) {
+	drinkOfTheDayMu.Lock()
+	defer drinkOfTheDayMu.Unlock()
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+	current := loadDrinkOfTheDay()
+	if current.Date != today {
+		if now.Hour() < config().DrinkOfTheDayHour {
+			return
+		}
+		current = pickDrinkOfTheDay(today, current.Drink)
+		if err := store.Put(drinkOfTheDayBucket, drinkOfTheDayKey, current); err != nil {
+			log.Printf("Error saving drink of the day: %v", err)
+			return
+		}
+		source := drinkOfTheDayCatalog
+		if current.FromAgent {
+			source = drinkOfTheDayAgent
+		}
+		log.Printf("🍹 Drink of the day: %s (from the %s)", current.Drink.Name, source)
+		metrics.Inc(metricLabel("drink_of_the_day_picks_total", "source", source))
+		refreshPresence(s)
+	}
+	for _, guild := range stateGuilds(s) {
+		announceDrinkOfTheDay(s, guild.ID, current)
+	}
+}
+
+// pickDrinkOfTheDay asks the agent for the day's drink when
+// DRINK_OF_THE_DAY_SOURCE is "agent", falling back to a catalog drink
+// other than yesterday's.
+func pickDrinkOfTheDay(date string, yesterday Drink) DrinkOfTheDay {
+	if config().DrinkOfTheDaySource == drinkOfTheDayAgent {
+		drink, err := agentDrinkOfTheDay()
+		if err == nil {
+			return DrinkOfTheDay{Date: date, Drink: drink, FromAgent: true}
+		}
+		log.Printf("Error asking the agent for a drink of the day, picking from the catalog: %v", err)
+	}
+	catalog := drinkCatalog()
+	candidates := make([]Drink, 0, len(catalog))
+	for _, d := range catalog {
+		if d.Name != yesterday.Name {
+			candidates = append(candidates, d)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = catalog
+	}
+	return DrinkOfTheDay{Date: date, Drink: candidates[rand.Intn(len(candidates))]}
+}
+
+// agentDrinkOfTheDay asks the agent to pick a drink. The agent answers with
+// a JSON object, optionally wrapped in prose; a pick matching a catalog
+// drink by name is served as that drink, so it can be ordered.
+func agentDrinkOfTheDay() (Drink, error) {
+	rlog := requestLog{id: newRequestID()}
+	var menu []string
+	for _, d := range drinkCatalog() {
+		menu = append(menu, d.Name)
+	}
+	ctx := map[string]interface{}{
+		"request_id": rlog.id,
+		"session_id": "drink-of-the-day",
+		"platform":   "discord",
+		"persona":    defaultPersonaID,
+		"intent":     "drink_of_the_day",
+		"menu":       menu,
+	}
+	prompt := `Pick today's drink of the day for the bar: a favourite from the menu or something new. Reply with only a JSON object with "name", "description" (one short sentence) and "emoji".`
+	resp, err := callAgent(Message{Message: prompt, Context: ctx, RequestID: rlog.id, Persona: defaultPersonaID})
+	if err != nil {
+		return Drink{}, err
+	}
+	text := resp.Response
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return Drink{}, errors.New("no JSON object in drink of the day response")
+	}
+	var pick Drink
+	if err := json.Unmarshal([]byte(text[start:end+1]), &pick); err != nil {
+		return Drink{}, fmt.Errorf("decoding drink of the day: %w", err)
+	}
+	pick.Name = truncateText(strings.TrimSpace(pick.Name), 80)
+	if pick.Name == "" {
+		return Drink{}, errors.New("agent picked a drink without a name")
+	}
+	for _, d := range drinkCatalog() {
+		if strings.EqualFold(d.Name, pick.Name) {
+			return d, nil
+		}
+	}
+	pick.ID, pick.Price = "", 0
+	pick.Description = truncateText(strings.TrimSpace(pick.Description), 300)
+	return pick, nil
+}
+
+// announceDrinkOfTheDay posts the day's drink in the guild's announcement
+// channel, once per day.
+func announceDrinkOfTheDay(s *discordgo.Session, guildID string, d DrinkOfTheDay) {
+	channelID := loadGuildConfig(guildID).DrinkOfTheDayChannelID
+	if channelID == "" || d.Drink.Name == "" {
+		return
+	}
+	var announced string
+	if _, err := store.Get(drinkOfTheDayAnnouncedBucket, guildID, &announced); err != nil || announced == d.Date {
+		return
+	}
+	// Recorded before posting, so a channel Elsie can't post in doesn't get
+	// retried every few minutes.
+	if err := store.Put(drinkOfTheDayAnnouncedBucket, guildID, d.Date); err != nil {
+		log.Printf("Error saving drink of the day announcement for guild %s: %v", guildID, err)
+		return
+	}
+	if _, err := s.ChannelMessageSendEmbed(channelID, drinkOfTheDayEmbed(guildID, d)); err != nil {
+		log.Printf("Error announcing drink of the day in %s: %v", channelID, err)
+		return
+	}
+	metrics.Inc("drink_of_the_day_announcements_total")
+}
+
+func drinkOfTheDayEmbed(guildID string, d DrinkOfTheDay) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s %s: %s", drinkEmoji(d.Drink), tr(guildID, "Drink of the day"), d.Drink.Name),
+		Description: themePhrase(guildID, "drink_of_the_day", map[string]interface{}{"Drink": d.Drink.Name}),
+		Color:       themeColor(guildID, "highlight"),
+	}
+	if d.Drink.Description != "" {
+		embed.Description += "\n\n*" + d.Drink.Description + "*"
+	}
+	if d.Drink.ID != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: tr(guildID, "Order one with /order")}
+	}
+	return embed
+}
+
+// drinkOfTheDayCommand is `!elsie dotd [channel <#channel|off>]`.
+func drinkOfTheDayCommand(ctx *commandContext) {
+	if !config().DrinkOfTheDayEnabled {
+		ctx.reply(ctx.tr("*Elsie glances at the empty chalkboard* There's no drink of the day on this station."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		d := loadDrinkOfTheDay()
+		if d.Drink.Name == "" {
+			ctx.reply(ctx.tr("*Elsie glances at the empty chalkboard* I haven't picked today's drink yet. Check back later!"))
+			return
+		}
+		ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, drinkOfTheDayEmbed(ctx.m.GuildID, d))
+		return
+	}
+	usage := ctx.tr("Usage: `!elsie dotd`, `!elsie dotd channel #channel|off`")
+	if !strings.EqualFold(ctx.args[0], "channel") || len(ctx.args) < 2 {
+		ctx.reply(usage)
+		return
+	}
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Drink of the day announcements are per server — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change where I announce the drink of the day."))
+		return
+	}
+	channelID := parseChannelMention(ctx.args[1])
+	if channelID == "" && !strings.EqualFold(ctx.args[1], "off") {
+		ctx.reply(usage)
+		return
+	}
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.DrinkOfTheDayChannelID = channelID })
+	if err != nil {
+		log.Printf("Error saving drink of the day channel: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if channelID == "" {
+		ctx.reply(ctx.tr("🍹 I'll stop announcing the drink of the day here."))
+		return
+	}
+	ctx.reply(ctx.tr("🍹 I'll announce the drink of the day in <#%s>.", channelID))
+}
+
+func eraseDrinkOfTheDayAnnouncements(s *discordgo.Session, guildID string) (int, error) {
+	if ok, err := store.Get(drinkOfTheDayAnnouncedBucket, guildID, new(string)); err != nil || !ok {
+		return 0, err
+	}
+	return 1, store.Delete(drinkOfTheDayAnnouncedBucket, guildID)
+}
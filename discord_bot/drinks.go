@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Drink is one entry in the bar's drink catalog.
+type Drink struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Emoji       string `json:"emoji,omitempty"`
+	Price       int    `json:"price,omitempty"` // bar credits on a tab
+}
+
+// defaultDrinkCatalog is served unless DRINK_CATALOG_FILE points at a JSON
+// array of drinks.
+var defaultDrinkCatalog = []Drink{
+	{ID: "romulan-ale", Name: "Romulan Ale", Description: "Blue and mysterious", Emoji: "🔵", Price: 12},
+	{ID: "earl-grey-hot", Name: "Earl Grey, Hot", Description: "The Captain's favorite", Emoji: "🫖", Price: 3},
+	{ID: "blood-wine", Name: "Klingon Blood Wine", Description: "For Klingon warriors", Emoji: "🍷", Price: 10},
+	{ID: "synthehol", Name: "Synthehol", Description: "No hangover guaranteed!", Emoji: "🍸", Price: 4},
+	{ID: "saurian-brandy", Name: "Saurian Brandy", Description: "Smooth, with a curved bottle to match", Emoji: "🥃", Price: 11},
+	{ID: "aldebaran-whiskey", Name: "Aldebaran Whiskey", Description: "Green, and older than you think", Emoji: "🟢", Price: 15},
+	{ID: "kanar", Name: "Kanar", Description: "Thick Cardassian liquor", Emoji: "🟤", Price: 8},
+	{ID: "raktajino", Name: "Raktajino", Description: "Klingon coffee, strong enough to fight", Emoji: "☕", Price: 4},
+	{ID: "andorian-ale", Name: "Andorian Ale", Description: "Cold as an Andorian winter", Emoji: "🧊", Price: 7},
+	{ID: "tranya", Name: "Tranya", Description: "A sweet First Federation favorite", Emoji: "🧃", Price: 5},
+	{ID: "prune-juice", Name: "Prune Juice", Description: "A warrior's drink", Emoji: "🧉", Price: 3},
+	{ID: "slug-o-cola", Name: "Slug-o-Cola", Description: "The slimiest cola in the quadrant", Emoji: "🥤", Price: 2},
+}
+
+// drinkCatalog is the bar menu in effect.
+func drinkCatalog() []Drink {
+	return currentCatalogs.Load().drinks
+}
+
+// loadDrinkCatalog reads the catalog at path, DRINK_CATALOG_FILE, falling
+// back to the default one.
+func loadDrinkCatalog(path string) []Drink {
+	if path == "" {
+		return defaultDrinkCatalog
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading drink catalog, using defaults: %v", err)
+		return defaultDrinkCatalog
+	}
+	var drinks []Drink
+	if err := json.Unmarshal(data, &drinks); err != nil || len(drinks) == 0 {
+		log.Printf("Invalid drink catalog %s, using defaults: %v", path, err)
+		return defaultDrinkCatalog
+	}
+	log.Printf("🍹 Loaded %d drinks from %s", len(drinks), path)
+	return drinks
+}
+
+func findDrink(id string) (Drink, bool) {
+	for _, d := range drinkCatalog() {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Drink{}, false
+}
+
+// maxSelectOptions is Discord's limit on options in one select menu.
+const maxSelectOptions = 25
+
+func init() {
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:        "order",
+		Description: "Order a drink from Elsie",
+	}, orderCommand)
+	registerComponentHandler("order", orderSelected)
+}
+
+// orderCommand shows the drink menu as a select menu only the customer sees.
+func orderCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if refusesChannel(s, i.GuildID, i.ChannelID) {
+		respondEphemeral(s, i, themePhrase(i.GuildID, "nsfw_refusal", nil))
+		return
+	}
+	catalog := drinkCatalog()
+	options := make([]discordgo.SelectMenuOption, 0, len(catalog))
+	for _, d := range catalog {
+		if len(options) == maxSelectOptions {
+			break
+		}
+		option := discordgo.SelectMenuOption{Label: d.Name, Value: d.ID, Description: d.Description}
+		if d.Emoji != "" {
+			option.Emoji = discordgo.ComponentEmoji{Name: d.Emoji}
+		}
+		options = append(options, option)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "🍹 *Elsie slides the menu across the bar.* What'll it be?",
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    "order:select",
+						Placeholder: "Choose a drink",
+						Options:     options,
+					},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error showing drink menu: %v", err)
+	}
+}
+
+// orderSelected sends the chosen drink to the agent as an "order" intent and
+// posts Elsie's narration in the channel.
+func orderSelected(s *discordgo.Session, i *discordgo.InteractionCreate, _ string) {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return
+	}
+	drink, ok := findDrink(values[0])
+	if !ok {
+		respondEphemeral(s, i, "*checks the shelves* That one seems to have gone off the menu.")
+		return
+	}
+	user := interactionUser(i)
+	placeOrder(s, i, drink.tabItem(), drink.Emoji, map[string]interface{}{
+		"item_type":   "drink",
+		"drink_id":    drink.ID,
+		"drink_name":  drink.Name,
+		"description": drink.Description,
+	}, fmt.Sprintf("I'd like to order a %s, please.", drink.Name),
+		fmt.Sprintf("*Elsie pours a %s and slides it over to <@%s>.* %s", drink.Name, user.ID, drink.Emoji))
+}
+
+// placeOrder serves an order picked from a menu: it swaps the menu for a
+// confirmation, puts the item on the customer's tab, and posts the agent's
+// narration of request, or fallback when the agent has nothing.
+func placeOrder(s *discordgo.Session, i *discordgo.InteractionCreate, item TabItem, emoji string, order map[string]interface{}, request, fallback string) {
+	// Acknowledge right away and swap the menu for a confirmation; the agent
+	// may take longer than Discord's 3 second interaction window.
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("%s Order placed: **%s**", emoji, item.Name),
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error acknowledging order: %v", err)
+	}
+
+	user := interactionUser(i)
+	rememberOrder(i.ChannelID, user.ID, item)
+	ctx := baseContext(s, i.ChannelID, i.GuildID, user)
+	ctx["intent"] = "order"
+	ctx["order"] = order
+	if i.GuildID != "" {
+		if tab, err := addToTab(i.GuildID, user.ID, item); err != nil {
+			log.Printf("Error putting %s on %s's tab: %v", item.id(), user.ID, err)
+		} else {
+			ctx["tab"] = tabContext(item, tab)
+		}
+	}
+	rlog := requestLog{id: newRequestID()}
+	ctx["request_id"] = rlog.id
+	message := Message{
+		Message:   request,
+		Context:   ctx,
+		RequestID: rlog.id,
+	}
+
+	s.ChannelTyping(i.ChannelID)
+	aiResponse, err := callAgent(message)
+	if err != nil || strings.TrimSpace(aiResponse.Response) == "" || aiResponse.silent() {
+		if err != nil {
+			log.Printf("Error processing order: %v", err)
+		}
+		s.ChannelMessageSend(i.ChannelID, fallback)
+		return
+	}
+
+	response, deliver := screenContent(s, i.GuildID, i.ChannelID, user.ID, "outbound", aiResponse.Response)
+	if !deliver {
+		response = fallback
+	}
+	if _, err := sendChunks(s, i.ChannelID, response); err != nil {
+		log.Printf("Error sending order response: %v", err)
+		return
+	}
+	scheduleFollowUp(aiResponse, i.GuildID, i.ChannelID, user.ID, defaultPersona(), rlog)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Response actions an agent can choose in its envelope.
+const (
+	responseReply  = "reply"
+	responseSilent = "silent"
+	responseReact  = "react"
+	responseDefer  = "defer"
+)
+
+// legacyNoResponse is the magic response text older agents send to stay
+// quiet. It is read as action "silent".
+const legacyNoResponse = "NO_RESPONSE"
+
+// action returns what the agent wants done with this response. Agents
+// without the envelope reply, or stay silent with NO_RESPONSE. An unknown
+// action is treated as a reply so a newer agent's text isn't lost.
+func (r *AIResponse) action() string {
+	if r == nil {
+		return ""
+	}
+	switch a := strings.ToLower(strings.TrimSpace(r.Action)); a {
+	case responseSilent, responseDefer:
+		return a
+	case responseReact:
+		if r.ReactionEmoji == "" {
+			return responseSilent
+		}
+		return a
+	}
+	if r.Response == legacyNoResponse {
+		return responseSilent
+	}
+	return responseReply
+}
+
+// silent reports whether the agent chose not to post. Callers with no
+// message to react to treat a reaction as silence too.
+func (r *AIResponse) silent() bool {
+	a := r.action()
+	return a == responseSilent || a == responseReact
+}
+
+// replyDelay is how long a deferred reply waits, capped by
+// RESPONSE_MAX_DELAY. Plain replies go out at once.
+func (r *AIResponse) replyDelay() time.Duration {
+	if r.action() != responseDefer || r.DelayMS <= 0 {
+		return 0
+	}
+	return min(time.Duration(r.DelayMS)*time.Millisecond, config().ResponseMaxDelay)
+}
+
+// waitTyping holds a deferred reply for d, keeping the typing indicator up
+// so the channel sees Elsie composing her answer.
+func waitTyping(s *discordgo.Session, channelID string, d time.Duration) {
+	deadline := time.Now().Add(d)
+	for {
+		s.ChannelTyping(channelID)
+		left := time.Until(deadline)
+		if left <= 0 {
+			return
+		}
+		// Discord shows typing for about ten seconds
+		time.Sleep(min(left, 8*time.Second))
+	}
+}
+
+// reactionAPIName turns the emoji an agent asked for into the form the
+// reactions API takes: Unicode as is, custom emoji as name:id. Known
+// :shortcodes: become their Unicode emoji.
+func reactionAPIName(emoji string) string {
+	emoji = strings.TrimSpace(emoji)
+	if name, ok := strings.CutPrefix(emoji, ":"); ok && strings.Count(emoji, ":") == 2 {
+		if u, ok := emojiShortcodes[strings.ToLower(strings.TrimSuffix(name, ":"))]; ok {
+			return u
+		}
+	}
+	emoji = strings.TrimSuffix(strings.TrimPrefix(emoji, "<"), ">")
+	emoji = strings.TrimPrefix(emoji, "a:")
+	return strings.TrimPrefix(emoji, ":")
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// dataEraser removes one subsystem's stored data about a user or a guild
+// and reports how many records went. Either func may be nil when the
+// subsystem keeps no such data. Features that persist anything about
+// players register an eraser so `!elsie forget me` and `!elsie purge-data`
+// stay complete.
+type dataEraser struct {
+	name  string
+	user  func(s *discordgo.Session, userID string) (int, error)
+	guild func(s *discordgo.Session, guildID string) (int, error)
+}
+
+var dataErasers []dataEraser
+
+func registerDataEraser(e dataEraser) {
+	dataErasers = append(dataErasers, e)
+}
+
+func init() {
+	registerDataEraser(dataEraser{name: "profile", user: eraseProfile})
+	registerDataEraser(dataEraser{name: "tabs", user: eraseGuildMapEntry(tabBucket, &tabsMu), guild: eraseGuildKey(tabBucket)})
+	registerDataEraser(dataEraser{name: "trivia scores", user: eraseGuildMapEntry(triviaBucket, &triviaMu), guild: eraseGuildKey(triviaBucket)})
+	registerDataEraser(dataEraser{name: "follow-ups", user: eraseFollowUps("user"), guild: eraseFollowUps("guild")})
+	registerDataEraser(dataEraser{name: "reports", user: eraseReports("user"), guild: eraseReports("guild")})
+	registerDataEraser(dataEraser{name: "scene archive", user: eraseScenePlayer, guild: eraseGuildKey(sceneArchiveBucket)})
+	registerDataEraser(dataEraser{name: "scenes", guild: eraseChannelKeys(sceneBucket)})
+	registerDataEraser(dataEraser{name: "initiative", guild: eraseChannelKeys(initiativeBucket)})
+	registerDataEraser(dataEraser{name: "pinned recaps", guild: eraseChannelKeys(pinnedRecapBucket)})
+	registerDataEraser(dataEraser{name: "sessions", user: eraseDMSessions, guild: eraseGuildSessions})
+	registerDataEraser(dataEraser{name: "DM topics", user: eraseDMTopics})
+	registerDataEraser(dataEraser{name: "exchange log", user: eraseExchanges("user"), guild: eraseExchanges("guild")})
+	registerDataEraser(dataEraser{name: "audit log", user: eraseDecisions("user"), guild: eraseDecisions("guild")})
+	registerDataEraser(dataEraser{name: "usage stats", guild: eraseGuildStats})
+	registerDataEraser(dataEraser{name: "schedules", guild: eraseGuildKey(scheduleBucket)})
+	registerDataEraser(dataEraser{name: "digest", guild: eraseGuildKey(digestBucket)})
+	registerDataEraser(dataEraser{name: "onboarding", guild: eraseGuildKey(onboardingBucket)})
+	registerDataEraser(dataEraser{name: "config history", guild: eraseGuildKey(guildConfigHistoryBucket)})
+	registerDataEraser(dataEraser{name: "settings", guild: eraseGuildConfig})
+	registerCommand(command{name: "purge-data", handler: purgeDataCommand})
+	registerCommand(command{name: "telemetry", handler: telemetryCommand})
+}
+
+// telemetryAllowed reports whether usage data may be recorded for a guild:
+// the exchange log and usage stats. DMs follow TELEMETRY_ENABLED alone.
+func telemetryAllowed(guildID string) bool {
+	return config().TelemetryEnabled && !loadGuildConfig(guildID).TelemetryOff
+}
+
+// erasure is the outcome of running every eraser.
+type erasure struct {
+	counts map[string]int
+	failed []string
+}
+
+func (e erasure) summary() string {
+	var parts []string
+	for _, d := range dataErasers {
+		if n := e.counts[d.name]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s (%d)", d.name, n))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing was on file"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// eraseUser removes everything stored about userID and asks the agents to
+// forget the user too.
+func eraseUser(s *discordgo.Session, userID string) erasure {
+	result := erasure{counts: map[string]int{}}
+	for _, d := range dataErasers {
+		if d.user == nil {
+			continue
+		}
+		n, err := d.user(s, userID)
+		if err != nil {
+			log.Printf("Error erasing %s for %s: %v", d.name, logUser("", userID), err)
+			result.failed = append(result.failed, d.name)
+		}
+		result.counts[d.name] += n
+	}
+	forgetAtAgents(map[string]interface{}{"user_id": userID}, &result)
+	return result
+}
+
+// eraseGuild removes everything stored about guildID, keeping only a
+// telemetry opt-out, and asks the agents to forget the guild too.
+func eraseGuild(s *discordgo.Session, guildID string) erasure {
+	result := erasure{counts: map[string]int{}}
+	for _, d := range dataErasers {
+		if d.guild == nil {
+			continue
+		}
+		n, err := d.guild(s, guildID)
+		if err != nil {
+			log.Printf("Error erasing %s for guild %s: %v", d.name, guildID, err)
+			result.failed = append(result.failed, d.name)
+		}
+		result.counts[d.name] += n
+	}
+	forgetAtAgents(map[string]interface{}{"guild_id": guildID}, &result)
+	return result
+}
+
+// forgetAtAgents sends POST /forget to every agent pool that negotiated the
+// feature, so agent-side memory goes too.
+func forgetAtAgents(payload map[string]interface{}, result *erasure) {
+	pools := allAgentPools()
+	requestID := newRequestID()
+	payload["request_id"] = requestID
+	rlog := requestLog{id: requestID}
+	for _, pool := range pools {
+		if pool == nil || !pool.supports(featureForget) {
+			continue
+		}
+		_, err := pool.call(requestID, "/forget", payload)
+		var rejected *agentRejectedError
+		switch {
+		case errors.As(err, &rejected):
+			rlog.Printf("Agent does not support forgetting: %v", err)
+		case err != nil:
+			rlog.Printf("Error asking the agent to forget: %v", err)
+			result.failed = append(result.failed, "agent memory")
+		default:
+			result.counts["agent memory"]++
+		}
+	}
+}
+
+func eraseProfile(s *discordgo.Session, userID string) (int, error) {
+	if loadProfile(userID) == nil {
+		return 0, nil
+	}
+	return 1, store.Delete(profileBucket, userID)
+}
+
+// eraseGuildKey deletes a bucket entry keyed by guild ID.
+func eraseGuildKey(bucket string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, guildID string) (int, error) {
+		var raw json.RawMessage
+		if ok, err := store.Get(bucket, guildID, &raw); err != nil || !ok {
+			return 0, err
+		}
+		return 1, store.Delete(bucket, guildID)
+	}
+}
+
+// eraseGuildMapEntry removes the user from every guild's map in a bucket
+// keyed by guild, then by user ID. mu, if set, guards the bucket.
+func eraseGuildMapEntry(bucket string, mu sync.Locker) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, userID string) (int, error) {
+		if mu != nil {
+			mu.Lock()
+			defer mu.Unlock()
+		}
+		n := 0
+		for _, guildID := range store.Keys(bucket) {
+			entries := map[string]json.RawMessage{}
+			if _, err := store.Get(bucket, guildID, &entries); err != nil {
+				return n, err
+			}
+			if _, ok := entries[userID]; !ok {
+				continue
+			}
+			delete(entries, userID)
+			if err := store.Put(bucket, guildID, entries); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+// eraseChannelKeys deletes a bucket's entries keyed by a channel of the
+// guild. Keys may carry a ":persona" suffix, as session IDs do.
+func eraseChannelKeys(bucket string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, guildID string) (int, error) {
+		n := 0
+		for _, key := range store.Keys(bucket) {
+			channelID, _, _ := strings.Cut(key, ":")
+			channel, err := getChannel(s, channelID)
+			if err != nil || channel.GuildID != guildID {
+				continue
+			}
+			if err := store.Delete(bucket, key); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+// eraseGuildSessions drops the memory checkpoints of the guild's channel
+// sessions, in memory and in the store.
+func eraseGuildSessions(s *discordgo.Session, guildID string) (int, error) {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	for sessionID := range checkpoints {
+		channelID, _, _ := strings.Cut(sessionID, ":")
+		if channel, err := getChannel(s, channelID); err == nil && channel.GuildID == guildID {
+			delete(checkpoints, sessionID)
+		}
+	}
+	return eraseChannelKeys(memoryCheckpointBucket)(s, guildID)
+}
+
+func eraseFollowUps(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		followUpMu.Lock()
+		defer followUpMu.Unlock()
+		n := 0
+		for _, f := range loadFollowUps() {
+			if (scope == "user" && f.UserID != id) || (scope == "guild" && f.GuildID != id) {
+				continue
+			}
+			if err := store.Delete(followUpBucket, f.RequestID); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+func eraseReports(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		n := 0
+		for _, key := range store.Keys(reportBucket) {
+			r, ok := loadReport(key)
+			if !ok || (scope == "user" && r.ReporterID != id) || (scope == "guild" && r.GuildID != id) {
+				continue
+			}
+			if err := store.Delete(reportBucket, key); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+// eraseScenePlayer drops the user's rows from archived scene stats. The
+// scenes' totals stay, so campaign numbers still add up.
+func eraseScenePlayer(s *discordgo.Session, userID string) (int, error) {
+	sceneMu.Lock()
+	defer sceneMu.Unlock()
+	n := 0
+	for _, guildID := range store.Keys(sceneArchiveBucket) {
+		var archive []SceneArchive
+		if _, err := store.Get(sceneArchiveBucket, guildID, &archive); err != nil {
+			return n, err
+		}
+		changed := false
+		for i := range archive {
+			players := archive[i].Stats.Players[:0]
+			for _, p := range archive[i].Stats.Players {
+				if p.UserID == userID {
+					changed = true
+					n++
+					continue
+				}
+				players = append(players, p)
+			}
+			archive[i].Stats.Players = players
+			if archive[i].StartedBy == userID {
+				archive[i].StartedBy = ""
+			}
+		}
+		if changed {
+			if err := store.Put(sceneArchiveBucket, guildID, archive); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// eraseDMSessions drops the memory checkpoints of the user's DM sessions
+// with every persona.
+func eraseDMSessions(s *discordgo.Session, userID string) (int, error) {
+	dm, err := s.UserChannelCreate(userID)
+	if err != nil {
+		return 0, err
+	}
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	n := 0
+	for _, p := range personas {
+		sessionID := p.sessionIDFor(dm.ID)
+		delete(checkpoints, sessionID)
+		delete(compactionFailures, sessionID)
+		if ok, _ := store.Get(memoryCheckpointBucket, sessionID, &MemoryCheckpoint{}); !ok {
+			continue
+		}
+		if err := store.Delete(memoryCheckpointBucket, sessionID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func eraseExchanges(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		return exchanges.erase(func(rec exchangeRecord) bool {
+			if scope == "guild" {
+				return rec.GuildID == id
+			}
+			return rec.AuthorID == id || rec.AuthorID == logUser("", id)
+		})
+	}
+}
+
+// erase rewrites the log without the records drop matches.
+func (l *exchangeLog) erase(drop func(exchangeRecord) bool) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines, err := l.lines()
+	if err != nil {
+		return 0, err
+	}
+	kept := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		var rec exchangeRecord
+		if err := json.Unmarshal(line, &rec); err == nil && drop(rec) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	n := len(lines) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, l.replace(kept)
+}
+
+func eraseDecisions(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		return decisions.drop(func(d messageDecision) bool {
+			if scope == "guild" {
+				return d.GuildID == id
+			}
+			return d.AuthorID == id
+		}), nil
+	}
+}
+
+// drop removes matching decisions, keeping the rest in order.
+func (r *decisionRing) drop(match func(messageDecision) bool) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := append(append([]messageDecision{}, r.items[r.next:]...), r.items[:r.next]...)
+	kept := ordered[:0]
+	for _, d := range ordered {
+		if !match(d) {
+			kept = append(kept, d)
+		}
+	}
+	n := len(ordered) - len(kept)
+	r.items, r.next = kept, 0
+	return n
+}
+
+func eraseGuildStats(s *discordgo.Session, guildID string) (int, error) {
+	guildStats.drop(guildID)
+	return eraseGuildKey(guildStatsBucket)(s, guildID)
+}
+
+// eraseGuildConfig deletes the guild's settings, keeping a telemetry
+// opt-out so a purge doesn't turn recording back on.
+func eraseGuildConfig(s *discordgo.Session, guildID string) (int, error) {
+	guildConfigMu.Lock()
+	defer guildConfigMu.Unlock()
+	cfg := loadGuildConfig(guildID)
+	n, err := eraseGuildKey(guildConfigBucket)(s, guildID)
+	guildConfigChanged(guildID)
+	if err != nil || !cfg.TelemetryOff {
+		return n, err
+	}
+	return n, store.Put(guildConfigBucket, guildID, &GuildConfig{TelemetryOff: true})
+}
+
+// forgetMe is `!elsie forget me`: erase everything stored about the user.
+func forgetMe(ctx *commandContext) {
+	result := eraseUser(ctx.s, ctx.m.Author.ID)
+	log.Printf("🧹 Erased stored data for %s: %s", logUser("", ctx.m.Author.ID), result.summary())
+	metrics.Inc(metricLabel("data_erasures_total", "scope", "user"))
+	if len(result.failed) > 0 {
+		ctx.reply(fmt.Sprintf("*holographic matrix flickers* I forgot most of it, but couldn't clear: %s. Please try again later.", strings.Join(result.failed, ", ")))
+		return
+	}
+	ctx.reply(fmt.Sprintf("*wipes the slate clean* I've forgotten you — %s. Messages you posted in channels stay on Discord.", result.summary()))
+}
+
+// purgeDataCommand is `!elsie purge-data [confirm]`: erase everything
+// stored about this server.
+func purgeDataCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Purging data is per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can purge the server's data.")
+		return
+	}
+	if len(ctx.args) == 0 || !strings.EqualFold(ctx.args[0], "confirm") {
+		ctx.reply("⚠️ This deletes everything I've stored about this server: settings, tabs, trivia scores, scenes, schedules, reports, logs, usage stats and my memory of its channels. It can't be undone.\n" +
+			"Run `!elsie purge-data confirm` to go ahead.")
+		return
+	}
+	result := eraseGuild(ctx.s, ctx.m.GuildID)
+	log.Printf("🧹 %s purged stored data for guild %s: %s", logUser("", ctx.m.Author.ID), ctx.m.GuildID, result.summary())
+	metrics.Inc(metricLabel("data_erasures_total", "scope", "guild"))
+	if len(result.failed) > 0 {
+		ctx.reply(fmt.Sprintf("*holographic matrix flickers* I purged most of it, but couldn't clear: %s. Please try again later.", strings.Join(result.failed, ", ")))
+		return
+	}
+	ctx.reply(fmt.Sprintf("🧹 Purged: %s. Settings are back to the defaults.", result.summary()))
+}
+
+// telemetryCommand is `!elsie telemetry [on|off]`: whether the server's
+// usage is recorded in the exchange log and usage stats.
+func telemetryCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Telemetry is per server — use this command in a server channel.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		state := "on"
+		if !telemetryAllowed(ctx.m.GuildID) {
+			state = "off"
+		}
+		ctx.reply(fmt.Sprintf("📡 **Telemetry:** %s. When on, I keep an exchange log for `!elsie trace` and usage stats for the weekly digest.\nUsage: `!elsie telemetry on|off`", state))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change telemetry.")
+		return
+	}
+	var off bool
+	switch strings.ToLower(ctx.args[0]) {
+	case "on":
+	case "off":
+		off = true
+	default:
+		ctx.reply("Usage: `!elsie telemetry on|off`")
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.TelemetryOff = off }); err != nil {
+		log.Printf("Error saving telemetry setting: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	switch {
+	case off:
+		ctx.reply("📡 Telemetry is off. I won't log exchanges or usage stats for this server. `!elsie purge-data` clears what's already stored.")
+	case !config().TelemetryEnabled:
+		ctx.reply("📡 Telemetry is on for this server, but the operator has turned it off for the whole bot.")
+	default:
+		ctx.reply("📡 Telemetry is on.")
+	}
+}
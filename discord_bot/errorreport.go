@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Kinds of reported errors, sent as the event's exception type and its
+// "kind" tag.
+const (
+	errorKindPanic   = "panic"
+	errorKindAgent   = "agent"
+	errorKindDiscord = "discord"
+)
+
+// errorReportInterval is the minimum gap between reports of the same error,
+// so an outage doesn't send one event per message.
+const errorReportInterval = time.Minute
+
+// errorEvent is a Sentry store API event. Only IDs and error text go in it,
+// never message content.
+type errorEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Message     string            `json:"message"`
+	Exception   errorException    `json:"exception"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type errorException struct {
+	Values []errorExceptionValue `json:"values"`
+}
+
+type errorExceptionValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sentryTarget is where events are posted, parsed from SENTRY_DSN.
+type sentryTarget struct {
+	storeURL string
+	key      string
+}
+
+var (
+	errorReporter     *sentryTarget
+	errorReportQueue  = make(chan errorEvent, 100)
+	errorReporterOnce sync.Once
+
+	// errorReports remembers when each error was last reported.
+	errorReports = newLRUCache[string, time.Time]("error_reports", 1000, time.Hour)
+)
+
+func init() {
+	trackCache(errorReports)
+}
+
+// parseSentryDSN turns a DSN such as https://<key>@o1.ingest.sentry.io/42
+// into the project's store endpoint and key.
+func parseSentryDSN(dsn string) (*sentryTarget, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("missing public key")
+	}
+	i := strings.LastIndex(u.Path, "/")
+	prefix, project := u.Path[:max(i, 0)], u.Path[i+1:]
+	if project == "" {
+		return nil, errors.New("missing project ID")
+	}
+	return &sentryTarget{
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		key:      u.User.Username(),
+	}, nil
+}
+
+// initErrorReporting enables reporting when SENTRY_DSN is set and valid.
+func initErrorReporting() {
+	if config().SentryDSN == "" {
+		return
+	}
+	target, err := parseSentryDSN(config().SentryDSN)
+	if err != nil {
+		log.Printf("Invalid SENTRY_DSN, error reporting is off: %v", err)
+		return
+	}
+	errorReporter = target
+	errorReporterOnce.Do(func() { go runErrorReporter() })
+	log.Printf("🚨 Reporting errors to %s", target.storeURL)
+}
+
+// reportError sends err to the error reporter in the background. tags
+// should only carry IDs; user IDs are pseudonymized here. extra holds
+// longer details, such as a panic's stack.
+func reportError(kind string, err error, tags, extra map[string]string) {
+	if errorReporter == nil || err == nil {
+		return
+	}
+	fingerprint := kind + ":" + err.Error()
+	if last, ok := errorReports.Get(fingerprint); ok && time.Since(last) < errorReportInterval {
+		return
+	}
+	errorReports.Add(fingerprint, time.Now())
+
+	level := "error"
+	if kind == errorKindPanic {
+		level = "fatal"
+	}
+	event := errorEvent{
+		EventID:     newRequestID() + newRequestID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       level,
+		Platform:    "go",
+		Logger:      "elsie",
+		ServerName:  instanceID,
+		Environment: config().SentryEnvironment,
+		Release:     config().SentryRelease,
+		Message:     err.Error(),
+		Exception:   errorException{Values: []errorExceptionValue{{Type: kind, Value: err.Error()}}},
+		Tags:        map[string]string{"kind": kind},
+		Extra:       extra,
+	}
+	for k, v := range tags {
+		if v == "" {
+			continue
+		}
+		if k == "user_id" {
+			k, v = "user", "user:"+privacyHashOf(v)
+		}
+		event.Tags[k] = v
+	}
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		if restErr.Response != nil {
+			event.Tags["http_status"] = strconv.Itoa(restErr.Response.StatusCode)
+		}
+		if restErr.Message != nil && restErr.Message.Code != 0 {
+			event.Tags["discord_code"] = strconv.Itoa(restErr.Message.Code)
+		}
+	}
+
+	select {
+	case errorReportQueue <- event:
+	default:
+		metrics.Inc("error_reports_dropped_total")
+	}
+}
+
+// runErrorReporter posts queued events one at a time.
+func runErrorReporter() {
+	for event := range errorReportQueue {
+		if err := sendErrorEvent(event); err != nil {
+			log.Printf("Error sending error report: %v", err)
+			metrics.Inc(metricLabel("error_reports_total", "result", "failed"))
+			continue
+		}
+		metrics.Inc(metricLabel("error_reports_total", "result", "sent"))
+	}
+}
+
+func sendErrorEvent(event errorEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, errorReporter.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=elsie-bot/1.0, sentry_key=%s", errorReporter.key))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// flushErrorReports gives queued events a moment to go out on shutdown.
+func flushErrorReports(timeout time.Duration) {
+	if errorReporter == nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	for len(errorReportQueue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Exchange outcomes recorded in the exchange log.
+const (
+	exchangeSent       = "sent"
+	exchangeNoResponse = "no_response"
+	exchangeFallback   = "fallback"
+	exchangeSendError  = "send_error"
+	exchangeDMFallback = "dm_fallback"
+	exchangeReacted    = "reacted"
+)
+
+// exchangeRecord links a player's Discord message to the agent request it
+// triggered and the messages the bot posted in reply.
+type exchangeRecord struct {
+	Time               time.Time `json:"time"`
+	RequestID          string    `json:"request_id"`
+	GuildID            string    `json:"guild_id,omitempty"`
+	ChannelID          string    `json:"channel_id"`
+	MessageID          string    `json:"message_id"`
+	MergedMessageIDs   []string  `json:"merged_message_ids,omitempty"`
+	AuthorID           string    `json:"author_id"`
+	Persona            string    `json:"persona"`
+	AgentSessionID     string    `json:"agent_session_id,omitempty"`
+	ResponseMessageIDs []string  `json:"response_message_ids,omitempty"`
+	Outcome            string    `json:"outcome"`
+}
+
+// exchangeLog is an append-only JSON-lines file. Records are written once
+// and only read for rare trace lookups, so it stays out of the main store,
+// which is rewritten on every change. Old records are pruned on a schedule;
+// see runExchangeLogPruner.
+type exchangeLog struct {
+	mu   sync.Mutex
+	path string
+	// replies maps the bot's reply message IDs to the offset of their
+	// record, so feedback reactions never scan the file.
+	replies map[string]int64
+}
+
+var exchanges *exchangeLog
+
+func openExchangeLog(path string) (*exchangeLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating exchange log directory: %w", err)
+	}
+	l := &exchangeLog{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading exchange log: %w", err)
+	}
+	l.indexReplies(data)
+	return l, nil
+}
+
+// indexReplies rebuilds l.replies from the log's contents. Callers must
+// hold l.mu, or own l.
+func (l *exchangeLog) indexReplies(data []byte) {
+	l.replies = make(map[string]int64)
+	var offset int64
+	for _, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		var rec exchangeRecord
+		if json.Unmarshal(line, &rec) == nil {
+			for _, id := range rec.ResponseMessageIDs {
+				l.replies[id] = offset
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// record appends rec to the log. Author IDs are pseudonymized when privacy
+// logging is on.
+func (l *exchangeLog) record(rec exchangeRecord) {
+	if l == nil || !telemetryAllowed(rec.GuildID) {
+		return
+	}
+	if config().PrivacyLogging != privacyOff {
+		rec.AuthorID = logUser("", rec.AuthorID)
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("Error encoding exchange %s: %v", rec.RequestID, err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Error opening exchange log: %v", err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("Error opening exchange log: %v", err)
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Error writing exchange %s: %v", rec.RequestID, err)
+		return
+	}
+	for _, id := range rec.ResponseMessageIDs {
+		l.replies[id] = info.Size()
+	}
+}
+
+// reply returns the exchange that posted the bot message messageID.
+func (l *exchangeLog) reply(messageID string) (exchangeRecord, bool) {
+	if l == nil {
+		return exchangeRecord{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	offset, ok := l.replies[messageID]
+	if !ok {
+		return exchangeRecord{}, false
+	}
+	f, err := os.Open(l.path)
+	if err != nil {
+		log.Printf("Error opening exchange log: %v", err)
+		return exchangeRecord{}, false
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(io.NewSectionReader(f, offset, 1024*1024)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		log.Printf("Error reading exchange log: %v", err)
+		return exchangeRecord{}, false
+	}
+	var rec exchangeRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return exchangeRecord{}, false
+	}
+	return rec, true
+}
+
+// find returns the records whose request, message or response IDs match id,
+// limited to guildID unless it is empty.
+func (l *exchangeLog) find(id, guildID string) ([]exchangeRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found []exchangeRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if !strings.Contains(scanner.Text(), id) {
+			continue
+		}
+		var rec exchangeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if guildID != "" && rec.GuildID != guildID {
+			continue
+		}
+		if rec.matches(id) {
+			found = append(found, rec)
+		}
+	}
+	return found, scanner.Err()
+}
+
+// recent returns up to limit records accepted by keep, newest first.
+func (l *exchangeLog) recent(limit int, keep func(rec exchangeRecord) bool) ([]exchangeRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var found []exchangeRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec exchangeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || !keep(rec) {
+			continue
+		}
+		found = append(found, rec)
+		if len(found) > limit {
+			found = found[1:]
+		}
+	}
+	slices.Reverse(found)
+	return found, scanner.Err()
+}
+
+// lines returns the log's records as raw JSON lines. Callers must hold l.mu.
+func (l *exchangeLog) lines() ([][]byte, error) {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// replace rewrites the log with lines. Callers must hold l.mu.
+func (l *exchangeLog) replace(lines [][]byte) error {
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	l.indexReplies(b.Bytes())
+	return nil
+}
+
+// prune drops the records older than retention, then the oldest records
+// until the log fits in maxBytes. Zero disables either limit.
+func (l *exchangeLog) prune(retention time.Duration, maxBytes int) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines, err := l.lines()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-retention)
+	kept := make([][]byte, 0, len(lines))
+	size := 0
+	for _, line := range lines {
+		var rec exchangeRecord
+		if retention > 0 && json.Unmarshal(line, &rec) == nil && rec.Time.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, line)
+		size += len(line) + 1
+	}
+	for maxBytes > 0 && size > maxBytes && len(kept) > 0 {
+		size -= len(kept[0]) + 1
+		kept = kept[1:]
+	}
+	n := len(lines) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, l.replace(kept)
+}
+
+// runExchangeLogPruner applies EXCHANGE_LOG_RETENTION and
+// EXCHANGE_LOG_MAX_BYTES at startup and then every interval.
+func runExchangeLogPruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c := config()
+		if n, err := exchanges.prune(c.ExchangeLogRetention, c.ExchangeLogMaxBytes); err != nil {
+			log.Printf("Error pruning exchange log: %v", err)
+		} else if n > 0 {
+			log.Printf("🧹 Pruned %d old exchanges from the exchange log", n)
+			metrics.Add("exchange_log_pruned_total", float64(n))
+		}
+		<-ticker.C
+	}
+}
+
+func (rec exchangeRecord) matches(id string) bool {
+	if rec.RequestID == id || rec.MessageID == id {
+		return true
+	}
+	for _, responseID := range rec.ResponseMessageIDs {
+		if responseID == id {
+			return true
+		}
+	}
+	for _, mergedID := range rec.MergedMessageIDs {
+		if mergedID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func messageIDs(msgs []*discordgo.Message) []string {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+// parseTraceID accepts a request ID, a message ID or a message link.
+func parseTraceID(arg string) string {
+	arg = strings.Trim(arg, "<>")
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		arg = arg[i+1:]
+	}
+	return arg
+}
+
+func init() {
+	registerCommand(command{name: "trace", handler: traceCommand})
+	registerEndpoint("trace", "/trace", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := parseTraceID(r.URL.Query().Get("id"))
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		records, err := exchanges.find(id, r.URL.Query().Get("guild_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if records == nil {
+			records = []exchangeRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	}), apiOperation{
+		Method:  http.MethodGet,
+		Summary: "Find the exchanges behind a request ID, message ID or message link",
+		Query: []apiParam{
+			{Name: "id", Type: "string", Required: true, Description: "Request ID, message ID or message link"},
+			{Name: "guild_id", Type: "string", Description: "Limit results to one guild"},
+		},
+		Response: []exchangeRecord{},
+	})
+}
+
+// traceCommand is `!elsie trace <message-id|request-id|link>`, or a reply to
+// a message with `!elsie trace`.
+func traceCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Traces are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can trace exchanges.")
+		return
+	}
+	id := ""
+	if len(ctx.args) > 0 {
+		id = parseTraceID(ctx.args[0])
+	} else if ctx.m.MessageReference != nil {
+		id = ctx.m.MessageReference.MessageID
+	}
+	if id == "" {
+		ctx.reply("Usage: `!elsie trace <message-id|request-id|message link>`, or reply to a message with `!elsie trace`")
+		return
+	}
+
+	guildID := ctx.m.GuildID
+	if isBotOwner(ctx.m.Author.ID) {
+		guildID = ""
+	}
+	records, err := exchanges.find(id, guildID)
+	if err != nil {
+		log.Printf("Error searching exchange log: %v", err)
+		ctx.reply("*holographic matrix flickers* I couldn't read the exchange log.")
+		return
+	}
+	if len(records) == 0 {
+		ctx.reply(fmt.Sprintf("🔎 No exchange found for `%s`.", id))
+		return
+	}
+
+	var b strings.Builder
+	for _, rec := range records {
+		author := rec.AuthorID
+		if !strings.HasPrefix(author, "user:") {
+			author = "<@" + author + ">"
+		}
+		responses := "none"
+		if len(rec.ResponseMessageIDs) > 0 {
+			responses = strings.Join(rec.ResponseMessageIDs, ", ")
+		}
+		fmt.Fprintf(&b, "🔎 **Request `%s`** (%s)\n• Player message: `%s` in <#%s> by %s\n• Persona: %s • Agent session: `%s`\n• Outcome: %s • Bot messages: %s\n",
+			rec.RequestID, rec.Time.UTC().Format("2006-01-02 15:04:05 MST"), rec.MessageID, rec.ChannelID,
+			author, rec.Persona, rec.AgentSessionID, rec.Outcome, responses)
+	}
+	if _, err := sendChunks(ctx.s, ctx.m.ChannelID, b.String()); err != nil {
+		log.Printf("Error sending trace: %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// guildArchiveVersion is bumped when the archive layout changes in a way
+	// older imports can't read.
+	guildArchiveVersion = 1
+	// guildArchiveMaxBytes caps an archive downloaded for import.
+	guildArchiveMaxBytes = 8 << 20
+)
+
+// guildArchive is a guild's data as written by `!elsie export`. Channels
+// and roles map the source guild's IDs to names, so an import into another
+// server can point settings at the channels and roles of the same name.
+type guildArchive struct {
+	Version    int                        `json:"version"`
+	GuildID    string                     `json:"guild_id"`
+	GuildName  string                     `json:"guild_name,omitempty"`
+	ExportedAt time.Time                  `json:"exported_at"`
+	Channels   map[string]string          `json:"channels,omitempty"`
+	Roles      map[string]string          `json:"roles,omitempty"`
+	Config     *GuildConfig               `json:"config"`
+	Data       map[string]json.RawMessage `json:"data,omitempty"`
+}
+
+// archivedBucket is per-guild data, stored under the guild's ID, that
+// travels with an export. mu, if set, guards the bucket.
+type archivedBucket struct {
+	name   string
+	bucket string
+	mu     sync.Locker
+}
+
+// archivedBuckets are the buckets an export carries besides the settings.
+// The drink and food menus are shared by every server (DRINK_CATALOG_FILE,
+// FOOD_CATALOG_FILE), so they aren't among them.
+var archivedBuckets = []archivedBucket{
+	{name: "tabs", bucket: tabBucket, mu: &tabsMu},
+	{name: "karma", bucket: karmaBucket, mu: &karmaMu},
+	{name: "trivia scores", bucket: triviaBucket, mu: &triviaMu},
+	{name: "schedules", bucket: scheduleBucket, mu: &schedulesMu},
+	{name: "scene archive", bucket: sceneArchiveBucket, mu: &sceneMu},
+}
+
+func init() {
+	registerCommand(command{name: "export", handler: exportCommand})
+	registerCommand(command{name: "import", handler: importCommand})
+}
+
+// exportCommand is `!elsie export [dm]`: the server's settings and data as
+// a JSON file, posted in the channel or sent by DM.
+func exportCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Exports are per server; run this in the server you want to export."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can export my data for this server."))
+		return
+	}
+	archive, err := buildGuildArchive(ctx.s, ctx.m.GuildID)
+	if err != nil {
+		log.Printf("Error exporting guild %s: %v", ctx.m.GuildID, err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't export this server: %v.", err))
+		return
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding the export of guild %s: %v", ctx.m.GuildID, err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't export this server: %v.", err))
+		return
+	}
+
+	channelID := ctx.m.ChannelID
+	if len(ctx.args) > 0 && strings.EqualFold(ctx.args[0], "dm") {
+		dm, err := ctx.s.UserChannelCreate(ctx.m.Author.ID)
+		if err != nil {
+			ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't open a DM with you: %v.", err))
+			return
+		}
+		channelID = dm.ID
+	}
+	name := fmt.Sprintf("elsie-%s-%s.json", ctx.m.GuildID, archive.ExportedAt.Format("20060102-150405"))
+	_, err = ctx.s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content: ctx.tr("📦 Export of **%s**: %s. Restore it with `!elsie import` and this file attached.", archive.GuildName, archiveSummary(archive)),
+		Files:   []*discordgo.File{{Name: name, ContentType: "application/json", Reader: bytes.NewReader(data)}},
+	})
+	if err != nil {
+		log.Printf("Error sending the export of guild %s: %v", ctx.m.GuildID, err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't send the export: %v.", err))
+		return
+	}
+	if channelID != ctx.m.ChannelID {
+		ctx.reply(ctx.tr("📦 Sent you the export by DM."))
+	}
+	log.Printf("📦 Guild %s exported by %s", ctx.m.GuildID, logUser("", ctx.m.Author.ID))
+	metrics.Inc(metricLabel("guild_transfers_total", "direction", "export"))
+}
+
+// importCommand is `!elsie import` with an export attached: it replaces
+// the server's settings and data with the archive's.
+func importCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Imports are per server; run this in the server you want to restore into."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can import data into this server."))
+		return
+	}
+	if len(ctx.m.Attachments) == 0 {
+		ctx.reply(ctx.tr("Attach a file from `!elsie export` to `!elsie import`. It replaces this server's settings, tabs, karma, trivia scores, schedules and scene archive."))
+		return
+	}
+	data, err := fetchArchive(ctx.m.Attachments[0].URL)
+	if err != nil {
+		log.Printf("Error downloading an import for guild %s: %v", ctx.m.GuildID, err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't read the attachment: %v.", err))
+		return
+	}
+	archive, unmatched, err := decodeGuildArchive(ctx.s, ctx.m.GuildID, data)
+	if err != nil {
+		ctx.reply(ctx.tr("*holographic matrix flickers* That isn't an export I can restore: %v.", err))
+		return
+	}
+	if err := restoreGuildArchive(ctx.m.GuildID, ctx.m.Author.ID, archive); err != nil {
+		log.Printf("Error importing into guild %s: %v", ctx.m.GuildID, err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* The import stopped partway: %v.", err))
+		return
+	}
+	log.Printf("📦 Guild %s imported from guild %s by %s", ctx.m.GuildID, archive.GuildID, logUser("", ctx.m.Author.ID))
+	metrics.Inc(metricLabel("guild_transfers_total", "direction", "import"))
+
+	msg := ctx.tr("📦 Imported %s from **%s**. `!elsie config rollback` can undo the settings part.", archiveSummary(archive), archive.GuildName)
+	if len(unmatched) > 0 {
+		msg += "\n" + ctx.tr("⚠️ No channel or role here is named %s, so settings that used them still point at the old server. Set them again.", strings.Join(unmatched, ", "))
+	}
+	ctx.reply(msg)
+}
+
+// buildGuildArchive collects the guild's settings, the archived buckets and
+// its channel and role names.
+func buildGuildArchive(s *discordgo.Session, guildID string) (*guildArchive, error) {
+	archive := &guildArchive{
+		Version:    guildArchiveVersion,
+		GuildID:    guildID,
+		ExportedAt: time.Now().UTC(),
+		Channels:   map[string]string{},
+		Roles:      map[string]string{},
+		Config:     loadGuildConfig(guildID),
+		Data:       map[string]json.RawMessage{},
+	}
+	if guild, err := getGuild(s, guildID); err == nil {
+		archive.GuildName = guild.Name
+	}
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("listing channels: %w", err)
+	}
+	for _, c := range channels {
+		archive.Channels[c.ID] = c.Name
+	}
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return nil, fmt.Errorf("listing roles: %w", err)
+	}
+	for _, r := range roles {
+		archive.Roles[r.ID] = r.Name
+	}
+	for _, b := range archivedBuckets {
+		var raw json.RawMessage
+		ok, err := store.Get(b.bucket, guildID, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", b.name, err)
+		}
+		if ok {
+			archive.Data[b.bucket] = raw
+		}
+	}
+	return archive, nil
+}
+
+// decodeGuildArchive parses an export. An archive from another server has
+// its channel and role IDs swapped for the IDs of this server's channels
+// and roles with the same names; the names without a match are returned.
+func decodeGuildArchive(s *discordgo.Session, guildID string, data []byte) (*guildArchive, []string, error) {
+	var archive guildArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case archive.Version == 0 || archive.GuildID == "":
+		return nil, nil, errors.New("it has no version or server ID")
+	case archive.Version > guildArchiveVersion:
+		return nil, nil, fmt.Errorf("it's version %d, newer than this bot reads (%d)", archive.Version, guildArchiveVersion)
+	case archive.Config == nil:
+		return nil, nil, errors.New("it has no settings")
+	}
+	if archive.GuildID == guildID {
+		return &archive, nil, nil
+	}
+
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing channels: %w", err)
+	}
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing roles: %w", err)
+	}
+	channelIDs, roleIDs := map[string]string{}, map[string]string{}
+	for _, c := range channels {
+		channelIDs[c.Name] = c.ID
+	}
+	for _, r := range roles {
+		roleIDs[r.Name] = r.ID
+	}
+
+	// IDs are quoted strings wherever they appear, so swapping the quoted
+	// forms can't touch a number that merely contains one
+	pairs := []string{`"` + archive.GuildID + `"`, `"` + guildID + `"`}
+	body := archiveBody(data)
+	unmatched := map[string]bool{}
+	remap := func(names, ids map[string]string, mark string) {
+		for oldID, name := range names {
+			if id, ok := ids[name]; ok {
+				pairs = append(pairs, `"`+oldID+`"`, `"`+id+`"`)
+			} else if bytes.Contains(body, []byte(`"`+oldID+`"`)) {
+				unmatched[mark+name] = true
+			}
+		}
+	}
+	remap(archive.Channels, channelIDs, "#")
+	remap(archive.Roles, roleIDs, "@")
+
+	var remapped guildArchive
+	if err := json.Unmarshal([]byte(strings.NewReplacer(pairs...).Replace(string(data))), &remapped); err != nil {
+		return nil, nil, err
+	}
+	remapped.GuildID, remapped.Channels, remapped.Roles = archive.GuildID, archive.Channels, archive.Roles
+	return &remapped, sortedKeys(unmatched), nil
+}
+
+// archiveBody is the part of an archive that settings and data live in,
+// leaving out the channel and role name lists.
+func archiveBody(data []byte) []byte {
+	var body struct {
+		Config json.RawMessage `json:"config"`
+		Data   json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(data, &body)
+	return append(body.Config, body.Data...)
+}
+
+// restoreGuildArchive replaces the guild's settings and archived buckets
+// with the archive's. Buckets the archive has no entry for were empty when
+// it was made, so they're cleared.
+func restoreGuildArchive(guildID, actorID string, archive *guildArchive) error {
+	err := updateGuildConfig(guildID, actorID, func(cfg *GuildConfig) {
+		*cfg = *archive.Config
+	})
+	if err != nil {
+		return fmt.Errorf("restoring settings: %w", err)
+	}
+	for _, b := range archivedBuckets {
+		if err := restoreArchivedBucket(guildID, b, archive.Data[b.bucket]); err != nil {
+			return fmt.Errorf("restoring %s: %w", b.name, err)
+		}
+	}
+	return nil
+}
+
+func restoreArchivedBucket(guildID string, b archivedBucket, raw json.RawMessage) error {
+	if b.mu != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return store.Delete(b.bucket, guildID)
+	}
+	return store.Put(b.bucket, guildID, raw)
+}
+
+// archiveSummary lists what an archive holds, e.g. "settings, tabs, karma".
+func archiveSummary(archive *guildArchive) string {
+	parts := []string{"settings"}
+	for _, b := range archivedBuckets {
+		if len(archive.Data[b.bucket]) > 0 {
+			parts = append(parts, b.name)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fetchArchive downloads an attached export, up to guildArchiveMaxBytes.
+func fetchArchive(rawURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("downloading the attachment: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, guildArchiveMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > guildArchiveMaxBytes {
+		return nil, fmt.Errorf("the file is over %d MB", guildArchiveMaxBytes>>20)
+	}
+	return data, nil
+}
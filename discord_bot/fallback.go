@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// fallbackIntent is a coarse guess at what a message wants, used to pick a
+// canned reply when no agent is reachable.
+type fallbackIntent struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+	Replies  []string `json:"replies"`
+}
+
+// defaultFallbacks are checked in order; the first intent with a matching
+// keyword wins. FALLBACK_RESPONSES_FILE can replace them with a JSON array.
+var defaultFallbacks = []fallbackIntent{
+	{
+		Name:     "greeting",
+		Keywords: []string{"hello", "hi", "hey", "greetings", "evening", "morning"},
+		Replies: []string{
+			"*Elsie looks up from polishing a glass* Welcome in! Fair warning, my conversational subroutines are running on backup power tonight.",
+			"*flickers into view behind the bar* Hello there! I'm a little slow on the uptake at the moment, but pull up a stool.",
+		},
+	},
+	{
+		Name:     "thanks",
+		Keywords: []string{"thanks", "thank", "cheers", "appreciate"},
+		Replies: []string{
+			"*smiles warmly* Anytime.",
+			"*raises a glass* My pleasure.",
+		},
+	},
+	{
+		Name:     "farewell",
+		Keywords: []string{"bye", "goodbye", "goodnight", "later", "farewell"},
+		Replies: []string{
+			"*waves a towel* Safe travels! Come back when my matrix is feeling more talkative.",
+			"*nods* Until next time.",
+		},
+	},
+	{
+		Name:     "drink",
+		Keywords: []string{"drink", "order", "pour", "menu", "bar", "ale", "wine", "whiskey", "tea", "coffee"},
+		Replies: []string{
+			"*taps the replicator, which sputters* The replicators are down, I'm afraid. Try me again in a few minutes.",
+			"*sighs at the dark replicator panel* Maintenance has the replicators offline. I'll have your drink as soon as they're back.",
+		},
+	},
+}
+
+// fallbackApologies answer anything that matches no intent.
+var fallbackApologies = []string{
+	"*holographic matrix flickers* My apologies, the replicators are down and so is half my personality. Please try again shortly.",
+	"*static crackles across her form* I'm running on emergency power just now. Give me a few minutes to recalibrate.",
+}
+
+// fallbacks is the intent library in effect.
+func fallbacks() []fallbackIntent {
+	return currentCatalogs.Load().fallbacks
+}
+
+func (f fallbackIntent) matches(words []string) bool {
+	for _, w := range words {
+		for _, k := range f.Keywords {
+			if w == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadFallbacks reads the intents at path, FALLBACK_RESPONSES_FILE,
+// falling back to the built-in library.
+func loadFallbacks(path string) []fallbackIntent {
+	if path == "" {
+		return defaultFallbacks
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading fallback responses, using defaults: %v", err)
+		return defaultFallbacks
+	}
+	var intents []fallbackIntent
+	if err := json.Unmarshal(data, &intents); err != nil || len(intents) == 0 {
+		log.Printf("Invalid fallback responses %s, using defaults: %v", path, err)
+		return defaultFallbacks
+	}
+	log.Printf("🗂️ Loaded %d fallback intents from %s", len(intents), path)
+	return intents
+}
+
+// fallbackResponse picks an in-character reply for content without the
+// agent. Orders for a drink or dish on the menu are acknowledged by name.
+func fallbackResponse(p *persona, content string) string {
+	if p != nil && p.ID != defaultPersonaID {
+		metrics.Inc(metricLabel("fallback_responses_total", "intent", "persona"))
+		return fmt.Sprintf("*%s is offline. Please repeat your request later.*", p.Name)
+	}
+
+	lower := strings.ToLower(content)
+	for _, d := range drinkCatalog() {
+		if strings.Contains(lower, strings.ToLower(d.Name)) {
+			metrics.Inc(metricLabel("fallback_responses_total", "intent", "drink"))
+			return fmt.Sprintf("*Elsie reaches past the dark replicator and pours a %s by hand.* %s Old-fashioned way tonight, I'm afraid.", d.Name, d.Emoji)
+		}
+	}
+	for _, d := range foodCatalog() {
+		if strings.Contains(lower, strings.ToLower(d.Name)) {
+			metrics.Inc(metricLabel("fallback_responses_total", "intent", "food"))
+			return fmt.Sprintf("*Elsie taps the dark replicator, sighs, and finds a %s in the galley's stasis locker.* %s It'll have to do tonight.", d.Name, d.Emoji)
+		}
+	}
+
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r == '\'')
+	})
+	for _, intent := range fallbacks() {
+		if len(intent.Replies) == 0 || !intent.matches(words) {
+			continue
+		}
+		metrics.Inc(metricLabel("fallback_responses_total", "intent", intent.Name))
+		return intent.Replies[rand.Intn(len(intent.Replies))]
+	}
+	metrics.Inc(metricLabel("fallback_responses_total", "intent", "apology"))
+	return fallbackApologies[rand.Intn(len(fallbackApologies))]
+}
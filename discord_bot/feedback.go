@@ -0,0 +1,122 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Reactions players use to rate a reply.
+const (
+	feedbackUp   = "👍"
+	feedbackDown = "👎"
+)
+
+// feedbackRequest reports a player's rating of one reply to the agent's
+// /feedback endpoint.
+type feedbackRequest struct {
+	RequestID string `json:"request_id"`
+	SessionID string `json:"session_id,omitempty"`
+	Persona   string `json:"persona,omitempty"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id"`
+	Rating    string `json:"rating"` // "up" or "down"
+	Action    string `json:"action"` // "add" or "remove"
+}
+
+var (
+	// sentReplies maps recently posted reply message IDs to their
+	// exchange, so most reactions resolve without the exchange log.
+	sentReplies = newLRUCache[string, exchangeRecord]("replies", 5000, 24*time.Hour)
+
+	// notReplies remembers reacted-to messages that aren't Elsie's replies,
+	// so the thumbs on everyone else's messages skip the exchange log.
+	notReplies = newLRUCache[string, struct{}]("not_replies", 20000, time.Hour)
+)
+
+func init() {
+	trackCache(sentReplies)
+	trackCache(notReplies)
+}
+
+// rememberReply records which exchange each posted message belongs to.
+func rememberReply(rec exchangeRecord) {
+	for _, id := range rec.ResponseMessageIDs {
+		sentReplies.Add(id, rec)
+		notReplies.Remove(id)
+	}
+}
+
+// replyExchange finds the exchange that produced messageID, falling back to
+// the exchange log's reply index for replies older than the cache.
+func replyExchange(guildID, messageID string) (exchangeRecord, bool) {
+	if rec, ok := sentReplies.Get(messageID); ok {
+		return rec, true
+	}
+	if notReplies.Contains(messageID) {
+		return exchangeRecord{}, false
+	}
+	rec, ok := exchanges.reply(messageID)
+	if !ok || (guildID != "" && rec.GuildID != guildID) {
+		notReplies.Add(messageID, struct{}{})
+		return exchangeRecord{}, false
+	}
+	sentReplies.Add(messageID, rec)
+	return rec, true
+}
+
+func messageReactionAdd(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	reportFeedback(s, r.MessageReaction, "add")
+	updateStarboard(s, r.MessageReaction)
+}
+
+func messageReactionRemove(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	reportFeedback(s, r.MessageReaction, "remove")
+	updateStarboard(s, r.MessageReaction)
+}
+
+// reportFeedback forwards 👍/👎 reactions on Elsie's replies to the agent.
+func reportFeedback(s *discordgo.Session, r *discordgo.MessageReaction, action string) {
+	if !config().FeedbackReactionsEnabled || !botReady.Load() || safeMode.Load() || r.UserID == s.State.User.ID {
+		return
+	}
+	var rating string
+	switch r.Emoji.Name {
+	case feedbackUp:
+		rating = "up"
+	case feedbackDown:
+		rating = "down"
+	default:
+		return
+	}
+	if contentProcessingDisabled(r.GuildID) || userExclusion(r.GuildID, r.UserID) != "" {
+		return
+	}
+	rec, ok := replyExchange(r.GuildID, r.MessageID)
+	if !ok {
+		return
+	}
+
+	req := feedbackRequest{
+		RequestID: rec.RequestID,
+		SessionID: rec.AgentSessionID,
+		Persona:   rec.Persona,
+		MessageID: r.MessageID,
+		UserID:    r.UserID,
+		Rating:    rating,
+		Action:    action,
+	}
+	metrics.Inc(metricLabel("feedback_total", "rating", rating))
+	pool := poolFor(rec.Persona)
+	if !pool.supports(featureFeedback) {
+		return
+	}
+	go func() {
+		rlog := requestLog{id: rec.RequestID}
+		if _, err := pool.call(rec.RequestID, "/feedback", req); err != nil {
+			rlog.Printf("Error sending %s feedback to AI agent: %v", rating, err)
+			return
+		}
+		rlog.Printf("👍 Feedback %s (%s) from %s", rating, action, logUser("", r.UserID))
+	}()
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Filter strictness levels. A rule tagged with a level applies to every
+// guild whose strictness is at least that level.
+const (
+	filterOff = iota
+	filterLow
+	filterMedium
+	filterHigh
+)
+
+var filterLevelNames = map[string]int{"off": filterOff, "low": filterLow, "medium": filterMedium, "high": filterHigh}
+
+// Filter actions taken on a match.
+const (
+	filterActionRedact = "redact" // replace matched text, then deliver
+	filterActionBlock  = "block"  // drop the message entirely
+	filterActionFlag   = "flag"   // deliver unchanged, only notify moderators
+)
+
+// filterMatch is a span of text matched by a filter stage.
+type filterMatch struct {
+	stage      string
+	term       string
+	start, end int
+}
+
+// filterStage is one pluggable check in the content filter pipeline.
+type filterStage interface {
+	Name() string
+	Match(text string, level int) []filterMatch
+}
+
+var filterStages []filterStage
+
+// registerFilterStage appends a stage to the pipeline.
+func registerFilterStage(stage filterStage) {
+	filterStages = append(filterStages, stage)
+}
+
+// filterRule is a compiled pattern that applies from minLevel upward.
+type filterRule struct {
+	minLevel int
+	source   string
+	re       *regexp.Regexp
+}
+
+// patternStage matches a list of compiled rules; it backs both the word
+// list and the regex stages.
+type patternStage struct {
+	name  string
+	rules []filterRule
+}
+
+func (p *patternStage) Name() string { return p.name }
+
+func (p *patternStage) Match(text string, level int) []filterMatch {
+	var matches []filterMatch
+	for _, rule := range p.rules {
+		if rule.minLevel > level {
+			continue
+		}
+		for _, loc := range rule.re.FindAllStringIndex(text, -1) {
+			matches = append(matches, filterMatch{stage: p.name, term: rule.source, start: loc[0], end: loc[1]})
+		}
+	}
+	return matches
+}
+
+// initContentFilter builds the word list and regex stages from
+// FILTER_WORDLIST_FILE and FILTER_REGEX_FILE. Each line is an entry,
+// optionally prefixed with the minimum strictness level ("medium darn").
+func initContentFilter() {
+	if config().FilterWordlistFile != "" {
+		rules, err := loadFilterRules(config().FilterWordlistFile, func(term string) string {
+			return `(?i)\b` + regexp.QuoteMeta(term) + `\b`
+		})
+		if err != nil {
+			log.Printf("Error loading filter word list: %v", err)
+		} else {
+			registerFilterStage(&patternStage{name: "wordlist", rules: rules})
+			log.Printf("🧼 Content filter word list loaded (%d entries)", len(rules))
+		}
+	}
+	if config().FilterRegexFile != "" {
+		rules, err := loadFilterRules(config().FilterRegexFile, func(pattern string) string { return pattern })
+		if err != nil {
+			log.Printf("Error loading filter regexes: %v", err)
+		} else {
+			registerFilterStage(&patternStage{name: "regex", rules: rules})
+			log.Printf("🧼 Content filter regexes loaded (%d entries)", len(rules))
+		}
+	}
+}
+
+func loadFilterRules(path string, toPattern func(string) string) ([]filterRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []filterRule
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		level := filterLow
+		if first, rest, ok := strings.Cut(line, " "); ok {
+			if l, known := filterLevelNames[strings.ToLower(first)]; known && l != filterOff {
+				level, line = l, strings.TrimSpace(rest)
+			}
+		}
+		re, err := regexp.Compile(toPattern(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		rules = append(rules, filterRule{minLevel: level, source: line, re: re})
+	}
+	return rules, scanner.Err()
+}
+
+func (cfg *GuildConfig) filterLevel() int {
+	if l, ok := filterLevelNames[cfg.FilterLevel]; ok {
+		return l
+	}
+	return filterLevelNames[config().FilterDefaultLevel]
+}
+
+func (cfg *GuildConfig) filterAction() string {
+	if cfg.FilterAction != "" {
+		return cfg.FilterAction
+	}
+	return config().FilterDefaultAction
+}
+
+// screenContent runs text through the filter pipeline using the guild's
+// strictness and action policy. direction is "inbound" for user messages
+// and "outbound" for agent responses. It returns the text to use and
+// whether it may be delivered at all.
+func screenContent(s *discordgo.Session, guildID, channelID, authorID, direction, text string) (string, bool) {
+	cfg := loadGuildConfig(guildID)
+	level := cfg.filterLevel()
+	if level == filterOff || len(filterStages) == 0 {
+		return text, true
+	}
+
+	var matches []filterMatch
+	for _, stage := range filterStages {
+		matches = append(matches, stage.Match(text, level)...)
+	}
+	if len(matches) == 0 {
+		return text, true
+	}
+
+	action := cfg.filterAction()
+	metrics.Inc(metricLabel("filter_matches_total", "direction", direction))
+	log.Printf("🧼 Content filter matched %d term(s) in %s message (action: %s)", len(matches), direction, action)
+	if cfg.ModChannelID != "" {
+		flagToModerators(s, guildID, cfg.ModChannelID, channelID, authorID, direction, action, matches)
+	}
+
+	switch action {
+	case filterActionBlock:
+		return "", false
+	case filterActionFlag:
+		return text, true
+	default:
+		return redactMatches(text, matches), true
+	}
+}
+
+// redactMatches replaces every matched span, merging overlaps.
+func redactMatches(text string, matches []filterMatch) string {
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var b strings.Builder
+	pos := 0
+	for _, match := range matches {
+		if match.end <= pos {
+			continue
+		}
+		if match.start > pos {
+			b.WriteString(text[pos:match.start])
+		}
+		b.WriteString("[redacted]")
+		pos = match.end
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+func flagToModerators(s *discordgo.Session, guildID, modChannelID, channelID, authorID, direction, action string, matches []filterMatch) {
+	terms := make([]string, 0, len(matches))
+	seen := map[string]bool{}
+	for _, match := range matches {
+		key := match.stage + ":" + match.term
+		if !seen[key] {
+			seen[key] = true
+			terms = append(terms, fmt.Sprintf("`%s` (%s)", match.term, match.stage))
+		}
+	}
+	source := "AI response"
+	if direction == "inbound" {
+		source = fmt.Sprintf("<@%s>", authorID)
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: "🧼 Content filter match",
+		Color: themeColor(guildID, "warning"),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Channel", Value: fmt.Sprintf("<#%s>", channelID), Inline: true},
+			{Name: "Source", Value: source, Inline: true},
+			{Name: "Action", Value: action, Inline: true},
+			{Name: "Matched", Value: strings.Join(terms, ", ")},
+		},
+	}
+	if _, err := s.ChannelMessageSendEmbed(modChannelID, embed); err != nil {
+		log.Printf("Error flagging content to mod channel %s: %v", modChannelID, err)
+	}
+}
+
+func init() {
+	registerCommand(command{name: "filter", handler: filterCommand})
+}
+
+// filterCommand lets guild admins view and change the filter policy:
+// `!elsie filter [level <off|low|medium|high> | action <redact|block|flag> | modchannel <#channel|off>]`.
+func filterCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Filter settings are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can adjust my content filter.")
+		return
+	}
+
+	if len(ctx.args) < 2 {
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		modChannel := "not set"
+		if cfg.ModChannelID != "" {
+			modChannel = fmt.Sprintf("<#%s>", cfg.ModChannelID)
+		}
+		level := cfg.FilterLevel
+		if level == "" {
+			level = config().FilterDefaultLevel + " (default)"
+		}
+		ctx.reply(fmt.Sprintf("🧼 **Content filter**\n• Level: %s\n• Action: %s\n• Mod channel: %s\n"+
+			"Usage: `!elsie filter level <off|low|medium|high>`, `!elsie filter action <redact|block|flag>`, `!elsie filter modchannel <#channel|off>`",
+			level, cfg.filterAction(), modChannel))
+		return
+	}
+
+	setting, value := strings.ToLower(ctx.args[0]), strings.ToLower(ctx.args[1])
+	var apply func(cfg *GuildConfig)
+	switch setting {
+	case "level":
+		if _, ok := filterLevelNames[value]; !ok {
+			ctx.reply("Level must be one of `off`, `low`, `medium`, `high`.")
+			return
+		}
+		apply = func(cfg *GuildConfig) { cfg.FilterLevel = value }
+	case "action":
+		if value != filterActionRedact && value != filterActionBlock && value != filterActionFlag {
+			ctx.reply("Action must be one of `redact`, `block`, `flag`.")
+			return
+		}
+		apply = func(cfg *GuildConfig) { cfg.FilterAction = value }
+	case "modchannel":
+		channelID := parseChannelMention(ctx.args[1])
+		if value != "off" && channelID == "" {
+			ctx.reply("Mention the channel, e.g. `!elsie filter modchannel #mod-log`, or use `off`.")
+			return
+		}
+		apply = func(cfg *GuildConfig) { cfg.ModChannelID = channelID }
+	default:
+		ctx.reply("Usage: `!elsie filter [level|action|modchannel] <value>`")
+		return
+	}
+
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving filter config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(fmt.Sprintf("🧼 Content filter %s set to **%s**.", setting, ctx.args[1]))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const followUpBucket = "follow_ups"
+
+// followUpRequest is the agent's `follow_up_after` response field: send
+// Prompt back to the agent once Duration has passed.
+type followUpRequest struct {
+	Duration string `json:"duration"`
+	Prompt   string `json:"prompt"`
+}
+
+// FollowUp is a scheduled follow-up, persisted so it survives restarts.
+type FollowUp struct {
+	RequestID string    `json:"request_id"`
+	GuildID   string    `json:"guild_id,omitempty"`
+	ChannelID string    `json:"channel_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+	Prompt    string    `json:"prompt"`
+	Scheduled time.Time `json:"scheduled"`
+	Due       time.Time `json:"due"`
+}
+
+// maxFollowUpsPerChannel caps how many follow-ups a channel can have
+// pending, so a chatty agent can't queue up a flood.
+const maxFollowUpsPerChannel = 5
+
+// scheduleFollowUp stores the follow-up an agent response asked for, if
+// any. Returns without scheduling on invalid or out-of-range durations.
+func scheduleFollowUp(resp *AIResponse, guildID, channelID, userID string, p *persona, rlog requestLog) {
+	if resp == nil || resp.FollowUp == nil || !config().FollowUpsEnabled {
+		return
+	}
+	delay, err := time.ParseDuration(resp.FollowUp.Duration)
+	prompt := strings.TrimSpace(resp.FollowUp.Prompt)
+	switch {
+	case err != nil || delay <= 0 || prompt == "":
+		rlog.Printf("⏰ Ignoring invalid follow_up_after %+v", *resp.FollowUp)
+		return
+	case delay > config().FollowUpMaxDelay:
+		rlog.Printf("⏰ Ignoring follow_up_after of %s (max %s)", delay, config().FollowUpMaxDelay)
+		return
+	}
+
+	followUpMu.Lock()
+	defer followUpMu.Unlock()
+	pending := 0
+	for _, f := range loadFollowUps() {
+		if f.ChannelID == channelID {
+			pending++
+		}
+	}
+	if pending >= maxFollowUpsPerChannel {
+		rlog.Printf("⏰ Channel %s already has %d follow-ups pending, dropping another", channelID, pending)
+		return
+	}
+
+	now := time.Now()
+	f := FollowUp{
+		RequestID: rlog.id,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		UserID:    userID,
+		Persona:   p.ID,
+		Prompt:    prompt,
+		Scheduled: now,
+		Due:       now.Add(delay),
+	}
+	if err := store.Put(followUpBucket, f.RequestID, f); err != nil {
+		rlog.Printf("Error scheduling follow-up: %v", err)
+		return
+	}
+	rlog.Printf("⏰ Follow-up scheduled in %s", delay)
+	metrics.Inc("follow_ups_scheduled_total")
+}
+
+// followUpMu guards the follow-up bucket between scheduling and delivery.
+var followUpMu sync.Mutex
+
+func loadFollowUps() []FollowUp {
+	var out []FollowUp
+	for _, key := range store.Keys(followUpBucket) {
+		var f FollowUp
+		if ok, err := store.Get(followUpBucket, key, &f); err != nil || !ok {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+var followUpSchedulerOnce sync.Once
+
+// startFollowUpScheduler starts the follow-up delivery loop once.
+func startFollowUpScheduler(s *discordgo.Session) {
+	if !config().FollowUpsEnabled {
+		return
+	}
+	followUpSchedulerOnce.Do(func() { go runFollowUpScheduler(s) })
+}
+
+// runFollowUpScheduler delivers due follow-ups every few seconds.
+func runFollowUpScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		var due []FollowUp
+		followUpMu.Lock()
+		for _, f := range loadFollowUps() {
+			if time.Now().Before(f.Due) {
+				continue
+			}
+			// Remove before sending: a crash mid-delivery drops the follow-up
+			// rather than repeating it on every restart.
+			if err := store.Delete(followUpBucket, f.RequestID); err != nil {
+				log.Printf("Error removing follow-up %s: %v", f.RequestID, err)
+				continue
+			}
+			due = append(due, f)
+		}
+		followUpMu.Unlock()
+		for _, f := range due {
+			deliverFollowUp(s, f)
+		}
+	}
+}
+
+// deliverFollowUp sends the stored prompt back to the agent with current
+// channel context and posts the reply.
+func deliverFollowUp(s *discordgo.Session, f FollowUp) {
+	rlog := requestLog{id: newRequestID()}
+	if contentProcessingDisabled(f.GuildID) || refusesChannel(s, f.GuildID, f.ChannelID) {
+		rlog.Printf("⏰ Dropping follow-up %s: channel policy changed", f.RequestID)
+		return
+	}
+	// The gates messageCreate applies still hold hours later: the player may
+	// have opted out or been blocked, and a listening channel gets no
+	// unprompted posts
+	if f.UserID != "" {
+		if reason := userExclusion(f.GuildID, f.UserID); reason != "" {
+			rlog.Printf("⏰ Dropping follow-up %s: its player is excluded (%s)", f.RequestID, reason)
+			return
+		}
+	}
+	if channelListening(s, f.GuildID, f.ChannelID) {
+		rlog.Printf("⏰ Dropping follow-up %s: the channel is in listening mode", f.RequestID)
+		return
+	}
+	if ok, scope, _ := consumeQuota(f.GuildID, f.ChannelID, f.UserID); !ok {
+		rlog.Printf("⏰ Dropping follow-up %s: over the %s quota", f.RequestID, scope)
+		return
+	}
+	p := findPersona(f.Persona)
+	if p == nil {
+		p = defaultPersona()
+	}
+
+	var user *discordgo.User
+	if f.UserID != "" {
+		user = &discordgo.User{ID: f.UserID}
+		if u, err := s.User(f.UserID); err == nil {
+			user = u
+		}
+	}
+	ctx := baseContext(s, f.ChannelID, f.GuildID, user)
+	ctx["session_id"] = p.sessionID(f.ChannelID)
+	ctx["request_id"] = rlog.id
+	ctx["persona"] = p.ID
+	ctx["intent"] = "follow_up"
+	ctx["follow_up"] = map[string]interface{}{
+		"original_request_id": f.RequestID,
+		"scheduled_at":        f.Scheduled.UTC().Format(time.RFC3339),
+		"delay":               f.Due.Sub(f.Scheduled).String(),
+	}
+	resp, err := callAgent(Message{Message: f.Prompt, Context: ctx, RequestID: rlog.id, Persona: p.ID})
+	if err != nil || resp == nil || strings.TrimSpace(resp.Response) == "" || resp.silent() {
+		if err != nil {
+			rlog.Printf("Error delivering follow-up %s: %v", f.RequestID, err)
+		}
+		return
+	}
+	if resp.FollowUp != nil {
+		// Follow-ups don't chain; one check-in per exchange.
+		rlog.Printf("⏰ Ignoring follow_up_after on a follow-up response")
+	}
+
+	response, deliver := screenContent(s, f.GuildID, f.ChannelID, f.UserID, "outbound", resp.Response)
+	if !deliver {
+		rlog.Printf("🧼 Follow-up blocked by content filter")
+		return
+	}
+	if _, err := sendAs(s, f.ChannelID, p, response); err != nil {
+		rlog.Printf("Error sending follow-up: %v", err)
+		return
+	}
+	rlog.Printf("⏰ Follow-up %s delivered to %s", f.RequestID, f.ChannelID)
+	metrics.Inc("follow_ups_delivered_total")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Dish is one entry in the replicator's food catalog.
+type Dish struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Emoji       string `json:"emoji,omitempty"`
+	Price       int    `json:"price,omitempty"` // bar credits on a tab
+}
+
+// foodCategory is a section of the replicator menu.
+type foodCategory struct {
+	ID    string
+	Name  string
+	Emoji string
+}
+
+// foodCategories are the built-in sections, in menu order. A custom catalog
+// can add its own; they're listed after these under their ID.
+var foodCategories = []foodCategory{
+	{ID: "klingon", Name: "Klingon", Emoji: "⚔️"},
+	{ID: "vulcan", Name: "Vulcan", Emoji: "🖖"},
+	{ID: "comfort", Name: "Human comfort food", Emoji: "🍲"},
+}
+
+// defaultFoodCatalog is served unless FOOD_CATALOG_FILE points at a JSON
+// array of dishes.
+var defaultFoodCatalog = []Dish{
+	{ID: "gagh", Name: "Gagh", Description: "Serpent worms, best served live", Category: "klingon", Emoji: "🪱", Price: 8},
+	{ID: "heart-of-targ", Name: "Heart of Targ", Description: "A warrior's feast", Category: "klingon", Emoji: "🫀", Price: 10},
+	{ID: "rokeg-blood-pie", Name: "Rokeg Blood Pie", Description: "Just like a Klingon grandmother makes it", Category: "klingon", Emoji: "🥧", Price: 9},
+	{ID: "pipius-claw", Name: "Pipius Claw", Description: "Crunchy, and worth the fight", Category: "klingon", Emoji: "🦀", Price: 7},
+	{ID: "plomeek-soup", Name: "Plomeek Soup", Description: "Clear, orange and perfectly logical", Category: "vulcan", Emoji: "🥣", Price: 5},
+	{ID: "kreyla", Name: "Kreyla", Description: "Flat Vulcan bread, baked plain", Category: "vulcan", Emoji: "🫓", Price: 3},
+	{ID: "gespar", Name: "Gespar", Description: "A Vulcan fruit, sliced thin", Category: "vulcan", Emoji: "🍐", Price: 4},
+	{ID: "creole-jambalaya", Name: "Creole Jambalaya", Description: "A New Orleans recipe worth the real kitchen", Category: "comfort", Emoji: "🍛", Price: 8},
+	{ID: "tomato-soup", Name: "Tomato Soup", Description: "With a grilled cheese sandwich for dipping", Category: "comfort", Emoji: "🍅", Price: 5},
+	{ID: "mac-and-cheese", Name: "Macaroni and Cheese", Description: "Just the way you remember it", Category: "comfort", Emoji: "🧀", Price: 5},
+	{ID: "chocolate-sundae", Name: "Chocolate Sundae", Description: "Hot fudge, because it's been a long shift", Category: "comfort", Emoji: "🍨", Price: 6},
+}
+
+// foodCatalog is the replicator menu in effect.
+func foodCatalog() []Dish {
+	return currentCatalogs.Load().food
+}
+
+// loadFoodCatalog reads the catalog at path, FOOD_CATALOG_FILE, falling
+// back to the default one.
+func loadFoodCatalog(path string) []Dish {
+	if path == "" {
+		return defaultFoodCatalog
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading food catalog, using defaults: %v", err)
+		return defaultFoodCatalog
+	}
+	var dishes []Dish
+	if err := json.Unmarshal(data, &dishes); err != nil || len(dishes) == 0 {
+		log.Printf("Invalid food catalog %s, using defaults: %v", path, err)
+		return defaultFoodCatalog
+	}
+	log.Printf("🍽️ Loaded %d dishes from %s", len(dishes), path)
+	return dishes
+}
+
+func findDish(id string) (Dish, bool) {
+	for _, d := range foodCatalog() {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Dish{}, false
+}
+
+// menuCategories returns the categories the catalog has dishes in: the
+// built-in ones first, then any others in catalog order.
+func menuCategories() []foodCategory {
+	catalog := foodCatalog()
+	present := map[string]bool{}
+	for _, d := range catalog {
+		present[d.Category] = true
+	}
+	var categories []foodCategory
+	for _, c := range foodCategories {
+		if present[c.ID] {
+			categories = append(categories, c)
+			delete(present, c.ID)
+		}
+	}
+	for _, d := range catalog {
+		if present[d.Category] {
+			categories = append(categories, foodCategory{ID: d.Category, Name: d.Category})
+			delete(present, d.Category)
+		}
+	}
+	return categories
+}
+
+func findFoodCategory(id string) (foodCategory, bool) {
+	for _, c := range menuCategories() {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return foodCategory{}, false
+}
+
+func init() {
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:        "replicate",
+		Description: "Order food from the replicator",
+	}, replicateCommand)
+	registerComponentHandler("replicate", replicatorSelected)
+}
+
+// replicateCommand shows the replicator's categories as a select menu only
+// the customer sees; picking one shows its dishes.
+func replicateCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if refusesChannel(s, i.GuildID, i.ChannelID) {
+		respondEphemeral(s, i, themePhrase(i.GuildID, "nsfw_refusal", nil))
+		return
+	}
+	var options []discordgo.SelectMenuOption
+	for _, c := range menuCategories() {
+		if len(options) == maxSelectOptions {
+			break
+		}
+		option := discordgo.SelectMenuOption{Label: tr(i.GuildID, c.Name), Value: c.ID}
+		if c.Emoji != "" {
+			option.Emoji = discordgo.ComponentEmoji{Name: c.Emoji}
+		}
+		options = append(options, option)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: tr(i.GuildID, "🍽️ *Elsie nods at the replicator.* What are you hungry for?"),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    "replicate:category",
+						Placeholder: tr(i.GuildID, "Choose a cuisine"),
+						Options:     options,
+					},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error showing replicator menu: %v", err)
+	}
+}
+
+// replicatorSelected handles both replicator menus: a category swaps in
+// its dishes, and a dish is served like a drink order.
+func replicatorSelected(s *discordgo.Session, i *discordgo.InteractionCreate, payload string) {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return
+	}
+	switch payload {
+	case "category":
+		showDishes(s, i, values[0])
+	case "dish":
+		dish, ok := findDish(values[0])
+		if !ok {
+			respondEphemeral(s, i, tr(i.GuildID, "*frowns at the replicator* That pattern seems to have dropped out of the buffer."))
+			return
+		}
+		metrics.Inc(metricLabel("food_orders_total", "category", dish.Category))
+		user := interactionUser(i)
+		placeOrder(s, i, dish.tabItem(), dish.Emoji, map[string]interface{}{
+			"item_type":   "food",
+			"dish_id":     dish.ID,
+			"dish_name":   dish.Name,
+			"category":    dish.Category,
+			"description": dish.Description,
+		}, fmt.Sprintf("I'd like the %s from the replicator, please.", dish.Name),
+			fmt.Sprintf("*The replicator hums, and Elsie sets a %s in front of <@%s>.* %s", dish.Name, user.ID, dish.Emoji))
+	}
+}
+
+// showDishes swaps the category menu for the category's dishes.
+func showDishes(s *discordgo.Session, i *discordgo.InteractionCreate, categoryID string) {
+	category, ok := findFoodCategory(categoryID)
+	if !ok {
+		respondEphemeral(s, i, tr(i.GuildID, "*frowns at the replicator* That pattern seems to have dropped out of the buffer."))
+		return
+	}
+	var options []discordgo.SelectMenuOption
+	for _, d := range foodCatalog() {
+		if d.Category != category.ID {
+			continue
+		}
+		if len(options) == maxSelectOptions {
+			break
+		}
+		option := discordgo.SelectMenuOption{Label: d.Name, Value: d.ID, Description: d.Description}
+		if d.Emoji != "" {
+			option.Emoji = discordgo.ComponentEmoji{Name: d.Emoji}
+		}
+		options = append(options, option)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: tr(i.GuildID, "🍽️ **%s** — what'll it be?", tr(i.GuildID, category.Name)),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    "replicate:dish",
+						Placeholder: tr(i.GuildID, "Choose a dish"),
+						Options:     options,
+					},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Error showing replicator dishes: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+const guildConfigBucket = "guild_config"
+
+// GuildConfig holds the per-guild settings admins manage with `!elsie`
+// commands. Zero values mean "use the default".
+type GuildConfig struct {
+	FilterLevel  string `json:"filter_level,omitempty"`
+	FilterAction string `json:"filter_action,omitempty"`
+	ModChannelID string `json:"mod_channel_id,omitempty"`
+
+	AnnouncementChannelID string `json:"announcement_channel_id,omitempty"`
+	BroadcastOptOut       bool   `json:"broadcast_opt_out,omitempty"`
+
+	DigestChannelID string `json:"digest_channel_id,omitempty"`
+	DigestOptOut    bool   `json:"digest_opt_out,omitempty"`
+
+	// DrinkOfTheDayChannelID is where the daily drink is announced.
+	DrinkOfTheDayChannelID string `json:"drink_of_the_day_channel_id,omitempty"`
+
+	// ContentProcessingOff stops Elsie from reading or forwarding message
+	// content in the guild; only slash commands are served.
+	ContentProcessingOff bool `json:"content_processing_off,omitempty"`
+
+	// IgnoreRules exclude channels from monitoring.
+	IgnoreRules ChannelIgnoreRules `json:"ignore_rules,omitempty"`
+
+	// OOCMode is "skip" (default) or "tag" for out-of-character messages in
+	// monitored channels.
+	OOCMode string `json:"ooc_mode,omitempty"`
+
+	// DisabledActions are agent action types this guild turned off, and
+	// AssignableRoles the only roles assign_role may grant.
+	DisabledActions []string `json:"disabled_actions,omitempty"`
+	AssignableRoles []string `json:"assignable_roles,omitempty"`
+
+	// Timezone is the IANA zone scheduled events follow, e.g. "Europe/London".
+	Timezone string `json:"timezone,omitempty"`
+
+	// GreetVoiceChannelID is the voice channel whose first arrival Elsie
+	// greets in its text chat.
+	GreetVoiceChannelID string `json:"greet_voice_channel_id,omitempty"`
+
+	// StageChannels links stage channels to the text channel where Elsie
+	// announces and narrates them.
+	StageChannels map[string]string `json:"stage_channels,omitempty"`
+
+	// PairOOCThreads opens an OOC thread alongside each scene started in a
+	// thread.
+	PairOOCThreads bool `json:"pair_ooc_threads,omitempty"`
+
+	// CommandGates limit commands to roles or permissions, keyed by
+	// command name, "command subcommand" or "/slash-command".
+	CommandGates map[string]CommandGate `json:"command_gates,omitempty"`
+
+	// MissionLogChannelID is the forum or text channel mission reports
+	// are filed in.
+	MissionLogChannelID string `json:"mission_log_channel_id,omitempty"`
+
+	// ThreadArchiveMode overrides THREAD_ARCHIVE_MODE: "bump", "marker"
+	// or "off".
+	ThreadArchiveMode string `json:"thread_archive_mode,omitempty"`
+
+	// ReportChannelID receives DM and /report reports for staff;
+	// ReportAnonymous hides the reporter's name there.
+	ReportChannelID string `json:"report_channel_id,omitempty"`
+	ReportAnonymous bool   `json:"report_anonymous,omitempty"`
+
+	// Theme names the theme pack for system messages; empty is the default.
+	Theme string `json:"theme,omitempty"`
+
+	// RefuseNSFW keeps Elsie silent in age-restricted channels.
+	RefuseNSFW bool `json:"refuse_nsfw,omitempty"`
+
+	// QuotaOverrides replace the operator's default agent quotas, keyed by
+	// scope ("user", "channel", "guild") with values like "10/1m" or "off".
+	QuotaOverrides map[string]string `json:"quota_overrides,omitempty"`
+	QuotaExempt    []string          `json:"quota_exempt,omitempty"`
+
+	// ChannelPersonas maps channel IDs to the persona that answers there;
+	// DefaultPersona answers everywhere else.
+	ChannelPersonas map[string]string `json:"channel_personas,omitempty"`
+	DefaultPersona  string            `json:"default_persona,omitempty"`
+
+	// MonitoredChannels are monitored in addition to the built-in allow
+	// rules, as chosen in the setup wizard.
+	MonitoredChannels []string `json:"monitored_channels,omitempty"`
+
+	// ListeningChannels are channels, and their threads, where Elsie
+	// follows the conversation but only answers mentions and commands.
+	ListeningChannels []string `json:"listening_channels,omitempty"`
+
+	// ChannelLengths are response length budgets in characters, keyed by
+	// channel ID; threads follow their parent.
+	ChannelLengths map[string]int `json:"channel_lengths,omitempty"`
+
+	// AckReactions are the emoji Elsie reacts with to monitored messages
+	// she doesn't answer, keyed by channel ID; threads follow their parent.
+	AckReactions map[string]string `json:"ack_reactions,omitempty"`
+
+	// BlockedUsers are members whose messages Elsie ignores in the guild.
+	BlockedUsers []string `json:"blocked_users,omitempty"`
+
+	// BotPolicy overrides BOT_POLICY for the guild, and AllowedBots are bot
+	// and webhook IDs answered whatever the policy.
+	BotPolicy   string   `json:"bot_policy,omitempty"`
+	AllowedBots []string `json:"allowed_bots,omitempty"`
+
+	// Calendar maps real time to the guild's stardates; nil uses
+	// STARDATE_YEAR_OFFSET.
+	Calendar *StardateCalendar `json:"stardate_calendar,omitempty"`
+
+	// Replacements are applied to every response, and PostProcessOff names
+	// post-processing stages turned off for the guild.
+	Replacements   []Replacement `json:"replacements,omitempty"`
+	PostProcessOff []string      `json:"post_process_off,omitempty"`
+	// ActionStyle is how RP actions are written in responses: "italic"
+	// (empty) or "brackets".
+	ActionStyle string `json:"action_style,omitempty"`
+
+	// Prefix is the guild's own command prefix. PrefixMode decides whether
+	// it works alongside "!elsie" (empty), instead of it ("only"), or
+	// whether prefix commands are off and only slash commands work ("off").
+	Prefix     string `json:"prefix,omitempty"`
+	PrefixMode string `json:"prefix_mode,omitempty"`
+
+	// Language is the guild's language for bot messages and agent replies;
+	// empty is DEFAULT_LANGUAGE.
+	Language string `json:"language,omitempty"`
+
+	// StarboardChannelID receives reposts of messages with
+	// StarboardThreshold stars; zero is STARBOARD_DEFAULT_THRESHOLD.
+	StarboardChannelID string `json:"starboard_channel_id,omitempty"`
+	StarboardThreshold int    `json:"starboard_threshold,omitempty"`
+
+	// TelemetryOff stops the exchange log and usage stats for the guild.
+	TelemetryOff bool `json:"telemetry_off,omitempty"`
+}
+
+// guildConfigMu serializes read-modify-write cycles on guild configs.
+var guildConfigMu sync.Mutex
+
+// loadGuildConfig returns the stored config for guildID, or an empty config
+// for DMs and guilds that never changed a setting.
+func loadGuildConfig(guildID string) *GuildConfig {
+	cfg := &GuildConfig{}
+	if guildID == "" {
+		return cfg
+	}
+	if _, err := store.Get(guildConfigBucket, guildID, cfg); err != nil {
+		log.Printf("Error loading config for guild %s: %v", guildID, err)
+		return &GuildConfig{}
+	}
+	return cfg
+}
+
+// guildConfigChanged drops what was derived from a guild's config, after
+// any write to it.
+func guildConfigChanged(guildID string) {
+	guildPrefixes.Remove(guildID)
+}
+
+// updateGuildConfig applies fn to the guild's config and persists it.
+// actorID is the user making the change; every change is versioned for
+// `!elsie config history`.
+func updateGuildConfig(guildID, actorID string, fn func(cfg *GuildConfig)) error {
+	guildConfigMu.Lock()
+	defer guildConfigMu.Unlock()
+
+	before := loadGuildConfig(guildID)
+	cfg := loadGuildConfig(guildID)
+	fn(cfg)
+	if err := store.Put(guildConfigBucket, guildID, cfg); err != nil {
+		return err
+	}
+	guildConfigChanged(guildID)
+	recordConfigVersion(guildID, actorID, before, cfg, nil)
+	log.Printf("⚙️  Guild %s config updated by %s", guildID, actorID)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// httpEndpoint is one bot-hosted HTTP surface. Each endpoint gets its own
+// auth policy so exposing one (say, metrics) never exposes another.
+type httpEndpoint struct {
+	name    string // policy key, e.g. "metrics" -> HTTP_AUTH_METRICS
+	pattern string
+	handler http.Handler
+	ops     []apiOperation // documented in /openapi.json
+}
+
+var httpEndpoints []httpEndpoint
+
+// registerEndpoint adds an HTTP endpoint to the bot's server. Feature files
+// call this from their init functions, describing each method the endpoint
+// serves for the OpenAPI document.
+func registerEndpoint(name, pattern string, handler http.Handler, ops ...apiOperation) {
+	httpEndpoints = append(httpEndpoints, httpEndpoint{name: name, pattern: pattern, handler: handler, ops: ops})
+}
+
+func init() {
+	registerEndpoint("metrics", "/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.WriteText(w)
+	}), apiOperation{
+		Method:       http.MethodGet,
+		Summary:      "Prometheus metrics",
+		ResponseType: "text/plain",
+	})
+}
+
+// startHTTPServer serves every registered endpoint behind its auth policy.
+// It does nothing when HTTP_ADDR is unset.
+func startHTTPServer() {
+	c := config()
+	if c.HTTPAddr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	for _, ep := range httpEndpoints {
+		policy := endpointPolicy(ep.name)
+		mux.Handle(ep.pattern, requireAuth(ep.name, policy, ep.handler))
+		log.Printf("🌐 HTTP endpoint %s (%s) protected by: %s", ep.pattern, ep.name, policy)
+	}
+
+	srv := &http.Server{
+		Addr:              c.HTTPAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	tlsEnabled := c.HTTPTLSCert != "" && c.HTTPTLSKey != ""
+	if tlsEnabled {
+		tlsConfig, err := serverTLSConfig()
+		if err != nil {
+			log.Fatal("Error configuring HTTP TLS: ", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	go func() {
+		var err error
+		if tlsEnabled {
+			log.Printf("🌐 HTTPS server listening on %s", c.HTTPAddr)
+			err = srv.ListenAndServeTLS(c.HTTPTLSCert, c.HTTPTLSKey)
+		} else {
+			log.Printf("🌐 HTTP server listening on %s", c.HTTPAddr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+}
+
+// serverTLSConfig asks for (but does not require) client certificates when a
+// client CA is configured; the mtls authenticator decides per endpoint
+// whether a verified certificate is needed.
+func serverTLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config().HTTPClientCA == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(config().HTTPClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", config().HTTPClientCA)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	return cfg, nil
+}
+
+// endpointPolicy returns the authenticators accepted by an endpoint, read
+// from HTTP_AUTH_<NAME> (e.g. "token", "mtls,oidc" or "none"). Endpoints
+// default to token auth so nothing is exposed by accident.
+func endpointPolicy(name string) []string {
+	raw := os.Getenv("HTTP_AUTH_" + strings.ToUpper(name))
+	if strings.TrimSpace(raw) == "" {
+		return []string{"token"}
+	}
+	var policy []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			policy = append(policy, part)
+		}
+	}
+	return policy
+}
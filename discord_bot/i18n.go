@@ -0,0 +1,194 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Bot-authored strings are written in English in the code and used as
+// message IDs: a locale file maps each English string to its translation.
+// A string missing from a locale stays in English, so new strings never
+// break a language.
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+const defaultLanguage = "en"
+
+var (
+	catalogsMu sync.RWMutex
+	catalogs   = map[string]map[string]string{}
+)
+
+func init() {
+	entries, err := builtinLocales.ReadDir("locales")
+	if err != nil {
+		log.Printf("Error reading built-in locales: %v", err)
+		return
+	}
+	for _, e := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + e.Name())
+		if err != nil {
+			log.Printf("Error reading built-in locale %s: %v", e.Name(), err)
+			continue
+		}
+		if err := mergeCatalog(strings.TrimSuffix(e.Name(), ".json"), data); err != nil {
+			log.Printf("Invalid built-in locale %s: %v", e.Name(), err)
+		}
+	}
+	registerCommand(command{name: "language", handler: languageCommand})
+}
+
+// mergeCatalog adds a locale file's translations for lang, overriding any
+// it already has.
+func mergeCatalog(lang string, data []byte) error {
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	lang = strings.ToLower(lang)
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+	if catalogs[lang] == nil {
+		catalogs[lang] = map[string]string{}
+	}
+	for k, v := range entries {
+		catalogs[lang][k] = v
+	}
+	return nil
+}
+
+// loadLocaleDir merges LOCALES_DIR/<lang>.json files into the built-in
+// locales, so operators can fix translations or add a language.
+func loadLocaleDir() {
+	if config().LocalesDir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(config().LocalesDir, "*.json"))
+	if err != nil || len(files) == 0 {
+		log.Printf("No locale files found in %s", config().LocalesDir)
+		return
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err == nil {
+			err = mergeCatalog(strings.TrimSuffix(filepath.Base(f), ".json"), data)
+		}
+		if err != nil {
+			log.Printf("Error loading locale %s: %v", f, err)
+			continue
+		}
+	}
+	log.Printf("🌐 Loaded %d locale files from %s", len(files), config().LocalesDir)
+}
+
+// hasCatalog reports whether translations are loaded for lang.
+func hasCatalog(lang string) bool {
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// translate returns msgid in lang, or msgid itself if it isn't translated.
+func translate(lang, msgid string) string {
+	if lang == "" || lang == defaultLanguage {
+		return msgid
+	}
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+	if t, ok := catalogs[lang][msgid]; ok && t != "" {
+		return t
+	}
+	return msgid
+}
+
+// trLang translates msgid into lang and formats it with args, if any.
+func trLang(lang, msgid string, args ...interface{}) string {
+	text := translate(lang, msgid)
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// guildLanguage returns the language a guild picked with `!elsie language`,
+// or DEFAULT_LANGUAGE.
+func guildLanguage(guildID string) string {
+	if guildID != "" {
+		if lang := loadGuildConfig(guildID).Language; lang != "" {
+			return lang
+		}
+	}
+	return config().DefaultLanguage
+}
+
+// tr translates msgid into the guild's language.
+func tr(guildID, msgid string, args ...interface{}) string {
+	return trLang(guildLanguage(guildID), msgid, args...)
+}
+
+// agentLocale is the language the agent should answer in: the guild's
+// choice, or in DMs and unset guilds the user's own language.
+func agentLocale(guildID, userID string) string {
+	if guildID != "" {
+		if lang := loadGuildConfig(guildID).Language; lang != "" {
+			return lang
+		}
+	}
+	if lang := userLanguage(userID); lang != "" {
+		return lang
+	}
+	return config().DefaultLanguage
+}
+
+// lang is the language for replies to a command: the guild's, else the
+// user's own.
+func (ctx *commandContext) lang() string {
+	return agentLocale(ctx.m.GuildID, ctx.m.Author.ID)
+}
+
+// tr translates msgid for the command's reply.
+func (ctx *commandContext) tr(msgid string, args ...interface{}) string {
+	return trLang(ctx.lang(), msgid, args...)
+}
+
+// languageCommand is `!elsie language [code|default]`: the server's
+// language for bot messages and agent replies.
+func languageCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("The server language is set per server. In DMs, use `!elsie remember language <code>`."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		current := loadGuildConfig(ctx.m.GuildID).Language
+		if current == "" {
+			current = config().DefaultLanguage + " (" + ctx.tr("default") + ")"
+		}
+		ctx.reply(ctx.tr("🌐 **Server language:** %s\nAvailable: %s\nUsage: `!elsie language <code|default>`", current, strings.Join(supportedLanguages(), ", ")))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change the server language."))
+		return
+	}
+	lang := strings.ToLower(ctx.args[0])
+	if lang == "default" {
+		lang = ""
+	} else if !isSupportedLanguage(lang) {
+		ctx.reply(ctx.tr("*tilts head* I don't speak %q yet. Try one of: %s.", lang, strings.Join(supportedLanguages(), ", ")))
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.Language = lang }); err != nil {
+		log.Printf("Error saving server language: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(tr(ctx.m.GuildID, "🌐 Got it — I'll speak %s in this server.", languageName(guildLanguage(ctx.m.GuildID))))
+}
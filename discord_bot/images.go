@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// agentImage is an image in a /process response, such as generated drink
+// art or a character portrait. It has either a URL or base64 Data, which
+// may be a data: URI.
+type agentImage struct {
+	URL      string `json:"url,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	// Description is shown with the image, e.g. as alt text for players
+	// using screen readers.
+	Description string `json:"description,omitempty"`
+}
+
+// responseImage is a checked image, ready to attach.
+type responseImage struct {
+	name        string
+	contentType string
+	description string
+	data        []byte
+}
+
+// imageTypes are the image types Discord shows inline, by file extension.
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// loadResponseImages fetches or decodes the response's images, dropping any
+// that are too big or aren't images. At most IMAGE_MAX_COUNT are kept.
+func loadResponseImages(images []agentImage, rlog requestLog) []responseImage {
+	var out []responseImage
+	for i, img := range images {
+		if len(out) == config().ImageMaxCount {
+			rlog.Printf("🖼️ Dropping %d more image(s) over IMAGE_MAX_COUNT", len(images)-i)
+			break
+		}
+		loaded, err := loadResponseImage(img, len(out)+1)
+		if err != nil {
+			rlog.Printf("🖼️ Dropping image %d from the agent: %v", i+1, err)
+			metrics.Inc(metricLabel("response_images_total", "result", "rejected"))
+			continue
+		}
+		out = append(out, loaded)
+		metrics.Inc(metricLabel("response_images_total", "result", "accepted"))
+	}
+	return out
+}
+
+func loadResponseImage(img agentImage, n int) (responseImage, error) {
+	var data []byte
+	var err error
+	switch {
+	case img.Data != "":
+		data, err = decodeImageData(img.Data)
+	case img.URL != "":
+		data, err = fetchImage(img.URL)
+	default:
+		return responseImage{}, errors.New("no url or data")
+	}
+	if err != nil {
+		return responseImage{}, err
+	}
+	if len(data) > config().ImageMaxBytes {
+		return responseImage{}, fmt.Errorf("%d bytes is over IMAGE_MAX_BYTES", len(data))
+	}
+	// The bytes decide the type, whatever the agent or the server claims
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return responseImage{}, fmt.Errorf("unsupported type %s", contentType)
+	}
+	name := strings.TrimSuffix(path.Base(img.Filename), path.Ext(img.Filename))
+	if img.Filename == "" || name == "" || name == "." || name == "/" {
+		name = fmt.Sprintf("elsie-%d", n)
+	}
+	return responseImage{
+		name:        name + ext,
+		contentType: contentType,
+		description: truncateText(img.Description, 1024),
+		data:        data,
+	}, nil
+}
+
+// decodeImageData decodes base64 image data, with or without a data: URI
+// prefix.
+func decodeImageData(data string) ([]byte, error) {
+	if strings.HasPrefix(data, "data:") {
+		_, encoded, ok := strings.Cut(data, ",")
+		if !ok {
+			return nil, errors.New("malformed data URI")
+		}
+		data = encoded
+	}
+	// Check the size before decoding, so a huge payload isn't decoded
+	if base64.StdEncoding.DecodedLen(len(data)) > config().ImageMaxBytes+2 {
+		return nil, errors.New("data is over IMAGE_MAX_BYTES")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return decoded, nil
+}
+
+// imageClient downloads the images agents link to. The URLs come from
+// model output, so its dialer refuses anything but public addresses; the
+// check is on the address actually dialed, so redirects and DNS names
+// pointing inside the network are caught too. It skips any proxy, which
+// would hide the real address.
+var imageClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: publicAddressOnly,
+		}).DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// sharedAddressSpace is 100.64.0.0/10, the carrier-grade NAT range some
+// clouds put metadata services in.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddressOnly is a net.Dialer Control func refusing loopback,
+// private, link-local and other non-public addresses.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// fetchImage downloads an image over HTTP(S) with imageClient, reading at
+// most one byte more than IMAGE_MAX_BYTES so oversized images are caught
+// without loading them.
+func fetchImage(rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid image URL %q", rawURL)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config().ImageFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetching %s: %s", u.Host, resp.Status)
+	}
+	if resp.ContentLength > int64(config().ImageMaxBytes) {
+		return nil, fmt.Errorf("%d bytes is over IMAGE_MAX_BYTES", resp.ContentLength)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, int64(config().ImageMaxBytes)+1))
+}
+
+// imageFiles turns images into attachments. Readers are single use, so this
+// is called again for each send attempt.
+func imageFiles(images []responseImage) []*discordgo.File {
+	files := make([]*discordgo.File, len(images))
+	for i, img := range images {
+		files[i] = &discordgo.File{Name: img.name, ContentType: img.contentType, Reader: bytes.NewReader(img.data)}
+	}
+	return files
+}
+
+// imageCaption is the descriptions of images that have one, shown under
+// them.
+func imageCaption(images []responseImage) string {
+	var lines []string
+	for _, img := range images {
+		if img.description != "" {
+			lines = append(lines, "-# "+img.description)
+		}
+	}
+	return truncateText(strings.Join(lines, "\n"), 2000)
+}
+
+// sendImagesAs posts images as attachments in one message, from the
+// persona's webhook like sendAs, falling back to the bot.
+func sendImagesAs(s *discordgo.Session, channelID string, p *persona, images []responseImage) (*discordgo.Message, error) {
+	caption := imageCaption(images)
+	if p != nil && p.ID != defaultPersonaID {
+		hook, threadID, err := channelWebhook(s, channelID)
+		if err == nil {
+			params := &discordgo.WebhookParams{Content: caption, Username: p.Name, AvatarURL: p.AvatarURL, Files: imageFiles(images)}
+			var msg *discordgo.Message
+			if threadID != "" {
+				msg, err = s.WebhookThreadExecute(hook.ID, hook.Token, true, threadID, params)
+			} else {
+				msg, err = s.WebhookExecute(hook.ID, hook.Token, true, params)
+			}
+			if err == nil {
+				return msg, nil
+			}
+			forgetChannelWebhook(s, channelID)
+		}
+		log.Printf("Persona webhook unavailable for images in %s, sending as the bot: %v", channelID, err)
+	}
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: caption, Files: imageFiles(images)})
+}
+
+// sendReply posts a response's text and then its images, returning every
+// message sent. Either may be empty.
+func sendReply(s *discordgo.Session, channelID string, p *persona, text string, images []responseImage) ([]*discordgo.Message, error) {
+	var sent []*discordgo.Message
+	if strings.TrimSpace(text) != "" {
+		var err error
+		if sent, err = sendAs(s, channelID, p, text); err != nil {
+			return sent, err
+		}
+	}
+	if len(images) == 0 {
+		return sent, nil
+	}
+	msg, err := sendImagesAs(s, channelID, p, images)
+	if err != nil {
+		// The text got through, so only the images are lost
+		if len(sent) > 0 {
+			log.Printf("Error sending response images in %s: %v", channelID, err)
+			return sent, nil
+		}
+		return sent, err
+	}
+	return append(sent, msg), nil
+}
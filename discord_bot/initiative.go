@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const initiativeBucket = "initiative"
+
+// InitiativeEntry is one combatant in a channel's turn order.
+type InitiativeEntry struct {
+	Name string `json:"name"`
+	Roll int    `json:"roll"`
+}
+
+// Initiative is the turn order for an encounter in one channel.
+type Initiative struct {
+	Entries   []InitiativeEntry `json:"entries"`
+	Current   int               `json:"current"`
+	Round     int               `json:"round"`
+	MessageID string            `json:"message_id,omitempty"` // pinned tracker embed
+}
+
+// initiativeMu serializes changes to turn orders.
+var initiativeMu sync.Mutex
+
+func loadInitiative(channelID string) *Initiative {
+	in := &Initiative{}
+	if _, err := store.Get(initiativeBucket, channelID, in); err != nil {
+		log.Printf("Error loading initiative for %s: %v", channelID, err)
+	}
+	return in
+}
+
+// currentActor returns whose turn it is, or "" with no encounter running.
+func (in *Initiative) currentActor() string {
+	if in.Current < 0 || in.Current >= len(in.Entries) {
+		return ""
+	}
+	return in.Entries[in.Current].Name
+}
+
+// sortEntries orders combatants by roll, highest first, keeping the current
+// actor's turn.
+func (in *Initiative) sortEntries() {
+	current := in.currentActor()
+	sort.SliceStable(in.Entries, func(i, j int) bool { return in.Entries[i].Roll > in.Entries[j].Roll })
+	for i, e := range in.Entries {
+		if e.Name == current {
+			in.Current = i
+		}
+	}
+}
+
+func (in *Initiative) find(name string) int {
+	for i, e := range in.Entries {
+		if strings.EqualFold(e.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (in *Initiative) embed(guildID string) *discordgo.MessageEmbed {
+	var lines []string
+	for i, e := range in.Entries {
+		marker := "▫️"
+		if i == in.Current {
+			marker = "▶️"
+		}
+		lines = append(lines, fmt.Sprintf("%s **%s** — %d", marker, e.Name, e.Roll))
+	}
+	if len(lines) == 0 {
+		lines = []string{"No combatants yet. Add one with `!elsie init add <name> [roll]`."}
+	}
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("⚔️ Initiative — Round %d", in.Round),
+		Description: strings.Join(lines, "\n"),
+		Color:       themeColor(guildID, "danger"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "!elsie init next • add • remove • end"},
+	}
+}
+
+// contextFields describes the encounter for the agent so narration follows
+// the turn.
+func (in *Initiative) contextFields() map[string]interface{} {
+	order := make([]string, 0, len(in.Entries))
+	for _, e := range in.Entries {
+		order = append(order, e.Name)
+	}
+	return map[string]interface{}{
+		"current_actor": in.currentActor(),
+		"round":         in.Round,
+		"order":         order,
+	}
+}
+
+// initiativeContext returns the channel's encounter for the agent context,
+// or nil when there is none.
+func initiativeContext(channelID string) map[string]interface{} {
+	in := loadInitiative(channelID)
+	if len(in.Entries) == 0 {
+		return nil
+	}
+	return in.contextFields()
+}
+
+// showInitiative posts the tracker embed and pins it the first time, then
+// edits it in place on later changes.
+func showInitiative(s *discordgo.Session, guildID, channelID string, in *Initiative) {
+	if in.MessageID != "" {
+		if _, err := s.ChannelMessageEditEmbed(channelID, in.MessageID, in.embed(guildID)); err == nil {
+			return
+		}
+		// The tracker was deleted by hand; post a fresh one.
+	}
+	msg, err := s.ChannelMessageSendEmbed(channelID, in.embed(guildID))
+	if err != nil {
+		log.Printf("Error posting initiative tracker in %s: %v", channelID, err)
+		return
+	}
+	in.MessageID = msg.ID
+	if err := s.ChannelMessagePin(channelID, msg.ID); err != nil {
+		log.Printf("Error pinning initiative tracker in %s: %v", channelID, err)
+	}
+}
+
+func init() {
+	registerCommand(command{name: "init", handler: initiativeCommand})
+	registerCommand(command{name: "initiative", handler: initiativeCommand})
+}
+
+// initiativeCommand is `!elsie init [add <name> [roll]|remove <name>|next|end]`.
+func initiativeCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie init add <name> [roll]`, `!elsie init remove <name>`, `!elsie init next`, `!elsie init end`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Initiative is tracked per channel — use this command in a server channel.")
+		return
+	}
+
+	initiativeMu.Lock()
+	defer initiativeMu.Unlock()
+	channelID := ctx.m.ChannelID
+	in := loadInitiative(channelID)
+
+	sub := ""
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	switch sub {
+	case "":
+		if len(in.Entries) == 0 {
+			ctx.reply("⚔️ No encounter running here.\n" + usage)
+			return
+		}
+		in.MessageID = "" // re-post the tracker where everyone can see it
+	case "add":
+		args := ctx.args[1:]
+		if len(args) == 0 {
+			ctx.reply(usage)
+			return
+		}
+		roll, err := strconv.Atoi(args[len(args)-1])
+		if err == nil && len(args) > 1 {
+			args = args[:len(args)-1]
+		} else {
+			roll = rand.Intn(20) + 1
+		}
+		name := strings.Join(args, " ")
+		if i := in.find(name); i >= 0 {
+			in.Entries[i].Roll = roll
+		} else {
+			in.Entries = append(in.Entries, InitiativeEntry{Name: name, Roll: roll})
+		}
+		if in.Round == 0 {
+			in.Round = 1
+		}
+		in.sortEntries()
+		ctx.reply(fmt.Sprintf("⚔️ **%s** rolls initiative: %d", name, roll))
+	case "remove":
+		name := strings.Join(ctx.args[1:], " ")
+		i := in.find(name)
+		if i < 0 {
+			ctx.reply(fmt.Sprintf("*checks the list* There's no %q in this fight.", name))
+			return
+		}
+		in.Entries = append(in.Entries[:i], in.Entries[i+1:]...)
+		if i < in.Current || in.Current >= len(in.Entries) {
+			in.Current--
+		}
+		if in.Current < 0 {
+			in.Current = 0
+		}
+	case "next":
+		if len(in.Entries) == 0 {
+			ctx.reply("⚔️ No one has rolled initiative yet.")
+			return
+		}
+		in.Current++
+		if in.Current >= len(in.Entries) {
+			in.Current = 0
+			in.Round++
+		}
+		ctx.reply(fmt.Sprintf("▶️ Round %d: **%s**, you're up.", in.Round, in.currentActor()))
+	case "end", "clear":
+		if in.MessageID != "" {
+			ctx.s.ChannelMessageUnpin(channelID, in.MessageID)
+		}
+		if err := store.Delete(initiativeBucket, channelID); err != nil {
+			log.Printf("Error clearing initiative for %s: %v", channelID, err)
+		}
+		ctx.reply("⚔️ *Elsie wipes down the tactical display.* Encounter over.")
+		return
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	showInitiative(ctx.s, ctx.m.GuildID, channelID, in)
+	if err := store.Put(initiativeBucket, channelID, in); err != nil {
+		log.Printf("Error saving initiative for %s: %v", channelID, err)
+		ctx.reply("*holographic matrix flickers* I couldn't save the turn order. Please try again later.")
+	}
+}
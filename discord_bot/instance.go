@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// instanceLock is the heartbeat file that keeps two copies of the bot from
+// running against the same data directory and token, which makes every
+// message get two answers.
+type instanceLock struct {
+	InstanceID string    `json:"instance_id"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	Started    time.Time `json:"started"`
+	Heartbeat  time.Time `json:"heartbeat"`
+}
+
+var (
+	// instanceID identifies this process in the lock file.
+	instanceID = newRequestID()
+
+	// instanceLost is closed when another instance takes the lock over; main
+	// then shuts down quietly.
+	instanceLost = make(chan struct{})
+)
+
+func instanceLockPath() string {
+	return filepath.Join(DataDir, "instance.lock")
+}
+
+// readInstanceLock returns the current lock holder, or nil if there is none.
+func readInstanceLock() (*instanceLock, error) {
+	data, err := os.ReadFile(instanceLockPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading instance lock: %w", err)
+	}
+	var lock instanceLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parsing instance lock: %w", err)
+	}
+	return &lock, nil
+}
+
+// writeInstanceLock records this process as the holder, via a temp file
+// and rename like the store.
+func writeInstanceLock(lock instanceLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding instance lock: %w", err)
+	}
+	if err := os.MkdirAll(DataDir, 0o755); err != nil {
+		return fmt.Errorf("creating data directory: %w", err)
+	}
+	tmp := instanceLockPath() + "." + instanceID
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing instance lock: %w", err)
+	}
+	return os.Rename(tmp, instanceLockPath())
+}
+
+// acquireInstanceLock runs before anything touches the store or the
+// gateway. If another instance's heartbeat is fresh, it alerts the admin
+// channel over REST and exits; a stale lock is taken over.
+func acquireInstanceLock(s *discordgo.Session) {
+	if !config().InstanceLockEnabled {
+		return
+	}
+	holder, err := readInstanceLock()
+	if err != nil {
+		log.Printf("Ignoring unreadable instance lock: %v", err)
+	}
+	if holder != nil && time.Since(holder.Heartbeat) < config().InstanceLockStale {
+		msg := fmt.Sprintf("🚨 **Duplicate instance refused.** Another copy of Elsie is already running (host `%s`, pid %d, started %s, last heartbeat %s ago). This one on `%s` is exiting.",
+			holder.Host, holder.PID, holder.Started.Format(time.RFC3339), time.Since(holder.Heartbeat).Round(time.Second), hostname())
+		log.Print(msg)
+		postToAdminChannel(s, msg)
+		os.Exit(1)
+	}
+	if holder != nil {
+		log.Printf("🔒 Taking over stale instance lock from %s (pid %d, last heartbeat %s ago)",
+			holder.Host, holder.PID, time.Since(holder.Heartbeat).Round(time.Second))
+	}
+
+	now := time.Now().UTC()
+	lock := instanceLock{InstanceID: instanceID, Host: hostname(), PID: os.Getpid(), Started: now, Heartbeat: now}
+	if err := writeInstanceLock(lock); err != nil {
+		log.Printf("Error writing instance lock: %v", err)
+		return
+	}
+	go heartbeatInstanceLock(s, lock)
+}
+
+// heartbeatInstanceLock refreshes the lock until another instance takes it
+// over, e.g. after this process was paused long enough to look stale.
+func heartbeatInstanceLock(s *discordgo.Session, lock instanceLock) {
+	ticker := time.NewTicker(config().InstanceHeartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		holder, err := readInstanceLock()
+		if err != nil {
+			log.Printf("Error checking instance lock: %v", err)
+			continue
+		}
+		if holder != nil && holder.InstanceID != instanceID {
+			msg := fmt.Sprintf("🚨 **Instance lock taken over** by host `%s` (pid %d). This copy on `%s` is standing down.", holder.Host, holder.PID, hostname())
+			log.Print(msg)
+			postToAdminChannel(s, msg)
+			close(instanceLost)
+			return
+		}
+		lock.Heartbeat = time.Now().UTC()
+		if err := writeInstanceLock(lock); err != nil {
+			log.Printf("Error refreshing instance lock: %v", err)
+		}
+	}
+}
+
+// releaseInstanceLock removes the lock on a clean shutdown, if it is still
+// ours, so the next start doesn't wait for it to go stale.
+func releaseInstanceLock() {
+	if !config().InstanceLockEnabled {
+		return
+	}
+	if holder, err := readInstanceLock(); err == nil && holder != nil && holder.InstanceID == instanceID {
+		if err := os.Remove(instanceLockPath()); err != nil {
+			log.Printf("Error removing instance lock: %v", err)
+		}
+	}
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// slashCommand is an application command and the handler that serves it.
+type slashCommand struct {
+	def     *discordgo.ApplicationCommand
+	handler func(s *discordgo.Session, i *discordgo.InteractionCreate)
+}
+
+var (
+	slashCommands = map[string]slashCommand{}
+	// componentHandlers are keyed by custom ID prefix; custom IDs look like
+	// "<prefix>:<payload>".
+	componentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate, payload string){}
+)
+
+// registerSlashCommand adds an application command. Feature files call this
+// from their init functions; commands are published to Discord on ready.
+func registerSlashCommand(def *discordgo.ApplicationCommand, handler func(s *discordgo.Session, i *discordgo.InteractionCreate)) {
+	slashCommands[def.Name] = slashCommand{def: def, handler: handler}
+}
+
+// registerComponentHandler routes button, select menu and modal interactions
+// whose custom ID starts with prefix.
+func registerComponentHandler(prefix string, handler func(s *discordgo.Session, i *discordgo.InteractionCreate, payload string)) {
+	componentHandlers[prefix] = handler
+}
+
+// publishSlashCommands overwrites the bot's application commands with the
+// registered set. SLASH_COMMAND_GUILD_ID publishes to one guild instead,
+// which updates instantly during development.
+func publishSlashCommands(s *discordgo.Session) {
+	defs := make([]*discordgo.ApplicationCommand, 0, len(slashCommands))
+	for _, cmd := range slashCommands {
+		localizeSlashCommand(cmd.def)
+		defs = append(defs, cmd.def)
+	}
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, config().SlashCommandGuildID, defs); err != nil {
+		log.Printf("Error publishing slash commands: %v", err)
+		return
+	}
+	log.Printf("⚡ Published %d slash commands", len(defs))
+}
+
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !botReady.Load() {
+		respondEphemeral(s, i, "*Elsie is still running her startup diagnostics.* Try again in a moment.")
+		return
+	}
+	if safeMode.Load() {
+		respondEphemeral(s, i, "🛟 Elsie is in safe mode and only answers `!elsie` commands for now.")
+		return
+	}
+	if reason := userExclusion(i.GuildID, interactionUser(i).ID); reason != "" {
+		respondEphemeral(s, i, exclusionNotice(i.GuildID, reason))
+		return
+	}
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		name := i.ApplicationCommandData().Name
+		cmd, ok := slashCommands[name]
+		if !ok {
+			return
+		}
+		if denial, ok := slashCommandAllowed(s, i, name); !ok {
+			respondEphemeral(s, i, denial)
+			return
+		}
+		user := interactionUser(i)
+		if ok, wait := checkCooldown(user.ID, name); !ok {
+			respondEphemeral(s, i, fmt.Sprintf("⏳ `/%s` is cooling down. Try again in %s.", name, wait.Round(time.Second)))
+			return
+		}
+		if ok, scope, wait := consumeQuota(i.GuildID, i.ChannelID, user.ID); !ok {
+			respondEphemeral(s, i, quotaMessage(i.GuildID, scope, wait))
+			return
+		}
+		cmd.handler(s, i)
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		prefix, payload, _ := strings.Cut(customID, ":")
+		if handler, ok := componentHandlers[prefix]; ok {
+			handler(s, i, payload)
+		}
+	case discordgo.InteractionModalSubmit:
+		// Modals share the component prefixes of the buttons that open them
+		prefix, payload, _ := strings.Cut(i.ModalSubmitData().CustomID, ":")
+		if handler, ok := componentHandlers[prefix]; ok {
+			handler(s, i, payload)
+		}
+	}
+}
+
+// interactionUser returns the invoking user in guilds and DMs alike.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// respondEphemeral answers an interaction with a message only the invoker
+// can see.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to interaction: %v", err)
+	}
+}
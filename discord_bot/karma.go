@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const karmaBucket = "karma"
+
+// Karma is a member's standing in one guild. Points come from tips and
+// thanks; Tipped counts what they gave away with `!elsie tip`.
+type Karma struct {
+	Points int `json:"points"`
+	Thanks int `json:"thanks,omitempty"`
+	Tipped int `json:"tipped,omitempty"`
+}
+
+var (
+	// karmaMu serializes changes to karma.
+	karmaMu sync.Mutex
+
+	// karmaCooldowns remembers who recently tipped or thanked whom, keyed
+	// by "guild:giver:recipient", so karma can't be farmed.
+	karmaCooldowns = newLRUCache[string, time.Time]("karma_cooldowns", 10000, 24*time.Hour)
+)
+
+// thanksPattern matches a thank-you anywhere in a message.
+var thanksPattern = regexp.MustCompile(`(?i)\b(thanks|thank you|thank u|thx|ty|tysm|cheers|danke|gracias|merci)\b`)
+
+func init() {
+	trackCache(karmaCooldowns)
+	registerCommand(command{name: "tip", handler: tipCommand})
+	registerCommand(command{name: "karma", handler: karmaCommand})
+	registerDataEraser(dataEraser{name: "karma", user: eraseGuildMapEntry(karmaBucket, &karmaMu), guild: eraseGuildKey(karmaBucket)})
+}
+
+// loadKarma returns the guild's karma keyed by user ID.
+func loadKarma(guildID string) map[string]*Karma {
+	karma := map[string]*Karma{}
+	if _, err := store.Get(karmaBucket, guildID, &karma); err != nil {
+		log.Printf("Error loading karma for %s: %v", guildID, err)
+	}
+	return karma
+}
+
+// karmaCooling reports whether giverID gave recipientID karma within
+// KARMA_COOLDOWN, and starts the cooldown if not.
+func karmaCooling(guildID, giverID, recipientID string) bool {
+	key := guildID + ":" + giverID + ":" + recipientID
+	if last, ok := karmaCooldowns.Get(key); ok && time.Since(last) < config().KarmaCooldown {
+		return true
+	}
+	karmaCooldowns.Add(key, time.Now())
+	return false
+}
+
+// addKarma gives recipientID points from giverID. A tip also counts
+// towards the giver's Tipped total, which is returned.
+func addKarma(guildID, giverID, recipientID string, points int, tip bool) (tipped int, err error) {
+	karmaMu.Lock()
+	defer karmaMu.Unlock()
+	karma := loadKarma(guildID)
+	entry := func(id string) *Karma {
+		if karma[id] == nil {
+			karma[id] = &Karma{}
+		}
+		return karma[id]
+	}
+	entry(recipientID).Points += points
+	if tip {
+		entry(giverID).Tipped += points
+		tipped = karma[giverID].Tipped
+	} else {
+		karma[recipientID].Thanks++
+	}
+	return tipped, store.Put(karmaBucket, guildID, karma)
+}
+
+// awardThanksKarma gives a point to the member a message thanks, either by
+// replying to them or mentioning them. It never stops the message from
+// being handled further.
+func awardThanksKarma(s *discordgo.Session, m *discordgo.MessageCreate, content string) {
+	if config().KarmaCooldown <= 0 || !thanksPattern.MatchString(content) {
+		return
+	}
+	var recipient *discordgo.User
+	if m.ReferencedMessage != nil && m.ReferencedMessage.Author != nil {
+		recipient = m.ReferencedMessage.Author
+	} else {
+		for _, u := range m.Mentions {
+			if !u.Bot && u.ID != m.Author.ID {
+				recipient = u
+				break
+			}
+		}
+	}
+	if recipient == nil || recipient.Bot || recipient.ID == m.Author.ID || karmaCooling(m.GuildID, m.Author.ID, recipient.ID) {
+		return
+	}
+	if _, err := addKarma(m.GuildID, m.Author.ID, recipient.ID, 1, false); err != nil {
+		log.Printf("Error saving karma: %v", err)
+		return
+	}
+	metrics.Inc(metricLabel("karma_awarded_total", "source", "thanks"))
+}
+
+// tipCommand is `!elsie tip @user [amount]`.
+func tipCommand(ctx *commandContext) {
+	c := config()
+	usage := ctx.tr("Usage: `!elsie tip @user [1-%d]`", c.KarmaMaxTip)
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Tips are per server — use this command in a server channel."))
+		return
+	}
+	if len(ctx.m.Mentions) == 0 {
+		ctx.reply(usage)
+		return
+	}
+	recipient := ctx.m.Mentions[0]
+	amount := 1
+	if len(ctx.args) > 1 {
+		n, err := strconv.Atoi(ctx.args[len(ctx.args)-1])
+		if err != nil || n < 1 || n > c.KarmaMaxTip {
+			ctx.reply(usage)
+			return
+		}
+		amount = n
+	}
+	switch {
+	case recipient.ID == ctx.m.Author.ID:
+		ctx.reply(ctx.tr("*Elsie raises an eyebrow* Tipping yourself? Nice try."))
+		return
+	case recipient.Bot:
+		ctx.reply(ctx.tr("*Elsie smiles* Keep it — bots don't take tips."))
+		return
+	case karmaCooling(ctx.m.GuildID, ctx.m.Author.ID, recipient.ID):
+		ctx.reply(ctx.tr("*Elsie slides your coins back* You tipped them recently. Try again later."))
+		return
+	}
+	tipped, err := addKarma(ctx.m.GuildID, ctx.m.Author.ID, recipient.ID, amount, true)
+	if err != nil {
+		log.Printf("Error saving karma: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	metrics.Inc(metricLabel("karma_awarded_total", "source", "tip"))
+	ctx.reply(ctx.tr("🪙 <@%s> tipped <@%s> %d karma.", ctx.m.Author.ID, recipient.ID, amount))
+
+	// Elsie notices the generous ones each time they pass a milestone
+	if c.KarmaBigTipper > 0 && (tipped-amount)/c.KarmaBigTipper < tipped/c.KarmaBigTipper {
+		ctx.reply(themePhrase(ctx.m.GuildID, "big_tipper", map[string]interface{}{
+			"Who":   "<@" + ctx.m.Author.ID + ">",
+			"Total": tipped,
+		}))
+	}
+}
+
+// karmaCommand is `!elsie karma [@user|top]`.
+func karmaCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Karma is per server — use this command in a server channel."))
+		return
+	}
+	karmaMu.Lock()
+	karma := loadKarma(ctx.m.GuildID)
+	karmaMu.Unlock()
+	if len(ctx.args) > 0 && strings.EqualFold(ctx.args[0], "top") {
+		showKarmaLeaderboard(ctx, karma)
+		return
+	}
+	userID := ctx.m.Author.ID
+	if len(ctx.m.Mentions) > 0 {
+		userID = ctx.m.Mentions[0].ID
+	}
+	k := karma[userID]
+	if k == nil {
+		k = &Karma{}
+	}
+	ctx.reply(ctx.tr("🪙 <@%s> has %d karma (%d from thanks) and has tipped %d.", userID, k.Points, k.Thanks, k.Tipped))
+}
+
+// showKarmaLeaderboard posts the guild's karma standings.
+func showKarmaLeaderboard(ctx *commandContext, karma map[string]*Karma) {
+	points := make(map[string]int, len(karma))
+	for id, k := range karma {
+		if k.Points > 0 {
+			points[id] = k.Points
+		}
+	}
+	ranked := rankScores(points)
+	if len(ranked) == 0 {
+		ctx.reply(ctx.tr("🪙 Nobody has any karma yet. Tip someone with `!elsie tip @user`, or thank them in a reply."))
+		return
+	}
+	medals := []string{"🥇", "🥈", "🥉"}
+	var lines []string
+	for i, e := range ranked {
+		if i == 10 {
+			break
+		}
+		marker := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) {
+			marker = medals[i]
+		}
+		lines = append(lines, ctx.tr("%s <@%s> — %d karma", marker, e.userID, e.points))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       ctx.tr("🪙 Karma leaderboard"),
+		Description: strings.Join(lines, "\n"),
+		Color:       themeColor(ctx.m.GuildID, "highlight"),
+	}
+	if _, err := ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, embed); err != nil {
+		log.Printf("Error posting karma leaderboard: %v", err)
+	}
+}
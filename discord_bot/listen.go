@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() {
+	registerCommand(command{name: "listen", handler: func(ctx *commandContext) { listenCommand(ctx, true) }})
+	registerCommand(command{name: "speak", handler: func(ctx *commandContext) { listenCommand(ctx, false) }})
+}
+
+// channelListening reports whether Elsie only observes a channel. Threads
+// inherit their parent's mode.
+func channelListening(s *discordgo.Session, guildID, channelID string) bool {
+	if guildID == "" {
+		return false
+	}
+	listening := loadGuildConfig(guildID).ListeningChannels
+	if len(listening) == 0 {
+		return false
+	}
+	if slices.Contains(listening, channelID) {
+		return true
+	}
+	channel, err := getChannel(s, channelID)
+	return err == nil && isThreadChannel(channel) && slices.Contains(listening, channel.ParentID)
+}
+
+// listenCommand is `!elsie listen [#channel]` and `!elsie speak [#channel]`.
+// Without a channel the current channel or thread is changed.
+func listenCommand(ctx *commandContext, listen bool) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Listening mode is per channel — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change whether I speak in a channel."))
+		return
+	}
+	channelID := ctx.m.ChannelID
+	if len(ctx.args) > 0 {
+		if channelID = parseChannelMention(ctx.args[0]); channelID == "" {
+			ctx.reply(ctx.tr("Mention the channel, e.g. `!elsie listen #briefing-room`."))
+			return
+		}
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		cfg.ListeningChannels = slices.DeleteFunc(cfg.ListeningChannels, func(id string) bool { return id == channelID })
+		if listen {
+			cfg.ListeningChannels = append(cfg.ListeningChannels, channelID)
+		}
+	})
+	if err != nil {
+		log.Printf("Error saving listening mode: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if !listen {
+		ctx.reply(ctx.tr("🗣️ I'll join in again in <#%s>.", channelID))
+		return
+	}
+	ctx.reply(ctx.tr("👂 I'll just listen in <#%s>: I keep following the conversation but won't chime in. Mention me or use a command if you need me, and `!elsie speak` to have me join in again.", channelID))
+}
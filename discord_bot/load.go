@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyEWMAWeight is how much each new /process latency moves the
+// running average.
+const latencyEWMAWeight = 0.2
+
+// defaultAmbientInterval spaces ambient requests in a channel while the
+// agent has asked the bot to slow down and didn't say by how much.
+const defaultAmbientInterval = 30 * time.Second
+
+// poolLoad is the bot's view of how loaded a pool's agents are: requests
+// still waiting on /process and a moving average of how long it answers.
+type poolLoad struct {
+	inFlight atomic.Int64
+	mu       sync.Mutex
+	ewma     time.Duration
+}
+
+func (l *poolLoad) begin(pool string) time.Time {
+	depth := l.inFlight.Add(1)
+	metrics.Set(metricLabel("agent_queue_depth", "pool", pool), float64(depth))
+	noteQueueDepth(pool, depth)
+	return time.Now()
+}
+
+func (l *poolLoad) end(pool string, start time.Time, ok bool) {
+	metrics.Set(metricLabel("agent_queue_depth", "pool", pool), float64(l.inFlight.Add(-1)))
+	noteAgentCall(ok)
+	if !ok {
+		return
+	}
+	took := time.Since(start)
+	l.mu.Lock()
+	if l.ewma == 0 {
+		l.ewma = took
+	} else {
+		l.ewma = time.Duration(latencyEWMAWeight*float64(took) + (1-latencyEWMAWeight)*float64(l.ewma))
+	}
+	ewma := l.ewma
+	l.mu.Unlock()
+	metrics.Set("agent_latency_ewma_seconds", ewma.Seconds())
+}
+
+// hints returns the load_hints context sent with each /process request.
+// queue_depth counts this request's peers, not the request itself.
+func (l *poolLoad) hints() map[string]interface{} {
+	l.mu.Lock()
+	ewma := l.ewma
+	l.mu.Unlock()
+	depth := l.inFlight.Load() - 1
+	if depth < 0 {
+		depth = 0
+	}
+	return map[string]interface{}{
+		"recent_latency_ms": ewma.Milliseconds(),
+		"queue_depth":       depth,
+		"slowed_down":       slowDownActive(),
+	}
+}
+
+// shedAmbient reports whether personaID's agents have LOAD_SHED_DEPTH or
+// more requests waiting, so monitored-channel chatter should be dropped to
+// keep mentions and DMs moving.
+func shedAmbient(personaID string) bool {
+	if config().LoadShedDepth <= 0 {
+		return false
+	}
+	return poolFor(personaID).load.inFlight.Load() >= int64(config().LoadShedDepth)
+}
+
+// slowDownHint is the agent's `slow_down` response field: for Duration,
+// answer ambient channel traffic at most once per AmbientInterval.
+type slowDownHint struct {
+	Duration        string `json:"duration"`
+	AmbientInterval string `json:"ambient_interval,omitempty"`
+}
+
+var (
+	slowDownMu       sync.Mutex
+	slowDownUntil    time.Time
+	slowDownInterval time.Duration
+	lastAmbient      = newLRUCache[string, time.Time]("ambient_throttle", 5000, time.Hour)
+)
+
+func init() {
+	trackCache(lastAmbient)
+}
+
+// applySlowDown honors a slow_down hint, capped at LOAD_SLOWDOWN_MAX. A
+// later hint replaces an earlier one, so the agent can also lift it early
+// with a zero duration.
+func applySlowDown(hint *slowDownHint, rlog requestLog) {
+	if hint == nil || !config().LoadHintsEnabled {
+		return
+	}
+	d, err := time.ParseDuration(hint.Duration)
+	if err != nil || d < 0 {
+		rlog.Printf("⚠️  Ignoring slow_down hint with duration %q", hint.Duration)
+		return
+	}
+	if d > config().LoadSlowDownMax {
+		d = config().LoadSlowDownMax
+	}
+	interval := defaultAmbientInterval
+	if hint.AmbientInterval != "" {
+		if i, err := time.ParseDuration(hint.AmbientInterval); err == nil && i > 0 {
+			interval = i
+		}
+	}
+
+	slowDownMu.Lock()
+	slowDownUntil = time.Now().Add(d)
+	slowDownInterval = interval
+	slowDownMu.Unlock()
+	if d > 0 {
+		rlog.Printf("🐢 Agent asked to slow ambient traffic for %s (one per %s per channel)", d, interval)
+		metrics.Inc("agent_slow_down_hints_total")
+	} else {
+		rlog.Printf("🐇 Agent lifted the ambient slow-down")
+	}
+}
+
+func slowDownActive() bool {
+	slowDownMu.Lock()
+	defer slowDownMu.Unlock()
+	return time.Now().Before(slowDownUntil)
+}
+
+// ambientThrottled reports whether an ambient message in channelID should
+// be skipped under an active slow-down, and otherwise counts it as the
+// channel's latest ambient request.
+func ambientThrottled(channelID string) bool {
+	slowDownMu.Lock()
+	defer slowDownMu.Unlock()
+	now := time.Now()
+	if !now.Before(slowDownUntil) {
+		return false
+	}
+	if last, ok := lastAmbient.Get(channelID); ok && now.Sub(last) < slowDownInterval {
+		metrics.Inc("ambient_throttled_total")
+		return true
+	}
+	lastAmbient.Add(channelID, now)
+	return false
+}
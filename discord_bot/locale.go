@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// languageLocales are the languages commands are localized into, keyed by
+// the code players use with `!elsie remember language <code>`.
+var languageLocales = map[string][]discordgo.Locale{
+	"en": {discordgo.EnglishUS, discordgo.EnglishGB},
+	"de": {discordgo.German},
+	"es": {discordgo.SpanishES},
+	"fr": {discordgo.French},
+}
+
+// commandAliases are localized names for prefix commands, per language.
+var commandAliases = map[string]map[string]string{
+	"de": {
+		"hilfe": "help", "erinnern": "remember", "vergessen": "forget",
+		"sternzeit": "stardate", "umrechnen": "convert", "initiative": "init",
+	},
+	"es": {
+		"ayuda": "help", "estado": "status", "recordar": "remember", "olvidar": "forget",
+		"fechaestelar": "stardate", "convertir": "convert", "iniciativa": "init",
+	},
+	"fr": {
+		"aide": "help", "statut": "status", "retiens": "remember", "oublie": "forget",
+		"datestellaire": "stardate", "convertir": "convert", "initiative": "init",
+	},
+}
+
+// slashText is a localized slash command name and description.
+type slashText struct {
+	name        string
+	description string
+}
+
+// slashLocalizations translate slash commands and their options, keyed by
+// command name, then option name ("" for the command itself).
+var slashLocalizations = map[string]map[string]map[string]slashText{
+	"order": {
+		"": {
+			"de": {"bestellen", "Bestelle ein Getränk bei Elsie"},
+			"es": {"pedir", "Pide una bebida a Elsie"},
+			"fr": {"commander", "Commande une boisson à Elsie"},
+		},
+	},
+}
+
+// languageNames are the languages' own names, for confirmations.
+var languageNames = map[string]string{
+	"en": "English",
+	"de": "Deutsch",
+	"es": "Español",
+	"fr": "Français",
+}
+
+// isSupportedLanguage reports whether code is a built-in language or one
+// added through LOCALES_DIR.
+func isSupportedLanguage(code string) bool {
+	code = strings.ToLower(code)
+	_, ok := languageLocales[code]
+	return ok || hasCatalog(code)
+}
+
+// supportedLanguages lists the language codes players and servers can pick.
+func supportedLanguages() []string {
+	codes := sortedKeys(languageLocales)
+	catalogsMu.RLock()
+	for code := range catalogs {
+		if _, ok := languageLocales[code]; !ok {
+			codes = append(codes, code)
+		}
+	}
+	catalogsMu.RUnlock()
+	sort.Strings(codes)
+	return codes
+}
+
+func languageName(code string) string {
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// userLanguage returns the user's chosen language, or "" if they never
+// picked one.
+func userLanguage(userID string) string {
+	if p := loadProfile(userID); p != nil {
+		return p.Language
+	}
+	return ""
+}
+
+// resolveCommandAlias maps a localized command name to its canonical name.
+// The user's own language wins when two languages share an alias.
+func resolveCommandAlias(name, language string) (string, bool) {
+	if canonical, ok := commandAliases[language][name]; ok {
+		return canonical, true
+	}
+	for _, lang := range sortedKeys(commandAliases) {
+		if canonical, ok := commandAliases[lang][name]; ok {
+			return canonical, true
+		}
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// aliasHelp lists the localized command names for the help message.
+func aliasHelp(language string) string {
+	aliases := commandAliases[language]
+	if len(aliases) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, alias := range sortedKeys(aliases) {
+		parts = append(parts, fmt.Sprintf("`%s` → `%s`", alias, aliases[alias]))
+	}
+	return "\n\n**" + strings.ToUpper(language) + ":** " + strings.Join(parts, ", ")
+}
+
+// localizeSlashCommand fills Discord's localization fields for def and its
+// options from slashLocalizations.
+func localizeSlashCommand(def *discordgo.ApplicationCommand) {
+	texts := slashLocalizations[def.Name]
+	if texts == nil {
+		return
+	}
+	names, descriptions := localizedMaps(texts[""])
+	if len(names) > 0 {
+		def.NameLocalizations = &names
+		def.DescriptionLocalizations = &descriptions
+	}
+	for _, opt := range def.Options {
+		opt.NameLocalizations, opt.DescriptionLocalizations = localizedMaps(texts[opt.Name])
+	}
+}
+
+func localizedMaps(byLanguage map[string]slashText) (names, descriptions map[discordgo.Locale]string) {
+	names = make(map[discordgo.Locale]string)
+	descriptions = make(map[discordgo.Locale]string)
+	for lang, text := range byLanguage {
+		for _, locale := range languageLocales[lang] {
+			names[locale] = text.name
+			descriptions[locale] = text.description
+		}
+	}
+	return names, descriptions
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache is a size-bounded, optionally TTL-bound cache. When the cache is
+// full the least recently used entry is evicted to make room, so memory use
+// stays flat no matter how many guilds or channels the bot sees.
+type lruCache[K comparable, V any] struct {
+	mu        sync.Mutex
+	name      string
+	capacity  int
+	ttl       time.Duration
+	items     map[K]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// cacheStats is a point-in-time view of a cache used by the status command
+// and the metrics registry.
+type cacheStats struct {
+	Name      string
+	Size      int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// newLRUCache creates a cache holding at most capacity entries. A zero ttl
+// means entries only leave the cache through LRU eviction.
+func newLRUCache[K comparable, V any](name string, capacity int, ttl time.Duration) *lruCache[K, V] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &lruCache[K, V]{
+		name:     name,
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the cached value for key if present and not expired.
+func (c *lruCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.removeElement(elem)
+		c.misses++
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits++
+	return entry.value, true
+}
+
+// Add inserts or refreshes key, evicting the least recently used entry when
+// the cache is at capacity.
+func (c *lruCache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, value)
+}
+
+// AddIfAbsent inserts key unless it is already cached and unexpired, and
+// reports whether it did. Check and insert happen under one lock, so of
+// several concurrent callers with the same key exactly one wins.
+func (c *lruCache[K, V]) AddIfAbsent(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		if c.ttl == 0 || time.Now().Before(entry.expires) {
+			return false
+		}
+	}
+	c.add(key, value)
+	return true
+}
+
+// add is Add with c.mu held.
+func (c *lruCache[K, V]) add(key K, value V) {
+	expires := time.Time{}
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Contains reports whether key is cached without updating its recency.
+func (c *lruCache[K, V]) Contains(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	return c.ttl == 0 || time.Now().Before(entry.expires)
+}
+
+// Remove drops key from the cache if present.
+func (c *lruCache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Values returns the unexpired values, most recently used first, without
+// updating their recency.
+func (c *lruCache[K, V]) Values() []V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	values := make([]V, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*lruEntry[K, V])
+		if c.ttl == 0 || now.Before(entry.expires) {
+			values = append(values, entry.value)
+		}
+	}
+	return values
+}
+
+// Len returns the number of entries currently held, including any expired
+// entries not yet swept.
+func (c *lruCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// PurgeExpired removes every expired entry and returns how many were dropped.
+func (c *lruCache[K, V]) PurgeExpired() int {
+	if c.ttl == 0 {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	purged := 0
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*lruEntry[K, V]).expires) {
+			c.removeElement(elem)
+			c.evictions++
+			purged++
+		}
+		elem = prev
+	}
+	return purged
+}
+
+// Stats returns the cache's current size and counters.
+func (c *lruCache[K, V]) Stats() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cacheStats{
+		Name:      c.name,
+		Size:      c.order.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry[K, V]).key)
+}
@@ -1,29 +1,34 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/joho/godotenv"
 )
 
 var (
-	Token      string
-	AIAgentURL string
+	Token   string
+	DataDir string
+
+	// botSession is the live Discord session, for code that runs outside
+	// gateway event handlers (HTTP endpoints, background jobs).
+	botSession *discordgo.Session
 )
 
 type Message struct {
-	Message string                 `json:"message"`
-	Context map[string]interface{} `json:"context"`
+	Message   string                 `json:"message"`
+	Context   map[string]interface{} `json:"context"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Persona   string                 `json:"persona,omitempty"`
+	// SchemaVersion is the payload version, see agentSchemaVersion.
+	SchemaVersion int `json:"schema_version,omitempty"`
 }
 
 type AIResponse struct {
@@ -32,18 +37,33 @@ type AIResponse struct {
 	Context   map[string]interface{} `json:"context"`
 	SessionID string                 `json:"session_id"`
 	Bartender string                 `json:"bartender"`
+	Recap     bool                   `json:"recap,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	FollowUp  *followUpRequest       `json:"follow_up_after,omitempty"`
+	Actions   []agentAction          `json:"actions,omitempty"`
+	SlowDown  *slowDownHint          `json:"slow_down,omitempty"`
+	// Action is the envelope's reply|silent|react|defer; see envelope.go.
+	Action        string `json:"action,omitempty"`
+	ReactionEmoji string `json:"reaction_emoji,omitempty"`
+	DelayMS       int    `json:"delay_ms,omitempty"`
+	// Images are posted as attachments after the response; see images.go.
+	Images []agentImage `json:"images,omitempty"`
+	// Truncated is set when the reply was cut off before its end, e.g. at
+	// the model's output limit; see continue.go.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 func init() {
-	err := godotenv.Load()
+	err := loadDotenv()
 	if err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 	Token = os.Getenv("DISCORD_TOKEN")
-	AIAgentURL = os.Getenv("AI_AGENT_URL")
-	if AIAgentURL == "" {
-		AIAgentURL = "http://localhost:8000"
+	DataDir = os.Getenv("DATA_DIR")
+	if DataDir == "" {
+		DataDir = "data"
 	}
+	currentConfig.Store(loadConfig())
 }
 
 func main() {
@@ -51,120 +71,236 @@ func main() {
 	if err != nil {
 		log.Fatal("Error creating Discord session: ", err)
 	}
+	acquireInstanceLock(dg)
+
+	store, err = OpenStore(filepath.Join(DataDir, "store.json"))
+	if err != nil {
+		log.Fatal("Error opening data store: ", err)
+	}
+	exchanges, err = openExchangeLog(filepath.Join(DataDir, "exchanges.jsonl"))
+	if err != nil {
+		log.Fatal("Error opening exchange log: ", err)
+	}
 
-	dg.AddHandler(messageCreate)
-	dg.AddHandler(ready)
+	recordBoot()
+	initPrivacyLogging()
+	initErrorReporting()
+	loadOwnWebhooks()
+	initContentFilter()
+	currentCatalogs.Store(loadCatalogs(config()))
+	loadThemePacks()
+	loadLocaleDir()
+	initCaches(dg)
+	initChaos(dg)
+	botSession = dg
+	go runCacheSweeper(config().CacheSweepInterval)
+	startHTTPServer()
+	startDashboard()
+
+	initAgentClient()
+	initAgentBackends(config().AIAgentURLs, config().PersonaAgentURLs)
+	go runCapabilityHandshake()
+	go runAgentHealthChecks(config().AgentHealthInterval)
+	go runStatsFlusher(time.Minute)
+	go runExchangeLogPruner(time.Hour)
+	go watchReloadSignal()
+
+	dg.AddHandler(recovered("messageCreate", messageCreate))
+	dg.AddHandler(recovered("ready", ready))
+	dg.AddHandler(recovered("interactionCreate", interactionCreate))
+	dg.AddHandler(recovered("messageReactionAdd", messageReactionAdd))
+	dg.AddHandler(recovered("messageReactionRemove", messageReactionRemove))
+	dg.AddHandler(recovered("voiceStateUpdate", voiceStateUpdate))
+	dg.AddHandler(recovered("threadUpdate", threadUpdate))
+	dg.AddHandler(recovered("threadDelete", threadDelete))
+	dg.AddHandler(recovered("channelUpdate", channelUpdate))
+	dg.AddHandler(recovered("channelDelete", channelDelete))
+	dg.AddHandler(recovered("guildCreate", guildCreate))
+	dg.AddHandler(recovered("stageInstanceCreate", stageInstanceCreate))
+	dg.AddHandler(recovered("stageInstanceUpdate", stageInstanceUpdate))
+	dg.AddHandler(recovered("stageInstanceDelete", stageInstanceDelete))
+	dg.AddHandler(recovered("guildScheduledEventUpdate", guildScheduledEventUpdate))
+	dg.AddHandler(recovered("guildScheduledEventDelete", guildScheduledEventDelete))
+	dg.AddHandler(recovered("disconnect", gatewayDisconnect))
+	dg.AddHandler(recovered("connect", gatewayConnect))
+	dg.AddHandler(recovered("resumed", gatewayResumed))
 
 	// Add required intents
 	dg.Identify.Intents = discordgo.IntentsGuildMessages |
 		discordgo.IntentsMessageContent |
 		discordgo.IntentsDirectMessages |
+		discordgo.IntentsGuildMessageReactions |
+		discordgo.IntentsDirectMessageReactions |
 		discordgo.IntentsGuildMembers |
+		discordgo.IntentsGuildVoiceStates |
+		discordgo.IntentsGuildScheduledEvents |
 		discordgo.IntentsGuilds
 
+	validateStartup(dg)
 	err = dg.Open()
 	if err != nil {
+		if hint := gatewayOpenHint(err); hint != "" {
+			log.Printf("❌ %s", hint)
+		}
 		log.Fatal("Error opening connection: ", err)
 	}
+	startPersonaBots()
 
 	log.Printf("🍺 Elsie the Holographic Bartender is now online! 🍺")
 	log.Printf("Press CTRL-C to shut down the holographic matrix.")
+	// SIGUSR1 (or `!elsie shutdown`) is a planned shutdown: active scenes
+	// are told Elsie is stepping away, and welcomed back on the next start
 	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
-	<-sc
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	select {
+	case sig := <-sc:
+		if sig == syscall.SIGUSR1 {
+			announcePlannedShutdown(dg, "SIGUSR1")
+		}
+	case reason := <-shutdownRequests:
+		announcePlannedShutdown(dg, reason)
+	case <-instanceLost:
+	}
 
+	stopVoiceListeners()
+	guildStats.flush()
+	flushErrorReports(2 * time.Second)
+	markCleanShutdown()
+	releaseInstanceLock()
+	stopPersonaBots()
+	stopAlerts()
 	dg.Close()
 }
 
 func ready(s *discordgo.Session, event *discordgo.Ready) {
-	err := s.UpdateGameStatus(0, "🍺 Serving drinks across the galaxy")
+	status := normalStatus()
+	if !botReady.Load() {
+		status = "🔧 Running diagnostics"
+	}
+	err := s.UpdateGameStatus(0, status)
 	if err != nil {
 		log.Println("Error setting status:", err)
 	}
 	log.Printf("Logged in as: %v#%v\n", s.State.User.Username, s.State.User.Discriminator)
-}
-
-// Helper function to split messages into chunks of 2000 characters
-func splitMessage(message string) []string {
-	if len(message) <= 2000 {
-		return []string{message}
-	}
-
-	var chunks []string
-	for len(message) > 0 {
-		chunk := message
-		if len(chunk) > 2000 {
-			// Find the last space before 2000 characters
-			lastSpace := strings.LastIndex(chunk[:2000], " ")
-			if lastSpace == -1 {
-				// If no space found, just split at 2000
-				lastSpace = 2000
-			}
-			chunk = chunk[:lastSpace]
-			message = message[lastSpace+1:]
-		} else {
-			message = ""
-		}
-		chunks = append(chunks, chunk)
+	publishSlashCommands(s)
+	if safeMode.Load() {
+		startSafeMode(s)
+		return
 	}
-	return chunks
+	startDigestScheduler(s)
+	startFollowUpScheduler(s)
+	startReminderScheduler(s)
+	startPollScheduler(s)
+	startDrinkOfTheDayScheduler(s)
+	startPresenceRotation(s)
+	startEventScheduler(s)
+	startRPSessionScheduler(s)
+	startThreadArchiveWatcher(s)
+	startSelfTest(s)
 }
 
 func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Enhanced mention detection
 	mentioned := false
+	var mentionedPersona *persona
 	content := strings.TrimSpace(m.Content)
 
-	/* Debug logging
-	log.Printf("DEBUG: ========= Message Details =========")
-	log.Printf("DEBUG: From: %s (ID: %s)", m.Author.Username, m.Author.ID)
-	log.Printf("DEBUG: Channel: %s", m.ChannelID)
-	log.Printf("DEBUG: Raw Content: %q", m.Content)
-	log.Printf("DEBUG: Mentions: %+v", m.Mentions)
-	log.Printf("DEBUG: Role Mentions: %+v", m.MentionRoles)
-	log.Printf("DEBUG: Bot ID: %s", s.State.User.ID)
-	log.Printf("DEBUG: Bot Username: %s", s.State.User.Username)
-	log.Printf("DEBUG: ================================")
-	*/
-	// Ignore own messages
-	if m.Author.ID == s.State.User.ID {
+	// Ignore own messages, including persona webhook and persona bot posts
+	if m.Author.ID == s.State.User.ID || (m.WebhookID != "" && isOwnWebhook(m.WebhookID)) || personaBotUser(m.Author.ID) != nil {
+		resetSpeakers(m.ChannelID)
 		return
 	}
 
+	// A persona's own bot only answers its DMs; Elsie's session handles
+	// guild messages, including mentions of the persona bots
+	botPersona := sessionPersona(s)
+	if botPersona != nil && m.GuildID != "" {
+		return
+	}
+
+	// A message redelivered after a gateway resume was already answered
+	if !firstDelivery(m.ID) {
+		return
+	}
+
+	// Messages arriving empty mean the Message Content intent is off
+	watchMessageContent(s, m)
+
+	// Stay quiet until the startup self-test passes
+	if !botReady.Load() {
+		return
+	}
+
+	// Blocked and opted-out users are never processed or answered
+	if excludedMessage(s, m) {
+		return
+	}
+
+	// Guilds with content processing off only get slash commands
+	if contentProcessingDisabled(m.GuildID) {
+		metrics.Inc("content_processing_skipped_total")
+		return
+	}
+
+	// Every message gets a request ID that follows it through the agent
+	rlog := requestLog{id: newRequestID()}
+
+	// Every routing decision lands in one structured record, logged on return
+	dec := &messageDecision{
+		Time:        time.Now(),
+		RequestID:   rlog.id,
+		GuildID:     m.GuildID,
+		ChannelID:   m.ChannelID,
+		MessageID:   m.ID,
+		AuthorID:    m.Author.ID,
+		MentionType: mentionNone,
+		Pipeline:    pipelineIgnored,
+	}
+	defer dec.emit(rlog)
+
+	// Other bots and webhooks are answered as the guild's bot policy says;
+	// a person posting ends any bot loop cooldown in the channel
+	if m.Author.Bot {
+		if !botAuthorAllowed(m.GuildID, m.Author.ID, m.WebhookID != "") {
+			dec.Policy = policyBotIgnored
+			return
+		}
+		dec.match("bot_author")
+	} else {
+		noteHumanMessage(m.ChannelID)
+	}
+
 	// Check if message is a DM
 	isDM := m.GuildID == ""
+	if isDM {
+		dec.monitor("dm")
+	}
 
 	// Get basic channel info to determine if we should monitor all messages
 	shouldMonitorAll := false
 	if !isDM {
-		// Try to get channel info to determine if this is a thread or special channel
-		if channel, err := s.Channel(m.ChannelID); err == nil {
-			// Monitor all messages in threads (where roleplay typically happens)
-			isThread := channel.Type == discordgo.ChannelTypeGuildPublicThread ||
-				channel.Type == discordgo.ChannelTypeGuildPrivateThread ||
-				channel.Type == discordgo.ChannelTypeGuildNewsThread
-
-			if isThread {
-				shouldMonitorAll = true
-				log.Printf("DEBUG: Thread detected (%s) - monitoring all messages", channel.Name)
+		// The channel classifier applies the allow rules (threads, RP
+		// channels) and the guild's ignore rules
+		if channel, err := getChannel(s, m.ChannelID); err == nil {
+			class := classifyChannel(s, m.GuildID, channel)
+			shouldMonitorAll = class.Monitored
+			for _, rule := range class.AllowedBy {
+				dec.monitor(rule)
 			}
-
-			// Also monitor channels with "rp" in the name
-			if strings.Contains(strings.ToLower(channel.Name), "rp") ||
-				strings.Contains(strings.ToLower(channel.Name), "roleplay") {
-				shouldMonitorAll = true
-				log.Printf("DEBUG: RP channel detected (%s) - monitoring all messages", channel.Name)
+			if class.IgnoredBy != "" {
+				dec.MonitorReason = ""
+				dec.match("ignored:" + class.IgnoredBy)
 			}
 		} else {
 			// If we can't get channel info, log the error but continue
-			log.Printf("DEBUG: Could not get channel info: %v", err)
+			rlog.Printf("Could not get channel info: %v", err)
 		}
 	}
 
 	// Check for DGM posts - they override all channel restrictions
 	if strings.HasPrefix(strings.TrimSpace(content), "[DGM]") {
 		shouldMonitorAll = true
-		log.Printf("DEBUG: DGM post detected - monitoring regardless of channel type")
+		dec.monitor("dgm")
 	}
 
 	// Simple mention detection - if there are any mentions, process them
@@ -173,23 +309,26 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		for _, user := range m.Mentions {
 			if user.ID == s.State.User.ID {
 				mentioned = true
-				log.Printf("DEBUG: Bot was mentioned via user mention!")
+				dec.MentionType = mentionUser
 				break
 			}
+			if p := personaBotUser(user.ID); p != nil && botPersona == nil {
+				mentionedPersona = p
+			}
 		}
 
 		// Check role mentions
 		if !mentioned && m.GuildID != "" {
 			for _, roleID := range m.MentionRoles {
-				guild, err := s.Guild(m.GuildID)
+				guild, err := getGuild(s, m.GuildID)
 				if err != nil {
-					log.Printf("DEBUG: Error getting guild info: %v", err)
+					rlog.Printf("Error getting guild info: %v", err)
 					continue
 				}
 				for _, role := range guild.Roles {
 					if role.ID == roleID && strings.EqualFold(role.Name, s.State.User.Username) {
 						mentioned = true
-						log.Printf("DEBUG: Bot was mentioned via role mention!")
+						dec.MentionType = mentionRole
 						break
 					}
 				}
@@ -198,14 +337,66 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	}
 
 	// Handle commands
-	if strings.HasPrefix(content, "!elsie") {
-		content = strings.TrimPrefix(content, "!elsie")
-		content = strings.TrimSpace(content)
+	isCommand := false
+	if rest, ok := trimCommandPrefix(m.GuildID, content); ok {
+		isCommand = true
+		content = strings.TrimSpace(rest)
 		if content == "" {
 			content = "hello"
 		}
 		mentioned = true
-		log.Printf("DEBUG: Command detected, content: %s", content)
+		dec.MentionType = mentionCommand
+	}
+
+	// Messages in a channel with a trivia question open are guesses
+	if !isCommand && !m.Author.Bot && checkTriviaAnswer(s, m, content) {
+		dec.Pipeline = pipelineCommand
+		dec.match("trivia_guess")
+		return
+	}
+
+	// Thanking another member earns them karma; the message carries on
+	if !isDM && !isCommand && !m.Author.Bot {
+		awardThanksKarma(s, m, content)
+	}
+
+	// Safe mode answers `!elsie` commands only
+	if safeMode.Load() && !isCommand {
+		dec.match("safe_mode")
+		return
+	}
+
+	// OOC chatter in a scene with a paired OOC thread moves over there
+	if !isDM && !isCommand && routeSceneOOC(s, m) {
+		dec.match("ooc_routed")
+		return
+	}
+
+	// An explicit persona prefix (e.g. "!computer") addresses that persona
+	// directly; otherwise the channel's persona answers
+	persona := channelPersona(s, m.GuildID, m.ChannelID)
+	personaInvoked := false
+	if p, rest, ok := matchPersonaPrefix(content); ok && !isCommand {
+		persona, content, personaInvoked = p, rest, true
+		mentioned = true
+		dec.MentionType = mentionPersona
+		dec.match("persona_prefix:" + p.ID)
+	} else if botPersona != nil {
+		// A DM to a persona's own bot is for that persona
+		persona, personaInvoked = botPersona, true
+		dec.match("persona_bot:" + botPersona.ID)
+	} else if mentionedPersona != nil && !mentioned && !isCommand {
+		// So is a mention of its bot, unless Elsie was mentioned too
+		persona, personaInvoked = mentionedPersona, true
+		mentioned = true
+		dec.MentionType = mentionPersona
+		dec.match("persona_bot:" + mentionedPersona.ID)
+		if bot := personaBotSession(mentionedPersona); bot != nil && bot.State.User != nil {
+			content = strings.ReplaceAll(content, fmt.Sprintf("<@%s>", bot.State.User.ID), "")
+			content = strings.TrimSpace(strings.ReplaceAll(content, fmt.Sprintf("<@!%s>", bot.State.User.ID), ""))
+		}
+	} else if persona.ID != defaultPersonaID {
+		dec.match("channel_persona:" + persona.ID)
 	}
 
 	// Determine if we should respond
@@ -213,17 +404,22 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	// Only respond if mentioned, command used, in DM, or in a monitored channel
 	if !shouldRespond {
-		log.Printf("DEBUG: Message ignored - not mentioned, not DM, and not in monitored channel")
 		return
 	}
 
-	// Log why we're responding
-	if mentioned {
-		log.Printf("DEBUG: Responding due to mention")
-	} else if isDM {
-		log.Printf("DEBUG: Responding due to DM")
-	} else if shouldMonitorAll {
-		log.Printf("DEBUG: Responding due to channel monitoring (thread/RP channel)")
+	// Two AI bots answering each other would never stop on their own
+	if m.Author.Bot && botLoopTripped(m.ChannelID) {
+		dec.Policy = policyBotLoop
+		return
+	}
+	noteChannelActivity(m.GuildID, m.ChannelID)
+
+	// Everyone who acted since Elsie last spoke goes along with the next
+	// request, so she can answer the whole table
+	if shouldMonitorAll && !isDM && !isCommand {
+		if _, ooc := parseOOC(content); !ooc {
+			noteSpeaker(m, content)
+		}
 	}
 
 	// Clean up the content by removing mentions
@@ -233,7 +429,7 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		content = strings.ReplaceAll(content, fmt.Sprintf("<@!%s>", s.State.User.ID), "")
 		// Remove role mentions that match the bot's name
 		if m.GuildID != "" {
-			guild, err := s.Guild(m.GuildID)
+			guild, err := getGuild(s, m.GuildID)
 			if err == nil {
 				for _, role := range guild.Roles {
 					if strings.EqualFold(role.Name, s.State.User.Username) {
@@ -243,135 +439,331 @@ func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 			}
 		}
 		content = strings.TrimSpace(content)
-		log.Printf("DEBUG: Content after removing mention: %s", content)
 	}
 
-	log.Printf("DEBUG: Processing message: %s", content)
-
-	// Handle special Discord commands
-	switch strings.ToLower(content) {
-	case "ping":
-		s.ChannelMessageSend(m.ChannelID, "🍺 *holographic matrix responds* Pong! All systems operational!")
+	// Handle local commands (ping, help, status, ...)
+	if !personaInvoked && dispatchCommand(s, m, content, isCommand) {
+		dec.Pipeline = pipelineCommand
 		return
-	case "help":
-		helpMessage := `🍺 **ELSIE - HOLOGRAPHIC BARTENDER** 🍺
-
-**Commands:**
-• ` + "`!elsie [message]`" + ` - Chat with Elsie
-• ` + "`@Elsie [message]`" + ` - Mention me to chat
-• ` + "`!elsie menu`" + ` - View the galactic drink menu
-• ` + "`!elsie help`" + ` - Show this help message
-• ` + "`!elsie ping`" + ` - Test if I'm online
-
-**Direct Messages:**
-You can also chat with me privately by sending me a direct message! I'll respond to any message you send.
-
-**Example Drinks to Order:**
-• "Romulan Ale" - Blue and mysterious
-• "Earl Grey Hot" - The Captain's favorite
-• "Blood Wine" - For Klingon warriors
-• "Synthehol" - No hangover guaranteed!
-
-*I'm programmed with the finest bartending subroutines in the quadrant!*`
-		s.ChannelMessageSend(m.ChannelID, helpMessage)
+	}
+	if safeMode.Load() {
+		s.ChannelMessageSend(m.ChannelID, "🛟 I'm in safe mode right now, so I can only run commands. Try `!elsie help`.")
 		return
 	}
 
-	// Send typing indicator
-	s.ChannelTyping(m.ChannelID)
+	// DMs starting with "report" go to a server's staff channel
+	if isDM {
+		if text, ok := parseDMReport(content); ok {
+			dec.Pipeline = pipelineCommand
+			dec.match("report")
+			handleDMReport(s, m, text)
+			return
+		}
+	}
 
-	// Process message through AI agent
-	response := processWithAIEnhanced(content, s, m)
-
-	// Send response
-	if response != "" && response != "NO_RESPONSE" {
-		// Split response into chunks if needed
-		chunks := splitMessage(response)
-		for _, chunk := range chunks {
-			_, err := s.ChannelMessageSend(m.ChannelID, chunk)
-			if err != nil {
-				log.Printf("Error sending message chunk: %v", err)
+	// Respect the guild's policy for age-restricted channels
+	if refusesChannel(s, m.GuildID, m.ChannelID) {
+		dec.Policy = policyNSFWRefused
+		if mentioned {
+			s.ChannelMessageSend(m.ChannelID, themePhrase(m.GuildID, "nsfw_refusal", nil))
+		}
+		return
+	}
+
+	// Screen the user's message before it reaches the AI agent
+	content, allowed := screenContent(s, m.GuildID, m.ChannelID, m.Author.ID, "inbound", content)
+	if !allowed {
+		dec.Policy = policyFilterBlocked
+		if mentioned || isDM {
+			s.ChannelMessageSend(m.ChannelID, themePhrase(m.GuildID, "inbound_blocked", nil))
+		}
+		return
+	}
+
+	// Out-of-character chatter in monitored channels is skipped or tagged
+	extra := map[string]interface{}{}
+	if topic := activeDMTopic(m.ChannelID); isDM && topic != "" {
+		extra["dm_topic"] = topic
+	}
+	isOOC := false
+	if shouldMonitorAll && !isDM {
+		if text, ok := parseOOC(content); ok {
+			isOOC = true
+			dec.match("ooc")
+			if !mentioned && oocMode(m.GuildID) == oocSkip {
+				dec.Policy = policyOOCSkipped
 				return
 			}
+			content = text
+			extra["is_ooc"] = true
+		}
+	}
+
+	// Narration split across several quick messages goes out as one request
+	mergedIDs := []string{m.ID}
+	if shouldMonitorAll && !mentioned && !isDM && !isOOC {
+		var ok bool
+		if content, mergedIDs, ok = coalesceBurst(m, content); !ok {
+			dec.Policy, dec.Pipeline = policyAllowed, pipelineMerged
+			return
+		}
+		if len(mergedIDs) > 1 {
+			dec.match(fmt.Sprintf("burst:%d", len(mergedIDs)))
+		}
+		// The agent asked for less ambient traffic while it's under load
+		if ambientThrottled(m.ChannelID) {
+			dec.Policy = policyLoadShed
+			return
+		}
+		// Too many requests are waiting on the agent: chatter makes way for
+		// mentions and DMs, with a reaction so players know they were seen
+		if shedAmbient(persona.ID) {
+			dec.Policy = policyLoadShed
+			dec.match("queue_full")
+			metrics.Inc("load_shed_total")
+			if config().LoadShedReaction != "" && config().LoadShedReaction != "off" {
+				s.MessageReactionAdd(m.ChannelID, m.ID, reactionAPIName(config().LoadShedReaction))
+			}
+			return
 		}
-	} else if response == "NO_RESPONSE" {
-		log.Printf("🤐 NO_RESPONSE received - Elsie is staying silent (DGM post or listening mode)")
-		// Don't send any message - Elsie is intentionally staying quiet
+	}
+
+	// Keep agent usage within the configured quotas
+	if ok, scope, retryAfter := consumeQuota(m.GuildID, m.ChannelID, m.Author.ID); !ok {
+		dec.Policy = policyQuotaExceeded
+		dec.match("quota:" + scope)
+		if (mentioned || isDM) && shouldWarnQuota(m.Author.ID, scope, retryAfter) {
+			s.ChannelMessageSend(m.ChannelID, quotaMessage(m.GuildID, scope, retryAfter))
+		}
+		return
+	}
+
+	// "Put it on my tab" orders are recorded before the agent narrates them
+	if mentioned && !isDM && !isOOC {
+		if item, ok := tabOrderRequest(content); ok {
+			if tab, err := addToTab(m.GuildID, m.Author.ID, item); err != nil {
+				log.Printf("Error putting %s on a tab: %v", item.id(), err)
+			} else {
+				dec.match("tab:" + item.id())
+				extra["tab"] = tabContext(item, tab)
+				rememberOrder(m.ChannelID, m.Author.ID, item)
+			}
+		}
+	}
+
+	// In listening mode the agent still follows the channel, but only
+	// mentions and commands get an answer
+	listening := shouldMonitorAll && !mentioned && !isDM && channelListening(s, m.GuildID, m.ChannelID)
+	if listening {
+		dec.match("listening")
+		extra["listening"] = true
 	} else {
-		s.ChannelMessageSend(m.ChannelID, "*holographic matrix flickers* My apologizes, but my processing subroutines are experiencing difficulties. Please try again later.")
+		// Send typing indicator
+		speakingSession(s, persona).ChannelTyping(m.ChannelID)
+	}
+
+	// Process message through AI agent
+	exchange := exchangeRecord{
+		Time:           time.Now(),
+		RequestID:      rlog.id,
+		GuildID:        m.GuildID,
+		ChannelID:      m.ChannelID,
+		MessageID:      m.ID,
+		AuthorID:       m.Author.ID,
+		Persona:        persona.ID,
+		AgentSessionID: persona.sessionID(m.ChannelID),
+	}
+	if len(mergedIDs) > 1 {
+		exchange.MergedMessageIDs = mergedIDs[1:]
+	}
+	dec.Policy, dec.Pipeline = policyAllowed, pipelineAgent
+	defer func() {
+		dec.Outcome = exchange.Outcome
+		exchanges.record(exchange)
+	}()
+
+	// Elsie joining a thread mid-scene gets the story so far
+	if backfill := threadBackfill(s, m, persona, mentioned, rlog); len(backfill) > 0 {
+		extra["thread_backfill"] = backfill
+	}
+	// ...and picking an archived scene back up gets what it was
+	if resumed := sceneResumeContext(m.ChannelID); resumed != nil {
+		extra["scene_resumed"] = resumed
+	}
+	if speakers := speakersSince(m.ChannelID, mergedIDs); len(speakers) > 0 {
+		extra["since_last_reply"] = speakers
+	}
+	budget := channelLengthBudget(s, m.GuildID, m.ChannelID)
+	if budget > 0 {
+		extra["length_budget"] = budget
+	}
+	guildStats.recordMessage(m.GuildID, m.ChannelID)
+	// A slow agent gets an in-character placeholder once typing runs out
+	placeholder := &thinkingPlaceholder{}
+	if !listening {
+		placeholder = startThinkingPlaceholder(s, m.GuildID, m.ChannelID, persona)
+	}
+	aiResponse := processWithAIEnhanced(content, s, m, persona, extra, rlog)
+	response := ""
+	if aiResponse != nil {
+		response = aiResponse.Response
+		if aiResponse.SessionID != "" {
+			exchange.AgentSessionID = aiResponse.SessionID
+		}
+	}
+	guildStats.recordAgentCall(m.GuildID, aiResponse == nil, len(response))
+	if aiResponse != nil {
+		recordForwarded(persona, persona.sessionID(m.ChannelID))
+	}
+
+	// The agent's envelope decides whether Elsie replies, reacts or stays quiet
+	action := aiResponse.action()
+	if listening && (action == responseReply || action == responseDefer || action == responseReact) {
+		rlog.Printf("👂 Listening mode: keeping the agent's %s to myself", action)
+		metrics.Inc("listening_suppressed_total")
+		action = responseSilent
+	}
+	var images []responseImage
+	if action == responseReply || action == responseDefer {
+		images = loadResponseImages(aiResponse.Images, rlog)
+		if strings.TrimSpace(response) == "" && len(images) == 0 {
+			action = ""
+		} else if strings.TrimSpace(response) != "" {
+			var deliver bool
+			response, deliver = screenContent(s, m.GuildID, m.ChannelID, m.Author.ID, "outbound", response)
+			if !deliver {
+				rlog.Printf("🧼 Outbound response blocked by content filter")
+				response = themePhrase(m.GuildID, "outbound_blocked", nil)
+			}
+		}
+	}
+	if action != "" {
+		metrics.Inc(metricLabel("agent_response_actions_total", "action", action))
+	}
+
+	thinking := placeholder.stop()
+	switch action {
+	case responseReply, responseDefer:
+		// Players who already waited for the placeholder don't wait again
+		if delay := aiResponse.replyDelay(); delay > 0 && !thinking {
+			rlog.Printf("⏳ Agent deferred the reply by %s", delay)
+			waitTyping(s, m.ChannelID, delay)
+		}
+		// Anything over the channel's budget waits behind a More button
+		response, rest := splitAtBudget(response, budget)
+		// Split response into chunks if needed, with any images after them
+		sent, err := placeholder.deliver(s, m.ChannelID, persona, response, images)
+		exchange.ResponseMessageIDs = messageIDs(sent)
+		if err != nil {
+			rlog.Printf("Error sending message chunk: %v", err)
+			reportError(errorKindDiscord, err, map[string]string{
+				"request_id": rlog.id, "guild_id": m.GuildID, "channel_id": m.ChannelID, "operation": "send_reply",
+			}, nil)
+			guildStats.recordSendError(m.GuildID)
+			exchange.Outcome = exchangeSendError
+			// Questions put to Elsie directly aren't lost: she DMs the answer
+			if mentioned && dmFallback(s, m, response, err, rlog) {
+				exchange.Outcome = exchangeDMFallback
+			}
+			return
+		}
+		exchange.Outcome = exchangeSent
+		rememberReply(exchange)
+		offerNextPart(s, m.GuildID, m.ChannelID, persona, sent, response, rest, aiResponse.Truncated)
+		if aiResponse.isRecap() {
+			pinRecap(s, m.ChannelID, sent[0])
+		}
+		scheduleFollowUp(aiResponse, m.GuildID, m.ChannelID, m.Author.ID, persona, rlog)
+		runAgentActions(s, aiResponse.Actions, actionScope{
+			guildID: m.GuildID, channelID: m.ChannelID, authorID: m.Author.ID,
+			trigger: m.ID, reply: sent[0].ID,
+		}, rlog)
+	case responseSilent, responseReact:
+		placeholder.discard(s)
+		exchange.Outcome = exchangeNoResponse
+		if action == responseReact {
+			emoji := reactionAPIName(aiResponse.ReactionEmoji)
+			if err := s.MessageReactionAdd(m.ChannelID, m.ID, emoji); err != nil {
+				rlog.Printf("Error reacting with %q: %v", aiResponse.ReactionEmoji, err)
+			} else {
+				rlog.Printf("👍 Agent reacted with %s instead of replying", emoji)
+				exchange.Outcome = exchangeReacted
+			}
+		} else {
+			// Don't send any message - Elsie is intentionally staying quiet
+			rlog.Printf("🤐 Agent chose silence - Elsie is staying quiet (DGM post or listening mode)")
+			if shouldMonitorAll && !mentioned && !isDM {
+				acknowledgeSilently(s, m, rlog)
+			}
+		}
+		// A silent reply can still act, e.g. react to the player's message
+		runAgentActions(s, aiResponse.Actions, actionScope{
+			guildID: m.GuildID, channelID: m.ChannelID, authorID: m.Author.ID, trigger: m.ID,
+		}, rlog)
+	default:
+		// The agent is unreachable; answer from the local library instead
+		placeholder.discard(s)
+		rlog.Printf("🗂️ Serving local fallback response")
+		exchange.Outcome = exchangeFallback
+		if msg, err := s.ChannelMessageSend(m.ChannelID, fallbackResponse(persona, content)); err == nil {
+			exchange.ResponseMessageIDs = []string{msg.ID}
+		}
 	}
 }
 
-func processWithAI(content string, channelID string) string {
-	log.Printf("⚠️  USING BASIC PROCESSING (no enhanced channel detection)")
-	log.Printf("   📋 Channel ID: %s", channelID)
+// processWithAI sends a message with the minimal context, for when channel
+// details can't be loaded. extra is merged into the context.
+func processWithAI(content string, channelID string, persona *persona, extra map[string]interface{}, rlog requestLog) *AIResponse {
+	rlog.Printf("⚠️  USING BASIC PROCESSING (no enhanced channel detection)")
+	rlog.Printf("   📋 Channel ID: %s", channelID)
 
 	// Create message payload
 	message := Message{
 		Message: content,
 		Context: map[string]interface{}{
-			"session_id": channelID, // Use channel ID as session ID
+			"session_id": persona.sessionID(channelID), // Use channel ID as session ID
 			"platform":   "discord",
+			"request_id": rlog.id,
+			"persona":    persona.ID,
 		},
+		RequestID: rlog.id,
+		Persona:   persona.ID,
 	}
-
-	// Convert to JSON
-	jsonData, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
-		return ""
+	for k, v := range extra {
+		message.Context[k] = v
 	}
 
 	// Make HTTP request to AI agent
-	log.Printf("DEBUG: Sending basic request to %s with data: %s", AIAgentURL+"/process", string(jsonData))
-	resp, err := http.Post(AIAgentURL+"/process", "application/json", bytes.NewBuffer(jsonData))
+	rlog.Printf("DEBUG: Sending basic request to %s with message: %s", config().AIAgentURL+"/process", logText(content))
+	aiResponse, err := callAgent(message)
 	if err != nil {
-		log.Printf("Error calling AI agent: %v", err)
-		return ""
+		rlog.Printf("Error calling AI agent: %v", err)
+		return nil
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response: %v", err)
-		return ""
-	}
-	log.Printf("DEBUG: Received response: %s", string(body))
-
-	// Parse AI response
-	var aiResponse AIResponse
-	err = json.Unmarshal(body, &aiResponse)
-	if err != nil {
-		log.Printf("Error unmarshaling AI response: %v", err)
-		return ""
-	}
-
-	// Return the response if it exists (AI agent doesn't send status field)
-	if aiResponse.Response != "" {
-		return aiResponse.Response
-	}
-
-	return ""
+	// The AI agent doesn't send a status field; an empty Response means no reply
+	return aiResponse
 }
 
-func processWithAIEnhanced(content string, s *discordgo.Session, m *discordgo.MessageCreate) string {
-	log.Printf("🔍 ATTEMPTING ENHANCED CHANNEL DETECTION:")
-	log.Printf("   📋 Channel ID: %s", m.ChannelID)
-	log.Printf("   🏰 Guild ID: %s", m.GuildID)
+// processWithAIEnhanced sends a message with the full channel context.
+// extra carries per-message fields such as is_ooc.
+func processWithAIEnhanced(content string, s *discordgo.Session, m *discordgo.MessageCreate, persona *persona, extra map[string]interface{}, rlog requestLog) *AIResponse {
+	rlog.Printf("🔍 ATTEMPTING ENHANCED CHANNEL DETECTION:")
+	rlog.Printf("   📋 Channel ID: %s", m.ChannelID)
+	rlog.Printf("   🏰 Guild ID: %s", m.GuildID)
 
 	// Get channel information
-	channel, err := s.Channel(m.ChannelID)
+	channel, err := getChannel(s, m.ChannelID)
 	if err != nil {
-		log.Printf("❌ ERROR getting channel info: %v", err)
-		log.Printf("   🔄 Falling back to basic processing...")
-		return processWithAI(content, m.ChannelID)
+		rlog.Printf("❌ ERROR getting channel info: %v", err)
+		rlog.Printf("   🔄 Falling back to basic processing...")
+		return processWithAI(content, m.ChannelID, persona, extra, rlog)
 	}
 
-	log.Printf("✅ CHANNEL INFO RETRIEVED:")
-	log.Printf("   📛 Name: %s", channel.Name)
-	log.Printf("   🏷️ Type: %v", channel.Type)
-	log.Printf("   🆔 ID: %s", channel.ID)
+	rlog.Printf("✅ CHANNEL INFO RETRIEVED:")
+	rlog.Printf("   📛 Name: %s", channel.Name)
+	rlog.Printf("   🏷️ Type: %v", channel.Type)
+	rlog.Printf("   🆔 ID: %s", channel.ID)
 
 	// Determine channel type and thread status
 	isDM := m.GuildID == ""
@@ -388,101 +780,99 @@ func processWithAIEnhanced(content string, s *discordgo.Session, m *discordgo.Me
 		channelType = "DM"
 		isDM = true
 		channelName = "DM"
-		log.Printf("   💬 Detected as: Direct Message")
+		rlog.Printf("   💬 Detected as: Direct Message")
 	case discordgo.ChannelTypeGuildText:
 		channelType = "GUILD_TEXT"
-		log.Printf("   📝 Detected as: Text Channel")
+		rlog.Printf("   📝 Detected as: Text Channel")
 	case discordgo.ChannelTypeGuildVoice:
 		channelType = "GUILD_VOICE"
-		log.Printf("   🔊 Detected as: Voice Channel")
+		rlog.Printf("   🔊 Detected as: Voice Channel")
 	case discordgo.ChannelTypeGuildPublicThread:
 		channelType = "GUILD_PUBLIC_THREAD"
 		isThread = true
-		log.Printf("   🧵 Detected as: Public Thread")
+		rlog.Printf("   🧵 Detected as: Public Thread")
 	case discordgo.ChannelTypeGuildPrivateThread:
 		channelType = "GUILD_PRIVATE_THREAD"
 		isThread = true
-		log.Printf("   🔒 Detected as: Private Thread")
+		rlog.Printf("   🔒 Detected as: Private Thread")
 	case discordgo.ChannelTypeGuildNewsThread:
 		channelType = "GUILD_NEWS_THREAD"
 		isThread = true
-		log.Printf("   📰 Detected as: News Thread")
+		rlog.Printf("   📰 Detected as: News Thread")
 	case discordgo.ChannelTypeGuildNews:
 		channelType = "GUILD_NEWS"
-		log.Printf("   📰 Detected as: News Channel")
+		rlog.Printf("   📰 Detected as: News Channel")
 	case discordgo.ChannelTypeGuildStageVoice:
 		channelType = "GUILD_STAGE_VOICE"
-		log.Printf("   🎤 Detected as: Stage Channel")
+		rlog.Printf("   🎤 Detected as: Stage Channel")
 	case discordgo.ChannelTypeGuildCategory:
 		channelType = "GUILD_CATEGORY"
-		log.Printf("   📁 Detected as: Category Channel")
+		rlog.Printf("   📁 Detected as: Category Channel")
 	case discordgo.ChannelTypeGuildForum:
 		channelType = "GUILD_FORUM"
-		log.Printf("   💭 Detected as: Forum Channel")
+		rlog.Printf("   💭 Detected as: Forum Channel")
 	default:
 		channelType = "UNKNOWN"
-		log.Printf("   ❓ Unknown channel type: %v", channel.Type)
+		rlog.Printf("   ❓ Unknown channel type: %v", channel.Type)
 	}
 
 	// Create enhanced message payload with channel context
 	message := Message{
 		Message: content,
 		Context: map[string]interface{}{
-			"session_id":   m.ChannelID,
+			"session_id":   persona.sessionID(m.ChannelID),
 			"platform":     "discord",
 			"channel_id":   m.ChannelID,
 			"channel_name": channelName,
 			"channel_type": channelType,
 			"is_dm":        isDM,
 			"is_thread":    isThread,
+			"is_nsfw":      isNSFWChannel(s, channel),
 			"guild_id":     m.GuildID,
 			"user_id":      m.Author.ID,
 			"username":     m.Author.Username,
+			"request_id":   rlog.id,
+			"persona":      persona.ID,
+			"locale":       agentLocale(m.GuildID, m.Author.ID),
 		},
+		RequestID: rlog.id,
+		Persona:   persona.ID,
 	}
-
-	log.Printf("🌐 ENHANCED CHANNEL CONTEXT:")
-	log.Printf("   📍 Channel: %s (%s)", channelName, channelType)
-	log.Printf("   🧵 Is Thread: %v | 💬 Is DM: %v", isThread, isDM)
-	log.Printf("   🆔 Channel ID: %s | Guild ID: %s", m.ChannelID, m.GuildID)
-	log.Printf("   👤 User: %s (%s)", m.Author.Username, m.Author.ID)
-
-	// Convert to JSON
-	jsonData, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Error marshaling JSON: %v", err)
-		return ""
+	if profile := loadProfile(m.Author.ID); profile != nil && !profile.isEmpty() {
+		message.Context["user_profile"] = profile.contextFields()
 	}
 
-	// Make HTTP request to AI agent
-	log.Printf("DEBUG: Sending enhanced request to %s", AIAgentURL+"/process")
-	resp, err := http.Post(AIAgentURL+"/process", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		log.Printf("Error calling AI agent: %v", err)
-		return ""
+	if results := recentUtilityResults(m.ChannelID); len(results) > 0 {
+		message.Context["utility_results"] = results
 	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response: %v", err)
-		return ""
+	if initiative := initiativeContext(m.ChannelID); initiative != nil {
+		message.Context["initiative"] = initiative
 	}
-	log.Printf("DEBUG: Received response: %s", string(body))
-
-	// Parse AI response
-	var aiResponse AIResponse
-	err = json.Unmarshal(body, &aiResponse)
-	if err != nil {
-		log.Printf("Error unmarshaling AI response: %v", err)
-		return ""
+	if rules := rulesContext(m.ChannelID); rules != nil {
+		message.Context["scene_rules"] = rules
+	}
+	if cp := checkpointContext(persona.sessionID(m.ChannelID)); cp != nil {
+		message.Context["memory_checkpoint"] = cp
 	}
+	for k, v := range extra {
+		message.Context[k] = v
+	}
+
+	rlog.Printf("🌐 ENHANCED CHANNEL CONTEXT:")
+	rlog.Printf("   📍 Channel: %s (%s)", channelName, channelType)
+	rlog.Printf("   🧵 Is Thread: %v | 💬 Is DM: %v", isThread, isDM)
+	rlog.Printf("   🆔 Channel ID: %s | Guild ID: %s", m.ChannelID, m.GuildID)
+	rlog.Printf("   👤 User: %s", logUser(m.Author.Username, m.Author.ID))
+	rlog.Printf("   🏷️ Request ID: %s | Message ID: %s", rlog.id, m.ID)
+	rlog.Printf("   🎭 Persona: %s", persona.Name)
 
-	// Return the response if it exists
-	if aiResponse.Response != "" {
-		return aiResponse.Response
+	// Make HTTP request to AI agent
+	rlog.Printf("DEBUG: Sending enhanced request to %s", config().AIAgentURL+"/process")
+	aiResponse, err := callAgent(message)
+	if err != nil {
+		rlog.Printf("Error calling AI agent: %v", err)
+		return nil
 	}
 
-	return ""
+	return aiResponse
 }
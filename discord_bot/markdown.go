@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// markdownReserve is the room kept free in each chunk for the markers
+// balanceMarkdown adds when it closes and reopens formatting.
+const markdownReserve = 32
+
+// emphasisMarkers are checked longest first so "**" isn't read as two "*".
+var emphasisMarkers = []string{"**", "__", "~~", "||", "*", "_"}
+
+// isDelimiter reports whether the marker of length n at text[i] can open or
+// close formatting: Discord ignores markers with whitespace on both sides
+// ("5 * 3") and underscores inside words (snake_case).
+func isDelimiter(text string, i, n int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:i])
+	after, _ := utf8.DecodeRuneInString(text[i+n:])
+	spaceBefore := i == 0 || unicode.IsSpace(before)
+	spaceAfter := i+n == len(text) || unicode.IsSpace(after)
+	if spaceBefore && spaceAfter {
+		return false
+	}
+	if text[i] == '_' && i > 0 && i+n < len(text) && isWordRune(before) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// openMarkers returns the formatting markers still open at the end of text,
+// outermost first. Code fences are recorded with their language ("```go").
+func openMarkers(text string) []string {
+	var open []string
+	top := func() string {
+		if len(open) == 0 {
+			return ""
+		}
+		return open[len(open)-1]
+	}
+	for i := 0; i < len(text); {
+		inFence := strings.HasPrefix(top(), "```")
+		inCode := top() == "`"
+		switch {
+		case text[i] == '\\' && !inFence && !inCode:
+			i += 2
+			continue
+		case strings.HasPrefix(text[i:], "```") && !inCode:
+			if inFence {
+				open = open[:len(open)-1]
+				i += 3
+				continue
+			}
+			lang := ""
+			if end := strings.IndexByte(text[i+3:], '\n'); end >= 0 {
+				if candidate := text[i+3 : i+3+end]; strings.TrimFunc(candidate, isWordRune) == "" {
+					lang = candidate
+				}
+			}
+			open = append(open, "```"+lang)
+			i += 3
+			continue
+		case inFence:
+			i++
+			continue
+		case text[i] == '`':
+			if inCode {
+				open = open[:len(open)-1]
+			} else {
+				open = append(open, "`")
+			}
+			i++
+			continue
+		case inCode:
+			i++
+			continue
+		}
+
+		matched := 0
+		for _, marker := range emphasisMarkers {
+			if strings.HasPrefix(text[i:], marker) && isDelimiter(text, i, len(marker)) {
+				matched = len(marker)
+				if j := lastIndexOf(open, marker); j >= 0 {
+					open = append(open[:j], open[j+1:]...)
+				} else {
+					open = append(open, marker)
+				}
+				break
+			}
+		}
+		if matched == 0 {
+			matched = 1
+		}
+		i += matched
+	}
+	return open
+}
+
+func lastIndexOf(list []string, value string) int {
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i] == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// closingMarkers closes open formatting, innermost first.
+func closingMarkers(open []string) string {
+	var b strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		if strings.HasPrefix(open[i], "```") {
+			b.WriteString("\n```")
+		} else {
+			b.WriteString(open[i])
+		}
+	}
+	return b.String()
+}
+
+// reopeningMarkers restores formatting closed at the end of the previous
+// chunk, outermost first.
+func reopeningMarkers(open []string) string {
+	var b strings.Builder
+	for _, marker := range open {
+		b.WriteString(marker)
+		if strings.HasPrefix(marker, "```") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// balanceMarkdown repairs each chunk so its formatting is self-contained:
+// markers left open by the agent or cut by splitting are closed at the end
+// of the chunk and reopened at the start of the next, so one stray asterisk
+// can't italicize the rest of a scene.
+func balanceMarkdown(chunks []string) []string {
+	var carry []string
+	out := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		chunk = reopeningMarkers(carry) + chunk
+		open := openMarkers(chunk)
+		if len(open) > 0 {
+			metrics.Inc("markdown_repairs_total")
+		}
+		out[i] = chunk + closingMarkers(open)
+		carry = open
+	}
+	return out
+}
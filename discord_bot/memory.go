@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Bounded caches for Discord objects. These replace per-message REST lookups
+// and discordgo's unbounded member tracking so memory stays flat on bots
+// invited to hundreds of guilds.
+var (
+	channelCache *lruCache[string, *discordgo.Channel]
+	guildCache   *lruCache[string, *discordgo.Guild]
+	memberCache  *lruCache[string, *discordgo.Member]
+)
+
+// trackedCache is the subset of lruCache the guardrails need, so caches with
+// different key/value types can share one registry.
+type trackedCache interface {
+	PurgeExpired() int
+	Stats() cacheStats
+}
+
+var (
+	trackedCachesMu sync.Mutex
+	trackedCaches   []trackedCache
+)
+
+// trackCache registers a cache for periodic eviction and size reporting.
+func trackCache(c trackedCache) {
+	trackedCachesMu.Lock()
+	trackedCaches = append(trackedCaches, c)
+	trackedCachesMu.Unlock()
+}
+
+func allCacheStats() []cacheStats {
+	trackedCachesMu.Lock()
+	defer trackedCachesMu.Unlock()
+	stats := make([]cacheStats, 0, len(trackedCaches))
+	for _, c := range trackedCaches {
+		stats = append(stats, c.Stats())
+	}
+	return stats
+}
+
+// initCaches builds the Discord object caches and applies state limits.
+func initCaches(dg *discordgo.Session) {
+	c := config()
+	channelCache = newLRUCache[string, *discordgo.Channel]("channels", c.MaxCachedChannels, c.CacheTTL)
+	guildCache = newLRUCache[string, *discordgo.Guild]("guilds", c.MaxCachedGuilds, c.CacheTTL)
+	memberCache = newLRUCache[string, *discordgo.Member]("members", c.MaxCachedMembers, c.CacheTTL)
+	trackCache(channelCache)
+	trackCache(guildCache)
+	trackCache(memberCache)
+	processedMessages = newLRUCache[string, time.Time]("processed_messages", c.DedupMaxMessages, c.DedupWindow)
+	trackCache(processedMessages)
+
+	// Members live in the bounded cache instead of the gateway state, which
+	// would otherwise keep every member of every guild forever.
+	dg.State.TrackMembers = false
+	dg.State.TrackPresences = false
+	dg.State.MaxMessageCount = 0
+}
+
+// runCacheSweeper evicts expired entries and refreshes the size metrics.
+func runCacheSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sweepCaches()
+	}
+}
+
+func sweepCaches() {
+	trackedCachesMu.Lock()
+	caches := append([]trackedCache(nil), trackedCaches...)
+	trackedCachesMu.Unlock()
+
+	purged := 0
+	for _, c := range caches {
+		purged += c.PurgeExpired()
+		st := c.Stats()
+		metrics.Set(metricLabel("cache_entries", "cache", st.Name), float64(st.Size))
+		metrics.Set(metricLabel("cache_evictions", "cache", st.Name), float64(st.Evictions))
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	metrics.Set("heap_alloc_bytes", float64(mem.HeapAlloc))
+	metrics.Set("goroutines", float64(runtime.NumGoroutine()))
+	if purged > 0 {
+		log.Printf("🧹 Cache sweep evicted %d expired entries", purged)
+	}
+}
+
+// getChannel returns channel info from the gateway state or the bounded
+// cache, falling back to the REST API. The state doesn't track messages, so
+// its channels have a stale LastMessageID; use fetchChannel when that
+// matters.
+func getChannel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	if channel, err := s.State.Channel(channelID); err == nil {
+		return channel, nil
+	}
+	return fetchChannel(s, channelID)
+}
+
+// fetchChannel returns channel info from the bounded cache, falling back to
+// the REST API.
+func fetchChannel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	if channel, ok := channelCache.Get(channelID); ok {
+		return channel, nil
+	}
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Inc("channel_fetches_total")
+	channelCache.Add(channelID, channel)
+	return channel, nil
+}
+
+// channelUpdate refreshes a cached channel, so renames, permission and
+// NSFW changes apply without waiting for CACHE_TTL.
+func channelUpdate(s *discordgo.Session, c *discordgo.ChannelUpdate) {
+	if c.Channel == nil {
+		return
+	}
+	if channelCache.Contains(c.ID) {
+		channelCache.Add(c.ID, c.Channel)
+	}
+}
+
+func channelDelete(s *discordgo.Session, c *discordgo.ChannelDelete) {
+	if c.Channel != nil {
+		channelCache.Remove(c.ID)
+	}
+}
+
+func threadDelete(s *discordgo.Session, t *discordgo.ThreadDelete) {
+	if t.Channel != nil {
+		channelCache.Remove(t.ID)
+	}
+}
+
+// getGuild returns guild info from the gateway state or the bounded cache,
+// falling back to the REST API.
+func getGuild(s *discordgo.Session, guildID string) (*discordgo.Guild, error) {
+	if guild, err := s.State.Guild(guildID); err == nil {
+		return guild, nil
+	}
+	if guild, ok := guildCache.Get(guildID); ok {
+		return guild, nil
+	}
+	guild, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	guildCache.Add(guildID, guild)
+	return guild, nil
+}
+
+// getMember returns a guild member from the bounded cache, falling back to
+// the REST API.
+func getMember(s *discordgo.Session, guildID, userID string) (*discordgo.Member, error) {
+	key := guildID + ":" + userID
+	if member, ok := memberCache.Get(key); ok {
+		return member, nil
+	}
+	member, err := s.GuildMember(guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	memberCache.Add(key, member)
+	return member, nil
+}
+
+// stateGuilds returns a snapshot of the guilds in the gateway state, safe to
+// range over from background goroutines.
+func stateGuilds(s *discordgo.Session) []*discordgo.Guild {
+	s.State.RLock()
+	defer s.State.RUnlock()
+	return append([]*discordgo.Guild(nil), s.State.Guilds...)
+}
+
+// memoryReport renders the `!elsie status --memory` detail view.
+func memoryReport() string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	var b strings.Builder
+	b.WriteString("🧠 **Holographic Memory Banks**\n```\n")
+	fmt.Fprintf(&b, "Heap in use:   %s\n", formatBytes(mem.HeapAlloc))
+	fmt.Fprintf(&b, "Heap reserved: %s\n", formatBytes(mem.HeapSys))
+	fmt.Fprintf(&b, "Total from OS: %s\n", formatBytes(mem.Sys))
+	fmt.Fprintf(&b, "GC cycles:     %d\n", mem.NumGC)
+	fmt.Fprintf(&b, "Goroutines:    %d\n\n", runtime.NumGoroutine())
+	fmt.Fprintf(&b, "%-10s %7s %7s %8s %8s %9s\n", "CACHE", "SIZE", "CAP", "HITS", "MISSES", "EVICTED")
+	for _, st := range allCacheStats() {
+		fmt.Fprintf(&b, "%-10s %7d %7d %8d %8d %9d\n", st.Name, st.Size, st.Capacity, st.Hits, st.Misses, st.Evictions)
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
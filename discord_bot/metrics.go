@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// metricsRegistry holds process-wide counters and gauges. Metric names may
+// carry Prometheus-style labels, e.g. `cache_entries{cache="channels"}`.
+type metricsRegistry struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+}
+
+var metrics = &metricsRegistry{
+	counters: make(map[string]float64),
+	gauges:   make(map[string]float64),
+}
+
+// Inc adds one to the named counter.
+func (r *metricsRegistry) Inc(name string) {
+	r.Add(name, 1)
+}
+
+// Add adds delta to the named counter.
+func (r *metricsRegistry) Add(name string, delta float64) {
+	r.mu.Lock()
+	r.counters[name] += delta
+	r.mu.Unlock()
+}
+
+// Set records the current value of the named gauge.
+func (r *metricsRegistry) Set(name string, value float64) {
+	r.mu.Lock()
+	r.gauges[name] = value
+	r.mu.Unlock()
+}
+
+// Value returns the current value of a counter or gauge.
+func (r *metricsRegistry) Value(name string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.counters[name]; ok {
+		return v
+	}
+	return r.gauges[name]
+}
+
+// WriteText writes every metric in Prometheus text exposition format.
+func (r *metricsRegistry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	write := func(values map[string]float64) {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "elsie_%s %g\n", name, values[name])
+		}
+	}
+	write(r.counters)
+	write(r.gauges)
+}
+
+// metricLabel formats a metric name with a single label.
+func metricLabel(name, label, value string) string {
+	return fmt.Sprintf("%s{%s=%q}", name, label, value)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// missionLogBucket records which threads have a mission report, by thread
+// ID, so the same mission isn't filed twice by accident.
+const missionLogBucket = "mission_logs"
+
+// MissionLog points at the report filed for a thread.
+type MissionLog struct {
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	FiledBy   string    `json:"filed_by"`
+	FiledAt   time.Time `json:"filed_at"`
+}
+
+// missionReportRequest is the payload for POST /mission_report.
+type missionReportRequest struct {
+	RequestID    string               `json:"request_id"`
+	SessionID    string               `json:"session_id"`
+	Persona      string               `json:"persona"`
+	GuildID      string               `json:"guild_id"`
+	ChannelID    string               `json:"channel_id"`
+	ChannelName  string               `json:"channel_name,omitempty"`
+	Stardate     string               `json:"stardate"`
+	Participants []missionParticipant `json:"participants"`
+	Messages     []transcriptMessage  `json:"messages"`
+}
+
+type missionParticipant struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Posts  int    `json:"posts"`
+}
+
+// missionReportResponse is the agent's answer to POST /mission_report.
+type missionReportResponse struct {
+	Title        string   `json:"title"`
+	Stardate     string   `json:"stardate"`
+	Summary      string   `json:"summary"`
+	Participants []string `json:"participants"`
+	Highlights   []string `json:"highlights"`
+}
+
+func init() {
+	registerCommand(command{name: "log", handler: logCommand})
+	registerDataEraser(dataEraser{name: "mission logs", guild: eraseChannelKeys(missionLogBucket)})
+}
+
+// logCommand is `!elsie log mission [#thread] [--again]` and
+// `!elsie log channel [#channel|off]`.
+func logCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie log mission [#thread]` or `!elsie log channel #channel|off`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Mission logs live in server channels — use this command there."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		ctx.reply(usage)
+		return
+	}
+	switch strings.ToLower(ctx.args[0]) {
+	case "mission":
+		logMission(ctx, ctx.args[1:])
+	case "channel":
+		missionLogChannelCommand(ctx, ctx.args[1:])
+	default:
+		ctx.reply(usage)
+	}
+}
+
+// missionLogChannelCommand sets where mission reports are filed: a forum
+// channel gets one post per mission; a text channel gets pinned embeds.
+func missionLogChannelCommand(ctx *commandContext, args []string) {
+	if len(args) == 0 {
+		if id := loadGuildConfig(ctx.m.GuildID).MissionLogChannelID; id != "" {
+			ctx.reply(ctx.tr("📁 **Mission logs:** <#%s>", id))
+		} else {
+			ctx.reply(ctx.tr("📁 **Mission logs:** not set up. Pick a forum or text channel with `!elsie log channel #channel`."))
+		}
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can choose the mission log channel."))
+		return
+	}
+	channelID := ""
+	if !strings.EqualFold(args[0], "off") {
+		channelID = parseChannelMention(args[0])
+		channel, err := getChannel(ctx.s, channelID)
+		if channelID == "" || err != nil || channel.GuildID != ctx.m.GuildID ||
+			(channel.Type != discordgo.ChannelTypeGuildForum && channel.Type != discordgo.ChannelTypeGuildText) {
+			ctx.reply(ctx.tr("*squints* That isn't a forum or text channel in this server. Mention it (`<#id>`) or give its ID."))
+			return
+		}
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.MissionLogChannelID = channelID }); err != nil {
+		log.Printf("Error saving mission log channel: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if channelID == "" {
+		ctx.reply(ctx.tr("📁 Mission logs are off."))
+		return
+	}
+	ctx.reply(ctx.tr("📁 Mission reports will be filed in <#%s>.", channelID))
+}
+
+// logMission files a mission report for a finished RP thread: the thread
+// the command is run in, or the one given.
+func logMission(ctx *commandContext, args []string) {
+	archiveID := loadGuildConfig(ctx.m.GuildID).MissionLogChannelID
+	if archiveID == "" {
+		ctx.reply(ctx.tr("📁 There's no mission log channel yet. An admin can set one with `!elsie log channel #channel`."))
+		return
+	}
+	threadID := ctx.m.ChannelID
+	for _, arg := range withoutFlags(args) {
+		if id := parseChannelMention(arg); id != "" {
+			threadID = id
+		}
+	}
+	thread, err := getChannel(ctx.s, threadID)
+	if err != nil || thread.GuildID != ctx.m.GuildID || !thread.IsThread() {
+		ctx.reply(ctx.tr("📁 Run this in the mission's thread, or mention the thread."))
+		return
+	}
+	if thread.OwnerID != ctx.m.Author.ID && !isModerator(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only moderators or the thread's creator can file its mission log."))
+		return
+	}
+	if loadScene(threadID).Active {
+		ctx.reply(ctx.tr("🎬 That scene is still running. Close it with `!elsie scene close` before filing the log."))
+		return
+	}
+	var filed MissionLog
+	if ok, _ := store.Get(missionLogBucket, threadID, &filed); ok && !ctx.hasFlag("again") {
+		ctx.reply(ctx.tr("📁 This mission was already filed: https://discord.com/channels/%s/%s/%s. Add `--again` to file a new report.",
+			ctx.m.GuildID, filed.ChannelID, filed.MessageID))
+		return
+	}
+
+	p := channelPersona(ctx.s, ctx.m.GuildID, threadID)
+	pool := poolFor(p.ID)
+	if !pool.supports(featureMissionLog) {
+		ctx.reply(ctx.tr("📁 My agent can't write mission reports yet."))
+		return
+	}
+	rlog := requestLog{id: newRequestID()}
+	ctx.s.ChannelTyping(ctx.m.ChannelID)
+
+	beforeID := ""
+	if threadID == ctx.m.ChannelID {
+		beforeID = ctx.m.ID
+	}
+	history, err := readRecentHistory(ctx.s, threadID, beforeID, config().SummarizeMaxMessages)
+	if err != nil {
+		rlog.Printf("Error reading mission history in %s: %v", threadID, err)
+		ctx.reply(ctx.tr("*frowns* I couldn't read that thread's history. I need Read Message History there."))
+		return
+	}
+	transcript := summaryTranscript(ctx.s, ctx.m.GuildID, history)
+	if len(transcript) == 0 {
+		ctx.reply(ctx.tr("📁 There's nothing in that thread to report on."))
+		return
+	}
+
+	req := missionReportRequest{
+		RequestID:    rlog.id,
+		SessionID:    p.sessionID(threadID),
+		Persona:      p.ID,
+		GuildID:      ctx.m.GuildID,
+		ChannelID:    threadID,
+		ChannelName:  thread.Name,
+		Stardate:     formatStardate(stardateOf(ctx.m.GuildID, transcript[0].Timestamp)),
+		Participants: missionParticipants(transcript),
+		Messages:     transcript,
+	}
+	body, err := pool.call(rlog.id, "/mission_report", req)
+	var rejected *agentRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		rlog.Printf("Agent does not support mission reports: %v", err)
+		ctx.reply(ctx.tr("📁 My agent can't write mission reports yet."))
+		return
+	case err != nil:
+		rlog.Printf("Error writing mission report for %s: %v", threadID, err)
+		ctx.reply(ctx.tr("*winces* I lost my train of thought. Try again in a moment."))
+		return
+	}
+	var resp missionReportResponse
+	if err := json.Unmarshal(body, &resp); err != nil || strings.TrimSpace(resp.Summary) == "" {
+		rlog.Printf("Invalid mission report response: %v", err)
+		ctx.reply(ctx.tr("*winces* I lost my train of thought. Try again in a moment."))
+		return
+	}
+	screened, ok := screenContent(ctx.s, ctx.m.GuildID, archiveID, "", "outbound", resp.Summary)
+	if !ok {
+		ctx.reply(ctx.tr("📁 I'd rather not file that report."))
+		return
+	}
+	resp.Summary = screened
+	if resp.Stardate == "" {
+		resp.Stardate = req.Stardate
+	}
+	if resp.Title == "" {
+		resp.Title = thread.Name
+	}
+
+	embed := missionReportEmbed(ctx.m.GuildID, thread, resp, req.Participants, transcript)
+	post, err := fileMissionReport(ctx.s, archiveID, resp, embed)
+	if err != nil {
+		rlog.Printf("Error filing mission report in %s: %v", archiveID, err)
+		ctx.reply(ctx.tr("*frowns* I couldn't post in <#%s>. Check my permissions there.", archiveID))
+		return
+	}
+	entry := MissionLog{ChannelID: post.ChannelID, MessageID: post.ID, FiledBy: ctx.m.Author.ID, FiledAt: time.Now()}
+	if err := store.Put(missionLogBucket, threadID, entry); err != nil {
+		rlog.Printf("Error recording mission log for %s: %v", threadID, err)
+	}
+	rlog.Printf("📁 Filed mission report for thread %s in %s", threadID, archiveID)
+	metrics.Inc("mission_logs_total")
+	ctx.reply(ctx.tr("📁 Mission report filed: https://discord.com/channels/%s/%s/%s", ctx.m.GuildID, post.ChannelID, post.ID))
+}
+
+// missionParticipants lists the players in a transcript by post count.
+func missionParticipants(transcript []transcriptMessage) []missionParticipant {
+	var out []missionParticipant
+	index := map[string]int{}
+	for _, msg := range transcript {
+		if msg.Bot {
+			continue
+		}
+		i, ok := index[msg.AuthorID]
+		if !ok {
+			i = len(out)
+			index[msg.AuthorID] = i
+			out = append(out, missionParticipant{UserID: msg.AuthorID, Name: msg.Author})
+		}
+		out[i].Posts++
+	}
+	return out
+}
+
+// missionReportEmbed renders the agent's report with a link to the thread.
+func missionReportEmbed(guildID string, thread *discordgo.Channel, resp missionReportResponse, participants []missionParticipant, transcript []transcriptMessage) *discordgo.MessageEmbed {
+	crew := resp.Participants
+	if len(crew) == 0 {
+		for _, p := range participants {
+			crew = append(crew, fmt.Sprintf("<@%s>", p.UserID))
+		}
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "📁 " + truncateText(resp.Title, 240),
+		Description: truncateText(strings.TrimSpace(resp.Summary), 4000),
+		Color:       themeColor(guildID, "info"),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: tr(guildID, "Stardate"), Value: resp.Stardate, Inline: true},
+			{Name: tr(guildID, "Thread"), Value: fmt.Sprintf("<#%s>", thread.ID), Inline: true},
+		},
+	}
+	if len(crew) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: tr(guildID, "Participants"), Value: truncateText(strings.Join(crew, ", "), 1024)})
+	}
+	if len(resp.Highlights) > 0 {
+		var b strings.Builder
+		for _, h := range resp.Highlights {
+			fmt.Fprintf(&b, "• %s\n", strings.TrimSpace(h))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: tr(guildID, "Highlights"), Value: truncateText(b.String(), 1024)})
+	}
+	first, last := transcript[0].Timestamp, transcript[len(transcript)-1].Timestamp
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("%d messages • %s – %s", len(transcript), first.UTC().Format("Jan 2 15:04"), last.UTC().Format("Jan 2 15:04 UTC")),
+	}
+	return embed
+}
+
+// fileMissionReport posts the report as a new forum post, or as a pinned
+// embed in a text channel, and returns the posted message.
+func fileMissionReport(s *discordgo.Session, channelID string, resp missionReportResponse, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	channel, err := getChannel(s, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.Type == discordgo.ChannelTypeGuildForum {
+		name := truncateText(fmt.Sprintf("Stardate %s — %s", resp.Stardate, resp.Title), 100)
+		post, err := s.ForumThreadStartComplex(channelID, &discordgo.ThreadStart{Name: name}, &discordgo.MessageSend{
+			Embeds:          []*discordgo.MessageEmbed{embed},
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// A forum post's starter message shares the thread's ID
+		return &discordgo.Message{ID: post.ID, ChannelID: post.ID}, nil
+	}
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:          []*discordgo.MessageEmbed{embed},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ChannelMessagePin(channelID, msg.ID); err != nil {
+		log.Printf("Error pinning mission report %s: %v", msg.ID, err)
+	}
+	return msg, nil
+}
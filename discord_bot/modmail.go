@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const reportBucket = "reports"
+
+// Report states, shown on the staff embed.
+const (
+	reportOpen         = "open"
+	reportAcknowledged = "acknowledged"
+	reportClosed       = "closed"
+)
+
+// Report is a message forwarded to a guild's staff channel. ReporterID is
+// kept even for anonymous reports so staff replies can reach the reporter;
+// it is only hidden from the embed.
+type Report struct {
+	ID         string    `json:"id"`
+	GuildID    string    `json:"guild_id"`
+	ReporterID string    `json:"reporter_id"`
+	Text       string    `json:"text"`
+	Source     string    `json:"source"`
+	Anonymous  bool      `json:"anonymous,omitempty"`
+	Status     string    `json:"status"`
+	HandledBy  string    `json:"handled_by,omitempty"`
+	Created    time.Time `json:"created"`
+	ChannelID  string    `json:"channel_id"`
+	MessageID  string    `json:"message_id,omitempty"`
+}
+
+var (
+	// reportMu serializes status changes on reports.
+	reportMu sync.Mutex
+	// pendingReports holds DM reports while the reporter picks which server
+	// to send them to.
+	pendingReports = newLRUCache[string, string]("pending_reports", 1000, 10*time.Minute)
+)
+
+func init() {
+	trackCache(pendingReports)
+	registerCommand(command{name: "reports", handler: reportsCommand})
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:        "report",
+		Description: "Send a private report to the server's staff",
+		Options: []*discordgo.ApplicationCommandOption{{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "message",
+			Description: "What you'd like the staff to know",
+			Required:    true,
+			MaxLength:   1500,
+		}},
+	}, reportSlash)
+	registerComponentHandler("modmail", modmailComponent)
+}
+
+func loadReport(id string) (*Report, bool) {
+	r := &Report{}
+	ok, err := store.Get(reportBucket, id, r)
+	if err != nil {
+		log.Printf("Error loading report %s: %v", id, err)
+	}
+	return r, ok && err == nil
+}
+
+// parseDMReport recognizes a DM that starts with "report", e.g. "report
+// someone is spamming #general", and returns the report text.
+func parseDMReport(content string) (string, bool) {
+	trimmed := strings.TrimSpace(content)
+	if len(trimmed) < len("report") || !strings.EqualFold(trimmed[:len("report")], "report") {
+		return "", false
+	}
+	rest := trimmed[len("report"):]
+	if rest != "" && !strings.ContainsAny(rest[:1], " \n:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ":")), true
+}
+
+// reportGuilds returns the guilds with a staff channel that userID belongs
+// to.
+func reportGuilds(s *discordgo.Session, userID string) []*discordgo.Guild {
+	var guilds []*discordgo.Guild
+	for _, g := range stateGuilds(s) {
+		if loadGuildConfig(g.ID).ReportChannelID == "" {
+			continue
+		}
+		if _, err := s.State.Member(g.ID, userID); err != nil {
+			if _, err := s.GuildMember(g.ID, userID); err != nil {
+				continue
+			}
+		}
+		guilds = append(guilds, g)
+	}
+	return guilds
+}
+
+// handleDMReport forwards a DM report, asking the reporter which server it
+// is for when they share several with a staff channel.
+func handleDMReport(s *discordgo.Session, m *discordgo.MessageCreate, text string) {
+	if text == "" {
+		s.ChannelMessageSend(m.ChannelID, "📨 Tell me what to pass on, e.g. `report someone is spamming in #general`.")
+		return
+	}
+	guilds := reportGuilds(s, m.Author.ID)
+	switch len(guilds) {
+	case 0:
+		s.ChannelMessageSend(m.ChannelID, "📨 None of the servers we share take reports through me, I'm afraid.")
+		return
+	case 1:
+		s.ChannelMessageSend(m.ChannelID, fileReport(s, guilds[0].ID, m.Author.ID, text, "DM"))
+		return
+	}
+
+	pendingReports.Add(m.Author.ID, text)
+	options := make([]discordgo.SelectMenuOption, 0, len(guilds))
+	for _, g := range guilds {
+		if len(options) == maxSelectOptions {
+			break
+		}
+		options = append(options, discordgo.SelectMenuOption{Label: truncateText(g.Name, 100), Value: g.ID})
+	}
+	_, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content: "📨 Which server's staff should get this report?",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{CustomID: "modmail:pick", Placeholder: "Choose a server", Options: options},
+			}},
+		},
+	})
+	if err != nil {
+		log.Printf("Error asking which server to report to: %v", err)
+	}
+}
+
+// reportSlash is `/report message:<text>`, in a server or in DMs.
+func reportSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	text := strings.TrimSpace(i.ApplicationCommandData().Options[0].StringValue())
+	if i.GuildID != "" {
+		if loadGuildConfig(i.GuildID).ReportChannelID == "" {
+			respondEphemeral(s, i, "📨 This server doesn't take reports through me.")
+			return
+		}
+		respondEphemeral(s, i, fileReport(s, i.GuildID, user.ID, text, fmt.Sprintf("/report in <#%s>", i.ChannelID)))
+		return
+	}
+	guilds := reportGuilds(s, user.ID)
+	switch len(guilds) {
+	case 0:
+		respondEphemeral(s, i, "📨 None of the servers we share take reports through me, I'm afraid.")
+	case 1:
+		respondEphemeral(s, i, fileReport(s, guilds[0].ID, user.ID, text, "/report in DMs"))
+	default:
+		respondEphemeral(s, i, "📨 We share several servers — send this as a DM starting with `report` and I'll ask which one.")
+	}
+}
+
+// fileReport stores the report, posts it to the guild's staff channel and
+// returns the confirmation for the reporter.
+func fileReport(s *discordgo.Session, guildID, reporterID, text, source string) string {
+	cfg := loadGuildConfig(guildID)
+	r := &Report{
+		ID:         newRequestID()[:8],
+		GuildID:    guildID,
+		ReporterID: reporterID,
+		Text:       truncateText(text, 1500),
+		Source:     source,
+		Anonymous:  cfg.ReportAnonymous,
+		Status:     reportOpen,
+		Created:    time.Now().UTC(),
+		ChannelID:  cfg.ReportChannelID,
+	}
+	msg, err := s.ChannelMessageSendComplex(r.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{r.embed()},
+		Components: r.components(),
+	})
+	if err != nil {
+		log.Printf("Error posting report to %s: %v", r.ChannelID, err)
+		return "*holographic matrix flickers* I couldn't reach the staff channel. Please try again later or contact a moderator directly."
+	}
+	r.MessageID = msg.ID
+	if err := store.Put(reportBucket, r.ID, r); err != nil {
+		log.Printf("Error saving report %s: %v", r.ID, err)
+	}
+	metrics.Inc("reports_total")
+	log.Printf("📨 Report %s filed in guild %s by %s", r.ID, guildID, logUser("", reporterID))
+	note := ""
+	if r.Anonymous {
+		note = " Your name isn't shown to them."
+	}
+	return fmt.Sprintf("📨 Passed on to the staff as report `%s`.%s I'll DM you if they reply.", r.ID, note)
+}
+
+func (r *Report) embed() *discordgo.MessageEmbed {
+	from := fmt.Sprintf("<@%s>", r.ReporterID)
+	if r.Anonymous {
+		from = "Anonymous"
+	}
+	status := r.Status
+	if r.HandledBy != "" {
+		status += fmt.Sprintf(" by <@%s>", r.HandledBy)
+	}
+	color := themeColor(r.GuildID, "warning")
+	if r.Status == reportClosed {
+		color = themeColor(r.GuildID, "info")
+	}
+	return &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📨 Report `%s`", r.ID),
+		Description: r.Text,
+		Color:       color,
+		Timestamp:   r.Created.Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "From", Value: from, Inline: true},
+			{Name: "Via", Value: r.Source, Inline: true},
+			{Name: "Status", Value: status, Inline: true},
+		},
+	}
+}
+
+func (r *Report) components() []discordgo.MessageComponent {
+	if r.Status == reportClosed {
+		return []discordgo.MessageComponent{}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Acknowledge", Style: discordgo.PrimaryButton, CustomID: "modmail:ack:" + r.ID, Disabled: r.Status == reportAcknowledged},
+			discordgo.Button{Label: "Reply", Style: discordgo.SecondaryButton, CustomID: "modmail:reply:" + r.ID},
+			discordgo.Button{Label: "Close", Style: discordgo.DangerButton, CustomID: "modmail:close:" + r.ID},
+		}},
+	}
+}
+
+// isStaffInteraction reports whether the interaction comes from someone who
+// can handle reports: moderators, guild admins and bot owners.
+func isStaffInteraction(i *discordgo.InteractionCreate) bool {
+	if isBotOwner(interactionUser(i).ID) {
+		return true
+	}
+	if i.Member == nil {
+		return false
+	}
+	return i.Member.Permissions&(discordgo.PermissionManageMessages|discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0
+}
+
+// modmailComponent handles the server pick in DMs, the staff buttons and
+// the reply modal.
+func modmailComponent(s *discordgo.Session, i *discordgo.InteractionCreate, payload string) {
+	action, id, _ := strings.Cut(payload, ":")
+	user := interactionUser(i)
+
+	if action == "pick" {
+		text, ok := pendingReports.Get(user.ID)
+		values := i.MessageComponentData().Values
+		if !ok || len(values) == 0 {
+			respondEphemeral(s, i, "📨 That report timed out — please send it again.")
+			return
+		}
+		pendingReports.Remove(user.ID)
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    fileReport(s, values[0], user.ID, text, "DM"),
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			log.Printf("Error confirming report: %v", err)
+		}
+		return
+	}
+
+	if !isStaffInteraction(i) {
+		respondEphemeral(s, i, "*shakes head* Only moderators can handle reports.")
+		return
+	}
+	r, ok := loadReport(id)
+	if !ok || r.GuildID != i.GuildID {
+		respondEphemeral(s, i, "📨 I can't find that report anymore.")
+		return
+	}
+
+	switch action {
+	case "ack", "close":
+		reportMu.Lock()
+		r.HandledBy = user.ID
+		r.Status = reportAcknowledged
+		if action == "close" {
+			r.Status = reportClosed
+		}
+		err := store.Put(reportBucket, r.ID, r)
+		reportMu.Unlock()
+		if err != nil {
+			log.Printf("Error saving report %s: %v", r.ID, err)
+			respondEphemeral(s, i, themePhrase(i.GuildID, "save_failed", nil))
+			return
+		}
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{r.embed()},
+				Components: r.components(),
+			},
+		})
+		if err != nil {
+			log.Printf("Error updating report %s: %v", r.ID, err)
+		}
+	case "reply":
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: "modmail:send:" + r.ID,
+				Title:    "Reply to report " + r.ID,
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{CustomID: "text", Label: "Reply", Style: discordgo.TextInputParagraph, Required: true, MaxLength: 1500},
+					}},
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error opening reply form for report %s: %v", r.ID, err)
+		}
+	case "send":
+		text := modalValue(i, "text")
+		if text == "" {
+			respondEphemeral(s, i, "📨 The reply was empty, so I didn't send it.")
+			return
+		}
+		guildName := i.GuildID
+		if g, err := getGuild(s, i.GuildID); err == nil {
+			guildName = g.Name
+		}
+		dm, err := s.UserChannelCreate(r.ReporterID)
+		if err == nil {
+			_, err = s.ChannelMessageSend(dm.ID, fmt.Sprintf("📨 **The staff of %s replied to your report `%s`:**\n%s", guildName, r.ID, text))
+		}
+		if err != nil {
+			log.Printf("Error delivering reply to report %s: %v", r.ID, err)
+			respondEphemeral(s, i, "📨 I couldn't DM the reporter — they may have DMs turned off.")
+			return
+		}
+		log.Printf("📨 Staff %s replied to report %s", logUser("", user.ID), r.ID)
+		respondEphemeral(s, i, "📨 Reply sent to the reporter.")
+		if _, err := s.ChannelMessageSend(r.ChannelID, fmt.Sprintf("📨 <@%s> replied to report `%s`:\n>>> %s", user.ID, r.ID, text)); err != nil {
+			log.Printf("Error logging reply to report %s: %v", r.ID, err)
+		}
+	}
+}
+
+// modalValue returns the text input with customID from a modal submission.
+func modalValue(i *discordgo.InteractionCreate, customID string) string {
+	for _, row := range i.ModalSubmitData().Components {
+		actions, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, c := range actions.Components {
+			if input, ok := c.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// reportsCommand is `!elsie reports [channel #channel|off|anonymous on|off]`.
+func reportsCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie reports channel #channel`, `!elsie reports off`, `!elsie reports anonymous on|off`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Report settings are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change report settings.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		channel := "off"
+		if cfg.ReportChannelID != "" {
+			channel = fmt.Sprintf("<#%s>", cfg.ReportChannelID)
+		}
+		anonymous := "off"
+		if cfg.ReportAnonymous {
+			anonymous = "on"
+		}
+		ctx.reply(fmt.Sprintf("📨 **Reports:** %s • Anonymous: %s\n%s", channel, anonymous, usage))
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	var confirmation string
+	switch strings.ToLower(ctx.args[0]) {
+	case "channel":
+		if len(ctx.args) < 2 || parseChannelMention(ctx.args[1]) == "" {
+			ctx.reply("Mention the channel, e.g. `!elsie reports channel #staff`.")
+			return
+		}
+		channelID := parseChannelMention(ctx.args[1])
+		apply = func(cfg *GuildConfig) { cfg.ReportChannelID = channelID }
+		confirmation = fmt.Sprintf("📨 Reports will be forwarded to <#%s>.", channelID)
+	case "off":
+		apply = func(cfg *GuildConfig) { cfg.ReportChannelID = "" }
+		confirmation = "📨 Reports are **off** for this server."
+	case "anonymous":
+		if len(ctx.args) < 2 || (ctx.args[1] != "on" && ctx.args[1] != "off") {
+			ctx.reply(usage)
+			return
+		}
+		on := ctx.args[1] == "on"
+		apply = func(cfg *GuildConfig) { cfg.ReportAnonymous = on }
+		confirmation = "📨 Reporters' names are now shown to staff."
+		if on {
+			confirmation = "📨 Reporters' names are now hidden from staff."
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving report settings: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(confirmation)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// isNSFWChannel reports whether channel is age-restricted. Threads inherit
+// the flag from their parent channel.
+func isNSFWChannel(s *discordgo.Session, channel *discordgo.Channel) bool {
+	if channel == nil {
+		return false
+	}
+	if channel.NSFW {
+		return true
+	}
+	if isThreadChannel(channel) && channel.ParentID != "" {
+		if parent, err := getChannel(s, channel.ParentID); err == nil {
+			return parent.NSFW
+		}
+	}
+	return false
+}
+
+// refusesChannel reports whether the guild's policy keeps Elsie out of the
+// channel because it is age-restricted.
+func refusesChannel(s *discordgo.Session, guildID, channelID string) bool {
+	if guildID == "" || !loadGuildConfig(guildID).RefuseNSFW {
+		return false
+	}
+	channel, err := getChannel(s, channelID)
+	if err != nil {
+		return false
+	}
+	return isNSFWChannel(s, channel)
+}
+
+func init() {
+	registerCommand(command{name: "nsfw", handler: nsfwCommand})
+}
+
+// nsfwCommand is `!elsie nsfw [respond|refuse]`.
+func nsfwCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("The NSFW policy is per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change where I serve.")
+		return
+	}
+
+	if len(ctx.args) == 0 {
+		policy := "respond"
+		if loadGuildConfig(ctx.m.GuildID).RefuseNSFW {
+			policy = "refuse"
+		}
+		ctx.reply(fmt.Sprintf("🔞 **Age-restricted channels:** %s\nUsage: `!elsie nsfw respond|refuse`", policy))
+		return
+	}
+
+	var refuse bool
+	switch strings.ToLower(ctx.args[0]) {
+	case "respond":
+		refuse = false
+	case "refuse":
+		refuse = true
+	default:
+		ctx.reply("Usage: `!elsie nsfw respond|refuse`")
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.RefuseNSFW = refuse }); err != nil {
+		log.Printf("Error saving NSFW policy: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if refuse {
+		ctx.reply("🔞 I'll stay out of age-restricted channels.")
+	} else {
+		ctx.reply("🔞 I'll answer in age-restricted channels; the agent is told when a channel is NSFW.")
+	}
+}
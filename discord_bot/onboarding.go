@@ -0,0 +1,397 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const onboardingBucket = "onboarding"
+
+// onboardingJoinWindow is how recently Elsie must have joined a guild for
+// its GuildCreate to count as a new invite rather than a reconnect.
+const onboardingJoinWindow = 10 * time.Minute
+
+// onboardingRecord remembers that a guild was offered the setup wizard, so
+// it is only sent once per guild.
+type onboardingRecord struct {
+	SentAt      time.Time `json:"sent_at"`
+	SentTo      string    `json:"sent_to"` // "dm" or "channel"
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+	CompletedBy string    `json:"completed_by,omitempty"`
+}
+
+// quotaPresets are the per-user rate limits offered by the wizard; "default"
+// removes the override so the operator's QUOTA_USER applies.
+var quotaPresets = []struct {
+	ID, Label, Value string
+}{
+	{"default", "Operator default", ""},
+	{"relaxed", "Relaxed — 20 per minute", "20/1m"},
+	{"strict", "Strict — 5 per minute", "5/1m"},
+	{"off", "No limit", "off"},
+}
+
+func init() {
+	registerComponentHandler("onboard", onboardingComponent)
+	registerCommand(command{name: "setup", handler: setupCommand})
+}
+
+// guildCreate offers the setup wizard when Elsie is invited to a guild. The
+// gateway also sends GuildCreate for known guilds on every connect, so only
+// guilds joined moments ago that were never offered the wizard count.
+func guildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
+	if !botReady.Load() || safeMode.Load() || g.Unavailable {
+		return
+	}
+	if g.JoinedAt.IsZero() || time.Since(g.JoinedAt) > onboardingJoinWindow {
+		return
+	}
+	var record onboardingRecord
+	if ok, _ := store.Get(onboardingBucket, g.ID, &record); ok {
+		return
+	}
+	log.Printf("👋 Joined guild %s (%s), sending setup wizard", g.Name, g.ID)
+	metrics.Inc("guild_joins_total")
+
+	sentTo := "dm"
+	channelID := ""
+	if inviter := guildInviter(s, g.ID); inviter != "" {
+		if dm, err := s.UserChannelCreate(inviter); err == nil {
+			channelID = dm.ID
+		}
+	}
+	if channelID == "" {
+		sentTo, channelID = "channel", g.SystemChannelID
+	}
+	if channelID == "" {
+		log.Printf("No inviter DM or system channel for guild %s, skipping setup wizard", g.ID)
+		return
+	}
+	if err := sendSetupWizard(s, g.ID, channelID, sentTo == "dm"); err != nil && sentTo == "dm" && g.SystemChannelID != "" {
+		// The inviter may not accept DMs; the system channel is the fallback
+		log.Printf("Error sending setup wizard by DM in guild %s: %v", g.ID, err)
+		sentTo = "channel"
+		err = sendSetupWizard(s, g.ID, g.SystemChannelID, false)
+		if err != nil {
+			log.Printf("Error sending setup wizard in guild %s: %v", g.ID, err)
+			return
+		}
+	} else if err != nil {
+		log.Printf("Error sending setup wizard in guild %s: %v", g.ID, err)
+		return
+	}
+	if err := store.Put(onboardingBucket, g.ID, onboardingRecord{SentAt: time.Now().UTC(), SentTo: sentTo}); err != nil {
+		log.Printf("Error saving onboarding record for guild %s: %v", g.ID, err)
+	}
+}
+
+// guildInviter returns who added Elsie to the guild, from the audit log.
+// It needs View Audit Log, so an empty result is normal.
+func guildInviter(s *discordgo.Session, guildID string) string {
+	entries, err := s.GuildAuditLog(guildID, "", "", int(discordgo.AuditLogActionBotAdd), 10)
+	if err != nil {
+		return ""
+	}
+	for _, e := range entries.AuditLogEntries {
+		if e.TargetID == s.State.User.ID {
+			return e.UserID
+		}
+	}
+	return ""
+}
+
+// sendSetupWizard posts the wizard for guildID in channelID. In DMs Discord
+// can't offer the guild's channels in a channel picker, so they are listed
+// in a plain select instead.
+func sendSetupWizard(s *discordgo.Session, guildID, channelID string, inDM bool) error {
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{setupEmbed(s, guildID)},
+		Components: setupComponents(s, guildID, inDM),
+	})
+	return err
+}
+
+// setupEmbed summarizes the guild's current choices.
+func setupEmbed(s *discordgo.Session, guildID string) *discordgo.MessageEmbed {
+	cfg := loadGuildConfig(guildID)
+	guildName := guildID
+	if g, err := getGuild(s, guildID); err == nil {
+		guildName = g.Name
+	}
+
+	channels := tr(guildID, "Threads and channels with \"rp\" or \"roleplay\" in the name")
+	if len(cfg.MonitoredChannels) > 0 {
+		mentions := make([]string, 0, len(cfg.MonitoredChannels))
+		for _, id := range cfg.MonitoredChannels {
+			mentions = append(mentions, fmt.Sprintf("<#%s>", id))
+		}
+		channels += tr(guildID, ", plus %s", strings.Join(mentions, ", "))
+	}
+	p := findPersona(cfg.DefaultPersona)
+	if p == nil {
+		p = defaultPersona()
+	}
+	prefix := prefixStatus(guildID)
+
+	return &discordgo.MessageEmbed{
+		Title:       themeEmoji(guildID, "announcement") + " " + tr(guildID, "Setting up the bar in %s", guildName),
+		Description: tr(guildID, "Thanks for inviting me! Pick the options below — each choice is saved right away, and admins can reopen this any time with `!elsie setup`."),
+		Color:       themeColor(guildID, "info"),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: tr(guildID, "Channels I read"), Value: channels},
+			{Name: tr(guildID, "Who answers"), Value: fmt.Sprintf("%s — %s", p.Name, p.Description), Inline: true},
+			{Name: tr(guildID, "Command prefix"), Value: prefix, Inline: true},
+			{Name: tr(guildID, "Rate limit per member"), Value: guildQuota(cfg, "user").String(), Inline: true},
+			{Name: tr(guildID, "Language"), Value: languageName(guildLanguage(guildID)), Inline: true},
+		},
+	}
+}
+
+// setupComponents builds the wizard's selects and buttons. Custom IDs carry
+// the guild ID because DM interactions have none.
+func setupComponents(s *discordgo.Session, guildID string, inDM bool) []discordgo.MessageComponent {
+	cfg := loadGuildConfig(guildID)
+	zero := 0
+
+	channelMenu := discordgo.SelectMenu{
+		MenuType:     discordgo.ChannelSelectMenu,
+		CustomID:     "onboard:channels:" + guildID,
+		Placeholder:  tr(guildID, "Extra channels where I read every message"),
+		MinValues:    &zero,
+		MaxValues:    maxSelectOptions,
+		ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+	}
+	if inDM {
+		channelMenu.MenuType = discordgo.StringSelectMenu
+		channelMenu.Options = textChannelOptions(s, guildID, cfg.MonitoredChannels)
+		channelMenu.MaxValues = len(channelMenu.Options)
+	}
+
+	personaOptions := make([]discordgo.SelectMenuOption, 0, len(personas))
+	for _, p := range personas {
+		personaOptions = append(personaOptions, discordgo.SelectMenuOption{
+			Label:       p.Name,
+			Value:       p.ID,
+			Description: p.Description,
+			Default:     p.ID == cfg.DefaultPersona || (cfg.DefaultPersona == "" && p.ID == defaultPersonaID),
+		})
+	}
+
+	current := cfg.QuotaOverrides["user"]
+	quotaOptions := make([]discordgo.SelectMenuOption, 0, len(quotaPresets))
+	for _, preset := range quotaPresets {
+		quotaOptions = append(quotaOptions, discordgo.SelectMenuOption{
+			Label:   tr(guildID, preset.Label),
+			Value:   preset.ID,
+			Default: preset.Value == current,
+		})
+	}
+
+	lang := guildLanguage(guildID)
+	var languageOptions []discordgo.SelectMenuOption
+	for _, code := range supportedLanguages() {
+		languageOptions = append(languageOptions, discordgo.SelectMenuOption{
+			Label:   languageName(code),
+			Value:   code,
+			Default: code == lang,
+		})
+	}
+
+	rows := []discordgo.MessageComponent{}
+	if len(channelMenu.Options) > 0 || !inDM {
+		rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{channelMenu}})
+	}
+	return append(rows,
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: "onboard:persona:" + guildID, Placeholder: tr(guildID, "Who answers by default"), Options: personaOptions},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: "onboard:quota:" + guildID, Placeholder: tr(guildID, "Rate limit per member"), Options: quotaOptions},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{CustomID: "onboard:language:" + guildID, Placeholder: tr(guildID, "Language"), Options: languageOptions},
+		}},
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: tr(guildID, "Set command prefix"), Style: discordgo.SecondaryButton, CustomID: "onboard:prefix:" + guildID},
+			discordgo.Button{Label: tr(guildID, "Done"), Style: discordgo.SuccessButton, CustomID: "onboard:done:" + guildID},
+		}},
+	)
+}
+
+// textChannelOptions lists up to maxSelectOptions of the guild's text
+// channels, marking the selected ones.
+func textChannelOptions(s *discordgo.Session, guildID string, selected []string) []discordgo.SelectMenuOption {
+	g, err := getGuild(s, guildID)
+	if err != nil {
+		return nil
+	}
+	var options []discordgo.SelectMenuOption
+	for _, c := range g.Channels {
+		if c.Type != discordgo.ChannelTypeGuildText {
+			continue
+		}
+		if len(options) == maxSelectOptions {
+			break
+		}
+		options = append(options, discordgo.SelectMenuOption{
+			Label:   truncateText("#"+c.Name, 100),
+			Value:   c.ID,
+			Default: slices.Contains(selected, c.ID),
+		})
+	}
+	return options
+}
+
+// onboardingComponent handles the wizard's selects, buttons and the prefix
+// modal. Every change is written to the guild config immediately.
+func onboardingComponent(s *discordgo.Session, i *discordgo.InteractionCreate, payload string) {
+	action, guildID, _ := strings.Cut(payload, ":")
+	user := interactionUser(i)
+	if guildID == "" || !isGuildAdminID(s, guildID, user.ID) {
+		respondEphemeral(s, i, tr(guildID, "*shakes head* Only server admins can change my settings."))
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	switch action {
+	case "channels":
+		values := i.MessageComponentData().Values
+		apply = func(cfg *GuildConfig) { cfg.MonitoredChannels = values }
+	case "persona":
+		values := i.MessageComponentData().Values
+		if len(values) == 0 || findPersona(values[0]) == nil {
+			return
+		}
+		id := values[0]
+		if id == defaultPersonaID {
+			id = ""
+		}
+		apply = func(cfg *GuildConfig) { cfg.DefaultPersona = id }
+	case "quota":
+		values := i.MessageComponentData().Values
+		if len(values) == 0 {
+			return
+		}
+		for _, preset := range quotaPresets {
+			if preset.ID != values[0] {
+				continue
+			}
+			value := preset.Value
+			apply = func(cfg *GuildConfig) {
+				if value == "" {
+					delete(cfg.QuotaOverrides, "user")
+					return
+				}
+				if cfg.QuotaOverrides == nil {
+					cfg.QuotaOverrides = map[string]string{}
+				}
+				cfg.QuotaOverrides["user"] = value
+			}
+		}
+	case "language":
+		values := i.MessageComponentData().Values
+		if len(values) == 0 || !isSupportedLanguage(values[0]) {
+			return
+		}
+		lang := values[0]
+		if lang == config().DefaultLanguage {
+			lang = ""
+		}
+		apply = func(cfg *GuildConfig) { cfg.Language = lang }
+	case "prefix":
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: "onboard:setprefix:" + guildID,
+				Title:    tr(guildID, "Command prefix"),
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{CustomID: "prefix", Label: tr(guildID, "Prefix (empty for just !elsie)"), Style: discordgo.TextInputShort, Placeholder: "!bar", MaxLength: maxPrefixLength},
+					}},
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Error opening prefix form for guild %s: %v", guildID, err)
+		}
+		return
+	case "setprefix":
+		prefix := modalValue(i, "prefix")
+		if err := validatePrefix(prefix); err != nil {
+			respondEphemeral(s, i, "⚠️ "+err.Error())
+			return
+		}
+		if prefix == "!elsie" {
+			prefix = ""
+		}
+		apply = func(cfg *GuildConfig) { cfg.Prefix = prefix }
+	case "done":
+		finishOnboarding(s, i, guildID, user.ID)
+		return
+	}
+	if apply == nil {
+		return
+	}
+
+	if err := updateGuildConfig(guildID, user.ID, apply); err != nil {
+		log.Printf("Error saving setup choice for guild %s: %v", guildID, err)
+		respondEphemeral(s, i, themePhrase(guildID, "save_failed", nil))
+		return
+	}
+	log.Printf("👋 Setup wizard: %s set %s in guild %s", logUser("", user.ID), action, guildID)
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{setupEmbed(s, guildID)},
+			Components: setupComponents(s, guildID, i.GuildID == ""),
+		},
+	})
+	if err != nil {
+		log.Printf("Error updating setup wizard for guild %s: %v", guildID, err)
+	}
+}
+
+// finishOnboarding closes the wizard and records who completed it.
+func finishOnboarding(s *discordgo.Session, i *discordgo.InteractionCreate, guildID, userID string) {
+	var record onboardingRecord
+	store.Get(onboardingBucket, guildID, &record)
+	record.CompletedAt = time.Now().UTC()
+	record.CompletedBy = userID
+	if err := store.Put(onboardingBucket, guildID, record); err != nil {
+		log.Printf("Error saving onboarding record for guild %s: %v", guildID, err)
+	}
+	metrics.Inc("guild_onboarding_completed_total")
+
+	embed := setupEmbed(s, guildID)
+	embed.Description = tr(guildID, "All set! Admins can change these any time with `!elsie setup`, and `!elsie help` lists everything else.")
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error closing setup wizard for guild %s: %v", guildID, err)
+	}
+}
+
+// setupCommand is `!elsie setup`: reopen the setup wizard in this channel.
+func setupCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Setup is per server — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change my settings."))
+		return
+	}
+	if err := sendSetupWizard(ctx.s, ctx.m.GuildID, ctx.m.ChannelID, false); err != nil {
+		log.Printf("Error sending setup wizard in guild %s: %v", ctx.m.GuildID, err)
+	}
+}
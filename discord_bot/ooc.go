@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// OOC handling modes for monitored channels.
+const (
+	oocSkip = "skip" // don't forward out-of-character chatter
+	oocTag  = "tag"  // forward it with is_ooc so the agent keeps it out of the scene
+)
+
+// parseOOC reports whether content is out-of-character, either wrapped in
+// ((double parentheses)) or prefixed with "ooc:", and returns the text
+// without the marker.
+func parseOOC(content string) (string, bool) {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "((") && strings.HasSuffix(trimmed, "))") && len(trimmed) >= 4 {
+		return strings.TrimSpace(trimmed[2 : len(trimmed)-2]), true
+	}
+	if len(trimmed) >= 4 && strings.EqualFold(trimmed[:4], "ooc:") {
+		return strings.TrimSpace(trimmed[4:]), true
+	}
+	return content, false
+}
+
+// oocMode returns how the guild handles OOC messages in monitored channels.
+func oocMode(guildID string) string {
+	if mode := loadGuildConfig(guildID).OOCMode; mode != "" {
+		return mode
+	}
+	return oocSkip
+}
+
+func init() {
+	registerCommand(command{name: "ooc", handler: oocCommand})
+}
+
+// oocCommand is `!elsie ooc [skip|tag]`.
+func oocCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("OOC handling is per server — use this command in a server channel.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		ctx.reply("💬 **Out-of-character messages:** " + oocMode(ctx.m.GuildID) +
+			"\nUsage: `!elsie ooc skip` (ignore them) or `!elsie ooc tag` (pass them on marked as OOC)")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change OOC handling.")
+		return
+	}
+	mode := strings.ToLower(ctx.args[0])
+	if mode != oocSkip && mode != oocTag {
+		ctx.reply("Usage: `!elsie ooc skip|tag`")
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.OOCMode = mode }); err != nil {
+		log.Printf("Error saving OOC mode: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if mode == oocSkip {
+		ctx.reply("💬 I'll ignore `((...))` and `ooc:` messages in roleplay channels unless you mention me.")
+		return
+	}
+	ctx.reply("💬 I'll pass `((...))` and `ooc:` messages on marked as out-of-character.")
+}
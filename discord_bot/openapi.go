@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the bot's HTTP contract in the OpenAPI
+// document. Bump the minor for additions and the major for breaking changes,
+// and keep the botapi client in step.
+const apiVersion = "1.0.0"
+
+// apiOperation documents one method on an endpoint. Request and Response
+// are zero values of the JSON body types; the schema is derived from their
+// json tags. A nil Response is described by ResponseType instead.
+type apiOperation struct {
+	Method       string
+	Summary      string
+	Query        []apiParam
+	Request      interface{}
+	Response     interface{}
+	ResponseType string
+}
+
+// apiParam is a query string parameter.
+type apiParam struct {
+	Name        string
+	Type        string // "string" or "integer"
+	Required    bool
+	Description string
+}
+
+func init() {
+	registerEndpoint("openapi", "/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		enc.Encode(openAPIDocument())
+	}), apiOperation{
+		Method:   http.MethodGet,
+		Summary:  "This OpenAPI document",
+		Response: map[string]interface{}{},
+	})
+}
+
+// openAPIDocument describes every registered endpoint, including the auth
+// policy each one is currently configured with.
+func openAPIDocument() map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, ep := range httpEndpoints {
+		if len(ep.ops) == 0 {
+			continue
+		}
+		security := []map[string][]string{}
+		for _, name := range endpointPolicy(ep.name) {
+			if scheme, ok := securitySchemeFor[name]; ok {
+				security = append(security, map[string][]string{scheme: {}})
+			}
+		}
+		item := map[string]interface{}{}
+		for _, op := range ep.ops {
+			item[strings.ToLower(op.Method)] = op.document(ep.name, security)
+		}
+		paths[ep.pattern] = item
+	}
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Elsie Discord bot HTTP API",
+			"version":     apiVersion,
+			"description": "Endpoints served on HTTP_ADDR. Each endpoint has its own auth policy (HTTP_AUTH_<ENDPOINT>).",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Static token from HTTP_TOKEN_<ENDPOINT> or HTTP_TOKEN"},
+				"oidc":  map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT", "description": "Token issued by OIDC_ISSUER"},
+				"mtls":  map[string]interface{}{"type": "mutualTLS", "description": "Client certificate signed by HTTP_CLIENT_CA"},
+			},
+		},
+	}
+}
+
+// securitySchemeFor maps auth policy names to security scheme names; "none"
+// contributes an empty requirement list, i.e. no auth.
+var securitySchemeFor = map[string]string{"token": "token", "oidc": "oidc", "mtls": "mtls"}
+
+func (op apiOperation) document(endpoint string, security []map[string][]string) map[string]interface{} {
+	doc := map[string]interface{}{
+		"operationId": endpoint + op.Method[:1] + strings.ToLower(op.Method[1:]),
+		"summary":     op.Summary,
+		"tags":        []string{endpoint},
+		"security":    security,
+	}
+	if len(op.Query) > 0 {
+		var params []map[string]interface{}
+		for _, p := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          "query",
+				"required":    p.Required,
+				"description": p.Description,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+		doc["parameters"] = params
+	}
+	if op.Request != nil {
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+	content := map[string]interface{}{}
+	if op.Response != nil {
+		content["application/json"] = map[string]interface{}{"schema": jsonSchema(reflect.TypeOf(op.Response))}
+	} else {
+		content[op.ResponseType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	}
+	doc["responses"] = map[string]interface{}{
+		"200": map[string]interface{}{"description": "OK", "content": content},
+		"400": map[string]interface{}{"description": "Invalid request"},
+		"401": map[string]interface{}{"description": "Not authorized by the endpoint's auth policy"},
+	}
+	return doc
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema derives a JSON Schema from a Go type the way encoding/json
+// would serialize it.
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
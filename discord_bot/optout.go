@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// optOutBucket holds the users who asked never to be processed, keyed by
+// user ID. Opt-outs apply everywhere and survive `!elsie forget me`, which
+// would otherwise let the next message back in.
+const optOutBucket = "opt_outs"
+
+// Why a user's messages are left alone.
+const (
+	exclusionOptOut = "opted_out"
+	exclusionBlock  = "blocked"
+)
+
+// optOut records when a user opted out.
+type optOut struct {
+	Time time.Time `json:"time"`
+}
+
+func init() {
+	registerCommand(command{name: "optout", handler: optOutCommand})
+	registerCommand(command{name: "optin", handler: optInCommand})
+	registerCommand(command{name: "block", handler: func(ctx *commandContext) { blockCommand(ctx, true) }})
+	registerCommand(command{name: "unblock", handler: func(ctx *commandContext) { blockCommand(ctx, false) }})
+}
+
+// userExclusion returns why the user's messages must not be processed,
+// sent to the agent or answered in the guild, or "" when they may be.
+func userExclusion(guildID, userID string) string {
+	if ok, err := store.Get(optOutBucket, userID, &optOut{}); err != nil {
+		log.Printf("Error loading the opt-out of %s: %v", logUser("", userID), err)
+	} else if ok {
+		return exclusionOptOut
+	}
+	if guildID != "" && slices.Contains(loadGuildConfig(guildID).BlockedUsers, userID) {
+		return exclusionBlock
+	}
+	return ""
+}
+
+// excludedMessage reports whether a message must be dropped unread because
+// its author opted out or is blocked. Opting back in and `!elsie forget me`
+// are the commands an opted-out user can still run.
+func excludedMessage(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	reason := userExclusion(m.GuildID, m.Author.ID)
+	if reason == "" {
+		return false
+	}
+	if reason == exclusionOptOut {
+		if rest, ok := trimCommandPrefix(m.GuildID, strings.TrimSpace(m.Content)); ok {
+			switch strings.ToLower(strings.Join(strings.Fields(rest), " ")) {
+			case "optin":
+				optInCommand(&commandContext{s: s, m: m, name: "optin"})
+				return true
+			case "forget me":
+				forgetMe(&commandContext{s: s, m: m, name: "forget", args: []string{"me"}, raw: "me"})
+				return true
+			}
+		}
+	}
+	metrics.Inc(metricLabel("excluded_messages_total", "reason", reason))
+	return true
+}
+
+// exclusionNotice tells an excluded user why Elsie won't answer them.
+func exclusionNotice(guildID, reason string) string {
+	if reason == exclusionOptOut {
+		return tr(guildID, "🚫 You opted out, so I'm leaving your requests alone. `!elsie optin` undoes that.")
+	}
+	return tr(guildID, "🚫 The server's admins have asked me not to answer you here.")
+}
+
+// optOutCommand is `!elsie optout`: Elsie stops reading, forwarding and
+// answering the user's messages everywhere.
+func optOutCommand(ctx *commandContext) {
+	if err := store.Put(optOutBucket, ctx.m.Author.ID, optOut{Time: time.Now()}); err != nil {
+		log.Printf("Error saving the opt-out of %s: %v", logUser("", ctx.m.Author.ID), err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	log.Printf("🚫 %s opted out", logUser("", ctx.m.Author.ID))
+	metrics.Inc(metricLabel("opt_outs_total", "change", "out"))
+	ctx.reply(ctx.tr("🚫 Done. I won't read, pass on or answer your messages anywhere from now on. `!elsie optin` undoes this, and `!elsie forget me` erases what I already know about you."))
+}
+
+// optInCommand is `!elsie optin`, undoing `!elsie optout`.
+func optInCommand(ctx *commandContext) {
+	if ok, _ := store.Get(optOutBucket, ctx.m.Author.ID, &optOut{}); !ok {
+		ctx.reply(ctx.tr("You haven't opted out, so there's nothing to undo."))
+		return
+	}
+	if err := store.Delete(optOutBucket, ctx.m.Author.ID); err != nil {
+		log.Printf("Error removing the opt-out of %s: %v", logUser("", ctx.m.Author.ID), err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	log.Printf("✅ %s opted back in", logUser("", ctx.m.Author.ID))
+	metrics.Inc(metricLabel("opt_outs_total", "change", "in"))
+	ctx.reply(ctx.tr("*smiles* Welcome back. I'll answer your messages again."))
+}
+
+// blockCommand is `!elsie block [@user...]` and `!elsie unblock @user...`:
+// messages from blocked members are ignored in the server. Without
+// mentions, block lists who is blocked.
+func blockCommand(ctx *commandContext, block bool) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Blocks are per server — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can block members."))
+		return
+	}
+	if len(ctx.m.Mentions) == 0 {
+		if !block {
+			ctx.reply(ctx.tr("Mention the member, e.g. `!elsie unblock @Ensign`."))
+			return
+		}
+		blocked := loadGuildConfig(ctx.m.GuildID).BlockedUsers
+		if len(blocked) == 0 {
+			ctx.reply(ctx.tr("🚫 Nobody is blocked here. Block a member with `!elsie block @member`."))
+			return
+		}
+		mentions := make([]string, len(blocked))
+		for i, id := range blocked {
+			mentions[i] = "<@" + id + ">"
+		}
+		ctx.replyQuietly(ctx.tr("🚫 Blocked here: %s", strings.Join(mentions, ", ")))
+		return
+	}
+
+	var ids, skipped []string
+	for _, u := range ctx.m.Mentions {
+		if block && (u.ID == ctx.m.Author.ID || u.ID == ctx.s.State.User.ID || isBotOwner(u.ID)) {
+			skipped = append(skipped, "<@"+u.ID+">")
+			continue
+		}
+		ids = append(ids, u.ID)
+	}
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		cfg.BlockedUsers = slices.DeleteFunc(cfg.BlockedUsers, func(id string) bool { return slices.Contains(ids, id) })
+		if block {
+			cfg.BlockedUsers = append(cfg.BlockedUsers, ids...)
+		}
+	})
+	if err != nil {
+		log.Printf("Error saving blocked users: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = "<@" + id + ">"
+	}
+	var msg string
+	switch {
+	case len(ids) == 0:
+	case block:
+		msg = ctx.tr("🚫 I'll ignore messages from %s in this server.", strings.Join(mentions, ", "))
+	default:
+		msg = ctx.tr("✅ I'll answer %s again.", strings.Join(mentions, ", "))
+	}
+	if len(skipped) > 0 {
+		msg = strings.TrimSpace(msg + "\n" + ctx.tr("I can't block %s.", strings.Join(skipped, ", ")))
+	}
+	ctx.replyQuietly(msg)
+}
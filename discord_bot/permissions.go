@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// isBotOwner reports whether userID is one of the operators listed in
+// BOT_OWNER_IDS.
+func isBotOwner(userID string) bool {
+	for _, id := range config().BotOwnerIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// authorPermissions returns the message author's effective permissions in
+// the channel, using gateway state where possible.
+func authorPermissions(s *discordgo.Session, m *discordgo.MessageCreate) int64 {
+	if m.GuildID == "" {
+		return 0
+	}
+	if m.Member != nil {
+		if perms, err := s.State.MessagePermissions(m.Message); err == nil {
+			return perms
+		}
+	}
+	perms, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		log.Printf("Error resolving permissions for %s: %v", logUser("", m.Author.ID), err)
+		return 0
+	}
+	return perms
+}
+
+// isGuildAdmin reports whether the author may change the guild's Elsie
+// configuration: bot owners, and members with Manage Server.
+func isGuildAdmin(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	if isBotOwner(m.Author.ID) {
+		return true
+	}
+	perms := authorPermissions(s, m)
+	return perms&discordgo.PermissionManageServer != 0 || perms&discordgo.PermissionAdministrator != 0
+}
+
+// isGuildAdminID is isGuildAdmin for interactions outside the guild, e.g.
+// buttons in DMs: the guild owner and members whose roles grant Manage
+// Server.
+func isGuildAdminID(s *discordgo.Session, guildID, userID string) bool {
+	if isBotOwner(userID) {
+		return true
+	}
+	guild, err := getGuild(s, guildID)
+	if err != nil {
+		log.Printf("Error resolving guild %s: %v", guildID, err)
+		return false
+	}
+	if guild.OwnerID == userID {
+		return true
+	}
+	member, err := getMember(s, guildID, userID)
+	if err != nil {
+		return false
+	}
+	for _, role := range guild.Roles {
+		if role.ID != guildID && !slices.Contains(member.Roles, role.ID) {
+			continue
+		}
+		if role.Permissions&(discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isModerator reports whether the author can moderate Elsie's output in the
+// channel: guild admins and members with Manage Messages.
+func isModerator(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	if isGuildAdmin(s, m) {
+		return true
+	}
+	return authorPermissions(s, m)&discordgo.PermissionManageMessages != 0
+}
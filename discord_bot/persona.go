@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// persona is a character the bot can speak as. A persona may be served by its
+// own agent backends, so the bartender and ship's computer models stay
+// separate while sharing the Discord, storage and metrics plumbing, and by
+// its own bot account, so it can be mentioned and DMed like any member.
+type persona struct {
+	ID          string
+	Name        string
+	Description string
+	AvatarURL   string // webhook avatar; empty uses the webhook default
+	Prefix      string // explicit invocation, e.g. "!computer"
+	URLEnv      string // comma-separated agent URLs; unset shares AI_AGENT_URL
+	TokenEnv    string // the persona's own bot token; unset posts through a webhook
+}
+
+const defaultPersonaID = "elsie"
+
+var personas = []*persona{
+	{ID: defaultPersonaID, Name: "Elsie", Description: "The holographic bartender"},
+	{ID: "computer", Name: "Ship's Computer", Description: "Terse, factual ship's systems", Prefix: "!computer", URLEnv: "COMPUTER_AGENT_URL", TokenEnv: "COMPUTER_BOT_TOKEN"},
+	{ID: "science", Name: "Science Officer", Description: "Analytical sensor readings and theories", URLEnv: "SCIENCE_AGENT_URL", TokenEnv: "SCIENCE_BOT_TOKEN"},
+	{ID: "tactical", Name: "Tactical Officer", Description: "Combat NPC for tactical scenes", URLEnv: "TACTICAL_AGENT_URL", TokenEnv: "TACTICAL_BOT_TOKEN"},
+}
+
+func findPersona(id string) *persona {
+	for _, p := range personas {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func defaultPersona() *persona {
+	return findPersona(defaultPersonaID)
+}
+
+// channelPersona returns the persona assigned to a channel. Threads without
+// their own assignment inherit their parent's; everything else gets the
+// guild's default persona, normally Elsie.
+func channelPersona(s *discordgo.Session, guildID, channelID string) *persona {
+	if guildID == "" {
+		return defaultPersona()
+	}
+	cfg := loadGuildConfig(guildID)
+	assigned := cfg.ChannelPersonas
+	if p := findPersona(assigned[channelID]); p != nil {
+		return p
+	}
+	if channel, err := getChannel(s, channelID); err == nil && isThreadChannel(channel) {
+		if p := findPersona(assigned[channel.ParentID]); p != nil {
+			return p
+		}
+	}
+	if p := findPersona(cfg.DefaultPersona); p != nil {
+		return p
+	}
+	return defaultPersona()
+}
+
+// matchPersonaPrefix reports whether content invokes a persona explicitly and
+// returns the content with the prefix removed.
+func matchPersonaPrefix(content string) (*persona, string, bool) {
+	for _, p := range personas {
+		if p.Prefix == "" || !strings.HasPrefix(strings.ToLower(content), p.Prefix) {
+			continue
+		}
+		rest := content[len(p.Prefix):]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\n' {
+			continue // "!computers" is not "!computer"
+		}
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			rest = "hello"
+		}
+		return p, rest, true
+	}
+	return nil, content, false
+}
+
+// sessionID keeps each persona's conversation memory separate in channels
+// where more than one persona speaks, and each topic's in a DM.
+func (p *persona) sessionID(channelID string) string {
+	return p.sessionIDFor(dmTopicSessionID(channelID, activeDMTopic(channelID)))
+}
+
+// sessionIDFor is the persona's session on top of a channel or DM topic
+// session.
+func (p *persona) sessionIDFor(base string) string {
+	if p == nil || p.ID == defaultPersonaID {
+		return base
+	}
+	return base + ":" + p.ID
+}
+
+func init() {
+	registerCommand(command{name: "persona", handler: personaCommand})
+}
+
+// personaCommand is `!elsie persona [list|set <persona> [#channel]|clear [#channel]]`.
+// Without a channel the current channel or thread is changed.
+func personaCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie persona list`, `!elsie persona set <persona> [#channel]`, `!elsie persona clear [#channel]`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Personas are assigned per channel — use this command in a server channel.")
+		return
+	}
+
+	sub := "list"
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	if sub == "list" {
+		current := channelPersona(ctx.s, ctx.m.GuildID, ctx.m.ChannelID)
+		var b strings.Builder
+		b.WriteString("🎭 **Personas**\n")
+		for _, p := range personas {
+			marker := "•"
+			if p == current {
+				marker = "▶️"
+			}
+			fmt.Fprintf(&b, "%s `%s` — %s: %s\n", marker, p.ID, p.Name, p.Description)
+		}
+		b.WriteString(usage)
+		ctx.reply(b.String())
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change who answers in a channel.")
+		return
+	}
+
+	var p *persona
+	rest := ctx.args[1:]
+	switch sub {
+	case "set":
+		if len(rest) == 0 {
+			ctx.reply(usage)
+			return
+		}
+		if p = findPersona(strings.ToLower(rest[0])); p == nil {
+			ctx.reply(fmt.Sprintf("*checks the holodeck library* I don't have a persona called `%s`. Try `!elsie persona list`.", rest[0]))
+			return
+		}
+		rest = rest[1:]
+	case "clear":
+	default:
+		ctx.reply(usage)
+		return
+	}
+	channelID := ctx.m.ChannelID
+	if len(rest) > 0 {
+		if channelID = parseChannelMention(rest[0]); channelID == "" {
+			ctx.reply("Mention the channel, e.g. `!elsie persona set science #science-lab`.")
+			return
+		}
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		if p == nil || p.ID == defaultPersonaID {
+			delete(cfg.ChannelPersonas, channelID)
+			return
+		}
+		if cfg.ChannelPersonas == nil {
+			cfg.ChannelPersonas = make(map[string]string)
+		}
+		cfg.ChannelPersonas[channelID] = p.ID
+	})
+	if err != nil {
+		log.Printf("Error saving channel persona: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if p == nil {
+		p = defaultPersona()
+	}
+	ctx.reply(fmt.Sprintf("🎭 <#%s> is now served by **%s**.", channelID, p.Name))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// personaBot is a persona running under its own bot account. Guild
+// messages, commands and schedulers all stay with Elsie's session; the
+// persona's session only answers its DMs and posts the persona's replies.
+type personaBot struct {
+	persona *persona
+	session *discordgo.Session
+	userID  string // set once the session is ready
+}
+
+var (
+	// personaBotsMu guards personaBots and the bots' user IDs.
+	personaBotsMu sync.RWMutex
+	personaBots   = map[string]*personaBot{}
+)
+
+// startPersonaBots logs in every persona with a token in its TokenEnv.
+// Tokens are read once; a persona whose bot fails to connect falls back to
+// posting through webhooks.
+func startPersonaBots() {
+	for _, p := range personas {
+		if p.TokenEnv == "" || p.ID == defaultPersonaID {
+			continue
+		}
+		token := os.Getenv(p.TokenEnv)
+		if token == "" {
+			continue
+		}
+		s, err := discordgo.New("Bot " + token)
+		if err != nil {
+			log.Printf("Error creating the %s bot session: %v", p.Name, err)
+			continue
+		}
+		bot := &personaBot{persona: p, session: s}
+		s.AddHandler(recovered("personaBotReady", bot.ready))
+		s.AddHandler(recovered("messageCreate", messageCreate))
+		s.Identify.Intents = discordgo.IntentsGuilds |
+			discordgo.IntentsGuildMessages |
+			discordgo.IntentsDirectMessages |
+			discordgo.IntentsMessageContent
+		if err := s.Open(); err != nil {
+			log.Printf("Error connecting the %s bot, posting through webhooks instead: %v", p.Name, err)
+			continue
+		}
+		personaBotsMu.Lock()
+		personaBots[p.ID] = bot
+		personaBotsMu.Unlock()
+		metrics.Inc(metricLabel("persona_bots_started_total", "persona", p.ID))
+	}
+}
+
+func (b *personaBot) ready(s *discordgo.Session, event *discordgo.Ready) {
+	personaBotsMu.Lock()
+	b.userID = event.User.ID
+	personaBotsMu.Unlock()
+	if err := s.UpdateGameStatus(0, b.persona.Description); err != nil {
+		log.Printf("Error setting the %s bot's status: %v", b.persona.Name, err)
+	}
+	log.Printf("🤖 %s logged in as %v#%v", b.persona.Name, event.User.Username, event.User.Discriminator)
+}
+
+// stopPersonaBots closes the persona bots' sessions on shutdown.
+func stopPersonaBots() {
+	personaBotsMu.Lock()
+	defer personaBotsMu.Unlock()
+	for id, bot := range personaBots {
+		bot.session.Close()
+		delete(personaBots, id)
+	}
+}
+
+// personaBotSession returns the session of the persona's own bot, or nil
+// when it has none.
+func personaBotSession(p *persona) *discordgo.Session {
+	if p == nil {
+		return nil
+	}
+	personaBotsMu.RLock()
+	defer personaBotsMu.RUnlock()
+	if bot, ok := personaBots[p.ID]; ok {
+		return bot.session
+	}
+	return nil
+}
+
+// sessionPersona returns the persona whose bot s is, or nil for Elsie's
+// own session.
+func sessionPersona(s *discordgo.Session) *persona {
+	personaBotsMu.RLock()
+	defer personaBotsMu.RUnlock()
+	for _, bot := range personaBots {
+		if bot.session == s {
+			return bot.persona
+		}
+	}
+	return nil
+}
+
+// personaBotUser returns the persona whose bot account has userID, or nil.
+func personaBotUser(userID string) *persona {
+	personaBotsMu.RLock()
+	defer personaBotsMu.RUnlock()
+	for _, bot := range personaBots {
+		if bot.userID != "" && bot.userID == userID {
+			return bot.persona
+		}
+	}
+	return nil
+}
+
+// speakingSession is the session a persona speaks through: its own bot's,
+// if it has one, otherwise s.
+func speakingSession(s *discordgo.Session, p *persona) *discordgo.Session {
+	if bot := personaBotSession(p); bot != nil {
+		return bot
+	}
+	return s
+}
+
+// isPersonaTokenEnv reports whether name is a persona bot token setting.
+func isPersonaTokenEnv(name string) bool {
+	for _, p := range personas {
+		if p.TokenEnv != "" && p.TokenEnv == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// thinkingPlaceholder is an in-character "one moment" message posted when
+// the agent is slow, which the real reply then replaces.
+type thinkingPlaceholder struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+	msg     *discordgo.Message
+}
+
+// startThinkingPlaceholder posts a placeholder in the channel if the agent
+// hasn't answered within THINKING_PLACEHOLDER_AFTER. Only Elsie's own
+// replies get one; persona webhook posts can't be edited in threads.
+func startThinkingPlaceholder(s *discordgo.Session, guildID, channelID string, p *persona) *thinkingPlaceholder {
+	t := &thinkingPlaceholder{}
+	if config().ThinkingPlaceholderAfter <= 0 || (p != nil && p.ID != defaultPersonaID) {
+		return t
+	}
+	t.timer = time.AfterFunc(config().ThinkingPlaceholderAfter, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.stopped {
+			return
+		}
+		msg, err := s.ChannelMessageSend(channelID, themePhrase(guildID, "thinking", nil))
+		if err != nil {
+			log.Printf("Error posting thinking placeholder in %s: %v", channelID, err)
+			return
+		}
+		t.msg = msg
+		metrics.Inc("thinking_placeholders_total")
+	})
+	return t
+}
+
+// stop cancels a placeholder that hasn't been posted yet, and reports
+// whether one was.
+func (t *thinkingPlaceholder) stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	return t.msg != nil
+}
+
+// discard deletes a posted placeholder, for when there's no reply to put in
+// it.
+func (t *thinkingPlaceholder) discard(s *discordgo.Session) {
+	if !t.stop() {
+		return
+	}
+	if err := s.ChannelMessageDelete(t.msg.ChannelID, t.msg.ID); err != nil {
+		log.Printf("Error deleting thinking placeholder in %s: %v", t.msg.ChannelID, err)
+	}
+}
+
+// deliver sends a reply like sendReply, but edits its first part into the
+// placeholder when one was posted.
+func (t *thinkingPlaceholder) deliver(s *discordgo.Session, channelID string, p *persona, text string, images []responseImage) ([]*discordgo.Message, error) {
+	if !t.stop() {
+		return sendReply(s, channelID, p, text, images)
+	}
+	chunks := messageChunks(postProcess(s, channelID, text))
+	if len(chunks) == 0 {
+		t.discard(s)
+		return sendReply(s, channelID, p, "", images)
+	}
+	first, err := s.ChannelMessageEdit(channelID, t.msg.ID, chunks[0])
+	if err != nil {
+		log.Printf("Error editing thinking placeholder in %s, sending the reply instead: %v", channelID, err)
+		t.discard(s)
+		return sendReply(s, channelID, p, text, images)
+	}
+	sent := []*discordgo.Message{first}
+	for _, chunk := range chunks[1:] {
+		send := &discordgo.MessageSend{Content: chunk}
+		if config().ReplyChainChunks {
+			send.Reference, send.AllowedMentions = first.Reference(), chunkReplyMentions
+		}
+		msg, err := s.ChannelMessageSendComplex(channelID, send)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	if len(images) > 0 {
+		msg, err := sendImagesAs(s, channelID, p, images)
+		if err != nil {
+			log.Printf("Error sending response images in %s: %v", channelID, err)
+			return sent, nil
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}
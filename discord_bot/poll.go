@@ -0,0 +1,472 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const pollBucket = "polls"
+
+// Poll is a reaction poll, persisted so it closes on time across restarts.
+// Discord's native polls need a newer API than the bot speaks, so members
+// vote by reacting with an option's number.
+type Poll struct {
+	ID        string    `json:"id"`
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Question  string    `json:"question"`
+	Options   []string  `json:"options"`
+	Quiet     bool      `json:"quiet,omitempty"`
+	Created   time.Time `json:"created"`
+	Closes    time.Time `json:"closes"`
+}
+
+// pollEmoji are the reactions for each option, in order.
+var pollEmoji = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+var (
+	// pollMu guards the poll bucket between posting and closing.
+	pollMu sync.Mutex
+
+	pollSchedulerOnce sync.Once
+)
+
+func init() {
+	registerCommand(command{name: "poll", handler: pollCommand})
+	registerDataEraser(dataEraser{name: "polls", user: erasePolls("user"), guild: erasePolls("guild")})
+	dmPermission := false
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:         "poll",
+		Description:  "Ask the channel a question and let members vote with reactions",
+		DMPermission: &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "question",
+				Description: "What to ask, e.g. Next mission?",
+				Required:    true,
+				MaxLength:   256,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "options",
+				Description: "Two to ten options separated by |, e.g. Away team | Stay aboard",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "duration",
+				Description: "How long the poll stays open, e.g. 2h or 3d",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "quiet",
+				Description: "Close without Elsie announcing the winner",
+			},
+		},
+	}, pollSlash)
+}
+
+// splitQuoted splits s on whitespace, keeping "quoted phrases" (straight or
+// curly quotes) together.
+func splitQuoted(s string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted, started := false, false
+	flush := func() {
+		if started {
+			out = append(out, cur.String())
+		}
+		cur.Reset()
+		started = false
+	}
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			if quoted {
+				quoted = false
+				started = true
+				flush()
+			} else {
+				flush()
+				quoted = true
+			}
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	flush()
+	return out
+}
+
+// parsePollArgs reads `"question" option option ... [--for 2h] [--quiet]`.
+// Without quotes, the question runs up to its question mark.
+func parsePollArgs(raw string) (question string, options []string, duration time.Duration, quiet bool, ok bool) {
+	duration = config().PollDefaultDuration
+	words := splitQuoted(raw)
+	var rest []string
+	for i := 0; i < len(words); i++ {
+		switch strings.ToLower(words[i]) {
+		case "--quiet":
+			quiet = true
+		case "--for":
+			if i+1 >= len(words) {
+				return "", nil, 0, false, false
+			}
+			d, used := parseReminderDelay(words[i+1:])
+			if used == 0 {
+				return "", nil, 0, false, false
+			}
+			duration = d
+			i += used
+		default:
+			rest = append(rest, words[i])
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(raw), `"`) || strings.HasPrefix(strings.TrimSpace(raw), "“") {
+		if len(rest) == 0 {
+			return "", nil, 0, false, false
+		}
+		return rest[0], rest[1:], duration, quiet, true
+	}
+	// An unquoted question runs up to the first word with a question mark
+	for i, w := range rest {
+		if strings.HasSuffix(w, "?") {
+			return strings.Join(rest[:i+1], " "), rest[i+1:], duration, quiet, true
+		}
+	}
+	return "", nil, 0, false, false
+}
+
+// createPoll posts a poll and starts tracking it. The returned string is
+// the reply for whoever asked.
+func createPoll(s *discordgo.Session, guildID, channelID, authorID, question string, options []string, duration time.Duration, quiet bool) string {
+	for i := range options {
+		options[i] = truncateText(strings.TrimSpace(options[i]), 100)
+	}
+	options = removeEmpty(options)
+	switch {
+	case len(options) < 2 || len(options) > len(pollEmoji):
+		return tr(guildID, "📊 A poll needs between 2 and %d options.", len(pollEmoji))
+	case duration < time.Minute || duration > config().PollMaxDuration:
+		return tr(guildID, "📊 Polls can stay open from one minute up to %d days.", int(config().PollMaxDuration.Hours()/24))
+	}
+
+	now := time.Now()
+	p := Poll{
+		ID:        newEventID(),
+		GuildID:   guildID,
+		ChannelID: channelID,
+		AuthorID:  authorID,
+		Question:  truncateText(question, 256),
+		Options:   options,
+		Quiet:     quiet,
+		Created:   now,
+		Closes:    now.Add(duration),
+	}
+	msg, err := s.ChannelMessageSendEmbed(channelID, pollEmbed(p, nil))
+	if err != nil {
+		log.Printf("Error posting poll in %s: %v", channelID, err)
+		return tr(guildID, "*Elsie frowns at the display* I couldn't post the poll here. Do I have permission to send embeds?")
+	}
+	p.MessageID = msg.ID
+	for i := range p.Options {
+		if err := s.MessageReactionAdd(channelID, msg.ID, pollEmoji[i]); err != nil {
+			log.Printf("Error adding poll option %d to %s: %v", i+1, msg.ID, err)
+		}
+	}
+	pollMu.Lock()
+	err = store.Put(pollBucket, p.ID, p)
+	pollMu.Unlock()
+	if err != nil {
+		log.Printf("Error saving poll: %v", err)
+		return themePhrase(guildID, "save_failed", nil)
+	}
+	metrics.Inc("polls_created_total")
+	return tr(guildID, "📊 Poll `%s` is open until <t:%d:f>.", p.ID, p.Closes.Unix())
+}
+
+func removeEmpty(items []string) []string {
+	out := items[:0]
+	for _, item := range items {
+		if item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// pollEmbed shows a poll, with the tally once it has closed.
+func pollEmbed(p Poll, votes []int) *discordgo.MessageEmbed {
+	var b strings.Builder
+	for i, option := range p.Options {
+		fmt.Fprintf(&b, "%s %s", pollEmoji[i], option)
+		if votes != nil {
+			fmt.Fprintf(&b, " — **%d**", votes[i])
+		}
+		b.WriteString("\n")
+	}
+	footer := tr(p.GuildID, "React to vote · closes")
+	color := themeColor(p.GuildID, "info")
+	if votes != nil {
+		footer = tr(p.GuildID, "Poll closed")
+		color = themeColor(p.GuildID, "highlight")
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "📊 " + p.Question,
+		Description: b.String(),
+		Color:       color,
+		Footer:      &discordgo.MessageEmbedFooter{Text: p.ID + " · " + footer},
+		Timestamp:   p.Closes.UTC().Format(time.RFC3339),
+	}
+}
+
+// tallyPoll counts each option's votes from the poll message's reactions,
+// leaving out Elsie's own.
+func tallyPoll(s *discordgo.Session, p Poll) ([]int, error) {
+	msg, err := s.ChannelMessage(p.ChannelID, p.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	votes := make([]int, len(p.Options))
+	for _, r := range msg.Reactions {
+		for i := range p.Options {
+			if r.Emoji != nil && r.Emoji.Name == pollEmoji[i] {
+				votes[i] = r.Count
+				if r.Me {
+					votes[i]--
+				}
+			}
+		}
+	}
+	return votes, nil
+}
+
+// startPollScheduler starts the poll closing loop once.
+func startPollScheduler(s *discordgo.Session) {
+	pollSchedulerOnce.Do(func() { go runPollScheduler(s) })
+}
+
+// runPollScheduler closes polls as they come due, including any that came
+// due while the bot was down.
+func runPollScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		var due []Poll
+		pollMu.Lock()
+		for _, p := range loadPolls() {
+			if time.Now().Before(p.Closes) {
+				continue
+			}
+			// Removed before closing, like reminders, so a failure doesn't
+			// announce the same winner on every tick
+			if err := store.Delete(pollBucket, p.ID); err != nil {
+				log.Printf("Error removing poll %s: %v", p.ID, err)
+				continue
+			}
+			due = append(due, p)
+		}
+		pollMu.Unlock()
+		for _, p := range due {
+			closePoll(s, p)
+		}
+	}
+}
+
+func loadPolls() []Poll {
+	var out []Poll
+	for _, key := range store.Keys(pollBucket) {
+		var p Poll
+		if ok, err := store.Get(pollBucket, key, &p); err != nil || !ok {
+			continue
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Closes.Before(out[j].Closes) })
+	return out
+}
+
+// closePoll tallies a poll, shows the results on it and, unless it is
+// quiet, has Elsie announce the winner.
+func closePoll(s *discordgo.Session, p Poll) {
+	votes, err := tallyPoll(s, p)
+	if err != nil {
+		log.Printf("Error tallying poll %s, it may have been deleted: %v", p.ID, err)
+		return
+	}
+	if _, err := s.ChannelMessageEditEmbed(p.ChannelID, p.MessageID, pollEmbed(p, votes)); err != nil {
+		log.Printf("Error showing results of poll %s: %v", p.ID, err)
+	}
+	metrics.Inc("polls_closed_total")
+	log.Printf("📊 Poll %s closed with votes %v", p.ID, votes)
+	if p.Quiet {
+		return
+	}
+
+	top, total := 0, 0
+	for _, v := range votes {
+		total += v
+		top = max(top, v)
+	}
+	var winners []string
+	for i, v := range votes {
+		if v == top {
+			winners = append(winners, "**"+p.Options[i]+"**")
+		}
+	}
+	data := map[string]interface{}{"Question": p.Question, "Votes": top, "Total": total}
+	var text string
+	switch {
+	case total == 0:
+		text = themePhrase(p.GuildID, "poll_no_votes", data)
+	case len(winners) > 1:
+		data["Winner"] = strings.Join(winners, tr(p.GuildID, " and "))
+		text = themePhrase(p.GuildID, "poll_tie", data)
+	default:
+		data["Winner"] = winners[0]
+		text = themePhrase(p.GuildID, "poll_winner", data)
+	}
+	_, err = s.ChannelMessageSendComplex(p.ChannelID, &discordgo.MessageSend{
+		Content:         text,
+		Reference:       &discordgo.MessageReference{MessageID: p.MessageID, ChannelID: p.ChannelID, GuildID: p.GuildID},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error announcing poll %s: %v", p.ID, err)
+	}
+}
+
+func erasePolls(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		pollMu.Lock()
+		defer pollMu.Unlock()
+		n := 0
+		for _, p := range loadPolls() {
+			var err error
+			switch {
+			case scope == "guild" && p.GuildID == id:
+				err = store.Delete(pollBucket, p.ID)
+			case scope == "user" && p.AuthorID == id:
+				// The poll belongs to the channel too, so it still closes
+				p.AuthorID = ""
+				err = store.Put(pollBucket, p.ID, p)
+			default:
+				continue
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+// pollCommand handles polls:
+//
+//	!elsie poll "Next mission?" "Away team" "Stay aboard" --for 2h
+//	!elsie poll close <id>
+//	!elsie poll list
+func pollCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie poll \"Next mission?\" \"Away team\" \"Stay aboard\" [--for 2h] [--quiet]`, `!elsie poll list`, `!elsie poll close <id>`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Polls are for server channels — ask your question there."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		ctx.reply(usage)
+		return
+	}
+	switch strings.ToLower(ctx.args[0]) {
+	case "list":
+		var b strings.Builder
+		for _, p := range loadPolls() {
+			if p.GuildID == ctx.m.GuildID {
+				fmt.Fprintf(&b, "`%s` <#%s> %s — <t:%d:R>\n", p.ID, p.ChannelID, truncateText(p.Question, 80), p.Closes.Unix())
+			}
+		}
+		if b.Len() == 0 {
+			ctx.reply(ctx.tr("📊 There are no open polls in this server."))
+			return
+		}
+		ctx.reply(ctx.tr("📊 **Open polls**") + "\n" + b.String())
+		return
+	case "close", "end":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		id := strings.ToLower(ctx.args[1])
+		pollMu.Lock()
+		var p Poll
+		found, err := store.Get(pollBucket, id, &p)
+		allowed := found && p.GuildID == ctx.m.GuildID && (p.AuthorID == ctx.m.Author.ID || isGuildAdmin(ctx.s, ctx.m))
+		if err == nil && allowed {
+			err = store.Delete(pollBucket, id)
+		}
+		pollMu.Unlock()
+		switch {
+		case err != nil:
+			log.Printf("Error closing poll %s: %v", id, err)
+			ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		case !found || p.GuildID != ctx.m.GuildID:
+			ctx.reply(ctx.tr("*checks the board* There's no open poll `%s` here.", id))
+		case !allowed:
+			ctx.reply(ctx.tr("*shakes head* Only whoever started the poll, or a server admin, can close it early."))
+		default:
+			closePoll(ctx.s, p)
+		}
+		return
+	}
+
+	question, options, duration, quiet, ok := parsePollArgs(ctx.raw)
+	if !ok {
+		ctx.reply(usage)
+		return
+	}
+	if reply := createPoll(ctx.s, ctx.m.GuildID, ctx.m.ChannelID, ctx.m.Author.ID, question, options, duration, quiet); reply != "" {
+		ctx.reply(reply)
+	}
+}
+
+// pollSlash is /poll.
+func pollSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "Polls are for server channels — ask your question there.")
+		return
+	}
+	var question, options string
+	var quiet bool
+	duration := config().PollDefaultDuration
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "question":
+			question = opt.StringValue()
+		case "options":
+			options = opt.StringValue()
+		case "quiet":
+			quiet = opt.BoolValue()
+		case "duration":
+			d, used := parseReminderDelay(strings.Fields(opt.StringValue()))
+			if used == 0 {
+				respondEphemeral(s, i, tr(i.GuildID, "*tilts head* I didn't catch how long. Try `2h`, `90 minutes` or `3d`."))
+				return
+			}
+			duration = d
+		}
+	}
+	respondEphemeral(s, i, createPoll(s, i.GuildID, i.ChannelID, interactionUser(i).ID, question, strings.Split(options, "|"), duration, quiet))
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Replacement is a guild's literal string substitution in responses.
+type Replacement struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// maxReplacements caps a guild's replacement list.
+const maxReplacements = 50
+
+// postProcessor is one pluggable stage applied to every response before it
+// is sent. Stages run in registration order; each gets the previous
+// stage's output.
+type postProcessor interface {
+	Name() string
+	Process(s *discordgo.Session, guildID, text string) string
+}
+
+var postProcessors []postProcessor
+
+// registerPostProcessor appends a stage to the pipeline.
+func registerPostProcessor(p postProcessor) {
+	postProcessors = append(postProcessors, p)
+}
+
+// postProcessFunc adapts a function to postProcessor.
+type postProcessFunc struct {
+	name string
+	fn   func(s *discordgo.Session, guildID, text string) string
+}
+
+func (p postProcessFunc) Name() string { return p.name }
+
+func (p postProcessFunc) Process(s *discordgo.Session, guildID, text string) string {
+	return p.fn(s, guildID, text)
+}
+
+func init() {
+	registerPostProcessor(postProcessFunc{"replacements", applyReplacements})
+	registerPostProcessor(postProcessFunc{"emoji", substituteEmoji})
+	registerPostProcessor(postProcessFunc{"sanitize", sanitizeMarkdown})
+	registerPostProcessor(postProcessFunc{"actions", formatActions})
+	registerPostProcessor(postProcessFunc{"escape", escapeForDiscord})
+	registerPostProcessor(postProcessFunc{"trim", trimResponse})
+	registerCommand(command{name: "postprocess", handler: postProcessCommand})
+}
+
+// postProcessEnabled reports whether a stage runs in a guild: it must be
+// enabled by the operator and not turned off by the guild.
+func postProcessEnabled(cfg *GuildConfig, name string) bool {
+	if len(config().PostProcessors) > 0 && !slices.Contains(config().PostProcessors, name) {
+		return false
+	}
+	return !slices.Contains(cfg.PostProcessOff, name)
+}
+
+// postProcess runs text through the pipeline for the channel's guild.
+func postProcess(s *discordgo.Session, channelID, text string) string {
+	guildID := ""
+	if channel, err := getChannel(s, channelID); err == nil {
+		guildID = channel.GuildID
+	}
+	cfg := loadGuildConfig(guildID)
+	for _, p := range postProcessors {
+		if !postProcessEnabled(cfg, p.Name()) {
+			continue
+		}
+		if out := p.Process(s, guildID, text); out != text {
+			metrics.Inc(metricLabel("postprocess_changes_total", "stage", p.Name()))
+			text = out
+		}
+	}
+	return text
+}
+
+// applyReplacements applies the guild's literal substitutions in order.
+func applyReplacements(s *discordgo.Session, guildID, text string) string {
+	for _, r := range loadGuildConfig(guildID).Replacements {
+		text = strings.ReplaceAll(text, r.From, r.To)
+	}
+	return text
+}
+
+// emojiShortcodes are the :shortcodes: agents tend to write, mapped to the
+// Unicode emoji Discord would otherwise show as plain text from a webhook.
+var emojiShortcodes = map[string]string{
+	"beer": "🍺", "beers": "🍻", "cocktail": "🍸", "tropical_drink": "🍹",
+	"wine_glass": "🍷", "tumbler_glass": "🥃", "champagne": "🍾", "sake": "🍶",
+	"tea": "🍵", "coffee": "☕", "smile": "😄", "wink": "😉", "wave": "👋",
+	"thumbsup": "👍", "+1": "👍", "heart": "❤️", "star": "⭐", "sparkles": "✨",
+	"rocket": "🚀", "vulcan": "🖖", "vulcan_salute": "🖖", "fire": "🔥",
+	"tada": "🎉", "thinking": "🤔", "laughing": "😆", "joy": "😂",
+}
+
+var shortcodePattern = regexp.MustCompile(`(<a?)?:([a-zA-Z0-9_+]{2,32}):(\d+>)?`)
+
+// substituteEmoji turns :shortcodes: into the guild's custom emoji of that
+// name, or the matching Unicode emoji. Already-rendered custom emoji
+// (<:name:id>) are left alone.
+func substituteEmoji(s *discordgo.Session, guildID, text string) string {
+	var custom map[string]*discordgo.Emoji
+	if guildID != "" {
+		if g, err := getGuild(s, guildID); err == nil {
+			custom = make(map[string]*discordgo.Emoji, len(g.Emojis))
+			for _, e := range g.Emojis {
+				custom[strings.ToLower(e.Name)] = e
+			}
+		}
+	}
+	return shortcodePattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := shortcodePattern.FindStringSubmatch(match)
+		if parts[1] != "" || parts[3] != "" {
+			return match
+		}
+		name := strings.ToLower(parts[2])
+		if e, ok := custom[name]; ok && e.Available {
+			return e.MessageFormat()
+		}
+		if u, ok := emojiShortcodes[name]; ok {
+			return u
+		}
+		return match
+	})
+}
+
+var (
+	htmlBreak  = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlBold   = regexp.MustCompile(`(?i)</?(b|strong)>`)
+	htmlItalic = regexp.MustCompile(`(?i)</?(i|em)>`)
+	// Mentions, custom emoji, timestamps and <links> never match: their
+	// first word is followed by ":" or isn't a letter.
+	htmlTag        = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9]*(\s[^<>]*)?>`)
+	extraBlankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// sanitizeMarkdown converts the HTML some models emit into Discord
+// markdown, drops other tags and squeezes runs of blank lines. Code blocks
+// are left untouched.
+func sanitizeMarkdown(s *discordgo.Session, guildID, text string) string {
+	parts := strings.Split(text, "```")
+	for i := 0; i < len(parts); i += 2 {
+		p := strings.ReplaceAll(parts[i], "\r\n", "\n")
+		p = htmlBreak.ReplaceAllString(p, "\n")
+		p = htmlBold.ReplaceAllString(p, "**")
+		p = htmlItalic.ReplaceAllString(p, "*")
+		p = htmlTag.ReplaceAllString(p, "")
+		parts[i] = extraBlankRuns.ReplaceAllString(p, "\n\n")
+	}
+	return strings.TrimSpace(strings.Join(parts, "```"))
+}
+
+var massMention = regexp.MustCompile(`@(everyone|here)`)
+
+// escapeForDiscord defuses @everyone and @here so an agent response can
+// never ping a whole server.
+func escapeForDiscord(s *discordgo.Session, guildID, text string) string {
+	return massMention.ReplaceAllString(text, "@\u200b$1")
+}
+
+// trimResponse cuts responses longer than RESPONSE_MAX_LENGTH at the last
+// sentence end that fits.
+func trimResponse(s *discordgo.Session, guildID, text string) string {
+	if config().ResponseMaxLength <= 0 || len([]rune(text)) <= config().ResponseMaxLength {
+		return text
+	}
+	cut := string([]rune(text)[:config().ResponseMaxLength])
+	if i := strings.LastIndexAny(cut, ".!?"); i > len(cut)/2 {
+		return cut[:i+1]
+	}
+	return truncateText(text, config().ResponseMaxLength)
+}
+
+// postProcessCommand is `!elsie postprocess [on|off <stage>|actions <italic|brackets>|replace add <from> => <to>|replace remove <from>|replace clear]`.
+func postProcessCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie postprocess`, `!elsie postprocess on|off <stage>`, `!elsie postprocess actions italic|brackets`, `!elsie postprocess replace add <from> => <to>`, `!elsie postprocess replace remove <from>`, `!elsie postprocess replace clear`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Response settings are per server — use this command in a server channel.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		var b strings.Builder
+		b.WriteString("🪄 **Response post-processing**\n")
+		for _, p := range postProcessors {
+			state := "on"
+			if !postProcessEnabled(cfg, p.Name()) {
+				state = "off"
+			}
+			fmt.Fprintf(&b, "• `%s` — %s\n", p.Name(), state)
+		}
+		fmt.Fprintf(&b, "**Actions** are written %s\n", renderAction("like this", actionStyle(ctx.m.GuildID)))
+		if len(cfg.Replacements) > 0 {
+			b.WriteString("**Replacements**\n")
+			for _, r := range cfg.Replacements {
+				fmt.Fprintf(&b, "• `%s` → `%s`\n", r.From, r.To)
+			}
+		}
+		b.WriteString(usage)
+		ctx.reply(b.String())
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change how my responses are processed.")
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	var confirmation string
+	switch sub := strings.ToLower(ctx.args[0]); sub {
+	case "on", "off":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		name := strings.ToLower(ctx.args[1])
+		if !slices.ContainsFunc(postProcessors, func(p postProcessor) bool { return p.Name() == name }) {
+			ctx.reply(fmt.Sprintf("I don't have a `%s` stage. `!elsie postprocess` lists them.", name))
+			return
+		}
+		apply = func(cfg *GuildConfig) {
+			cfg.PostProcessOff = slices.DeleteFunc(cfg.PostProcessOff, func(n string) bool { return n == name })
+			if sub == "off" {
+				cfg.PostProcessOff = append(cfg.PostProcessOff, name)
+			}
+		}
+		confirmation = fmt.Sprintf("🪄 The `%s` stage is **%s** for this server.", name, sub)
+	case "actions":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		style := strings.ToLower(ctx.args[1])
+		if style == "bracket" {
+			style = actionStyleBrackets
+		}
+		if style != actionStyleItalic && style != actionStyleBrackets {
+			ctx.reply(usage)
+			return
+		}
+		apply = func(cfg *GuildConfig) {
+			cfg.ActionStyle = style
+			if style == actionStyleItalic {
+				cfg.ActionStyle = ""
+			}
+		}
+		confirmation = fmt.Sprintf("🪄 I'll write actions %s.", renderAction("like this", style))
+	case "replace":
+		rest := strings.TrimSpace(strings.TrimPrefix(ctx.raw, ctx.args[0]))
+		action, arg, _ := strings.Cut(rest, " ")
+		arg = strings.TrimSpace(arg)
+		switch strings.ToLower(action) {
+		case "add":
+			from, to, ok := strings.Cut(arg, "=>")
+			from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+			if !ok || from == "" {
+				ctx.reply("Usage: `!elsie postprocess replace add <from> => <to>`")
+				return
+			}
+			if len(loadGuildConfig(ctx.m.GuildID).Replacements) >= maxReplacements {
+				ctx.reply(fmt.Sprintf("🪄 That's the limit of %d replacements. Remove one first.", maxReplacements))
+				return
+			}
+			apply = func(cfg *GuildConfig) {
+				cfg.Replacements = slices.DeleteFunc(cfg.Replacements, func(r Replacement) bool { return r.From == from })
+				cfg.Replacements = append(cfg.Replacements, Replacement{From: from, To: to})
+			}
+			confirmation = fmt.Sprintf("🪄 I'll write `%s` as `%s`.", from, to)
+		case "remove":
+			apply = func(cfg *GuildConfig) {
+				cfg.Replacements = slices.DeleteFunc(cfg.Replacements, func(r Replacement) bool { return r.From == arg })
+			}
+			confirmation = fmt.Sprintf("🪄 Removed the replacement for `%s`.", arg)
+		case "clear":
+			apply = func(cfg *GuildConfig) { cfg.Replacements = nil }
+			confirmation = "🪄 Cleared all replacements."
+		default:
+			ctx.reply(usage)
+			return
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving post-processing config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(confirmation)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Values of GuildConfig.PrefixMode.
+const (
+	prefixModeBoth = ""
+	prefixModeOnly = "only"
+	prefixModeOff  = "off"
+)
+
+// guildPrefixes caches each guild's resolved prefixes so the message
+// handler doesn't load the guild config for every message. Config writes
+// drop the entry through guildConfigChanged.
+var guildPrefixes = newLRUCache[string, []string]("command_prefixes", 5000, time.Hour)
+
+func init() {
+	trackCache(guildPrefixes)
+	registerCommand(command{name: "prefix", handler: prefixCommand})
+
+	manageServer := int64(discordgo.PermissionManageServer)
+	dmPermission := false
+	registerSlashCommand(&discordgo.ApplicationCommand{
+		Name:                     "prefix",
+		Description:              "Change or turn off the command prefix for this server",
+		DefaultMemberPermissions: &manageServer,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "mode",
+				Description: "Which prefixes work",
+				Required:    true,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Custom prefix and !elsie", Value: "both"},
+					{Name: "Only the custom prefix", Value: prefixModeOnly},
+					{Name: "Off — slash commands only", Value: prefixModeOff},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "prefix",
+				Description: "The custom prefix, e.g. !bar",
+				MaxLength:   maxPrefixLength,
+			},
+		},
+	}, prefixSlash)
+}
+
+// commandPrefixes lists the prefixes that start a command in the guild,
+// longest first so `!elsie` isn't cut short by a custom `!e`. DMs always use
+// the default prefix.
+func commandPrefixes(guildID string) []string {
+	if guildID == "" {
+		return []string{defaultCommandPrefix}
+	}
+	if prefixes, ok := guildPrefixes.Get(guildID); ok {
+		return prefixes
+	}
+	cfg := loadGuildConfig(guildID)
+	var prefixes []string
+	switch cfg.PrefixMode {
+	case prefixModeOff:
+	case prefixModeOnly:
+		prefixes = []string{cmp.Or(cfg.Prefix, defaultCommandPrefix)}
+	default:
+		prefixes = []string{defaultCommandPrefix}
+		if cfg.Prefix != "" {
+			prefixes = append(prefixes, cfg.Prefix)
+		}
+	}
+	slices.SortFunc(prefixes, func(a, b string) int { return len(b) - len(a) })
+	guildPrefixes.Add(guildID, prefixes)
+	return prefixes
+}
+
+// prefixStatus describes which prefixes work in the guild.
+func prefixStatus(guildID string) string {
+	cfg := loadGuildConfig(guildID)
+	switch {
+	case cfg.PrefixMode == prefixModeOff:
+		return tr(guildID, "Prefix commands are off; only slash commands work")
+	case cfg.Prefix == "":
+		return "`" + defaultCommandPrefix + "`"
+	case cfg.PrefixMode == prefixModeOnly:
+		return "`" + cfg.Prefix + "`"
+	}
+	return tr(guildID, "`%s` or `!elsie`", cfg.Prefix)
+}
+
+// setCommandPrefix stores the guild's prefix and mode and returns the reply.
+// An empty prefix keeps the current one; "!elsie" clears it.
+func setCommandPrefix(guildID, actorID, prefix, mode string) string {
+	if err := validatePrefix(prefix); err != nil {
+		return "⚠️ " + err.Error()
+	}
+	if mode == prefixModeOnly && prefix == "" && loadGuildConfig(guildID).Prefix == "" {
+		return tr(guildID, "⚠️ Give the prefix to use instead of `!elsie`.")
+	}
+	if mode == prefixModeOnly && prefix == defaultCommandPrefix {
+		mode = prefixModeBoth
+	}
+	err := updateGuildConfig(guildID, actorID, func(cfg *GuildConfig) {
+		switch prefix {
+		case "":
+		case defaultCommandPrefix:
+			cfg.Prefix = ""
+		default:
+			cfg.Prefix = prefix
+		}
+		cfg.PrefixMode = mode
+	})
+	if err != nil {
+		log.Printf("Error saving command prefix: %v", err)
+		return themePhrase(guildID, "save_failed", nil)
+	}
+	log.Printf("⌨️  Command prefix for guild %s set to %q (mode %q) by %s", guildID, prefix, mode, actorID)
+	if mode == prefixModeOff {
+		return tr(guildID, "⌨️ Prefix commands are now **off**. I'll only answer slash commands here; use `/prefix` to turn them back on.")
+	}
+	return tr(guildID, "⌨️ Commands now start with %s.", prefixStatus(guildID))
+}
+
+// prefixCommand is `!elsie prefix [<prefix> [only]|default|off]`.
+func prefixCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie prefix <prefix>` (alongside `!elsie`), `!elsie prefix <prefix> only`, `!elsie prefix default` or `!elsie prefix off`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("The command prefix is per server — use this command in a server channel."))
+		return
+	}
+	if len(ctx.args) == 0 {
+		ctx.reply(ctx.tr("⌨️ **Command prefix:** %s", prefixStatus(ctx.m.GuildID)) + "\n" + usage)
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change the command prefix."))
+		return
+	}
+	switch arg := ctx.args[0]; strings.ToLower(arg) {
+	case "default":
+		ctx.reply(setCommandPrefix(ctx.m.GuildID, ctx.m.Author.ID, defaultCommandPrefix, prefixModeBoth))
+	case "off":
+		ctx.reply(setCommandPrefix(ctx.m.GuildID, ctx.m.Author.ID, "", prefixModeOff))
+	default:
+		mode := prefixModeBoth
+		if len(ctx.args) > 1 {
+			if !strings.EqualFold(ctx.args[1], "only") {
+				ctx.reply(usage)
+				return
+			}
+			mode = prefixModeOnly
+		}
+		ctx.reply(setCommandPrefix(ctx.m.GuildID, ctx.m.Author.ID, arg, mode))
+	}
+}
+
+// prefixSlash serves /prefix, which also works once prefix commands are off.
+func prefixSlash(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	user := interactionUser(i)
+	if i.GuildID == "" || i.Member == nil {
+		respondEphemeral(s, i, "The command prefix is per server — use this command in a server.")
+		return
+	}
+	perms := i.Member.Permissions
+	if !isBotOwner(user.ID) && perms&discordgo.PermissionManageServer == 0 && perms&discordgo.PermissionAdministrator == 0 {
+		respondEphemeral(s, i, tr(i.GuildID, "*shakes head* Only server admins can change the command prefix."))
+		return
+	}
+	var mode, prefix string
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "mode":
+			mode = opt.StringValue()
+		case "prefix":
+			prefix = strings.TrimSpace(opt.StringValue())
+		}
+	}
+	if mode == "both" {
+		mode = prefixModeBoth
+	}
+	respondEphemeral(s, i, setCommandPrefix(i.GuildID, user.ID, prefix, mode))
+}
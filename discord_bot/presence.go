@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const defaultPresence = "🍺 Serving drinks across the galaxy"
+
+// defaultPresenceStatuses is the rotation when PRESENCE_STATUSES is unset.
+var defaultPresenceStatuses = []string{
+	defaultPresence,
+	"{{if .DrinkOfTheDay}}{{.DrinkEmoji}} Drink of the day: {{.DrinkOfTheDay}}{{end}}",
+	"{{if .ActiveScenes}}🎭 Scenes in progress: {{.ActiveScenes}}{{end}}",
+	"🌌 Tending bar on {{.Guilds}} servers",
+}
+
+// presenceData is what PRESENCE_STATUSES templates can show.
+type presenceData struct {
+	Guilds        int
+	ActiveScenes  int
+	DrinkOfTheDay string
+	DrinkEmoji    string
+}
+
+var (
+	// presenceMu guards the rotation position and the status last sent.
+	presenceMu       sync.Mutex
+	presenceIndex    int
+	presenceLastSent string
+
+	presenceRotationOnce sync.Once
+)
+
+// parsePresenceStatuses reads PRESENCE_STATUSES, a "|"-separated list of
+// text/template statuses, dropping any that don't parse. A "|" inside
+// {{...}} is a template pipe, not a separator.
+func parsePresenceStatuses(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return defaultPresenceStatuses
+	}
+	var statuses []string
+	for _, status := range splitPresenceStatuses(raw) {
+		if status = strings.TrimSpace(status); status == "" {
+			continue
+		}
+		if _, err := template.New("presence").Parse(status); err != nil {
+			log.Printf("Invalid PRESENCE_STATUSES entry %q, skipping: %v", status, err)
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) == 0 {
+		return []string{defaultPresence}
+	}
+	return statuses
+}
+
+func splitPresenceStatuses(raw string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case strings.HasPrefix(raw[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(raw[i:], "}}") && depth > 0:
+			depth--
+			i++
+		case raw[i] == '|' && depth == 0:
+			parts = append(parts, raw[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, raw[start:])
+}
+
+func currentPresenceData() presenceData {
+	data := presenceData{}
+	if botSession != nil {
+		data.Guilds = len(stateGuilds(botSession))
+	}
+	for _, channelID := range store.Keys(sceneBucket) {
+		if loadScene(channelID).Active {
+			data.ActiveScenes++
+		}
+	}
+	if config().DrinkOfTheDayEnabled {
+		if d := loadDrinkOfTheDay(); d.Drink.Name != "" {
+			data.DrinkOfTheDay, data.DrinkEmoji = d.Drink.Name, drinkEmoji(d.Drink)
+		}
+	}
+	return data
+}
+
+// renderPresenceStatuses fills in the rotation, leaving out statuses that
+// render empty, like the drink of the day before one is picked.
+func renderPresenceStatuses() []string {
+	data := currentPresenceData()
+	var out []string
+	for _, status := range config().PresenceStatuses {
+		tmpl, err := template.New("presence").Parse(status)
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			log.Printf("Error rendering presence %q: %v", status, err)
+			continue
+		}
+		if text := strings.TrimSpace(buf.String()); text != "" {
+			out = append(out, truncateText(text, 128))
+		}
+	}
+	if len(out) == 0 {
+		return []string{defaultPresence}
+	}
+	return out
+}
+
+// normalStatus is Elsie's presence outside diagnostics and safe mode: the
+// current entry in the rotation.
+func normalStatus() string {
+	statuses := renderPresenceStatuses()
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	return statuses[presenceIndex%len(statuses)]
+}
+
+// refreshPresence sets the current status, if it changed since it was last
+// sent. Diagnostics and safe mode keep their own status.
+func refreshPresence(s *discordgo.Session) {
+	if !botReady.Load() || safeMode.Load() {
+		return
+	}
+	status := normalStatus()
+	presenceMu.Lock()
+	defer presenceMu.Unlock()
+	if status == presenceLastSent {
+		return
+	}
+	if err := s.UpdateGameStatus(0, status); err != nil {
+		log.Println("Error setting status:", err)
+		return
+	}
+	presenceLastSent = status
+}
+
+// startPresenceRotation starts cycling through the statuses once.
+func startPresenceRotation(s *discordgo.Session) {
+	presenceRotationOnce.Do(func() { go runPresenceRotation(s) })
+}
+
+// runPresenceRotation moves to the next status every PRESENCE_INTERVAL,
+// re-reading the interval each time so a config reload applies.
+func runPresenceRotation(s *discordgo.Session) {
+	for {
+		time.Sleep(config().PresenceInterval)
+		presenceMu.Lock()
+		presenceIndex++
+		presenceMu.Unlock()
+		refreshPresence(s)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Privacy logging modes for PRIVACY_LOGGING.
+const (
+	privacyOff      = "off"
+	privacyTruncate = "truncate"
+	privacyHash     = "hash"
+)
+
+// privacySalt keys log hashes so short messages can't be recovered by
+// hashing guesses. Without PRIVACY_LOG_SALT it is random per process, so
+// hashes only correlate within one run.
+var privacySalt []byte
+
+func initPrivacyLogging() {
+	if config().PrivacyLogSalt != "" {
+		privacySalt = []byte(config().PrivacyLogSalt)
+		return
+	}
+	privacySalt = make([]byte, 16)
+	rand.Read(privacySalt)
+}
+
+func privacyHashOf(value string) string {
+	sum := sha256.Sum256(append(append([]byte(nil), privacySalt...), value...))
+	return hex.EncodeToString(sum[:6])
+}
+
+// logText returns user or agent message content as it may appear in logs.
+func logText(text string) string {
+	switch config().PrivacyLogging {
+	case privacyTruncate:
+		return fmt.Sprintf("%q (%d chars)", truncateText(text, 20), utf8.RuneCountInString(text))
+	case privacyHash:
+		return fmt.Sprintf("sha:%s (%d chars)", privacyHashOf(text), utf8.RuneCountInString(text))
+	}
+	return text
+}
+
+// logUser identifies a user in non-essential log lines. In privacy mode the
+// name and ID are replaced by a pseudonym that still correlates lines.
+func logUser(username, userID string) string {
+	if config().PrivacyLogging == privacyOff {
+		if username == "" {
+			return userID
+		}
+		return fmt.Sprintf("%s (%s)", username, userID)
+	}
+	return "user:" + privacyHashOf(userID)
+}
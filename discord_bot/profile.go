@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const profileBucket = "user_profiles"
+
+// UserProfile is what Elsie remembers about a regular across sessions.
+type UserProfile struct {
+	PreferredName string `json:"preferred_name,omitempty"`
+	Pronouns      string `json:"pronouns,omitempty"`
+	FavoriteDrink string `json:"favorite_drink,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+	Language      string `json:"language,omitempty"`
+	// NoDMFallback stops Elsie DMing answers she couldn't post in a channel.
+	NoDMFallback bool `json:"no_dm_fallback,omitempty"`
+}
+
+// profileFields maps the `!elsie remember <field>` names to profile fields.
+var profileFields = map[string]func(p *UserProfile) *string{
+	"name":     func(p *UserProfile) *string { return &p.PreferredName },
+	"pronouns": func(p *UserProfile) *string { return &p.Pronouns },
+	"drink":    func(p *UserProfile) *string { return &p.FavoriteDrink },
+	"timezone": func(p *UserProfile) *string { return &p.Timezone },
+	"language": func(p *UserProfile) *string { return &p.Language },
+}
+
+func init() {
+	registerCommand(command{name: "remember", handler: rememberCommand})
+	registerCommand(command{name: "forget", handler: forgetCommand})
+}
+
+// loadProfile returns the stored profile for userID, or nil if Elsie doesn't
+// know them yet.
+func loadProfile(userID string) *UserProfile {
+	var p UserProfile
+	found, err := store.Get(profileBucket, userID, &p)
+	if err != nil {
+		log.Printf("Error loading profile for %s: %v", logUser("", userID), err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	return &p
+}
+
+func (p *UserProfile) isEmpty() bool {
+	return *p == UserProfile{}
+}
+
+// contextFields returns the profile as agent context, including the user's
+// local time when a timezone is known.
+func (p *UserProfile) contextFields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	if p.PreferredName != "" {
+		fields["preferred_name"] = p.PreferredName
+	}
+	if p.Pronouns != "" {
+		fields["pronouns"] = p.Pronouns
+	}
+	if p.FavoriteDrink != "" {
+		fields["favorite_drink"] = p.FavoriteDrink
+	}
+	if p.Timezone != "" {
+		fields["timezone"] = p.Timezone
+		if loc, err := time.LoadLocation(p.Timezone); err == nil {
+			fields["local_time"] = time.Now().In(loc).Format("Mon 15:04")
+		}
+	}
+	if p.Language != "" {
+		fields["language"] = p.Language
+	}
+	return fields
+}
+
+func (p *UserProfile) describe() string {
+	var lines []string
+	if p.PreferredName != "" {
+		lines = append(lines, "• Name: "+p.PreferredName)
+	}
+	if p.Pronouns != "" {
+		lines = append(lines, "• Pronouns: "+p.Pronouns)
+	}
+	if p.FavoriteDrink != "" {
+		lines = append(lines, "• Favorite drink: "+p.FavoriteDrink)
+	}
+	if p.Timezone != "" {
+		lines = append(lines, "• Timezone: "+p.Timezone)
+	}
+	if p.Language != "" {
+		lines = append(lines, "• Language: "+p.Language)
+	}
+	if p.NoDMFallback {
+		lines = append(lines, "• DM fallback: off")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rememberCommand(ctx *commandContext) {
+	userID := ctx.m.Author.ID
+	if len(ctx.args) == 0 {
+		p := loadProfile(userID)
+		if p == nil || p.isEmpty() {
+			ctx.reply("*polishes a glass* I don't have anything on file for you yet. Try `!elsie remember drink Romulan Ale`.")
+			return
+		}
+		ctx.reply("📇 **Here's what I remember about you:**\n" + p.describe())
+		return
+	}
+
+	field := strings.ToLower(ctx.args[0])
+	fieldOf, ok := profileFields[field]
+	value := strings.TrimSpace(strings.TrimPrefix(ctx.raw, ctx.args[0]))
+	if !ok || value == "" {
+		ctx.reply("Usage: `!elsie remember <name|pronouns|drink|timezone|language> <value>`")
+		return
+	}
+	if field == "language" {
+		value = strings.ToLower(value)
+		if !isSupportedLanguage(value) {
+			ctx.reply(fmt.Sprintf("*tilts head* I don't speak %q yet. Try one of: %s.", value, strings.Join(supportedLanguages(), ", ")))
+			return
+		}
+	}
+	if field == "timezone" {
+		if _, err := time.LoadLocation(value); err != nil {
+			ctx.reply(fmt.Sprintf("*tilts head* I don't know the timezone %q. Try something like `Europe/London`.", value))
+			return
+		}
+	}
+
+	p := loadProfile(userID)
+	if p == nil {
+		p = &UserProfile{}
+	}
+	*fieldOf(p) = value
+	if err := store.Put(profileBucket, userID, p); err != nil {
+		log.Printf("Error saving profile for %s: %v", logUser("", userID), err)
+		ctx.reply("*holographic matrix flickers* I couldn't commit that to memory. Please try again later.")
+		return
+	}
+	ctx.reply(fmt.Sprintf("*makes a note* Got it — your %s is **%s**. I'll remember that.", field, value))
+}
+
+func forgetCommand(ctx *commandContext) {
+	userID := ctx.m.Author.ID
+	if len(ctx.args) == 0 {
+		if err := store.Delete(profileBucket, userID); err != nil {
+			log.Printf("Error deleting profile for %s: %v", logUser("", userID), err)
+			ctx.reply("*holographic matrix flickers* I couldn't clear my memory banks. Please try again later.")
+			return
+		}
+		ctx.reply("*wipes the slate clean* Consider it forgotten.")
+		return
+	}
+
+	field := strings.ToLower(ctx.args[0])
+	if field == "me" {
+		forgetMe(ctx)
+		return
+	}
+	fieldOf, ok := profileFields[field]
+	if !ok {
+		ctx.reply("Usage: `!elsie forget [me|name|pronouns|drink|timezone|language]`")
+		return
+	}
+	p := loadProfile(userID)
+	if p == nil {
+		ctx.reply("*shrugs* I didn't have that on file anyway.")
+		return
+	}
+	*fieldOf(p) = ""
+
+	var err error
+	if p.isEmpty() {
+		err = store.Delete(profileBucket, userID)
+	} else {
+		err = store.Put(profileBucket, userID, p)
+	}
+	if err != nil {
+		log.Printf("Error saving profile for %s: %v", logUser("", userID), err)
+		ctx.reply("*holographic matrix flickers* I couldn't clear that. Please try again later.")
+		return
+	}
+	ctx.reply(fmt.Sprintf("*erases a line from the ledger* Your %s is forgotten.", field))
+}
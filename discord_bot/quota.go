@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// quotaLimit allows Limit agent requests per Window.
+type quotaLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (q quotaLimit) enabled() bool {
+	return q.Limit > 0 && q.Window > 0
+}
+
+func (q quotaLimit) String() string {
+	if !q.enabled() {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", q.Limit, q.Window)
+}
+
+// parseQuota reads "<limit>/<window>", e.g. "10/1m". "off" disables the
+// quota.
+func parseQuota(value string) (quotaLimit, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" || value == "off" {
+		return quotaLimit{}, nil
+	}
+	limitText, windowText, ok := strings.Cut(value, "/")
+	if !ok {
+		return quotaLimit{}, fmt.Errorf("quota %q should look like 10/1m", value)
+	}
+	limit, err := strconv.Atoi(limitText)
+	if err != nil || limit < 0 {
+		return quotaLimit{}, fmt.Errorf("quota limit %q is not a number", limitText)
+	}
+	window, err := time.ParseDuration(windowText)
+	if err != nil || window <= 0 {
+		return quotaLimit{}, fmt.Errorf("quota window %q is not a duration", windowText)
+	}
+	return quotaLimit{Limit: limit, Window: window}, nil
+}
+
+// envQuota reads a quota setting, falling back to def when it is invalid.
+func envQuota(name, def string) quotaLimit {
+	q, err := parseQuota(envString(name, def))
+	if err != nil {
+		log.Printf("Invalid %s: %v, using default %s", name, err, def)
+		q, _ = parseQuota(def)
+	}
+	return q
+}
+
+// quotaScopes are checked in order; the first exhausted scope is reported.
+var quotaScopes = []string{"user", "channel", "guild"}
+
+// quotaWindow counts requests in one fixed window.
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	quotaMu      sync.Mutex
+	quotaWindows = newLRUCache[string, *quotaWindow]("quotas", 20000, 24*time.Hour)
+	cooldowns    = newLRUCache[string, time.Time]("cooldowns", 20000, time.Hour)
+	quotaWarned  = newLRUCache[string, time.Time]("quota_warnings", 5000, time.Hour)
+)
+
+func init() {
+	trackCache(quotaWindows)
+	trackCache(cooldowns)
+	trackCache(quotaWarned)
+	registerCommand(command{name: "quota", handler: quotaCommand})
+}
+
+// guildQuota returns the limit for scope in a guild, with the guild's admin
+// override taking precedence over the operator default.
+func guildQuota(cfg *GuildConfig, scope string) quotaLimit {
+	if override, ok := cfg.QuotaOverrides[scope]; ok {
+		if q, err := parseQuota(override); err == nil {
+			return q
+		}
+	}
+	switch scope {
+	case "user":
+		return config().UserQuota
+	case "channel":
+		return config().ChannelQuota
+	case "guild":
+		return config().GuildQuota
+	}
+	return quotaLimit{}
+}
+
+// consumeQuota records one agent request against the user, channel and guild
+// quotas. If any is exhausted nothing is recorded and it reports the scope
+// and how long until it resets.
+func consumeQuota(guildID, channelID, userID string) (ok bool, scope string, retryAfter time.Duration) {
+	if isBotOwner(userID) {
+		return true, "", 0
+	}
+	cfg := loadGuildConfig(guildID)
+	for _, id := range cfg.QuotaExempt {
+		if id == userID {
+			return true, "", 0
+		}
+	}
+	keys := map[string]string{
+		"user":    "user:" + guildID + ":" + userID,
+		"channel": "channel:" + channelID,
+		"guild":   "guild:" + guildID,
+	}
+
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	now := time.Now()
+	var windows []*quotaWindow
+	for _, scope := range quotaScopes {
+		limit := guildQuota(cfg, scope)
+		if !limit.enabled() || (scope != "user" && guildID == "") {
+			continue
+		}
+		w, found := quotaWindows.Get(keys[scope])
+		if !found || now.Sub(w.start) >= limit.Window {
+			w = &quotaWindow{start: now}
+			quotaWindows.Add(keys[scope], w)
+		}
+		if w.count >= limit.Limit {
+			metrics.Inc(metricLabel("quota_exceeded_total", "scope", scope))
+			return false, scope, w.start.Add(limit.Window).Sub(now)
+		}
+		windows = append(windows, w)
+	}
+	for _, w := range windows {
+		w.count++
+	}
+	return true, "", 0
+}
+
+// checkCooldown enforces SLASH_COMMAND_COOLDOWN per user and command.
+func checkCooldown(userID, commandName string) (ok bool, retryAfter time.Duration) {
+	if config().SlashCommandCooldown <= 0 || isBotOwner(userID) {
+		return true, 0
+	}
+	key := userID + ":" + commandName
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	if last, found := cooldowns.Get(key); found {
+		if wait := config().SlashCommandCooldown - time.Since(last); wait > 0 {
+			return false, wait
+		}
+	}
+	cooldowns.Add(key, time.Now())
+	return true, 0
+}
+
+// shouldWarnQuota reports whether the user hasn't been told about this quota
+// recently, so a chatty channel doesn't fill up with warnings.
+func shouldWarnQuota(userID, scope string, retryAfter time.Duration) bool {
+	key := userID + ":" + scope
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+	if until, found := quotaWarned.Get(key); found && time.Now().Before(until) {
+		return false
+	}
+	quotaWarned.Add(key, time.Now().Add(retryAfter))
+	return true
+}
+
+func quotaMessage(guildID, scope string, retryAfter time.Duration) string {
+	return themePhrase(guildID, "quota_exceeded", map[string]string{
+		"Who":  map[string]string{"user": "you've", "channel": "this channel has", "guild": "this server has"}[scope],
+		"Wait": retryAfter.Round(time.Second).String(),
+	})
+}
+
+// quotaCommand is `!elsie quota [set <user|channel|guild> <n/window|off>|reset <scope>|exempt @user]`.
+func quotaCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie quota`, `!elsie quota set <user|channel|guild> <10/1m|off>`, `!elsie quota reset <scope>`, `!elsie quota exempt @user`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Quotas are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change my quotas.")
+		return
+	}
+
+	cfg := loadGuildConfig(ctx.m.GuildID)
+	if len(ctx.args) == 0 {
+		var b strings.Builder
+		b.WriteString("⏳ **Agent quotas**\n")
+		for _, scope := range quotaScopes {
+			note := ""
+			if _, ok := cfg.QuotaOverrides[scope]; ok {
+				note = " (override)"
+			}
+			fmt.Fprintf(&b, "• Per %s: %s%s\n", scope, guildQuota(cfg, scope), note)
+		}
+		if config().SlashCommandCooldown > 0 {
+			fmt.Fprintf(&b, "• Slash command cooldown: %s\n", config().SlashCommandCooldown)
+		}
+		if len(cfg.QuotaExempt) > 0 {
+			b.WriteString("• Exempt: ")
+			for i, id := range cfg.QuotaExempt {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				fmt.Fprintf(&b, "<@%s>", id)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(usage)
+		ctx.replyQuietly(b.String())
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	var confirm func() string
+	switch strings.ToLower(ctx.args[0]) {
+	case "set":
+		if len(ctx.args) < 3 || !isQuotaScope(ctx.args[1]) {
+			ctx.reply(usage)
+			return
+		}
+		scope := strings.ToLower(ctx.args[1])
+		q, err := parseQuota(ctx.args[2])
+		if err != nil {
+			ctx.reply(fmt.Sprintf("*squints* %v", err))
+			return
+		}
+		value := strings.ToLower(ctx.args[2])
+		apply = func(cfg *GuildConfig) {
+			if cfg.QuotaOverrides == nil {
+				cfg.QuotaOverrides = make(map[string]string)
+			}
+			cfg.QuotaOverrides[scope] = value
+		}
+		confirm = func() string { return fmt.Sprintf("⏳ Per-%s quota set to **%s**.", scope, q) }
+	case "reset":
+		if len(ctx.args) < 2 || !isQuotaScope(ctx.args[1]) {
+			ctx.reply(usage)
+			return
+		}
+		scope := strings.ToLower(ctx.args[1])
+		apply = func(cfg *GuildConfig) { delete(cfg.QuotaOverrides, scope) }
+		confirm = func() string {
+			return fmt.Sprintf("⏳ Per-%s quota back to the default (**%s**).", scope, guildQuota(&GuildConfig{}, scope))
+		}
+	case "exempt":
+		if len(ctx.m.Mentions) == 0 {
+			ctx.reply("Mention the member, e.g. `!elsie quota exempt @Captain`.")
+			return
+		}
+		userID := ctx.m.Mentions[0].ID
+		removed := false
+		apply = func(cfg *GuildConfig) {
+			for i, id := range cfg.QuotaExempt {
+				if id == userID {
+					cfg.QuotaExempt = append(cfg.QuotaExempt[:i], cfg.QuotaExempt[i+1:]...)
+					removed = true
+					return
+				}
+			}
+			cfg.QuotaExempt = append(cfg.QuotaExempt, userID)
+		}
+		confirm = func() string {
+			if removed {
+				return fmt.Sprintf("⏳ <@%s> is subject to quotas again.", userID)
+			}
+			return fmt.Sprintf("⏳ <@%s> is now exempt from quotas.", userID)
+		}
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving quota config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.replyQuietly(confirm())
+}
+
+func isQuotaScope(scope string) bool {
+	for _, s := range quotaScopes {
+		if strings.EqualFold(s, scope) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const pinnedRecapBucket = "pinned_recaps"
+
+// isRecap reports whether the agent marked this response as a scene recap,
+// either with the top-level flag or as the context's response type.
+func (r *AIResponse) isRecap() bool {
+	if r == nil {
+		return false
+	}
+	if r.Recap {
+		return true
+	}
+	kind, _ := r.Context["response_type"].(string)
+	return kind == "recap"
+}
+
+// pinRecap pins a freshly posted recap in its channel and unpins the recap
+// it replaces, so a scene's pins always hold exactly one current summary.
+func pinRecap(s *discordgo.Session, channelID string, msg *discordgo.Message) {
+	if !config().AutoPinRecaps || msg == nil {
+		return
+	}
+
+	var previousID string
+	if _, err := store.Get(pinnedRecapBucket, channelID, &previousID); err != nil {
+		log.Printf("Error loading pinned recap for %s: %v", channelID, err)
+	}
+	if previousID != "" && previousID != msg.ID {
+		if err := s.ChannelMessageUnpin(channelID, previousID); err != nil {
+			// The old recap may have been deleted or unpinned by hand.
+			log.Printf("Could not unpin previous recap %s: %v", previousID, err)
+		}
+	}
+
+	if err := s.ChannelMessagePin(channelID, msg.ID); err != nil {
+		log.Printf("Error pinning recap in %s: %v", channelID, err)
+		return
+	}
+	if err := store.Put(pinnedRecapBucket, channelID, msg.ID); err != nil {
+		log.Printf("Error saving pinned recap for %s: %v", channelID, err)
+	}
+	log.Printf("📌 Pinned recap %s in channel %s", msg.ID, channelID)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// panicReports remembers when each handler last reported a panic, so one
+// malformed event repeating in a loop doesn't flood the error channel.
+var panicReports = newLRUCache[string, time.Time]("panic_reports", 100, time.Hour)
+
+// panicReportInterval is the minimum gap between reports for one handler.
+const panicReportInterval = time.Minute
+
+func init() {
+	trackCache(panicReports)
+}
+
+// recovered wraps a Discord event handler so a panic is logged with its
+// stack and event context, reported to the error channel, and swallowed,
+// leaving the gateway connection and other handlers running.
+func recovered[T any](name string, handler func(*discordgo.Session, T)) func(*discordgo.Session, T) {
+	return func(s *discordgo.Session, event T) {
+		defer func() {
+			if r := recover(); r != nil {
+				reportPanic(s, name, eventContext(event), r, debug.Stack())
+			}
+		}()
+		handler(s, event)
+	}
+}
+
+func reportPanic(s *discordgo.Session, name, context string, value interface{}, stack []byte) {
+	metrics.Inc(metricLabel("handler_panics_total", "handler", name))
+	log.Printf("🔥 PANIC in %s handler (%s): %v\n%s", name, context, value, stack)
+	reportError(errorKindPanic, fmt.Errorf("%v", value), map[string]string{"handler": name}, map[string]string{
+		"event": context,
+		"stack": truncateText(string(stack), 8000),
+	})
+
+	channelID := config().ErrorChannelID
+	if channelID == "" {
+		channelID = config().AdminChannelID
+	}
+	if channelID == "" || s == nil {
+		return
+	}
+	if last, ok := panicReports.Get(name); ok && time.Since(last) < panicReportInterval {
+		return
+	}
+	panicReports.Add(name, time.Now())
+
+	text := fmt.Sprintf("🔥 **Recovered panic in `%s` handler**\n%s\n`%v`\n```\n%s\n```",
+		name, context, value, truncateText(string(stack), 1500))
+	if _, err := sendChunks(s, channelID, text); err != nil {
+		log.Printf("Error reporting panic: %v", err)
+	}
+}
+
+// eventContext describes an event for a panic report without including
+// message content.
+func eventContext(event interface{}) string {
+	switch e := event.(type) {
+	case *discordgo.MessageCreate:
+		author := ""
+		if e.Author != nil {
+			author = logUser("", e.Author.ID)
+		}
+		return fmt.Sprintf("message %s in channel %s, guild %s, author %s", e.ID, e.ChannelID, e.GuildID, author)
+	case *discordgo.InteractionCreate:
+		return fmt.Sprintf("interaction %s (type %d) in channel %s, guild %s", e.ID, e.Type, e.ChannelID, e.GuildID)
+	case *discordgo.MessageReactionAdd:
+		return fmt.Sprintf("reaction %s on message %s in channel %s", e.Emoji.Name, e.MessageID, e.ChannelID)
+	case *discordgo.MessageReactionRemove:
+		return fmt.Sprintf("reaction removal %s on message %s in channel %s", e.Emoji.Name, e.MessageID, e.ChannelID)
+	case *discordgo.VoiceStateUpdate:
+		return fmt.Sprintf("voice state for %s in channel %s, guild %s", logUser("", e.UserID), e.ChannelID, e.GuildID)
+	case *discordgo.Ready:
+		return fmt.Sprintf("ready for session %s (%d guilds)", e.SessionID, len(e.Guilds))
+	}
+	return fmt.Sprintf("%T", event)
+}
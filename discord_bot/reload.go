@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+// restartOnlySettings are read once at startup; a reload notes that they
+// changed but they only take effect after a restart.
+var restartOnlySettings = []string{
+	"DISCORD_TOKEN", "DATA_DIR",
+	"MAX_CACHED_CHANNELS", "MAX_CACHED_GUILDS", "MAX_CACHED_MEMBERS", "CACHE_TTL", "CACHE_SWEEP_INTERVAL",
+	"DEDUP_WINDOW", "DEDUP_MAX_MESSAGES",
+	"AGENT_HEALTH_INTERVAL", "AGENT_TLS_CERT", "AGENT_TLS_KEY", "AGENT_TLS_CA",
+	"AGENT_MAX_IDLE_CONNS", "AGENT_IDLE_CONN_TIMEOUT", "AGENT_HTTP2",
+	"INSTANCE_LOCK_ENABLED", "INSTANCE_HEARTBEAT_INTERVAL",
+	"FILTER_WORDLIST_FILE", "FILTER_REGEX_FILE", "THEME_PACKS_FILE", "LOCALES_DIR",
+	"PRIVACY_LOG_SALT", "SENTRY_DSN", "SENTRY_ENVIRONMENT", "SENTRY_RELEASE",
+	"CHAOS_ENABLED", "SLASH_COMMAND_GUILD_ID",
+	"HTTP_ADDR", "HTTP_TLS_CERT", "HTTP_TLS_KEY", "HTTP_CLIENT_CA", "OIDC_ISSUER", "OIDC_AUDIENCE",
+	"DASHBOARD_ADDR", "DASHBOARD_SESSION_SECRET",
+}
+
+var (
+	// reloadMu stops two reloads from interleaving.
+	reloadMu sync.Mutex
+
+	// processEnv is the environment the bot was started with, which takes
+	// precedence over .env on a reload as it does at startup.
+	processEnv map[string]string
+
+	// dotenvValues is what was last loaded from .env, so settings removed
+	// from the file can be unset.
+	dotenvValues map[string]string
+)
+
+func init() {
+	registerCommand(command{name: "reload", handler: reloadCommand})
+}
+
+// loadDotenv sets the variables in .env that weren't in the bot's own
+// environment, and unsets those that were removed from the file since the
+// last load.
+func loadDotenv() error {
+	if processEnv == nil {
+		processEnv = environ()
+	}
+	values, err := godotenv.Read()
+	if err != nil {
+		return err
+	}
+	for name := range dotenvValues {
+		if _, ok := values[name]; !ok {
+			if _, own := processEnv[name]; !own {
+				os.Unsetenv(name)
+			}
+		}
+	}
+	for name, value := range values {
+		if _, own := processEnv[name]; !own {
+			os.Setenv(name, value)
+		}
+	}
+	dotenvValues = values
+	return nil
+}
+
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		env[name] = value
+	}
+	return env
+}
+
+// watchReloadSignal reloads the configuration on every SIGHUP.
+func watchReloadSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		reloadConfig("SIGHUP")
+	}
+}
+
+// reloadConfig re-reads .env and the environment, and applies the result
+// without dropping the gateway connection: agent URLs, feature flags,
+// quotas and the drink, trivia and fallback files. It returns the names of
+// the settings that changed, and of those among them that need a restart.
+// Values are never logged, since some are secrets.
+func reloadConfig(source string) (changed, restartOnly []string) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	before := environ()
+	if err := loadDotenv(); err != nil {
+		log.Printf("Reloading configuration without .env: %v", err)
+	}
+	after := environ()
+	for name, value := range after {
+		if old, ok := before[name]; !ok || old != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	for _, name := range changed {
+		if slices.Contains(restartOnlySettings, name) || isPersonaTokenEnv(name) {
+			restartOnly = append(restartOnly, name)
+		}
+	}
+
+	// Everything is read before it's published, so readers never see the
+	// defaults or a half-applied reload
+	c := loadConfig()
+	cat := loadCatalogs(c)
+	currentConfig.Store(c)
+	currentCatalogs.Store(cat)
+	initAgentBackends(c.AIAgentURLs, c.PersonaAgentURLs)
+	go runCapabilityHandshake()
+	stopDisabledVoiceListeners()
+
+	metrics.Inc(metricLabel("config_reloads_total", "source", source))
+	log.Printf("🔄 Reloaded configuration (%s): %d settings changed %v", source, len(changed), changed)
+	if len(restartOnly) > 0 {
+		log.Printf("⚠️  %v changed but only take effect after a restart", restartOnly)
+	}
+	return changed, restartOnly
+}
+
+// reloadCommand is the owner-only `!elsie reload`.
+func reloadCommand(ctx *commandContext) {
+	if !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*shakes head* Only my operators can reload my configuration.")
+		return
+	}
+	changed, restartOnly := reloadConfig("command")
+	if len(changed) == 0 {
+		ctx.reply("🔄 Configuration reloaded; nothing changed.")
+		return
+	}
+	reply := fmt.Sprintf("🔄 Configuration reloaded: %d settings changed (`%s`).", len(changed), strings.Join(changed, "`, `"))
+	if len(restartOnly) > 0 {
+		reply += fmt.Sprintf("\n⚠️ `%s` only take effect after a restart.", strings.Join(restartOnly, "`, `"))
+	}
+	ctx.reply(truncateText(reply, 2000))
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const reminderBucket = "reminders"
+
+// Reminder is a player's `!elsie remind me`, persisted so it survives
+// restarts.
+type Reminder struct {
+	ID        string    `json:"id"`
+	GuildID   string    `json:"guild_id,omitempty"`
+	ChannelID string    `json:"channel_id"`
+	UserID    string    `json:"user_id"`
+	Text      string    `json:"text"`
+	DM        bool      `json:"dm,omitempty"`
+	Created   time.Time `json:"created"`
+	Due       time.Time `json:"due"`
+}
+
+// reminderUnits are the duration words a reminder understands.
+var reminderUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "wk": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// compactDurationPart matches one number-unit pair in forms like "1h30m".
+var compactDurationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)([a-z]+)`)
+
+// reminderMu guards the reminder bucket between scheduling and delivery.
+var reminderMu sync.Mutex
+
+var reminderSchedulerOnce sync.Once
+
+func init() {
+	registerCommand(command{name: "remind", handler: remindCommand})
+	registerDataEraser(dataEraser{name: "reminders", user: eraseReminders("user"), guild: eraseReminders("guild")})
+}
+
+// parseCompactDuration reads a single word such as "2h", "90m" or "1d12h".
+func parseCompactDuration(word string) (time.Duration, bool) {
+	parts := compactDurationPart.FindAllStringSubmatch(word, -1)
+	if len(parts) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	matched := 0
+	for _, p := range parts {
+		unit, ok := reminderUnits[p[2]]
+		n, err := strconv.ParseFloat(p[1], 64)
+		if !ok || err != nil {
+			return 0, false
+		}
+		total += time.Duration(n * float64(unit))
+		matched += len(p[0])
+	}
+	return total, matched == len(word)
+}
+
+// parseReminderDelay reads a duration from the start of words: "2h",
+// "1h30m", "90 minutes", "2 hours and 15 minutes", "an hour and a half" or
+// "half an hour". It returns the duration and how many words it used.
+func parseReminderDelay(words []string) (time.Duration, int) {
+	var total, last time.Duration
+	used := 0
+	word := func(i int) string {
+		if i < len(words) {
+			return strings.ToLower(strings.Trim(words[i], ",."))
+		}
+		return ""
+	}
+	for i := 0; i < len(words); {
+		w := word(i)
+		switch {
+		case w == "and" && last > 0 && word(i+1) == "a" && word(i+2) == "half":
+			total += last / 2
+			i += 3
+		case w == "and" && total > 0:
+			i++
+			continue // only counts as used if a duration follows
+		case w == "half" && (word(i+1) == "a" || word(i+1) == "an") && reminderUnits[word(i+2)] > 0:
+			last = reminderUnits[word(i+2)]
+			total += last / 2
+			i += 3
+		default:
+			if d, ok := parseCompactDuration(w); ok {
+				total, last = total+d, 0
+				i++
+				break
+			}
+			n, err := strconv.ParseFloat(w, 64)
+			if w == "a" || w == "an" {
+				n, err = 1, nil
+			}
+			unit := reminderUnits[word(i+1)]
+			if err != nil || unit == 0 || n <= 0 {
+				return total, used
+			}
+			last = unit
+			total += time.Duration(n * float64(unit))
+			i += 2
+		}
+		used = i
+	}
+	return total, used
+}
+
+// parseReminder reads `[me|dm] in <duration> [to|that|about] <text>`, or
+// `tomorrow` in place of `in <duration>`.
+func parseReminder(args []string) (delay time.Duration, text string, dm bool, ok bool) {
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "dm", "privately":
+			dm = true
+			args = args[1:]
+		case "me":
+			args = args[1:]
+		}
+	}
+	if len(args) > 0 && strings.EqualFold(args[0], "privately") {
+		dm = true
+		args = args[1:]
+	}
+	switch {
+	case len(args) > 0 && strings.EqualFold(args[0], "tomorrow"):
+		delay, args = 24*time.Hour, args[1:]
+	case len(args) > 1 && strings.EqualFold(args[0], "in"):
+		var used int
+		if delay, used = parseReminderDelay(args[1:]); used == 0 {
+			return 0, "", false, false
+		}
+		args = args[1+used:]
+	default:
+		return 0, "", false, false
+	}
+	if len(args) > 0 {
+		switch strings.ToLower(args[0]) {
+		case "to", "that", "about":
+			args = args[1:]
+		}
+	}
+	text = strings.TrimSpace(strings.Join(args, " "))
+	return delay, text, dm, delay > 0 && text != ""
+}
+
+func loadReminders() []Reminder {
+	var out []Reminder
+	for _, key := range store.Keys(reminderBucket) {
+		var r Reminder
+		if ok, err := store.Get(reminderBucket, key, &r); err != nil || !ok {
+			continue
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	return out
+}
+
+// userReminders returns userID's pending reminders, soonest first.
+func userReminders(userID string) []Reminder {
+	var out []Reminder
+	for _, r := range loadReminders() {
+		if r.UserID == userID {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// startReminderScheduler starts the reminder delivery loop once.
+func startReminderScheduler(s *discordgo.Session) {
+	reminderSchedulerOnce.Do(func() { go runReminderScheduler(s) })
+}
+
+// runReminderScheduler delivers due reminders every few seconds, including
+// any that came due while the bot was down.
+func runReminderScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		var due []Reminder
+		reminderMu.Lock()
+		for _, r := range loadReminders() {
+			if time.Now().Before(r.Due) {
+				break
+			}
+			// Remove before sending, as with follow-ups: a crash mid-delivery
+			// drops the reminder rather than repeating it on every restart.
+			if err := store.Delete(reminderBucket, r.ID); err != nil {
+				log.Printf("Error removing reminder %s: %v", r.ID, err)
+				continue
+			}
+			due = append(due, r)
+		}
+		reminderMu.Unlock()
+		for _, r := range due {
+			deliverReminder(s, r)
+		}
+	}
+}
+
+// deliverReminder mentions the player in the channel the reminder was set
+// in, or DMs them if they asked for that or the channel is gone.
+func deliverReminder(s *discordgo.Session, r Reminder) {
+	text := trLang(agentLocale(r.GuildID, r.UserID), "🛎️ <@%s>, you asked me <t:%d:R> to remind you: %s", r.UserID, r.Created.Unix(), r.Text)
+	if !r.DM && r.GuildID != "" {
+		_, err := s.ChannelMessageSendComplex(r.ChannelID, &discordgo.MessageSend{
+			Content:         text,
+			AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{r.UserID}},
+		})
+		if err == nil {
+			log.Printf("🛎️ Reminder %s delivered in %s", r.ID, r.ChannelID)
+			metrics.Inc(metricLabel("reminders_delivered_total", "via", "channel"))
+			return
+		}
+		log.Printf("Error delivering reminder %s in %s, trying a DM: %v", r.ID, r.ChannelID, err)
+	}
+	dm, err := s.UserChannelCreate(r.UserID)
+	if err == nil {
+		_, err = s.ChannelMessageSend(dm.ID, text)
+	}
+	if err != nil {
+		log.Printf("Error delivering reminder %s: %v", r.ID, err)
+		return
+	}
+	log.Printf("🛎️ Reminder %s delivered by DM", r.ID)
+	metrics.Inc(metricLabel("reminders_delivered_total", "via", "dm"))
+}
+
+func eraseReminders(scope string) func(*discordgo.Session, string) (int, error) {
+	return func(s *discordgo.Session, id string) (int, error) {
+		reminderMu.Lock()
+		defer reminderMu.Unlock()
+		n := 0
+		for _, r := range loadReminders() {
+			if (scope == "user" && r.UserID != id) || (scope == "guild" && r.GuildID != id) {
+				continue
+			}
+			if err := store.Delete(reminderBucket, r.ID); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	}
+}
+
+// remindCommand handles reminders:
+//
+//	!elsie remind me in 2h to rejoin the scene
+//	!elsie remind dm tomorrow to order the supplies
+//	!elsie remind list
+//	!elsie remind cancel <id>
+func remindCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie remind me in 2h to rejoin the scene`, `!elsie remind dm in 30 minutes to ...`, `!elsie remind list`, `!elsie remind cancel <id>`")
+	if len(ctx.args) == 0 {
+		ctx.reply(usage)
+		return
+	}
+	switch strings.ToLower(ctx.args[0]) {
+	case "list":
+		pending := userReminders(ctx.m.Author.ID)
+		if len(pending) == 0 {
+			ctx.reply(ctx.tr("🛎️ You have no reminders with me."))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(ctx.tr("🛎️ **Your reminders**") + "\n")
+		for _, r := range pending {
+			fmt.Fprintf(&b, "`%s` <t:%d:R> — %s\n", r.ID, r.Due.Unix(), truncateText(r.Text, 100))
+		}
+		ctx.reply(b.String())
+		return
+	case "cancel", "delete", "remove":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		id := strings.ToLower(ctx.args[1])
+		reminderMu.Lock()
+		var r Reminder
+		found, err := store.Get(reminderBucket, id, &r)
+		if err == nil && found && r.UserID == ctx.m.Author.ID {
+			err = store.Delete(reminderBucket, id)
+		}
+		reminderMu.Unlock()
+		switch {
+		case err != nil:
+			log.Printf("Error cancelling reminder %s: %v", id, err)
+			ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		case !found || r.UserID != ctx.m.Author.ID:
+			ctx.reply(ctx.tr("*checks the list* I don't have a reminder `%s` for you.", id))
+		default:
+			ctx.reply(ctx.tr("🛎️ Reminder `%s` cancelled.", id))
+		}
+		return
+	}
+
+	delay, text, dm, ok := parseReminder(ctx.args)
+	if !ok {
+		ctx.reply(ctx.tr("*tilts head* I didn't catch when. Try `in 2h`, `in 45 minutes`, `in an hour and a half` or `tomorrow`.") + "\n" + usage)
+		return
+	}
+	if delay < time.Minute || delay > config().ReminderMaxDelay {
+		ctx.reply(ctx.tr("🛎️ Reminders can be set from one minute up to %d days ahead.", int(config().ReminderMaxDelay.Hours()/24)))
+		return
+	}
+	if len(userReminders(ctx.m.Author.ID)) >= config().ReminderMaxPerUser {
+		ctx.reply(ctx.tr("🛎️ You already have %d reminders with me. Cancel one with `!elsie remind cancel <id>` first.", config().ReminderMaxPerUser))
+		return
+	}
+
+	now := time.Now()
+	r := Reminder{
+		ID:        newEventID(),
+		GuildID:   ctx.m.GuildID,
+		ChannelID: ctx.m.ChannelID,
+		UserID:    ctx.m.Author.ID,
+		Text:      truncateText(text, 500),
+		DM:        dm || ctx.m.GuildID == "",
+		Created:   now,
+		Due:       now.Add(delay),
+	}
+	reminderMu.Lock()
+	err := store.Put(reminderBucket, r.ID, r)
+	reminderMu.Unlock()
+	if err != nil {
+		log.Printf("Error saving reminder: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	metrics.Inc("reminders_scheduled_total")
+	if r.DM {
+		ctx.reply(ctx.tr("🛎️ I'll let you know by DM <t:%d:R>. (Reminder `%s`)", r.Due.Unix(), r.ID))
+	} else {
+		ctx.reply(ctx.tr("🛎️ I'll let you know here <t:%d:R>. (Reminder `%s`)", r.Due.Unix(), r.ID))
+	}
+}
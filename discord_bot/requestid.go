@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
+
+// newRequestID returns a random ID that ties a Discord message to the agent
+// generation it triggered.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Error generating request ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestLog prefixes log lines with a request ID so one exchange can be
+// followed across the bot's and the agent's logs.
+type requestLog struct {
+	id string
+}
+
+func (r requestLog) Printf(format string, args ...interface{}) {
+	log.Printf("[req=%s] "+format, append([]interface{}{r.id}, args...)...)
+}
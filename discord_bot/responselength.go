@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// minResponseLength is the smallest length budget admins can give a
+// channel, so a reply still fits a couple of sentences.
+const minResponseLength = 100
+
+// heldResponse is the part of a reply a channel's length budget held back,
+// posted when someone presses "More".
+type heldResponse struct {
+	guildID   string
+	channelID string
+	persona   *persona
+	text      string
+	truncated bool
+}
+
+// heldResponses are the held-back parts by button ID. A part nobody asks
+// for within the hour is dropped.
+var heldResponses = newLRUCache[string, heldResponse]("held_responses", 1000, time.Hour)
+
+func init() {
+	trackCache(heldResponses)
+	registerCommand(command{name: "length", handler: lengthCommand})
+	registerComponentHandler("more", moreComponent)
+}
+
+// channelLengthBudget returns the most characters a reply in the channel
+// should have: the channel's own budget or, in a thread, its parent's. 0
+// means no budget.
+func channelLengthBudget(s *discordgo.Session, guildID, channelID string) int {
+	lengths := loadGuildConfig(guildID).ChannelLengths
+	if len(lengths) == 0 {
+		return 0
+	}
+	if n, ok := lengths[channelID]; ok {
+		return n
+	}
+	if channel, err := getChannel(s, channelID); err == nil && isThreadChannel(channel) {
+		return lengths[channel.ParentID]
+	}
+	return 0
+}
+
+// splitAtBudget splits text into the part that fits in budget characters,
+// cut at the last sentence or line end that fits, and the rest.
+func splitAtBudget(text string, budget int) (string, string) {
+	runes := []rune(text)
+	if budget <= 0 || len(runes) <= budget {
+		return text, ""
+	}
+	cut := string(runes[:budget])
+	i := strings.LastIndexAny(cut, ".!?\n")
+	if i <= len(cut)/2 {
+		i = strings.LastIndex(cut, " ")
+	}
+	if i <= 0 {
+		i = len(cut) - 1
+	}
+	return strings.TrimSpace(cut[:i+1]), strings.TrimSpace(text[i+1:])
+}
+
+// holdRest keeps what a length budget held back and offers it behind a
+// "More" button. truncated marks a reply that was cut off at its end, so
+// its last part offers Continue instead.
+func holdRest(s *discordgo.Session, guildID, channelID string, p *persona, sent []*discordgo.Message, rest string, truncated bool) {
+	id := newRequestID()
+	heldResponses.Add(id, heldResponse{guildID: guildID, channelID: channelID, persona: p, text: rest, truncated: truncated})
+	metrics.Inc("responses_held_total")
+	attachButton(s, guildID, channelID, sent, tr(guildID, "(continued via 'more')"), discordgo.Button{
+		Label:    tr(guildID, "More"),
+		Style:    discordgo.SecondaryButton,
+		CustomID: "more:" + id,
+		Emoji:    discordgo.ComponentEmoji{Name: "📜"},
+	})
+}
+
+// attachButton puts a button on the last message of a reply, or on a
+// small note of its own when that message isn't Elsie's to edit.
+func attachButton(s *discordgo.Session, guildID, channelID string, sent []*discordgo.Message, note string, button discordgo.Button) {
+	if len(sent) == 0 {
+		return
+	}
+	components := []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{button}}}
+	last := sent[len(sent)-1]
+	if last.Author != nil && last.Author.ID == s.State.User.ID {
+		_, err := s.ChannelMessageEditComplex(&discordgo.MessageEdit{ID: last.ID, Channel: channelID, Components: components})
+		if err == nil {
+			return
+		}
+		log.Printf("Error adding the %s button in %s: %v", button.Label, channelID, err)
+	}
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:    "-# " + note,
+		Components: components,
+	})
+	if err != nil {
+		log.Printf("Error posting the %s button in %s: %v", button.Label, channelID, err)
+	}
+}
+
+// moreComponent posts the held-back part of a reply, itself held back
+// again if it's still over the channel's budget.
+func moreComponent(s *discordgo.Session, i *discordgo.InteractionCreate, payload string) {
+	held, ok := heldResponses.Get(payload)
+	if !ok {
+		respondEphemeral(s, i, tr(i.GuildID, "📜 The rest of that reply has expired."))
+		return
+	}
+	heldResponses.Remove(payload)
+	removeButtons(s, i)
+
+	text, rest := splitAtBudget(held.text, channelLengthBudget(s, held.guildID, held.channelID))
+	sent, err := sendAs(s, held.channelID, held.persona, text)
+	if err != nil {
+		log.Printf("Error sending the rest of a reply in %s: %v", held.channelID, err)
+		return
+	}
+	metrics.Inc(metricLabel("responses_continued_total", "source", "held"))
+	offerNextPart(s, held.guildID, held.channelID, held.persona, sent, text, rest, held.truncated)
+}
+
+// removeButtons answers a button press by taking the buttons off the
+// pressed message, so each part is only fetched once.
+func removeButtons(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    i.Message.Content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		log.Printf("Error removing the buttons in %s: %v", i.ChannelID, err)
+	}
+}
+
+// lengthCommand is `!elsie length [#channel] [<characters>|off]`: the
+// channel's response length budget, or every channel's without arguments.
+func lengthCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Response lengths are per channel — use this command in a server channel."))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change how long my replies are."))
+		return
+	}
+	args := ctx.args
+	if len(args) == 0 {
+		lengths := loadGuildConfig(ctx.m.GuildID).ChannelLengths
+		if len(lengths) == 0 {
+			ctx.reply(ctx.tr("📏 No channel has a response length budget. Set one with `!elsie length [#channel] <characters>`."))
+			return
+		}
+		var b strings.Builder
+		b.WriteString(ctx.tr("📏 **Response length budgets**") + "\n")
+		for _, channelID := range sortedKeys(lengths) {
+			fmt.Fprintf(&b, "• <#%s> — %d\n", channelID, lengths[channelID])
+		}
+		ctx.reply(b.String())
+		return
+	}
+
+	channelID := ctx.m.ChannelID
+	if len(args) > 1 {
+		if channelID = parseChannelMention(args[0]); channelID == "" {
+			ctx.reply(ctx.tr("Mention the channel, e.g. `!elsie length #general 400`."))
+			return
+		}
+		args = args[1:]
+	}
+	budget := 0
+	if !strings.EqualFold(args[0], "off") {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < minResponseLength {
+			ctx.reply(ctx.tr("Give a length of at least %d characters, or `off`.", minResponseLength))
+			return
+		}
+		budget = n
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) {
+		if budget == 0 {
+			delete(cfg.ChannelLengths, channelID)
+			return
+		}
+		if cfg.ChannelLengths == nil {
+			cfg.ChannelLengths = map[string]int{}
+		}
+		cfg.ChannelLengths[channelID] = budget
+	})
+	if err != nil {
+		log.Printf("Error saving response length: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if budget == 0 {
+		ctx.reply(ctx.tr("📏 Replies in <#%s> can be as long as they need to be.", channelID))
+		return
+	}
+	ctx.reply(ctx.tr("📏 I'll keep replies in <#%s> to about %d characters; anything longer waits behind a **More** button.", channelID, budget))
+}
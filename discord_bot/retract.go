@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const retractionNotice = "*[This message was retracted by a moderator.]*"
+
+// retractRequest tells the agent to drop a retracted reply from the
+// session's memory.
+type retractRequest struct {
+	SessionID   string `json:"session_id"`
+	MessageID   string `json:"message_id"`
+	Content     string `json:"content"`
+	ModeratorID string `json:"moderator_id"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+func init() {
+	registerCommand(command{name: "retract", handler: retractCommand})
+}
+
+// retractCommand takes down one of Elsie's messages. Moderators reply to the
+// message with `!elsie retract [--edit] [reason]`; by default it is deleted,
+// with --edit it is replaced by a redaction notice instead.
+func retractCommand(ctx *commandContext) {
+	s, m := ctx.s, ctx.m
+	if !isModerator(s, m) {
+		ctx.reply("*shakes head* Only moderators can retract my messages.")
+		return
+	}
+	if m.MessageReference == nil || m.MessageReference.MessageID == "" {
+		ctx.reply("Reply to the message you want retracted with `!elsie retract [--edit] [reason]`.")
+		return
+	}
+
+	target := m.ReferencedMessage
+	if target == nil {
+		var err error
+		target, err = s.ChannelMessage(m.ChannelID, m.MessageReference.MessageID)
+		if err != nil {
+			log.Printf("Error fetching message to retract: %v", err)
+			ctx.reply("*squints* I can't find that message.")
+			return
+		}
+	}
+	viaWebhook := target.WebhookID != "" && isOwnWebhook(target.WebhookID)
+	if !viaWebhook && (target.Author == nil || target.Author.ID != s.State.User.ID) {
+		ctx.reply("I can only retract my own messages.")
+		return
+	}
+
+	edit := ctx.hasFlag("edit")
+	var reasonWords []string
+	for _, arg := range ctx.args {
+		if !strings.EqualFold(arg, "--edit") {
+			reasonWords = append(reasonWords, arg)
+		}
+	}
+	reason := strings.Join(reasonWords, " ")
+
+	var err error
+	switch {
+	case edit && viaWebhook:
+		// Persona messages can only be edited through their webhook.
+		notice := retractionNotice
+		err = editOwnWebhookMessage(s, target.WebhookID, m.ChannelID, target.ID, &discordgo.WebhookEdit{Content: &notice})
+	case edit:
+		_, err = s.ChannelMessageEdit(m.ChannelID, target.ID, retractionNotice)
+	default:
+		err = s.ChannelMessageDelete(m.ChannelID, target.ID)
+	}
+	if err != nil {
+		log.Printf("Error retracting message %s: %v", target.ID, err)
+		ctx.reply("*holographic matrix flickers* I couldn't retract that message — check my Manage Messages permission.")
+		return
+	}
+
+	action := "deleted"
+	if edit {
+		action = "redacted"
+	}
+	log.Printf("🗑️ RETRACTION: message %s in channel %s %s by moderator %s (reason: %q)",
+		target.ID, m.ChannelID, action, m.Author.ID, reason)
+	metrics.Inc("retractions_total")
+
+	// The reply's exchange has the session it was said in, which differs
+	// from the channel for personas and DM topics
+	persona := channelPersona(s, m.GuildID, m.ChannelID)
+	sessionID := persona.sessionID(m.ChannelID)
+	if rec, ok := replyExchange(m.GuildID, target.ID); ok && rec.AgentSessionID != "" {
+		sessionID = rec.AgentSessionID
+		if p := findPersona(rec.Persona); p != nil {
+			persona = p
+		}
+	}
+	go notifyAgentOfRetraction(persona, retractRequest{
+		SessionID:   sessionID,
+		MessageID:   target.ID,
+		Content:     target.Content,
+		ModeratorID: m.Author.ID,
+		Reason:      reason,
+	})
+	reportRetraction(s, m, target, action, reason)
+
+	// Remove the command itself so the channel is left clean.
+	s.ChannelMessageDelete(m.ChannelID, m.ID)
+}
+
+func notifyAgentOfRetraction(p *persona, req retractRequest) {
+	if _, err := poolFor(p.ID).call(newRequestID(), "/retract", req); err != nil {
+		log.Printf("Error notifying AI agent of retraction %s: %v", req.MessageID, err)
+	}
+}
+
+// reportRetraction records the retraction in the guild's mod channel, if one
+// is configured.
+func reportRetraction(s *discordgo.Session, m *discordgo.MessageCreate, target *discordgo.Message, action, reason string) {
+	cfg := loadGuildConfig(m.GuildID)
+	if cfg.ModChannelID == "" {
+		return
+	}
+	if reason == "" {
+		reason = "none given"
+	}
+	excerpt := truncateText(target.Content, 1000)
+	embed := &discordgo.MessageEmbed{
+		Title:       "🗑️ Elsie message retracted",
+		Color:       themeColor(m.GuildID, "danger"),
+		Description: excerpt,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Channel", Value: fmt.Sprintf("<#%s>", m.ChannelID), Inline: true},
+			{Name: "Moderator", Value: fmt.Sprintf("<@%s>", m.Author.ID), Inline: true},
+			{Name: "Action", Value: action, Inline: true},
+			{Name: "Reason", Value: reason},
+		},
+	}
+	if _, err := s.ChannelMessageSendEmbed(cfg.ModChannelID, embed); err != nil {
+		log.Printf("Error logging retraction to mod channel: %v", err)
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const rpSessionBucket = "rp_sessions"
+
+// RPSession is a planned RP session and the Discord scheduled event members
+// mark themselves interested in.
+type RPSession struct {
+	EventID   string    `json:"event_id"`
+	ChannelID string    `json:"channel_id"`
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	CreatedBy string    `json:"created_by"`
+	Reminded  bool      `json:"reminded,omitempty"`
+	Started   bool      `json:"started,omitempty"`
+	// AddedMonitoring is set when starting the session put the channel on
+	// the monitored list, so ending it takes the channel back off.
+	AddedMonitoring bool `json:"added_monitoring,omitempty"`
+}
+
+var (
+	// rpSessionsMu serializes read-modify-write cycles on RP sessions.
+	rpSessionsMu sync.Mutex
+
+	rpSessionSchedulerOnce sync.Once
+)
+
+func init() {
+	registerCommand(command{name: "session", handler: sessionCommand})
+	registerDataEraser(dataEraser{name: "RP sessions", guild: eraseGuildKey(rpSessionBucket)})
+}
+
+func loadRPSessions(guildID string) []RPSession {
+	var sessions []RPSession
+	if _, err := store.Get(rpSessionBucket, guildID, &sessions); err != nil {
+		log.Printf("Error loading RP sessions for guild %s: %v", guildID, err)
+	}
+	return sessions
+}
+
+func updateRPSessions(guildID string, fn func(sessions []RPSession) []RPSession) error {
+	rpSessionsMu.Lock()
+	defer rpSessionsMu.Unlock()
+	sessions := fn(loadRPSessions(guildID))
+	if len(sessions) == 0 {
+		return store.Delete(rpSessionBucket, guildID)
+	}
+	return store.Put(rpSessionBucket, guildID, sessions)
+}
+
+// takeRPSession removes the session for eventID and returns it.
+func takeRPSession(guildID, eventID string) (RPSession, bool) {
+	var taken RPSession
+	found := false
+	err := updateRPSessions(guildID, func(sessions []RPSession) []RPSession {
+		for i, sess := range sessions {
+			if sess.EventID == eventID {
+				taken, found = sess, true
+				return append(sessions[:i], sessions[i+1:]...)
+			}
+		}
+		return sessions
+	})
+	if err != nil {
+		log.Printf("Error saving RP sessions for guild %s: %v", guildID, err)
+	}
+	return taken, found
+}
+
+// startRPSessionScheduler starts the RP session loop once.
+func startRPSessionScheduler(s *discordgo.Session) {
+	rpSessionSchedulerOnce.Do(func() { go runRPSessionScheduler(s) })
+}
+
+// runRPSessionScheduler pings interested members RP_SESSION_REMINDER
+// before each session, starts it on time and ends it when it's over,
+// including sessions whose time came while the bot was down.
+func runRPSessionScheduler(s *discordgo.Session) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		checkRPSessions(s)
+		<-ticker.C
+	}
+}
+
+func checkRPSessions(s *discordgo.Session) {
+	now := time.Now()
+	for _, guildID := range store.Keys(rpSessionBucket) {
+		var remind, start, end []RPSession
+		err := updateRPSessions(guildID, func(sessions []RPSession) []RPSession {
+			kept := sessions[:0]
+			for _, sess := range sessions {
+				switch {
+				case !now.Before(sess.End):
+					end = append(end, sess)
+					continue
+				case !sess.Started && !now.Before(sess.Start):
+					sess.Started, sess.Reminded = true, true
+					start = append(start, sess)
+				case !sess.Reminded && config().RPSessionReminder > 0 && !now.Before(sess.Start.Add(-config().RPSessionReminder)):
+					sess.Reminded = true
+					remind = append(remind, sess)
+				}
+				kept = append(kept, sess)
+			}
+			return kept
+		})
+		if err != nil {
+			log.Printf("Error saving RP sessions for guild %s: %v", guildID, err)
+			continue
+		}
+		for _, sess := range remind {
+			remindRPSession(s, guildID, sess)
+		}
+		for _, sess := range start {
+			startRPSession(s, guildID, sess, true)
+		}
+		for _, sess := range end {
+			endRPSession(s, guildID, sess, true)
+		}
+	}
+}
+
+// remindRPSession pings the members interested in the session's event in
+// its channel.
+func remindRPSession(s *discordgo.Session, guildID string, sess RPSession) {
+	text := tr(guildID, "📅 **%s** starts <t:%d:R> in <#%s>!", sess.Title, sess.Start.Unix(), sess.ChannelID)
+	users, err := s.GuildScheduledEventUsers(guildID, sess.EventID, 100, false, "", "")
+	if err != nil {
+		log.Printf("Error listing interested members for event %s: %v", sess.EventID, err)
+	}
+	var pinged []string
+	for _, u := range users {
+		if u.User == nil || u.User.Bot {
+			continue
+		}
+		mention := " <@" + u.User.ID + ">"
+		if len(text)+len(mention) > 2000 {
+			break
+		}
+		text += mention
+		pinged = append(pinged, u.User.ID)
+	}
+	_, err = s.ChannelMessageSendComplex(sess.ChannelID, &discordgo.MessageSend{
+		Content:         text,
+		AllowedMentions: &discordgo.MessageAllowedMentions{Users: pinged},
+	})
+	if err != nil {
+		log.Printf("Error sending RP session reminder in %s: %v", sess.ChannelID, err)
+		return
+	}
+	log.Printf("📅 Reminded %d members of RP session %s", len(pinged), sess.EventID)
+	metrics.Inc("rp_session_reminders_total")
+}
+
+// startRPSession turns on monitoring in the session's channel. setStatus
+// also marks the Discord event active, which is skipped when the event was
+// started from Discord.
+func startRPSession(s *discordgo.Session, guildID string, sess RPSession, setStatus bool) {
+	if setStatus {
+		_, err := s.GuildScheduledEventEdit(guildID, sess.EventID, &discordgo.GuildScheduledEventParams{Status: discordgo.GuildScheduledEventStatusActive})
+		if err != nil {
+			log.Printf("Error starting scheduled event %s: %v", sess.EventID, err)
+		}
+	}
+	added := false
+	err := updateGuildConfig(guildID, s.State.User.ID, func(cfg *GuildConfig) {
+		if !slices.Contains(cfg.MonitoredChannels, sess.ChannelID) {
+			cfg.MonitoredChannels = append(cfg.MonitoredChannels, sess.ChannelID)
+			added = true
+		}
+	})
+	if err != nil {
+		log.Printf("Error monitoring %s for RP session %s: %v", sess.ChannelID, sess.EventID, err)
+	}
+	if added {
+		err = updateRPSessions(guildID, func(sessions []RPSession) []RPSession {
+			for i := range sessions {
+				if sessions[i].EventID == sess.EventID {
+					sessions[i].AddedMonitoring = true
+				}
+			}
+			return sessions
+		})
+		if err != nil {
+			log.Printf("Error saving RP sessions for guild %s: %v", guildID, err)
+		}
+	}
+	s.ChannelMessageSend(sess.ChannelID, tr(guildID, "🎭 **%s** is starting! I'm following along in here.", sess.Title))
+	log.Printf("🎭 RP session %s started in %s", sess.EventID, sess.ChannelID)
+	metrics.Inc("rp_sessions_started_total")
+}
+
+// endRPSession stops monitoring the channel if the session started it, and
+// with setStatus completes the Discord event.
+func endRPSession(s *discordgo.Session, guildID string, sess RPSession, setStatus bool) {
+	if setStatus && sess.Started {
+		_, err := s.GuildScheduledEventEdit(guildID, sess.EventID, &discordgo.GuildScheduledEventParams{Status: discordgo.GuildScheduledEventStatusCompleted})
+		if err != nil {
+			log.Printf("Error completing scheduled event %s: %v", sess.EventID, err)
+		}
+	}
+	if sess.AddedMonitoring {
+		err := updateGuildConfig(guildID, s.State.User.ID, func(cfg *GuildConfig) {
+			cfg.MonitoredChannels = slices.DeleteFunc(cfg.MonitoredChannels, func(id string) bool { return id == sess.ChannelID })
+		})
+		if err != nil {
+			log.Printf("Error unmonitoring %s after RP session %s: %v", sess.ChannelID, sess.EventID, err)
+		}
+	}
+	log.Printf("🎭 RP session %s ended", sess.EventID)
+}
+
+// guildScheduledEventUpdate follows sessions started, ended or cancelled
+// from Discord.
+func guildScheduledEventUpdate(s *discordgo.Session, e *discordgo.GuildScheduledEventUpdate) {
+	switch e.Status {
+	case discordgo.GuildScheduledEventStatusActive:
+		started := false
+		var sess RPSession
+		err := updateRPSessions(e.GuildID, func(sessions []RPSession) []RPSession {
+			for i := range sessions {
+				if sessions[i].EventID == e.ID && !sessions[i].Started {
+					sessions[i].Started, sessions[i].Reminded = true, true
+					sess, started = sessions[i], true
+				}
+			}
+			return sessions
+		})
+		if err != nil {
+			log.Printf("Error saving RP sessions for guild %s: %v", e.GuildID, err)
+			return
+		}
+		if started {
+			startRPSession(s, e.GuildID, sess, false)
+		}
+	case discordgo.GuildScheduledEventStatusCompleted, discordgo.GuildScheduledEventStatusCanceled:
+		if sess, ok := takeRPSession(e.GuildID, e.ID); ok {
+			endRPSession(s, e.GuildID, sess, false)
+		}
+	}
+}
+
+func guildScheduledEventDelete(s *discordgo.Session, e *discordgo.GuildScheduledEventDelete) {
+	if sess, ok := takeRPSession(e.GuildID, e.ID); ok {
+		endRPSession(s, e.GuildID, sess, false)
+	}
+}
+
+// parseSessionTime reads `in <duration>` or `<YYYY-MM-DD> <HH:MM>` in the
+// guild's timezone from the start of args, and returns how many arguments
+// it used.
+func parseSessionTime(args []string, loc *time.Location) (time.Time, int, bool) {
+	if len(args) > 1 && strings.EqualFold(args[0], "in") {
+		delay, used := parseReminderDelay(args[1:])
+		if used == 0 || delay <= 0 {
+			return time.Time{}, 0, false
+		}
+		return time.Now().Add(delay), 1 + used, true
+	}
+	if len(args) > 1 {
+		t, err := time.ParseInLocation("2006-01-02 15:04", args[0]+" "+args[1], loc)
+		if err == nil {
+			return t, 2, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// sessionCommand manages planned RP sessions:
+//
+//	!elsie session
+//	!elsie session schedule #channel <in 2h|YYYY-MM-DD HH:MM> [for 3h] <title>
+//	!elsie session cancel <event id>
+func sessionCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("RP sessions are per server — use this command in a server channel."))
+		return
+	}
+	usage := ctx.tr("Usage: `!elsie session`, `!elsie session schedule #channel <in 2h|YYYY-MM-DD HH:MM> [for 3h] <title>`, `!elsie session cancel <event id>`")
+	if len(ctx.args) == 0 {
+		ctx.reply(describeRPSessions(ctx.m.GuildID) + "\n" + usage)
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can schedule RP sessions."))
+		return
+	}
+	switch strings.ToLower(ctx.args[0]) {
+	case "schedule":
+		sessionSchedule(ctx, usage)
+	case "cancel":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		sess, ok := takeRPSession(ctx.m.GuildID, ctx.args[1])
+		if !ok {
+			ctx.reply(ctx.tr("📅 There's no RP session `%s`.", ctx.args[1]))
+			return
+		}
+		if err := ctx.s.GuildScheduledEventDelete(ctx.m.GuildID, sess.EventID); err != nil {
+			log.Printf("Error deleting scheduled event %s: %v", sess.EventID, err)
+		}
+		endRPSession(ctx.s, ctx.m.GuildID, sess, false)
+		ctx.reply(ctx.tr("📅 **%s** is cancelled.", sess.Title))
+	default:
+		ctx.reply(usage)
+	}
+}
+
+// sessionSchedule creates the Discord event for `session schedule`. RP
+// channels are text channels, so the event is an external one with the
+// channel as its location.
+func sessionSchedule(ctx *commandContext, usage string) {
+	args := ctx.args[1:]
+	if len(args) < 3 {
+		ctx.reply(usage)
+		return
+	}
+	channelID := parseChannelMention(args[0])
+	if channelID == "" {
+		ctx.reply(usage)
+		return
+	}
+	start, used, ok := parseSessionTime(args[1:], guildLocation(ctx.m.GuildID))
+	if !ok {
+		ctx.reply(usage)
+		return
+	}
+	args = args[1+used:]
+	length := config().RPSessionDuration
+	if len(args) > 1 && strings.EqualFold(args[0], "for") {
+		d, n := parseReminderDelay(args[1:])
+		if n == 0 || d <= 0 {
+			ctx.reply(usage)
+			return
+		}
+		length, args = d, args[1+n:]
+	}
+	title := truncateText(strings.TrimSpace(strings.Join(args, " ")), 100)
+	if title == "" {
+		ctx.reply(usage)
+		return
+	}
+	if !start.After(time.Now()) {
+		ctx.reply(ctx.tr("*checks the chronometer* That time has already passed."))
+		return
+	}
+	location := "#" + channelID
+	if channel, err := getChannel(ctx.s, channelID); err == nil {
+		location = "#" + channel.Name
+	}
+	end := start.Add(length)
+	event, err := ctx.s.GuildScheduledEventCreate(ctx.m.GuildID, &discordgo.GuildScheduledEventParams{
+		Name:               title,
+		Description:        ctx.tr("RP session in %s, scheduled by %s.", location, ctx.m.Author.Username),
+		ScheduledStartTime: &start,
+		ScheduledEndTime:   &end,
+		PrivacyLevel:       discordgo.GuildScheduledEventPrivacyLevelGuildOnly,
+		EntityType:         discordgo.GuildScheduledEventEntityTypeExternal,
+		EntityMetadata:     &discordgo.GuildScheduledEventEntityMetadata{Location: location},
+	})
+	if err != nil {
+		log.Printf("Error creating scheduled event: %v", err)
+		ctx.reply(ctx.tr("*holographic matrix flickers* I couldn't create the event — I need the Manage Events permission."))
+		return
+	}
+	sess := RPSession{
+		EventID:   event.ID,
+		ChannelID: channelID,
+		Title:     title,
+		Start:     start,
+		End:       end,
+		CreatedBy: ctx.m.Author.ID,
+	}
+	if err := updateRPSessions(ctx.m.GuildID, func(sessions []RPSession) []RPSession { return append(sessions, sess) }); err != nil {
+		log.Printf("Error saving RP sessions: %v", err)
+		ctx.s.GuildScheduledEventDelete(ctx.m.GuildID, event.ID)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	metrics.Inc("rp_sessions_scheduled_total")
+	ctx.reply(ctx.tr("📅 **%s** is on the schedule for <t:%d:F> in <#%s>. Mark yourself interested in the server's events to get a ping before it starts.", title, start.Unix(), channelID))
+}
+
+func describeRPSessions(guildID string) string {
+	sessions := loadRPSessions(guildID)
+	if len(sessions) == 0 {
+		return tr(guildID, "📅 No RP sessions are planned.")
+	}
+	slices.SortFunc(sessions, func(a, b RPSession) int { return a.Start.Compare(b.Start) })
+	var b strings.Builder
+	b.WriteString(tr(guildID, "📅 **Planned RP sessions:**") + "\n")
+	for _, sess := range sessions {
+		status := ""
+		if sess.Started {
+			status = " " + tr(guildID, "(in progress)")
+		}
+		fmt.Fprintf(&b, "• `%s` **%s** <t:%d:F> in <#%s>%s\n", sess.EventID, sess.Title, sess.Start.Unix(), sess.ChannelID, status)
+	}
+	return b.String()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	lifecycleBucket = "lifecycle"
+	bootsKey        = "boots"
+	maxBootRecords  = 10
+)
+
+// bootRecord is one process start. A boot that never shut down cleanly and
+// never ran long enough to be marked stable counts as a crash.
+type bootRecord struct {
+	Started time.Time `json:"started"`
+	Clean   bool      `json:"clean,omitempty"`
+	Stable  bool      `json:"stable,omitempty"`
+}
+
+func (b bootRecord) crashed() bool { return !b.Clean && !b.Stable }
+
+// safeMode is set when the bot started after repeated crashes. It answers
+// `!elsie` commands only: no monitoring, no agent chat, no slash commands
+// and no schedulers, so a crashing subsystem can be diagnosed while the bot
+// stays reachable.
+var safeMode atomic.Bool
+
+var (
+	bootsMu     sync.Mutex
+	bootStarted time.Time
+	// bootCrashes is how many crashed boots preceded this one.
+	bootCrashes int
+)
+
+func loadBoots() []bootRecord {
+	var boots []bootRecord
+	if _, err := store.Get(lifecycleBucket, bootsKey, &boots); err != nil {
+		log.Printf("Error loading boot history: %v", err)
+	}
+	return boots
+}
+
+// recentCrashes counts the crashed boots in a row, newest first, that
+// started within SafeModeWindow.
+func recentCrashes(boots []bootRecord) int {
+	crashes := 0
+	for i := len(boots) - 1; i >= 0; i-- {
+		b := boots[i]
+		if !b.crashed() || time.Since(b.Started) > config().SafeModeWindow {
+			break
+		}
+		crashes++
+	}
+	return crashes
+}
+
+// recordBoot logs this start in the persisted boot history and enters safe
+// mode if the previous SafeModeThreshold boots all crashed.
+func recordBoot() {
+	bootsMu.Lock()
+	defer bootsMu.Unlock()
+	boots := loadBoots()
+	bootCrashes = recentCrashes(boots)
+	if config().SafeModeThreshold > 0 && bootCrashes >= config().SafeModeThreshold {
+		safeMode.Store(true)
+		log.Printf("🛟 %d crashes in the last %s — starting in SAFE MODE", bootCrashes, config().SafeModeWindow)
+	}
+	metrics.Set("recent_crashes", float64(bootCrashes))
+
+	bootStarted = time.Now()
+	boots = append(boots, bootRecord{Started: bootStarted})
+	if len(boots) > maxBootRecords {
+		boots = boots[len(boots)-maxBootRecords:]
+	}
+	if err := store.Put(lifecycleBucket, bootsKey, boots); err != nil {
+		log.Printf("Error saving boot history: %v", err)
+	}
+	go func() {
+		time.Sleep(config().SafeModeStableAfter)
+		updateCurrentBoot(func(b *bootRecord) { b.Stable = true })
+	}()
+}
+
+// markCleanShutdown records that this boot ended on purpose.
+func markCleanShutdown() {
+	updateCurrentBoot(func(b *bootRecord) { b.Clean = true })
+}
+
+func updateCurrentBoot(fn func(b *bootRecord)) {
+	bootsMu.Lock()
+	defer bootsMu.Unlock()
+	boots := loadBoots()
+	for i := range boots {
+		if boots[i].Started.Equal(bootStarted) {
+			fn(&boots[i])
+		}
+	}
+	if err := store.Put(lifecycleBucket, bootsKey, boots); err != nil {
+		log.Printf("Error saving boot history: %v", err)
+	}
+}
+
+var safeModeOnce sync.Once
+
+// startSafeMode replaces the normal ready sequence: it alerts the admin
+// channel, runs the self-test once for diagnosis and opens the bot for
+// commands whatever the result.
+func startSafeMode(s *discordgo.Session) {
+	safeModeOnce.Do(func() {
+		go func() {
+			alert := fmt.Sprintf("🛟 **Safe mode:** I crashed %d times in the last %s, so I started with monitoring, agent chat, slash commands and schedulers disabled. `!elsie` commands still work. A bot owner can run `!elsie safemode off` once the problem is fixed.", bootCrashes, config().SafeModeWindow)
+			log.Print(alert)
+			postToAdminChannel(s, alert)
+			if config().SelfTestEnabled {
+				summary := formatSelfTest(runSelfTest(s), 1)
+				log.Print(summary)
+				postToAdminChannel(s, summary)
+			}
+			markReady(s)
+			if err := s.UpdateGameStatus(0, "🛟 Safe mode"); err != nil {
+				log.Println("Error setting status:", err)
+			}
+		}()
+	})
+}
+
+// leaveSafeMode clears the crash history and starts everything safe mode
+// held back.
+func leaveSafeMode(s *discordgo.Session) {
+	bootsMu.Lock()
+	boots := loadBoots()
+	for i := range boots {
+		boots[i].Stable = true
+	}
+	if err := store.Put(lifecycleBucket, bootsKey, boots); err != nil {
+		log.Printf("Error saving boot history: %v", err)
+	}
+	bootsMu.Unlock()
+
+	if !safeMode.Swap(false) {
+		return
+	}
+	bootCrashes = 0
+	metrics.Set("recent_crashes", 0)
+	startDigestScheduler(s)
+	startFollowUpScheduler(s)
+	startReminderScheduler(s)
+	startPollScheduler(s)
+	startDrinkOfTheDayScheduler(s)
+	startPresenceRotation(s)
+	startEventScheduler(s)
+	startRPSessionScheduler(s)
+	startThreadArchiveWatcher(s)
+	if err := s.UpdateGameStatus(0, normalStatus()); err != nil {
+		log.Println("Error setting status:", err)
+	}
+	log.Printf("🛟 Safe mode lifted")
+}
+
+func init() {
+	registerCommand(command{name: "safemode", handler: safeModeCommand})
+}
+
+// safeModeCommand is the owner-only `!elsie safemode [on|off]`.
+func safeModeCommand(ctx *commandContext) {
+	if !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*shakes head* Only my operators can change safe mode.")
+		return
+	}
+	sub := ""
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	switch sub {
+	case "":
+		state := "off"
+		if safeMode.Load() {
+			state = "**on**"
+		}
+		ctx.reply(fmt.Sprintf("🛟 Safe mode is %s. Recent crashes: %d (threshold %d within %s).\nUsage: `!elsie safemode on|off`",
+			state, bootCrashes, config().SafeModeThreshold, config().SafeModeWindow))
+	case "off":
+		leaveSafeMode(ctx.s)
+		ctx.reply("🛟 Safe mode is off and the crash history is cleared. Back to full service.")
+	case "on":
+		safeMode.Store(true)
+		if err := ctx.s.UpdateGameStatus(0, "🛟 Safe mode"); err != nil {
+			log.Println("Error setting status:", err)
+		}
+		ctx.reply("🛟 Safe mode is on: I'll only answer `!elsie` commands. Schedulers keep running until the next restart.")
+	default:
+		ctx.reply("Usage: `!elsie safemode [on|off]`")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sceneBucket = "scenes"
+
+// Scene is the per-channel (or per-thread) state of an RP scene.
+type Scene struct {
+	RulesProfile string `json:"rules_profile,omitempty"`
+	CustomDice   string `json:"custom_dice,omitempty"`
+	CustomHints  string `json:"custom_hints,omitempty"`
+
+	// Active scenes were opened with `scene start`. OOCThreadID is the
+	// paired OOC thread; that thread's own record points back through
+	// SceneThreadID.
+	Active        bool      `json:"active,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	StartedAt     time.Time `json:"started_at,omitempty"`
+	StartedBy     string    `json:"started_by,omitempty"`
+	OOCThreadID   string    `json:"ooc_thread_id,omitempty"`
+	SceneThreadID string    `json:"scene_thread_id,omitempty"`
+
+	// PauseMarkerID is the "scene paused" marker posted before the thread
+	// auto-archived; ArchivedAt is set while the thread is archived.
+	PauseMarkerID string    `json:"pause_marker_id,omitempty"`
+	ArchivedAt    time.Time `json:"archived_at,omitempty"`
+}
+
+// sceneMu serializes read-modify-write cycles on scenes.
+var sceneMu sync.Mutex
+
+// loadScene returns the scene for channelID, or an empty scene.
+func loadScene(channelID string) *Scene {
+	sc := &Scene{}
+	if _, err := store.Get(sceneBucket, channelID, sc); err != nil {
+		log.Printf("Error loading scene for %s: %v", channelID, err)
+		return &Scene{}
+	}
+	return sc
+}
+
+// updateScene applies fn to the channel's scene and persists it.
+func updateScene(channelID string, fn func(sc *Scene)) error {
+	sceneMu.Lock()
+	defer sceneMu.Unlock()
+	sc := loadScene(channelID)
+	fn(sc)
+	return store.Put(sceneBucket, channelID, sc)
+}
+
+// sceneSubcommands are the `!elsie scene <name>` handlers. Scene features
+// register theirs from init, like top-level commands.
+var sceneSubcommands = map[string]func(ctx *commandContext){}
+
+func registerSceneSubcommand(name string, handler func(ctx *commandContext)) {
+	sceneSubcommands[name] = handler
+}
+
+func init() {
+	registerCommand(command{name: "scene", handler: sceneCommand})
+}
+
+// sceneCommand dispatches `!elsie scene <subcommand> ...`, passing the
+// remaining arguments on.
+func sceneCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Scenes live in server channels — use this command there.")
+		return
+	}
+	var names []string
+	for name := range sceneSubcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	usage := "Usage: `!elsie scene <" + strings.Join(names, "|") + "> ...`"
+	if len(ctx.args) == 0 {
+		ctx.reply(usage)
+		return
+	}
+	handler, ok := sceneSubcommands[strings.ToLower(ctx.args[0])]
+	if !ok {
+		ctx.reply(usage)
+		return
+	}
+	sub := *ctx
+	sub.name = strings.ToLower(ctx.args[0])
+	sub.args = ctx.args[1:]
+	sub.raw = strings.TrimSpace(strings.TrimPrefix(ctx.raw, ctx.args[0]))
+	handler(&sub)
+}
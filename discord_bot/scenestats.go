@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const sceneArchiveBucket = "scene_archive"
+
+// maxSceneArchive is how many closed scenes are kept per guild.
+const maxSceneArchive = 200
+
+// maxSceneStatsMessages caps how much history is read when a scene closes.
+const maxSceneStatsMessages = 5000
+
+// discordEpochMillis is the start of Discord's snowflake clock.
+const discordEpochMillis = 1420070400000
+
+// SceneArchive is the record of a closed scene, kept for DGM review.
+type SceneArchive struct {
+	ChannelID string     `json:"channel_id"`
+	Title     string     `json:"title,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	StartedBy string     `json:"started_by,omitempty"`
+	ClosedAt  time.Time  `json:"closed_at"`
+	ClosedBy  string     `json:"closed_by"`
+	Stats     SceneStats `json:"stats"`
+
+	// StartStardate and CloseStardate stamp the scene on the guild's
+	// calendar; scenes archived before stardates were kept have neither.
+	StartStardate string `json:"start_stardate,omitempty"`
+	CloseStardate string `json:"close_stardate,omitempty"`
+}
+
+// SceneStats summarize participation in one scene. OOC messages are left
+// out; the bot's own posts, including persona webhooks, count as
+// interjections.
+type SceneStats struct {
+	Posts         int           `json:"posts"`
+	Interjections int           `json:"interjections"`
+	Players       []PlayerStats `json:"players,omitempty"`
+	Truncated     bool          `json:"truncated,omitempty"`
+}
+
+// PlayerStats is one player's share of a scene. AvgGap is the average time
+// between someone else's post and the player's reply to it.
+type PlayerStats struct {
+	UserID string        `json:"user_id"`
+	Posts  int           `json:"posts"`
+	AvgGap time.Duration `json:"avg_gap"`
+}
+
+func init() {
+	registerSceneSubcommand("stats", sceneStatsCommand)
+}
+
+// snowflakeAt returns the smallest snowflake for t, for paging history
+// from a point in time.
+func snowflakeAt(t time.Time) string {
+	return strconv.FormatInt((t.UnixMilli()-discordEpochMillis)<<22, 10)
+}
+
+// computeSceneStats reads the scene's history since it started and tallies
+// posts, reply gaps and bot interjections.
+func computeSceneStats(s *discordgo.Session, channelID string, since time.Time) SceneStats {
+	var history []*discordgo.Message
+	after := snowflakeAt(since)
+	truncated := false
+	for {
+		batch, err := s.ChannelMessages(channelID, 100, "", after, "")
+		if err != nil {
+			log.Printf("Error reading scene history in %s: %v", channelID, err)
+			break
+		}
+		if len(batch) == 0 {
+			break
+		}
+		// Pages come newest first
+		for i := len(batch) - 1; i >= 0; i-- {
+			history = append(history, batch[i])
+		}
+		after = batch[0].ID
+		if len(history) >= maxSceneStatsMessages {
+			truncated = true
+			break
+		}
+		if len(batch) < 100 {
+			break
+		}
+	}
+	stats := sceneStatsFrom(s.State.User.ID, history)
+	stats.Truncated = truncated
+	return stats
+}
+
+// sceneStatsFrom tallies oldest-first history.
+func sceneStatsFrom(botID string, history []*discordgo.Message) SceneStats {
+	var stats SceneStats
+	type tally struct {
+		posts, gaps int
+		gapTotal    time.Duration
+	}
+	players := map[string]*tally{}
+	var prevAuthor string
+	var prevTime time.Time
+	for _, msg := range history {
+		if msg.Author == nil || msg.Type != discordgo.MessageTypeDefault && msg.Type != discordgo.MessageTypeReply {
+			continue
+		}
+		if _, ooc := parseOOC(msg.Content); ooc {
+			continue
+		}
+		author := msg.Author.ID
+		switch {
+		case author == botID || (msg.WebhookID != "" && isOwnWebhook(msg.WebhookID)):
+			stats.Interjections++
+			author = botID
+		case msg.Author.Bot:
+			continue
+		default:
+			t := players[author]
+			if t == nil {
+				t = &tally{}
+				players[author] = t
+			}
+			t.posts++
+			stats.Posts++
+			if prevAuthor != "" && prevAuthor != author {
+				t.gaps++
+				t.gapTotal += msg.Timestamp.Sub(prevTime)
+			}
+		}
+		prevAuthor, prevTime = author, msg.Timestamp
+	}
+
+	for id, t := range players {
+		ps := PlayerStats{UserID: id, Posts: t.posts}
+		if t.gaps > 0 {
+			ps.AvgGap = (t.gapTotal / time.Duration(t.gaps)).Round(time.Second)
+		}
+		stats.Players = append(stats.Players, ps)
+	}
+	sort.Slice(stats.Players, func(i, j int) bool {
+		if stats.Players[i].Posts != stats.Players[j].Posts {
+			return stats.Players[i].Posts > stats.Players[j].Posts
+		}
+		return stats.Players[i].UserID < stats.Players[j].UserID
+	})
+	return stats
+}
+
+// archiveScene adds a closed scene to the guild's archive, dropping the
+// oldest entries past maxSceneArchive.
+func archiveScene(guildID string, entry SceneArchive) error {
+	sceneMu.Lock()
+	defer sceneMu.Unlock()
+	var archive []SceneArchive
+	if _, err := store.Get(sceneArchiveBucket, guildID, &archive); err != nil {
+		return err
+	}
+	archive = append(archive, entry)
+	if len(archive) > maxSceneArchive {
+		archive = archive[len(archive)-maxSceneArchive:]
+	}
+	return store.Put(sceneArchiveBucket, guildID, archive)
+}
+
+func loadSceneArchive(guildID string) []SceneArchive {
+	var archive []SceneArchive
+	if _, err := store.Get(sceneArchiveBucket, guildID, &archive); err != nil {
+		log.Printf("Error loading scene archive for guild %s: %v", guildID, err)
+	}
+	return archive
+}
+
+// sceneStatsEmbed shows one closed scene's stats.
+func sceneStatsEmbed(guildID string, entry SceneArchive) *discordgo.MessageEmbed {
+	var lines []string
+	for _, p := range entry.Stats.Players {
+		gap := "—"
+		if p.AvgGap > 0 {
+			gap = p.AvgGap.String()
+		}
+		lines = append(lines, fmt.Sprintf("<@%s> — %d posts, replies after %s on average", p.UserID, p.Posts, gap))
+	}
+	if len(lines) == 0 {
+		lines = []string{"No player posts."}
+	}
+	title := entry.Title
+	if title == "" {
+		title = "Untitled scene"
+	}
+	footer := fmt.Sprintf("%d player posts • %d interjections from me • ran %s",
+		entry.Stats.Posts, entry.Stats.Interjections, entry.ClosedAt.Sub(entry.StartedAt).Round(time.Minute))
+	if entry.StartStardate != "" {
+		footer = fmt.Sprintf("Stardate %s–%s • ", entry.StartStardate, entry.CloseStardate) + footer
+	}
+	if entry.Stats.Truncated {
+		footer += fmt.Sprintf(" • first %d messages only", maxSceneStatsMessages)
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "📊 " + title,
+		Description: truncateText(strings.Join(lines, "\n"), 4000),
+		Color:       themeColor(guildID, "info"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: footer},
+	}
+}
+
+// sceneStatsCommand is `!elsie scene stats [scenes]`: post counts and
+// reply gaps across the guild's last closed scenes (10 by default), so DGMs
+// can see who is getting the spotlight over a campaign.
+func sceneStatsCommand(ctx *commandContext) {
+	if !isModerator(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only moderators can review scene statistics.")
+		return
+	}
+	n := 10
+	if len(ctx.args) > 0 {
+		v, err := strconv.Atoi(ctx.args[0])
+		if err != nil || v < 1 {
+			ctx.reply("Usage: `!elsie scene stats [number of scenes]`")
+			return
+		}
+		n = v
+	}
+	archive := loadSceneArchive(ctx.m.GuildID)
+	if len(archive) == 0 {
+		ctx.reply("📊 No closed scenes yet. Stats are recorded when a scene is closed with `!elsie scene close`.")
+		return
+	}
+	if len(archive) > n {
+		archive = archive[len(archive)-n:]
+	}
+	if len(archive) == 1 {
+		ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, sceneStatsEmbed(ctx.m.GuildID, archive[0]))
+		return
+	}
+
+	type total struct {
+		posts, scenes, gaps int
+		gapTotal            time.Duration
+	}
+	totals := map[string]*total{}
+	interjections := 0
+	for _, entry := range archive {
+		interjections += entry.Stats.Interjections
+		for _, p := range entry.Stats.Players {
+			t := totals[p.UserID]
+			if t == nil {
+				t = &total{}
+				totals[p.UserID] = t
+			}
+			t.posts += p.Posts
+			t.scenes++
+			if p.AvgGap > 0 {
+				t.gaps++
+				t.gapTotal += p.AvgGap
+			}
+		}
+	}
+	ids := make([]string, 0, len(totals))
+	allPosts := 0
+	for id, t := range totals {
+		ids = append(ids, id)
+		allPosts += t.posts
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if totals[ids[i]].posts != totals[ids[j]].posts {
+			return totals[ids[i]].posts > totals[ids[j]].posts
+		}
+		return ids[i] < ids[j]
+	})
+	var lines []string
+	for _, id := range ids {
+		t := totals[id]
+		gap := "—"
+		if t.gaps > 0 {
+			gap = (t.gapTotal / time.Duration(t.gaps)).Round(time.Second).String()
+		}
+		share := 0
+		if allPosts > 0 {
+			share = t.posts * 100 / allPosts
+		}
+		lines = append(lines, fmt.Sprintf("<@%s> — %d posts (%d%%) in %d scenes, replies after %s", id, t.posts, share, t.scenes, gap))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📊 Spotlight over the last %d scenes", len(archive)),
+		Description: truncateText(strings.Join(lines, "\n"), 4000),
+		Color:       themeColor(ctx.m.GuildID, "info"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%d player posts • %d interjections from me", allPosts, interjections)},
+	}
+	if _, err := ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, embed); err != nil {
+		log.Printf("Error posting scene stats: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// oocThreadArchiveMinutes is how long a paired OOC thread stays open
+// without activity, matching Discord's one-day auto-archive option.
+const oocThreadArchiveMinutes = 1440
+
+func init() {
+	registerSceneSubcommand("start", sceneStartCommand)
+	registerSceneSubcommand("close", sceneCloseCommand)
+	registerSceneSubcommand("pairing", scenePairingCommand)
+}
+
+// canRunScene reports whether the author may start or close the scene in
+// channel: moderators, and whoever opened the thread.
+func canRunScene(ctx *commandContext, channel *discordgo.Channel) bool {
+	return channel.OwnerID == ctx.m.Author.ID || isModerator(ctx.s, ctx.m)
+}
+
+// sceneStartCommand is `!elsie scene start [--ooc|--no-ooc] [title]`. In a
+// thread, it opens a paired OOC thread when the guild pairs threads by
+// default or --ooc is given.
+func sceneStartCommand(ctx *commandContext) {
+	channel, err := getChannel(ctx.s, ctx.m.ChannelID)
+	if err != nil {
+		log.Printf("Error loading channel %s: %v", ctx.m.ChannelID, err)
+		ctx.reply("*holographic matrix flickers* I couldn't look up this channel.")
+		return
+	}
+	if !canRunScene(ctx, channel) {
+		ctx.reply("*shakes head* Only moderators or the thread's creator can start a scene here.")
+		return
+	}
+	if sc := loadScene(ctx.m.ChannelID); sc.Active {
+		ctx.reply("🎬 A scene is already running here. End it with `!elsie scene close`.")
+		return
+	}
+
+	pair := loadGuildConfig(ctx.m.GuildID).PairOOCThreads
+	var titleWords []string
+	for _, arg := range ctx.args {
+		switch strings.ToLower(arg) {
+		case "--ooc":
+			pair = true
+		case "--no-ooc":
+			pair = false
+		default:
+			titleWords = append(titleWords, arg)
+		}
+	}
+	title := strings.Join(titleWords, " ")
+	if title == "" {
+		title = channel.Name
+	}
+
+	oocThreadID := ""
+	if pair {
+		if !isThreadChannel(channel) {
+			ctx.reply("💬 OOC threads can only be paired with a scene that runs in a thread; starting without one.")
+		} else if thread, err := ctx.s.ThreadStart(channel.ParentID, truncateText("OOC: "+title, 100),
+			discordgo.ChannelTypeGuildPublicThread, oocThreadArchiveMinutes); err != nil {
+			log.Printf("Error creating OOC thread for scene %s: %v", ctx.m.ChannelID, err)
+			ctx.reply("⚠️ I couldn't open an OOC thread (I need Create Public Threads); starting without one.")
+		} else {
+			oocThreadID = thread.ID
+		}
+	}
+
+	err = updateScene(ctx.m.ChannelID, func(sc *Scene) {
+		sc.Active, sc.Title, sc.StartedAt, sc.StartedBy = true, title, time.Now().UTC(), ctx.m.Author.ID
+		sc.OOCThreadID = oocThreadID
+	})
+	if err == nil && oocThreadID != "" {
+		err = updateScene(oocThreadID, func(sc *Scene) { sc.SceneThreadID = ctx.m.ChannelID })
+	}
+	if err != nil {
+		log.Printf("Error saving scene %s: %v", ctx.m.ChannelID, err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+
+	reply := fmt.Sprintf("🎬 **Scene started:** %s — stardate %s", title, formatStardate(stardateOf(ctx.m.GuildID, time.Now())))
+	if oocThreadID != "" {
+		reply += fmt.Sprintf("\n💬 Out-of-character chatter — `((like this))` or `ooc: like this` — moves to <#%s>.", oocThreadID)
+		if _, err := ctx.s.ChannelMessageSend(oocThreadID, fmt.Sprintf("💬 OOC for the scene in <#%s>. This thread closes with the scene.", ctx.m.ChannelID)); err != nil {
+			log.Printf("Error posting in OOC thread %s: %v", oocThreadID, err)
+		}
+	}
+	ctx.reply(reply)
+}
+
+// sceneCloseCommand is `!elsie scene close`, from the scene or its OOC
+// thread. Both threads are archived and locked together, and the scene's
+// participation stats go into the guild's scene archive.
+func sceneCloseCommand(ctx *commandContext) {
+	channel, err := getChannel(ctx.s, ctx.m.ChannelID)
+	if err != nil {
+		log.Printf("Error loading channel %s: %v", ctx.m.ChannelID, err)
+		ctx.reply("*holographic matrix flickers* I couldn't look up this channel.")
+		return
+	}
+	sceneID := ctx.m.ChannelID
+	if paired := loadScene(sceneID).SceneThreadID; paired != "" {
+		sceneID = paired
+	}
+	sc := loadScene(sceneID)
+	if !sc.Active {
+		ctx.reply("🎬 There's no scene running here.")
+		return
+	}
+	if sceneID != ctx.m.ChannelID {
+		if channel, err = getChannel(ctx.s, sceneID); err != nil {
+			log.Printf("Error loading scene channel %s: %v", sceneID, err)
+			ctx.reply("*holographic matrix flickers* I couldn't look up the scene's channel.")
+			return
+		}
+	}
+	if !canRunScene(ctx, channel) {
+		ctx.reply("*shakes head* Only moderators or the thread's creator can close this scene.")
+		return
+	}
+
+	// Stats are read from history before the threads are archived
+	entry := SceneArchive{ChannelID: sceneID, Title: sc.Title, StartedAt: sc.StartedAt, StartedBy: sc.StartedBy, ClosedAt: time.Now().UTC(), ClosedBy: ctx.m.Author.ID}
+	if !sc.StartedAt.IsZero() {
+		entry.Stats = computeSceneStats(ctx.s, sceneID, sc.StartedAt)
+		entry.StartStardate = formatStardate(stardateOf(ctx.m.GuildID, sc.StartedAt))
+		entry.CloseStardate = formatStardate(stardateOf(ctx.m.GuildID, entry.ClosedAt))
+	}
+
+	oocThreadID := sc.OOCThreadID
+	err = updateScene(sceneID, func(sc *Scene) {
+		sc.Active, sc.Title, sc.StartedAt, sc.StartedBy, sc.OOCThreadID = false, "", time.Time{}, "", ""
+		sc.PauseMarkerID, sc.ArchivedAt = "", time.Time{}
+	})
+	if err == nil && oocThreadID != "" {
+		err = store.Delete(sceneBucket, oocThreadID)
+	}
+	if err != nil {
+		log.Printf("Error closing scene %s: %v", sceneID, err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+
+	if err := archiveScene(ctx.m.GuildID, entry); err != nil {
+		log.Printf("Error archiving scene %s: %v", sceneID, err)
+	}
+	// Posted before the threads are locked, which posting would undo
+	if !entry.StartedAt.IsZero() {
+		if _, err := ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, sceneStatsEmbed(ctx.m.GuildID, entry)); err != nil {
+			log.Printf("Error posting scene stats: %v", err)
+		}
+	}
+
+	note := fmt.Sprintf("🎬 **Scene closed:** %s", sc.Title)
+	for _, id := range []string{oocThreadID, sceneID} {
+		if id == "" {
+			continue
+		}
+		if _, err := ctx.s.ChannelMessageSend(id, note); err != nil {
+			log.Printf("Error announcing scene close in %s: %v", id, err)
+		}
+		if ch, err := getChannel(ctx.s, id); err != nil || !isThreadChannel(ch) {
+			continue
+		}
+		archived, locked := true, true
+		if _, err := ctx.s.ChannelEdit(id, &discordgo.ChannelEdit{Archived: &archived, Locked: &locked}); err != nil {
+			log.Printf("Error archiving thread %s: %v", id, err)
+		}
+	}
+	if sceneID != ctx.m.ChannelID && oocThreadID != ctx.m.ChannelID {
+		ctx.reply(note)
+	}
+}
+
+// scenePairingCommand is `!elsie scene pairing [on|off]`: whether scenes
+// started in threads get a paired OOC thread by default.
+func scenePairingCommand(ctx *commandContext) {
+	if len(ctx.args) == 0 {
+		state := "off"
+		if loadGuildConfig(ctx.m.GuildID).PairOOCThreads {
+			state = "on"
+		}
+		ctx.reply("💬 **OOC thread pairing:** " + state + "\nUsage: `!elsie scene pairing on|off`")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change OOC thread pairing.")
+		return
+	}
+	var on bool
+	switch strings.ToLower(ctx.args[0]) {
+	case "on":
+		on = true
+	case "off":
+	default:
+		ctx.reply("Usage: `!elsie scene pairing on|off`")
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.PairOOCThreads = on }); err != nil {
+		log.Printf("Error saving OOC pairing: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if on {
+		ctx.reply("💬 Scenes started in threads will get a paired OOC thread.")
+	} else {
+		ctx.reply("💬 Scenes won't get OOC threads unless started with `--ooc`.")
+	}
+}
+
+// routeSceneOOC moves an out-of-character message from a scene with a
+// paired OOC thread into that thread, quoted under the author's name, and
+// removes it from the scene. It reports whether the message was moved.
+func routeSceneOOC(s *discordgo.Session, m *discordgo.MessageCreate) bool {
+	text, ok := parseOOC(m.Content)
+	if !ok || text == "" {
+		return false
+	}
+	sc := loadScene(m.ChannelID)
+	if !sc.Active || sc.OOCThreadID == "" {
+		return false
+	}
+
+	hook, threadID, err := channelWebhook(s, sc.OOCThreadID)
+	if err != nil {
+		log.Printf("OOC thread webhook unavailable for %s: %v", sc.OOCThreadID, err)
+		return false
+	}
+	name := m.Author.Username
+	if m.Member != nil && m.Member.Nick != "" {
+		name = m.Member.Nick
+	}
+	params := &discordgo.WebhookParams{
+		Content:         truncateText(text, 2000),
+		Username:        name,
+		AvatarURL:       m.Author.AvatarURL(""),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	if threadID != "" {
+		_, err = s.WebhookThreadExecute(hook.ID, hook.Token, true, threadID, params)
+	} else {
+		_, err = s.WebhookExecute(hook.ID, hook.Token, true, params)
+	}
+	if err != nil {
+		log.Printf("Error quoting OOC message into %s: %v", sc.OOCThreadID, err)
+		forgetChannelWebhook(s, sc.OOCThreadID)
+		return false
+	}
+	metrics.Inc("scene_ooc_routed_total")
+	if err := s.ChannelMessageDelete(m.ChannelID, m.ID); err != nil {
+		log.Printf("Error removing OOC message from scene %s: %v", m.ChannelID, err)
+	}
+	return true
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	// Embedded zone data, so guild timezones resolve on slim images
+	// without a system tz database.
+	_ "time/tzdata"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const scheduleBucket = "schedules"
+
+// eventKind is a built-in recurring event: what the agent is asked to
+// announce, and what Elsie says if the agent can't be reached.
+type eventKind struct {
+	Title    string
+	Prompt   string
+	Fallback string
+}
+
+var eventKinds = map[string]eventKind{
+	"happy_hour": {
+		Title:    "Happy hour",
+		Prompt:   "Announce to the bar that happy hour has just started, in character and in a sentence or two.",
+		Fallback: "🍹 *Elsie rings the bell over the bar.* Happy hour has begun — the synthehol's on special!",
+	},
+	"trivia": {
+		Title:    "Trivia night",
+		Prompt:   "Announce that the weekly trivia night is starting and invite everyone to play, in character and in a sentence or two.",
+		Fallback: "🧠 *Elsie taps a glass.* Trivia night is starting! Grab a drink and a seat.",
+	},
+	"last_call": {
+		Title:    "Last call",
+		Prompt:   "Announce last call at the bar, in character and in a sentence or two.",
+		Fallback: "🔔 *Elsie dims the lights a notch.* Last call, everyone!",
+	},
+	"custom": {
+		Title:    "Scheduled event",
+		Fallback: "📅 *Elsie checks the schedule.* It's that time again!",
+	},
+}
+
+// ScheduledEvent is one recurring guild event.
+type ScheduledEvent struct {
+	ID        string    `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	Cron      string    `json:"cron"`
+	Kind      string    `json:"kind"`
+	Prompt    string    `json:"prompt,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+}
+
+// prompt is what the agent is asked to say.
+func (e ScheduledEvent) prompt() string {
+	if e.Prompt != "" {
+		return e.Prompt
+	}
+	return eventKinds[e.Kind].Prompt
+}
+
+// schedulesMu serializes read-modify-write cycles on schedules.
+var schedulesMu sync.Mutex
+
+func loadSchedules(guildID string) []ScheduledEvent {
+	var events []ScheduledEvent
+	if _, err := store.Get(scheduleBucket, guildID, &events); err != nil {
+		log.Printf("Error loading schedules for guild %s: %v", guildID, err)
+	}
+	return events
+}
+
+func updateSchedules(guildID string, fn func(events []ScheduledEvent) []ScheduledEvent) error {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	return store.Put(scheduleBucket, guildID, fn(loadSchedules(guildID)))
+}
+
+// guildLocation returns the guild's configured timezone, or UTC.
+func guildLocation(guildID string) *time.Location {
+	if tz := loadGuildConfig(guildID).Timezone; tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+var eventSchedulerOnce sync.Once
+
+// startEventScheduler starts the recurring event loop once.
+func startEventScheduler(s *discordgo.Session) {
+	eventSchedulerOnce.Do(func() { go runEventScheduler(s) })
+}
+
+// runEventScheduler wakes at the top of every minute and runs the events
+// whose cron expression matches that minute in their guild's timezone.
+func runEventScheduler(s *discordgo.Session) {
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		minute := time.Now().Truncate(time.Minute)
+		for _, guildID := range store.Keys(scheduleBucket) {
+			local := minute.In(guildLocation(guildID))
+			due := map[string]ScheduledEvent{}
+			for _, e := range loadSchedules(guildID) {
+				if spec, err := parseCron(e.Cron); err == nil && spec.matches(local) && e.LastRun.Before(minute) {
+					due[e.ID] = e
+				}
+			}
+			if len(due) == 0 {
+				continue
+			}
+			err := updateSchedules(guildID, func(events []ScheduledEvent) []ScheduledEvent {
+				for i := range events {
+					if _, ok := due[events[i].ID]; ok {
+						events[i].LastRun = minute
+					}
+				}
+				return events
+			})
+			if err != nil {
+				log.Printf("Error updating schedules for guild %s: %v", guildID, err)
+				continue
+			}
+			for _, e := range due {
+				go runScheduledEvent(s, guildID, e)
+			}
+		}
+	}
+}
+
+// runScheduledEvent asks the agent for the event's announcement and posts
+// it as the channel's persona, falling back to a canned line.
+func runScheduledEvent(s *discordgo.Session, guildID string, e ScheduledEvent) error {
+	rlog := requestLog{id: newRequestID()}
+	if refusesChannel(s, guildID, e.ChannelID) {
+		rlog.Printf("📅 Skipping event %s: channel refused by guild policy", e.ID)
+		return nil
+	}
+	p := channelPersona(s, guildID, e.ChannelID)
+	kind := eventKinds[e.Kind]
+
+	text := kind.Fallback
+	ctx := baseContext(s, e.ChannelID, guildID, nil)
+	ctx["session_id"] = p.sessionID(e.ChannelID)
+	ctx["request_id"] = rlog.id
+	ctx["persona"] = p.ID
+	ctx["intent"] = "scheduled_event"
+	ctx["event"] = map[string]interface{}{
+		"id":   e.ID,
+		"kind": e.Kind,
+		"name": kind.Title,
+		"cron": e.Cron,
+	}
+	resp, err := callAgent(Message{Message: e.prompt(), Context: ctx, RequestID: rlog.id, Persona: p.ID})
+	switch {
+	case err != nil:
+		rlog.Printf("Error generating scheduled event %s: %v", e.ID, err)
+	case resp.silent():
+		return nil
+	case strings.TrimSpace(resp.Response) != "":
+		if screened, ok := screenContent(s, guildID, e.ChannelID, "", "outbound", resp.Response); ok {
+			text = screened
+		}
+	}
+
+	if _, err := sendAs(s, e.ChannelID, p, text); err != nil {
+		rlog.Printf("Error posting scheduled event %s: %v", e.ID, err)
+		return err
+	}
+	rlog.Printf("📅 Scheduled event %s (%s) posted in %s", e.ID, e.Kind, e.ChannelID)
+	metrics.Inc(metricLabel("scheduled_events_total", "kind", e.Kind))
+	return nil
+}
+
+func newEventID() string {
+	b := make([]byte, 3)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func init() {
+	registerCommand(command{name: "schedule", handler: scheduleCommand})
+}
+
+// scheduleCommand manages recurring events:
+//
+//	!elsie schedule
+//	!elsie schedule add #channel <cron> <happy_hour|trivia|last_call|custom> [prompt]
+//	!elsie schedule remove <id>
+//	!elsie schedule run <id>
+//	!elsie schedule timezone <Area/City>
+func scheduleCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Schedules are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can manage the bar's schedule.")
+		return
+	}
+	usage := "Usage: `!elsie schedule add #channel <cron|@daily|@weekly> <" + strings.Join(sortedKeys(eventKinds), "|") +
+		"> [prompt]`, `!elsie schedule remove <id>`, `!elsie schedule run <id>`, `!elsie schedule timezone <Area/City>`\n" +
+		"Cron fields are minute hour day month weekday, e.g. `0 18 * * 5` for Fridays at 18:00."
+	if len(ctx.args) == 0 {
+		ctx.reply(describeSchedules(ctx.m.GuildID) + "\n" + usage)
+		return
+	}
+
+	switch strings.ToLower(ctx.args[0]) {
+	case "add":
+		scheduleAdd(ctx, usage)
+	case "remove":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		id := strings.ToLower(ctx.args[1])
+		removed := false
+		err := updateSchedules(ctx.m.GuildID, func(events []ScheduledEvent) []ScheduledEvent {
+			for i, e := range events {
+				if e.ID == id {
+					removed = true
+					return append(events[:i], events[i+1:]...)
+				}
+			}
+			return events
+		})
+		switch {
+		case err != nil:
+			log.Printf("Error saving schedules: %v", err)
+			ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		case !removed:
+			ctx.reply(fmt.Sprintf("📅 There's no event `%s`.", id))
+		default:
+			ctx.reply(fmt.Sprintf("📅 Event `%s` removed.", id))
+		}
+	case "run":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		id := strings.ToLower(ctx.args[1])
+		for _, e := range loadSchedules(ctx.m.GuildID) {
+			if e.ID == id {
+				if err := runScheduledEvent(ctx.s, ctx.m.GuildID, e); err != nil {
+					ctx.reply("*holographic matrix flickers* I couldn't post that event — check my permissions in its channel.")
+				}
+				return
+			}
+		}
+		ctx.reply(fmt.Sprintf("📅 There's no event `%s`.", id))
+	case "timezone":
+		if len(ctx.args) < 2 {
+			ctx.reply(usage)
+			return
+		}
+		tz := ctx.args[1]
+		if _, err := time.LoadLocation(tz); err != nil {
+			ctx.reply("*squints* I don't know that timezone. Use a name like `America/New_York` or `Europe/London`.")
+			return
+		}
+		if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.Timezone = tz }); err != nil {
+			log.Printf("Error saving timezone: %v", err)
+			ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+			return
+		}
+		ctx.reply(fmt.Sprintf("📅 Events will follow **%s** time.", tz))
+	default:
+		ctx.reply(usage)
+	}
+}
+
+// scheduleAdd parses `add #channel <cron> <kind> [prompt]`. The cron
+// expression is either a shortcut like @daily or the next five arguments.
+func scheduleAdd(ctx *commandContext, usage string) {
+	args := ctx.args[1:]
+	if len(args) < 3 {
+		ctx.reply(usage)
+		return
+	}
+	channelID := parseChannelMention(args[0])
+	if channelID == "" {
+		ctx.reply("Mention the channel, e.g. `!elsie schedule add #ten-forward 0 18 * * 5 happy_hour`.")
+		return
+	}
+	cronFields := 5
+	if strings.HasPrefix(args[1], "@") {
+		cronFields = 1
+	}
+	if len(args) < 1+cronFields+1 {
+		ctx.reply(usage)
+		return
+	}
+	expr := strings.Join(args[1:1+cronFields], " ")
+	spec, err := parseCron(expr)
+	if err != nil {
+		ctx.reply(fmt.Sprintf("*squints at the calendar* %v.", err))
+		return
+	}
+	kind := strings.ToLower(args[1+cronFields])
+	if _, ok := eventKinds[kind]; !ok {
+		ctx.reply(usage)
+		return
+	}
+	prompt := strings.Join(args[2+cronFields:], " ")
+	if kind == "custom" && prompt == "" {
+		ctx.reply("Custom events need a prompt telling me what to announce.")
+		return
+	}
+
+	event := ScheduledEvent{
+		ID:        newEventID(),
+		ChannelID: channelID,
+		Cron:      expr,
+		Kind:      kind,
+		Prompt:    prompt,
+		CreatedBy: ctx.m.Author.ID,
+	}
+	err = updateSchedules(ctx.m.GuildID, func(events []ScheduledEvent) []ScheduledEvent {
+		return append(events, event)
+	})
+	if err != nil {
+		log.Printf("Error saving schedules: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	reply := fmt.Sprintf("📅 **%s** scheduled in <#%s> as `%s`.", eventKinds[kind].Title, channelID, event.ID)
+	loc := guildLocation(ctx.m.GuildID)
+	if next, ok := spec.next(time.Now().In(loc)); ok {
+		reply += fmt.Sprintf(" Next: %s (%s).", next.Format("Mon Jan 2 15:04"), loc)
+	}
+	ctx.reply(reply)
+}
+
+func describeSchedules(guildID string) string {
+	events := loadSchedules(guildID)
+	loc := guildLocation(guildID)
+	if len(events) == 0 {
+		return fmt.Sprintf("📅 No recurring events scheduled. Timezone: %s.", loc)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "📅 **Scheduled events** (timezone %s)\n", loc)
+	for _, e := range events {
+		next := "never"
+		if spec, err := parseCron(e.Cron); err == nil {
+			if t, ok := spec.next(time.Now().In(loc)); ok {
+				next = t.Format("Mon Jan 2 15:04")
+			}
+		}
+		fmt.Fprintf(&b, "• `%s` %s in <#%s> — `%s` (next %s)\n", e.ID, eventKinds[e.Kind].Title, e.ChannelID, e.Cron, next)
+	}
+	return b.String()
+}
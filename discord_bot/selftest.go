@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// selfTestCheck is one startup check. Critical checks must pass before the
+// bot starts answering players.
+type selfTestCheck struct {
+	name     string
+	critical bool
+	run      func(s *discordgo.Session) error
+}
+
+type selfTestResult struct {
+	check selfTestCheck
+	err   error
+	took  time.Duration
+}
+
+var selfTestChecks = []selfTestCheck{
+	{name: "agent", critical: true, run: checkAgentPing},
+	{name: "store", critical: true, run: checkStoreReadWrite},
+	{name: "discord", critical: true, run: checkDiscordREST},
+	{name: "permissions", critical: false, run: checkChannelPermissions},
+}
+
+// botReady is set once the critical self-test checks pass; until then
+// messages and interactions are ignored.
+var botReady atomic.Bool
+
+// checkAgentPing polls every agent's /health and fails if no default agent
+// is reachable.
+func checkAgentPing(s *discordgo.Session) error {
+	for _, b := range allAgentBackends() {
+		checkAgentHealth(b)
+	}
+	var down []string
+	for _, b := range poolFor(defaultPersonaID).backends {
+		if b.isHealthy() {
+			return nil
+		}
+		down = append(down, b.url)
+	}
+	if len(down) == 0 {
+		return errors.New("no AI agents configured")
+	}
+	return fmt.Errorf("no healthy agent (%s)", strings.Join(down, ", "))
+}
+
+func checkStoreReadWrite(s *discordgo.Session) error {
+	probe := time.Now().UTC().Format(time.RFC3339Nano)
+	if err := store.Put("selftest", "probe", probe); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	var got string
+	if _, err := store.Get("selftest", "probe", &got); err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+	if got != probe {
+		return fmt.Errorf("read back %q, wrote %q", got, probe)
+	}
+	return store.Delete("selftest", "probe")
+}
+
+func checkDiscordREST(s *discordgo.Session) error {
+	_, err := s.User("@me")
+	return err
+}
+
+// checkChannelPermissions verifies the bot's permissions in the admin
+// channel and every channel in SELFTEST_CHANNELS.
+func checkChannelPermissions(s *discordgo.Session) error {
+	channels := config().SelfTestChannels
+	if config().AdminChannelID != "" {
+		channels = append([]string{config().AdminChannelID}, channels...)
+	}
+	var problems []string
+	for _, channelID := range channels {
+		perms, err := s.UserChannelPermissions(s.State.User.ID, channelID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("<#%s>: %v", channelID, err))
+			continue
+		}
+		var missing []string
+		for _, p := range missingPermissions(perms, requiredPermissions()) {
+			missing = append(missing, p.name)
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("<#%s> missing %s", channelID, strings.Join(missing, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// runSelfTest runs every check not listed in SELFTEST_SKIP.
+func runSelfTest(s *discordgo.Session) []selfTestResult {
+	var results []selfTestResult
+	for _, check := range selfTestChecks {
+		skip := false
+		for _, name := range config().SelfTestSkip {
+			if strings.EqualFold(name, check.name) {
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+		start := time.Now()
+		err := check.run(s)
+		results = append(results, selfTestResult{check: check, err: err, took: time.Since(start)})
+	}
+	return results
+}
+
+func criticalFailures(results []selfTestResult) int {
+	n := 0
+	for _, r := range results {
+		if r.err != nil && r.check.critical {
+			n++
+		}
+	}
+	return n
+}
+
+// failingChecks names the checks that failed, which tells apart retries
+// worth reporting from repeats of the last one.
+func failingChecks(results []selfTestResult) string {
+	var names []string
+	for _, r := range results {
+		if r.err != nil {
+			names = append(names, r.check.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func formatSelfTest(results []selfTestResult, attempt int) string {
+	var b strings.Builder
+	failed := criticalFailures(results)
+	if failed == 0 {
+		fmt.Fprintf(&b, "🩺 **Startup self-test passed** (attempt %d)\n", attempt)
+	} else {
+		fmt.Fprintf(&b, "🩺 **Startup self-test failed** (attempt %d) — %d critical check(s) failing, not serving yet\n", attempt, failed)
+	}
+	for _, r := range results {
+		mark := "✅"
+		if r.err != nil {
+			mark = "⚠️"
+			if r.check.critical {
+				mark = "❌"
+			}
+		}
+		fmt.Fprintf(&b, "%s `%s` (%s)", mark, r.check.name, r.took.Round(time.Millisecond))
+		if r.err != nil {
+			fmt.Fprintf(&b, " — %v", r.err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// postToAdminChannel sends an operator notice to ADMIN_CHANNEL_ID, if set.
+func postToAdminChannel(s *discordgo.Session, text string) {
+	if config().AdminChannelID == "" {
+		return
+	}
+	if _, err := sendChunks(s, config().AdminChannelID, text); err != nil {
+		log.Printf("Error posting to admin channel: %v", err)
+	}
+}
+
+var selfTestOnce sync.Once
+
+// startSelfTest runs the self-test once per process, retrying until the
+// critical checks pass, then marks the bot ready.
+func startSelfTest(s *discordgo.Session) {
+	selfTestOnce.Do(func() {
+		if !config().SelfTestEnabled {
+			markReady(s)
+			return
+		}
+		go selfTestUntilReady(s)
+	})
+}
+
+// selfTestUntilReady posts the first attempt, any attempt whose failing
+// checks differ from the one before, and the pass to the admin channel.
+// Retries that fail the same way are only logged.
+func selfTestUntilReady(s *discordgo.Session) {
+	lastFailing := ""
+	for attempt := 1; ; attempt++ {
+		results := runSelfTest(s)
+		summary := formatSelfTest(results, attempt)
+		log.Print(summary)
+		failing := failingChecks(results)
+		passed := criticalFailures(results) == 0
+		if attempt == 1 || passed || failing != lastFailing {
+			postToAdminChannel(s, summary)
+		}
+		lastFailing = failing
+		if passed {
+			markReady(s)
+			return
+		}
+		metrics.Inc("selftest_failures_total")
+		time.Sleep(config().SelfTestRetryInterval)
+	}
+}
+
+func markReady(s *discordgo.Session) {
+	botReady.Store(true)
+	metrics.Set("ready", 1)
+	if err := s.UpdateGameStatus(0, normalStatus()); err != nil {
+		log.Println("Error setting status:", err)
+	}
+	log.Printf("✅ Elsie is ready to serve")
+	if !safeMode.Load() {
+		go postBackNotices(s)
+	}
+}
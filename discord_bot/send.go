@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxMessageLength is Discord's limit for one message.
+const maxMessageLength = 2000
+
+// splitMessageAt splits message into chunks of at most limit bytes,
+// preferring to break at spaces.
+func splitMessageAt(message string, limit int) []string {
+	if len(message) <= limit {
+		return []string{message}
+	}
+
+	var chunks []string
+	for len(message) > 0 {
+		chunk := message
+		if len(chunk) > limit {
+			// Find the last space before the limit
+			lastSpace := strings.LastIndex(chunk[:limit], " ")
+			if lastSpace == -1 {
+				// If no space found, just split at the limit
+				lastSpace = limit
+			}
+			chunk = chunk[:lastSpace]
+			message = strings.TrimPrefix(message[lastSpace:], " ")
+		} else {
+			message = ""
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// messageChunks splits text to fit Discord's message limit with markdown
+// balanced per chunk.
+func messageChunks(text string) []string {
+	return balanceMarkdown(splitMessageAt(text, maxMessageLength-markdownReserve))
+}
+
+// chunkReplyMentions lets follow-up chunks mention what the first chunk
+// could, without pinging the author of the first chunk.
+var chunkReplyMentions = &discordgo.MessageAllowedMentions{
+	Parse: []discordgo.AllowedMentionType{
+		discordgo.AllowedMentionTypeUsers,
+		discordgo.AllowedMentionTypeRoles,
+		discordgo.AllowedMentionTypeEveryone,
+	},
+	RepliedUser: false,
+}
+
+// noPings shows mentions without notifying anyone, for listings that name
+// players and roles, like audits and leaderboards.
+var noPings = &discordgo.MessageAllowedMentions{}
+
+// sendChunks sends text to a channel in chunks and returns the sent messages
+// in order. With REPLY_CHAIN_CHUNKS, chunk 2 onwards are sent as replies to
+// the first chunk, so a long answer stays grouped when others post in
+// between.
+func sendChunks(s *discordgo.Session, channelID, text string) ([]*discordgo.Message, error) {
+	return sendChunksMentioning(s, channelID, text, nil)
+}
+
+// sendChunksQuietly is sendChunks for listings: mentions don't ping.
+func sendChunksQuietly(s *discordgo.Session, channelID, text string) ([]*discordgo.Message, error) {
+	return sendChunksMentioning(s, channelID, text, noPings)
+}
+
+// sendChunksMentioning is sendChunks with the mentions allowed to ping;
+// nil keeps Discord's defaults.
+func sendChunksMentioning(s *discordgo.Session, channelID, text string, allowed *discordgo.MessageAllowedMentions) ([]*discordgo.Message, error) {
+	var sent []*discordgo.Message
+	for _, chunk := range messageChunks(text) {
+		var msg *discordgo.Message
+		var err error
+		switch {
+		case len(sent) > 0 && config().ReplyChainChunks:
+			mentions := chunkReplyMentions
+			if allowed != nil {
+				mentions = allowed
+			}
+			msg, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+				Content:         chunk,
+				Reference:       sent[0].Reference(),
+				AllowedMentions: mentions,
+			})
+		case allowed != nil:
+			msg, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: chunk, AllowedMentions: allowed})
+		default:
+			msg, err = s.ChannelMessageSend(channelID, chunk)
+		}
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const backNoticesKey = "back_notices"
+
+// backNoticeMaxAge drops "back" notices for a shutdown so old that players
+// have long moved on.
+const backNoticeMaxAge = 6 * time.Hour
+
+// channelActivity remembers when each guild channel last had a message Elsie
+// handled, so a planned shutdown can warn the scenes that are in progress.
+type channelActivity struct {
+	GuildID   string    `json:"guild_id"`
+	ChannelID string    `json:"channel_id"`
+	At        time.Time `json:"at"`
+}
+
+// backNotices are the channels told about a planned shutdown, persisted so
+// the next start can tell them Elsie is back.
+type backNotices struct {
+	Time     time.Time         `json:"time"`
+	Channels []channelActivity `json:"channels"`
+}
+
+var (
+	recentActivity = newLRUCache[string, channelActivity]("channel_activity", 5000, 24*time.Hour)
+
+	// shutdownRequests carries a planned shutdown requested by command; main
+	// treats it like SIGUSR1.
+	shutdownRequests = make(chan string, 1)
+	backNoticesOnce  sync.Once
+)
+
+func init() {
+	trackCache(recentActivity)
+	registerCommand(command{name: "shutdown", handler: shutdownCommand})
+}
+
+// noteChannelActivity records a handled message in a guild channel.
+func noteChannelActivity(guildID, channelID string) {
+	if guildID == "" {
+		return
+	}
+	recentActivity.Add(channelID, channelActivity{GuildID: guildID, ChannelID: channelID, At: time.Now()})
+}
+
+// activeChannels returns the channels with activity within window, most
+// recent first, at most limit of them.
+func activeChannels(window time.Duration, limit int) []channelActivity {
+	var active []channelActivity
+	for _, a := range recentActivity.Values() {
+		if time.Since(a.At) > window || len(active) == limit {
+			break
+		}
+		active = append(active, a)
+	}
+	return active
+}
+
+// announcePlannedShutdown posts the theme's shutdown notice in recently
+// active channels and remembers them for the "back" notice.
+func announcePlannedShutdown(s *discordgo.Session, reason string) {
+	if config().ShutdownNoticeWindow <= 0 {
+		return
+	}
+	active := activeChannels(config().ShutdownNoticeWindow, config().ShutdownNoticeMax)
+	log.Printf("🚪 Planned shutdown (%s): notifying %d active channels", reason, len(active))
+	var notified []channelActivity
+	for _, a := range active {
+		if _, err := s.ChannelMessageSend(a.ChannelID, themePhrase(a.GuildID, "shutdown_notice", nil)); err != nil {
+			log.Printf("Error posting shutdown notice in %s: %v", a.ChannelID, err)
+			continue
+		}
+		notified = append(notified, a)
+	}
+	if len(notified) == 0 {
+		return
+	}
+	if err := store.Put(lifecycleBucket, backNoticesKey, backNotices{Time: time.Now().UTC(), Channels: notified}); err != nil {
+		log.Printf("Error saving back notices: %v", err)
+	}
+}
+
+// postBackNotices tells the channels warned at the last planned shutdown
+// that Elsie is back, once per process.
+func postBackNotices(s *discordgo.Session) {
+	backNoticesOnce.Do(func() {
+		var pending backNotices
+		ok, err := store.Get(lifecycleBucket, backNoticesKey, &pending)
+		if err != nil {
+			log.Printf("Error loading back notices: %v", err)
+		}
+		if !ok {
+			return
+		}
+		if err := store.Delete(lifecycleBucket, backNoticesKey); err != nil {
+			log.Printf("Error clearing back notices: %v", err)
+		}
+		if time.Since(pending.Time) > backNoticeMaxAge {
+			return
+		}
+		for _, a := range pending.Channels {
+			if _, err := s.ChannelMessageSend(a.ChannelID, themePhrase(a.GuildID, "back_notice", nil)); err != nil {
+				log.Printf("Error posting back notice in %s: %v", a.ChannelID, err)
+			}
+		}
+		log.Printf("🚪 Posted back notices in %d channels", len(pending.Channels))
+	})
+}
+
+// shutdownCommand is the owner-only `!elsie shutdown [reason]`: a planned
+// shutdown with notices, for deploys run from Discord.
+func shutdownCommand(ctx *commandContext) {
+	if !isBotOwner(ctx.m.Author.ID) {
+		ctx.reply("*shakes head* Only my operators can shut me down.")
+		return
+	}
+	reason := ctx.raw
+	if reason == "" {
+		reason = "requested by " + logUser(ctx.m.Author.Username, ctx.m.Author.ID)
+	}
+	ctx.reply("🚪 Shutting down after notifying active scenes.")
+	select {
+	case shutdownRequests <- reason:
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// speakerMessage is one player message in a monitored channel since
+// Elsie last spoke there. Character is the name a proxy bot (Tupperbox,
+// PluralKit) posted under, for in-character posts made through one.
+type speakerMessage struct {
+	MessageID string    `json:"message_id"`
+	Author    string    `json:"author,omitempty"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Character string    `json:"character,omitempty"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var (
+	// speakersMu guards channelSpeakers.
+	speakersMu sync.Mutex
+	// channelSpeakers are the messages since Elsie's last post, per
+	// channel, oldest first.
+	channelSpeakers = map[string][]speakerMessage{}
+)
+
+// noteSpeaker records a message in a monitored channel for the next
+// request's batch, keeping the last SPEAKER_CONTEXT_MAX.
+func noteSpeaker(m *discordgo.MessageCreate, content string) {
+	if config().SpeakerContextMax <= 0 || strings.TrimSpace(content) == "" {
+		return
+	}
+	msg := speakerMessage{
+		MessageID: m.ID,
+		Content:   truncateText(content, 500),
+		Timestamp: m.Timestamp,
+	}
+	if m.WebhookID != "" {
+		msg.Character = m.Author.Username
+	} else {
+		msg.Author, msg.AuthorID = m.Author.Username, m.Author.ID
+		if m.Member != nil && m.Member.Nick != "" {
+			msg.Author = m.Member.Nick
+		}
+	}
+	speakersMu.Lock()
+	defer speakersMu.Unlock()
+	batch := append(channelSpeakers[m.ChannelID], msg)
+	if len(batch) > config().SpeakerContextMax {
+		batch = batch[len(batch)-config().SpeakerContextMax:]
+	}
+	channelSpeakers[m.ChannelID] = batch
+}
+
+// speakersSince returns the messages since Elsie's last post in the
+// channel, leaving out the ones the request itself carries.
+func speakersSince(channelID string, exclude []string) []speakerMessage {
+	speakersMu.Lock()
+	defer speakersMu.Unlock()
+	var out []speakerMessage
+	for _, msg := range channelSpeakers[channelID] {
+		if !slices.Contains(exclude, msg.MessageID) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// resetSpeakers starts a new batch once Elsie has posted in the channel.
+func resetSpeakers(channelID string) {
+	speakersMu.Lock()
+	defer speakersMu.Unlock()
+	delete(channelSpeakers, channelID)
+}
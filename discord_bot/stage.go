@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Stage phases Elsie narrates.
+const (
+	stageOpen  = "open"
+	stageTopic = "topic"
+	stageClose = "close"
+)
+
+// stageTopics remembers each live stage's topic so only real topic changes
+// are narrated, not privacy or other edits.
+var stageTopics = newLRUCache[string, string]("stage_topics", 1000, 24*time.Hour)
+
+func init() {
+	trackCache(stageTopics)
+	registerCommand(command{name: "stage", handler: stageCommand})
+}
+
+// stageAnnounceChannel returns the text channel linked to a stage channel,
+// or "" if the stage isn't announced.
+func stageAnnounceChannel(guildID, stageChannelID string) string {
+	if guildID == "" {
+		return ""
+	}
+	return loadGuildConfig(guildID).StageChannels[stageChannelID]
+}
+
+func stageInstanceCreate(s *discordgo.Session, e *discordgo.StageInstanceEventCreate) {
+	if e.StageInstance == nil || !botReady.Load() || safeMode.Load() {
+		return
+	}
+	stageTopics.Add(e.ChannelID, e.Topic)
+	go announceStage(s, e.StageInstance, stageOpen)
+}
+
+func stageInstanceUpdate(s *discordgo.Session, e *discordgo.StageInstanceEventUpdate) {
+	if e.StageInstance == nil || !botReady.Load() || safeMode.Load() {
+		return
+	}
+	if previous, ok := stageTopics.Get(e.ChannelID); ok && previous == e.Topic {
+		return
+	}
+	stageTopics.Add(e.ChannelID, e.Topic)
+	go announceStage(s, e.StageInstance, stageTopic)
+}
+
+func stageInstanceDelete(s *discordgo.Session, e *discordgo.StageInstanceEventDelete) {
+	if e.StageInstance == nil || !botReady.Load() || safeMode.Load() {
+		return
+	}
+	stageTopics.Remove(e.ChannelID)
+	go announceStage(s, e.StageInstance, stageClose)
+}
+
+// announceStage asks the agent for an in-character line about the stage
+// and posts it in the linked text channel, falling back to a canned line.
+func announceStage(s *discordgo.Session, stage *discordgo.StageInstance, phase string) {
+	textChannelID := stageAnnounceChannel(stage.GuildID, stage.ChannelID)
+	if textChannelID == "" {
+		return
+	}
+	rlog := requestLog{id: newRequestID()}
+	p := channelPersona(s, stage.GuildID, textChannelID)
+
+	var text, prompt string
+	switch phase {
+	case stageOpen:
+		text = fmt.Sprintf("*Elsie dims the lights and taps a glass.* The stage in <#%s> is open — tonight: **%s**. Come on in!", stage.ChannelID, stage.Topic)
+		prompt = fmt.Sprintf("A live stage called %q just opened in <#%s>. Announce it to the bar in character, in one or two sentences, inviting everyone in.", stage.Topic, stage.ChannelID)
+	case stageTopic:
+		text = fmt.Sprintf("*Elsie chalks a new line on the board.* Now on stage in <#%s>: **%s**.", stage.ChannelID, stage.Topic)
+		prompt = fmt.Sprintf("The live stage in <#%s> moved on to a new topic: %q. Narrate the change in character, in one sentence.", stage.ChannelID, stage.Topic)
+	default:
+		text = fmt.Sprintf("*Elsie brings the lights back up.* That's a wrap for **%s** — thanks for coming!", stage.Topic)
+		prompt = fmt.Sprintf("The live stage %q in <#%s> just ended. Close it out in character, in one sentence.", stage.Topic, stage.ChannelID)
+	}
+
+	ctx := baseContext(s, textChannelID, stage.GuildID, nil)
+	ctx["session_id"] = p.sessionID(textChannelID)
+	ctx["request_id"] = rlog.id
+	ctx["persona"] = p.ID
+	ctx["intent"] = "stage_event"
+	ctx["stage"] = map[string]interface{}{
+		"phase":      phase,
+		"topic":      stage.Topic,
+		"channel_id": stage.ChannelID,
+	}
+	resp, err := callAgent(Message{Message: prompt, Context: ctx, RequestID: rlog.id, Persona: p.ID})
+	switch {
+	case err != nil:
+		rlog.Printf("Error generating stage announcement: %v", err)
+	case resp.silent():
+		return
+	case strings.TrimSpace(resp.Response) != "":
+		if screened, ok := screenContent(s, stage.GuildID, textChannelID, "", "outbound", resp.Response); ok {
+			text = screened
+		}
+	}
+	if _, err := sendAs(s, textChannelID, p, text); err != nil {
+		rlog.Printf("Error posting stage announcement: %v", err)
+		return
+	}
+	rlog.Printf("🎭 Stage %s %s announced in %s", stage.ChannelID, phase, textChannelID)
+	metrics.Inc(metricLabel("stage_announcements_total", "phase", phase))
+}
+
+// stageCommand is `!elsie stage [link <stage channel> <#text channel>|unlink <stage channel>]`.
+func stageCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie stage`, `!elsie stage link <stage channel> #text-channel`, `!elsie stage unlink <stage channel>`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Stage announcements are per server — use this command in a server channel.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		links := loadGuildConfig(ctx.m.GuildID).StageChannels
+		if len(links) == 0 {
+			ctx.reply("🎭 No stages are announced.\n" + usage)
+			return
+		}
+		stages := make([]string, 0, len(links))
+		for id := range links {
+			stages = append(stages, id)
+		}
+		sort.Strings(stages)
+		var b strings.Builder
+		b.WriteString("🎭 **Announced stages**\n")
+		for _, id := range stages {
+			fmt.Fprintf(&b, "• <#%s> → <#%s>\n", id, links[id])
+		}
+		b.WriteString(usage)
+		ctx.reply(b.String())
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can set up stage announcements.")
+		return
+	}
+
+	sub := strings.ToLower(ctx.args[0])
+	if (sub != "link" && sub != "unlink") || len(ctx.args) < 2 {
+		ctx.reply(usage)
+		return
+	}
+	stageID := parseChannelMention(ctx.args[1])
+	stage, err := getChannel(ctx.s, stageID)
+	if stageID == "" || err != nil || stage.GuildID != ctx.m.GuildID || stage.Type != discordgo.ChannelTypeGuildStageVoice {
+		ctx.reply("*squints* That isn't a stage channel in this server. Mention it (`<#id>`) or give its ID.")
+		return
+	}
+
+	var apply func(cfg *GuildConfig)
+	var confirmation string
+	if sub == "link" {
+		if len(ctx.args) < 3 || parseChannelMention(ctx.args[2]) == "" {
+			ctx.reply("Mention the text channel to announce in, e.g. `!elsie stage link <stage channel> #events`.")
+			return
+		}
+		textID := parseChannelMention(ctx.args[2])
+		apply = func(cfg *GuildConfig) {
+			if cfg.StageChannels == nil {
+				cfg.StageChannels = map[string]string{}
+			}
+			cfg.StageChannels[stageID] = textID
+		}
+		confirmation = fmt.Sprintf("🎭 When <#%s> goes live, I'll announce it in <#%s>.", stageID, textID)
+	} else {
+		apply = func(cfg *GuildConfig) { delete(cfg.StageChannels, stageID) }
+		confirmation = fmt.Sprintf("🎭 I'll stop announcing <#%s>.", stageID)
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, apply); err != nil {
+		log.Printf("Error saving stage announcement config: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(confirmation)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	starboardBucket = "starboard"
+	starEmoji       = "⭐"
+	starboardColor  = 0xFFAC33
+)
+
+// StarboardEntry links a starred message to its repost on the starboard.
+// Entries are stored per guild, keyed by the original message ID.
+type StarboardEntry struct {
+	ChannelID string    `json:"channel_id"`
+	AuthorID  string    `json:"author_id"`
+	PostID    string    `json:"post_id"`
+	BoardID   string    `json:"board_id"`
+	Stars     int       `json:"stars"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+// starboardMu serializes starboard updates, so two quick stars on one
+// message can't both post it.
+var starboardMu sync.Mutex
+
+func init() {
+	registerCommand(command{name: "starboard", handler: starboardCommand})
+	registerDataEraser(dataEraser{name: "starboard", user: eraseStarboardAuthor, guild: eraseGuildKey(starboardBucket)})
+}
+
+func loadStarboard(guildID string) map[string]StarboardEntry {
+	entries := map[string]StarboardEntry{}
+	if _, err := store.Get(starboardBucket, guildID, &entries); err != nil {
+		log.Printf("Error loading starboard for guild %s: %v", guildID, err)
+	}
+	return entries
+}
+
+// starboardThreshold is the guild's star count for the board, or
+// STARBOARD_DEFAULT_THRESHOLD.
+func starboardThreshold(cfg *GuildConfig) int {
+	if cfg.StarboardThreshold > 0 {
+		return cfg.StarboardThreshold
+	}
+	return config().StarboardDefaultThreshold
+}
+
+// countStars counts the ⭐ reactions on a message, leaving out its author
+// and bots, so nobody can star their own way onto the board.
+func countStars(s *discordgo.Session, channelID, messageID, authorID string) (int, error) {
+	users, err := s.MessageReactions(channelID, messageID, starEmoji, 100, "", "")
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, u := range users {
+		if u.ID != authorID && !u.Bot {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// updateStarboard reposts a message to the guild's starboard once it has
+// enough stars, and keeps the count on the repost current after that.
+func updateStarboard(s *discordgo.Session, r *discordgo.MessageReaction) {
+	if r.GuildID == "" || r.Emoji.Name != starEmoji || !botReady.Load() || safeMode.Load() {
+		return
+	}
+	cfg := loadGuildConfig(r.GuildID)
+	if cfg.StarboardChannelID == "" || r.ChannelID == cfg.StarboardChannelID {
+		return
+	}
+
+	starboardMu.Lock()
+	defer starboardMu.Unlock()
+	msg, err := s.ChannelMessage(r.ChannelID, r.MessageID)
+	if err != nil || msg.Author == nil {
+		return
+	}
+	stars, err := countStars(s, r.ChannelID, r.MessageID, msg.Author.ID)
+	if err != nil {
+		log.Printf("Error counting stars on %s: %v", r.MessageID, err)
+		return
+	}
+	entries := loadStarboard(r.GuildID)
+	entry, posted := entries[r.MessageID]
+	if !posted && stars < starboardThreshold(cfg) {
+		return
+	}
+	if posted && entry.Stars == stars {
+		return
+	}
+
+	header := fmt.Sprintf("%s **%d** · <#%s>", starEmoji, stars, r.ChannelID)
+	if posted {
+		if entry.BoardID == cfg.StarboardChannelID {
+			if _, err := s.ChannelMessageEdit(entry.BoardID, entry.PostID, header); err != nil {
+				log.Printf("Error updating starboard post %s: %v", entry.PostID, err)
+			}
+		}
+		entry.Stars = stars
+		entries[r.MessageID] = entry
+		if err := store.Put(starboardBucket, r.GuildID, entries); err != nil {
+			log.Printf("Error saving starboard: %v", err)
+		}
+		return
+	}
+
+	// Age-restricted messages only go to an age-restricted board
+	source, err := getChannel(s, r.ChannelID)
+	board, berr := getChannel(s, cfg.StarboardChannelID)
+	if err != nil || berr != nil || (isNSFWChannel(s, source) && !isNSFWChannel(s, board)) {
+		return
+	}
+	post, err := s.ChannelMessageSendComplex(cfg.StarboardChannelID, &discordgo.MessageSend{
+		Content:         header,
+		Embeds:          []*discordgo.MessageEmbed{starboardEmbed(r.GuildID, msg)},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error posting to starboard in %s: %v", cfg.StarboardChannelID, err)
+		return
+	}
+	entries[r.MessageID] = StarboardEntry{
+		ChannelID: r.ChannelID,
+		AuthorID:  msg.Author.ID,
+		PostID:    post.ID,
+		BoardID:   cfg.StarboardChannelID,
+		Stars:     stars,
+		PostedAt:  time.Now(),
+	}
+	if err := store.Put(starboardBucket, r.GuildID, entries); err != nil {
+		log.Printf("Error saving starboard: %v", err)
+	}
+	log.Printf("⭐ Message %s reached the starboard with %d stars", r.MessageID, stars)
+	metrics.Inc("starboard_posts_total")
+}
+
+// starboardEmbed quotes a starred message with a jump link back to it.
+func starboardEmbed(guildID string, msg *discordgo.Message) *discordgo.MessageEmbed {
+	name := msg.Author.Username
+	if msg.Member != nil && msg.Member.Nick != "" {
+		name = msg.Member.Nick
+	}
+	embed := &discordgo.MessageEmbed{
+		Author:      &discordgo.MessageEmbedAuthor{Name: name, IconURL: msg.Author.AvatarURL("64")},
+		Description: truncateText(msg.Content, 4000),
+		Color:       starboardColor,
+		Timestamp:   msg.Timestamp.Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{{
+			Name:  "Source",
+			Value: fmt.Sprintf("[Jump to message](https://discord.com/channels/%s/%s/%s)", guildID, msg.ChannelID, msg.ID),
+		}},
+	}
+	for _, a := range msg.Attachments {
+		if strings.HasPrefix(a.ContentType, "image/") {
+			embed.Image = &discordgo.MessageEmbedImage{URL: a.URL}
+			break
+		}
+	}
+	if embed.Description == "" && embed.Image == nil && len(msg.Embeds) > 0 {
+		embed.Description = truncateText(msg.Embeds[0].Description, 4000)
+	}
+	return embed
+}
+
+// eraseStarboardAuthor takes the user's messages off every starboard, on
+// Discord and in the store.
+func eraseStarboardAuthor(s *discordgo.Session, userID string) (int, error) {
+	starboardMu.Lock()
+	defer starboardMu.Unlock()
+	n := 0
+	for _, guildID := range store.Keys(starboardBucket) {
+		entries := loadStarboard(guildID)
+		changed := false
+		for id, e := range entries {
+			if e.AuthorID != userID {
+				continue
+			}
+			if err := s.ChannelMessageDelete(e.BoardID, e.PostID); err != nil {
+				log.Printf("Error deleting starboard post %s: %v", e.PostID, err)
+			}
+			delete(entries, id)
+			changed = true
+			n++
+		}
+		if changed {
+			if err := store.Put(starboardBucket, guildID, entries); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// starboardCommand is `!elsie starboard [#channel|off|threshold <n>]`.
+func starboardCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie starboard #channel`, `!elsie starboard threshold <stars>` or `!elsie starboard off`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("The starboard is per server — use this command in a server channel."))
+		return
+	}
+	cfg := loadGuildConfig(ctx.m.GuildID)
+	if len(ctx.args) == 0 {
+		if cfg.StarboardChannelID == "" {
+			ctx.reply(ctx.tr("⭐ **Starboard:** off") + "\n" + usage)
+		} else {
+			ctx.reply(ctx.tr("⭐ **Starboard:** <#%s>, at %d stars", cfg.StarboardChannelID, starboardThreshold(cfg)) + "\n" + usage)
+		}
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can set up the starboard."))
+		return
+	}
+
+	var change func(cfg *GuildConfig)
+	var done string
+	switch strings.ToLower(ctx.args[0]) {
+	case "off":
+		change = func(cfg *GuildConfig) { cfg.StarboardChannelID = "" }
+		done = ctx.tr("⭐ The starboard is off.")
+	case "threshold":
+		n := 0
+		if len(ctx.args) > 1 {
+			n, _ = strconv.Atoi(ctx.args[1])
+		}
+		if n < 1 || n > 100 {
+			ctx.reply(usage)
+			return
+		}
+		change = func(cfg *GuildConfig) { cfg.StarboardThreshold = n }
+		done = ctx.tr("⭐ Messages now need %d stars to reach the starboard.", n)
+	default:
+		channelID := parseChannelMention(ctx.args[0])
+		channel, err := getChannel(ctx.s, channelID)
+		if channelID == "" || err != nil || channel.GuildID != ctx.m.GuildID || channel.Type != discordgo.ChannelTypeGuildText {
+			ctx.reply(ctx.tr("*squints* That isn't a text channel in this server. Mention it (`<#id>`) or give its ID."))
+			return
+		}
+		change = func(cfg *GuildConfig) { cfg.StarboardChannelID = channelID }
+		done = ctx.tr("⭐ Messages with %d or more stars will be reposted in <#%s>.", starboardThreshold(cfg), channelID)
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, change); err != nil {
+		log.Printf("Error saving starboard settings: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(done)
+}
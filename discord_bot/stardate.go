@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stardateRealPace is how many stardate units pass in a real day when they
+// keep pace with the calendar: 1000 a year.
+const stardateRealPace = 1000 / 365.2425
+
+// StardateCalendar maps real time to a guild's stardates. With an Anchor,
+// it was stardate AnchorStardate at Anchor, moving PerDay units a real day
+// (0 freezes it); otherwise it's the TNG-style year formula on the real
+// year shifted by YearOffset.
+type StardateCalendar struct {
+	YearOffset     int       `json:"year_offset,omitempty"`
+	Anchor         time.Time `json:"anchor,omitempty"`
+	AnchorStardate float64   `json:"anchor_stardate,omitempty"`
+	PerDay         float64   `json:"per_day,omitempty"`
+}
+
+func init() {
+	registerCommand(command{name: "stardate", handler: stardateCommand})
+}
+
+// guildCalendar returns the guild's calendar, or the default one built on
+// STARDATE_YEAR_OFFSET in DMs and guilds without their own.
+func guildCalendar(guildID string) StardateCalendar {
+	if guildID != "" {
+		if c := loadGuildConfig(guildID).Calendar; c != nil {
+			return *c
+		}
+	}
+	return StardateCalendar{YearOffset: config().StardateYearOffset}
+}
+
+// stardate maps an Earth time to a stardate. Without an anchor it's 1000
+// units per in-universe year, with stardate 0 at the start of 2323.
+func (c StardateCalendar) stardate(t time.Time) float64 {
+	if !c.Anchor.IsZero() {
+		return c.AnchorStardate + c.PerDay*t.Sub(c.Anchor).Hours()/24
+	}
+	t = t.UTC()
+	start := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	fraction := float64(t.Sub(start)) / float64(end.Sub(start))
+	year := t.Year() + c.YearOffset
+	return 1000*float64(year-2323) + 1000*fraction
+}
+
+// timeOf is the inverse of stardate. It fails on a frozen calendar, where
+// every moment has the same stardate.
+func (c StardateCalendar) timeOf(sd float64) (time.Time, bool) {
+	if !c.Anchor.IsZero() {
+		if c.PerDay == 0 {
+			return time.Time{}, false
+		}
+		days := (sd - c.AnchorStardate) / c.PerDay
+		return c.Anchor.Add(time.Duration(days * 24 * float64(time.Hour))), true
+	}
+	years := math.Floor(sd / 1000)
+	year := 2323 + int(years) - c.YearOffset
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	fraction := (sd - years*1000) / 1000
+	return start.Add(time.Duration(fraction * float64(end.Sub(start)))), true
+}
+
+// stardateOf is t's stardate on the guild's calendar.
+func stardateOf(guildID string, t time.Time) float64 {
+	return guildCalendar(guildID).stardate(t)
+}
+
+// formatStardate is how stardates are shown and sent to agents.
+func formatStardate(sd float64) string {
+	return fmt.Sprintf("%.1f", sd)
+}
+
+// stardateCommand is `!elsie stardate [now|YYYY-MM-DD|<stardate>]`, or
+// `!elsie stardate calendar ...` for the guild's calendar.
+func stardateCommand(ctx *commandContext) {
+	args := withoutFlags(ctx.args)
+	arg := "now"
+	if len(args) > 0 {
+		arg = strings.ToLower(args[0])
+	}
+	if arg == "calendar" {
+		calendarCommand(ctx, args[1:])
+		return
+	}
+	cal := guildCalendar(ctx.m.GuildID)
+
+	var result string
+	switch {
+	case arg == "now":
+		result = fmt.Sprintf("Current stardate: %s", formatStardate(cal.stardate(time.Now())))
+	case strings.Count(arg, "-") == 2:
+		t, err := time.Parse("2006-01-02", arg)
+		if err != nil {
+			ctx.reply("Dates should look like `2026-10-17`.")
+			return
+		}
+		result = fmt.Sprintf("%s is stardate %s", t.Format("January 2, 2006"), formatStardate(cal.stardate(t)))
+	default:
+		sd, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			ctx.reply("Usage: `!elsie stardate [now|YYYY-MM-DD|<stardate>] [--private]`")
+			return
+		}
+		t, ok := cal.timeOf(sd)
+		if !ok {
+			ctx.reply(ctx.tr("🖖 Time is frozen on this server's calendar, so stardate %s has no date.", formatStardate(sd)))
+			return
+		}
+		result = fmt.Sprintf("Stardate %s is %s", formatStardate(sd), t.Format("January 2, 2006 15:04 MST"))
+	}
+	rememberUtilityResult(ctx, result)
+	ctx.reply("🖖 " + result)
+}
+
+// calendarCommand is `!elsie stardate calendar [offset <years>|set
+// <stardate> [<units a day>]|reset]`; without arguments it shows the
+// guild's calendar.
+func calendarCommand(ctx *commandContext, args []string) {
+	usage := "Usage: `!elsie stardate calendar`, `!elsie stardate calendar offset <years>`, `!elsie stardate calendar set <stardate> [<units a day>]`, `!elsie stardate calendar reset`"
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Calendars are per server — use this command in a server channel."))
+		return
+	}
+	if len(args) == 0 {
+		ctx.reply(describeCalendar(ctx, loadGuildConfig(ctx.m.GuildID).Calendar))
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can change the calendar."))
+		return
+	}
+
+	var cal *StardateCalendar
+	switch strings.ToLower(args[0]) {
+	case "offset":
+		if len(args) != 2 {
+			ctx.reply(usage)
+			return
+		}
+		years, err := strconv.Atoi(args[1])
+		if err != nil {
+			ctx.reply(ctx.tr("Give the offset in whole years, e.g. `!elsie stardate calendar offset 354`."))
+			return
+		}
+		cal = &StardateCalendar{YearOffset: years}
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			ctx.reply(usage)
+			return
+		}
+		sd, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			ctx.reply(ctx.tr("Give a stardate like `58432.7`."))
+			return
+		}
+		perDay := stardateRealPace
+		if len(args) == 3 {
+			if perDay, err = strconv.ParseFloat(args[2], 64); err != nil || perDay < 0 {
+				ctx.reply(ctx.tr("Give how many units pass a day as a number, or `0` to stop the clock."))
+				return
+			}
+		}
+		cal = &StardateCalendar{Anchor: time.Now().UTC(), AnchorStardate: sd, PerDay: perDay}
+	case "reset":
+	default:
+		ctx.reply(usage)
+		return
+	}
+
+	err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.Calendar = cal })
+	if err != nil {
+		log.Printf("Error saving stardate calendar: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(describeCalendar(ctx, cal))
+}
+
+// describeCalendar explains how the guild's stardates are worked out.
+func describeCalendar(ctx *commandContext, cal *StardateCalendar) string {
+	now := formatStardate(stardateOf(ctx.m.GuildID, time.Now()))
+	switch {
+	case cal == nil:
+		return ctx.tr("📅 This server uses the default calendar: the real year plus %d years, at 1000 units a year. It's stardate %s.", config().StardateYearOffset, now)
+	case cal.Anchor.IsZero():
+		return ctx.tr("📅 This server's calendar is the real year plus %d years, at 1000 units a year. It's stardate %s.", cal.YearOffset, now)
+	case cal.PerDay == 0:
+		return ctx.tr("📅 This server's calendar was stopped at stardate %s on %s.", formatStardate(cal.AnchorStardate), cal.Anchor.Format("January 2, 2006"))
+	default:
+		return ctx.tr("📅 This server's calendar was set to stardate %s on %s and moves %.2f units a day. It's stardate %s.",
+			formatStardate(cal.AnchorStardate), cal.Anchor.Format("January 2, 2006"), cal.PerDay, now)
+	}
+}
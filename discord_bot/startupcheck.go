@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Application flags saying which privileged intents are switched on in the
+// developer portal. Verified bots in 100+ servers get the unlimited flags,
+// everyone else the limited ones.
+const (
+	appFlagGatewayGuildMembers          = 1 << 14
+	appFlagGatewayGuildMembersLimited   = 1 << 15
+	appFlagGatewayMessageContent        = 1 << 18
+	appFlagGatewayMessageContentLimited = 1 << 19
+)
+
+// emptyContentThreshold is how many guild messages in a row must arrive
+// without content before Elsie concludes she isn't being sent any.
+const emptyContentThreshold = 10
+
+// startupProblem is something that keeps the bot from working, and what
+// the operator should do about it.
+type startupProblem struct {
+	check string
+	err   string
+	fix   string
+	fatal bool
+}
+
+var (
+	// emptyContentMu guards the empty message content watch.
+	emptyContentMu     sync.Mutex
+	emptyContentRun    int
+	emptyContentWarned bool
+)
+
+// validateStartup checks the token, the privileged intents and the agent
+// before the gateway connection opens, and exits with what to fix instead
+// of running on and receiving messages without their content.
+func validateStartup(s *discordgo.Session) {
+	if !config().StartupChecksEnabled {
+		return
+	}
+	problems := startupProblems(s)
+	fatal := 0
+	for _, p := range problems {
+		mark := "⚠️ "
+		if p.fatal {
+			mark = "❌"
+			fatal++
+		}
+		log.Printf("%s Startup check %s: %s\n   → %s", mark, p.check, p.err, p.fix)
+	}
+	if fatal > 0 {
+		log.Fatalf("Startup checks failed (%d problems). Fix them and restart, or set STARTUP_CHECKS_ENABLED=false to skip the checks.", fatal)
+	}
+	log.Printf("✅ Startup checks passed")
+}
+
+func startupProblems(s *discordgo.Session) []startupProblem {
+	var problems []startupProblem
+	token := strings.TrimSpace(Token)
+	switch {
+	case token == "":
+		return []startupProblem{{check: "token", err: "DISCORD_TOKEN is not set", fatal: true,
+			fix: "Copy the bot token from Bot → Reset Token in the Discord developer portal into DISCORD_TOKEN."}}
+	case strings.HasPrefix(token, "Bot "):
+		return []startupProblem{{check: "token", err: `DISCORD_TOKEN starts with "Bot "`, fatal: true,
+			fix: `Set DISCORD_TOKEN to the token alone; the "Bot " prefix is added for you.`}}
+	case strings.Count(token, ".") != 2:
+		return []startupProblem{{check: "token", err: "DISCORD_TOKEN doesn't look like a bot token", fatal: true,
+			fix: "Bot tokens have three dot-separated parts. Check you didn't paste the client secret or application ID."}}
+	}
+
+	if _, err := s.User("@me"); err != nil {
+		if restStatus(err) == http.StatusUnauthorized {
+			return []startupProblem{{check: "token", err: "Discord rejected DISCORD_TOKEN", fatal: true,
+				fix: "The token was reset or revoked. Generate a new one with Bot → Reset Token in the developer portal."}}
+		}
+		// Without Discord the remaining checks can't run; the self-test
+		// retries once the gateway is up
+		return []startupProblem{{check: "discord", err: err.Error(),
+			fix: "Couldn't reach Discord. Check the network; startup continues and the self-test retries."}}
+	}
+
+	app, err := s.Application("@me")
+	if err != nil {
+		problems = append(problems, startupProblem{check: "intents", err: err.Error(),
+			fix: "Couldn't read the application's settings, so the privileged intents weren't checked."})
+	} else {
+		portal := fmt.Sprintf("https://discord.com/developers/applications/%s/bot", app.ID)
+		if app.Flags&(appFlagGatewayMessageContent|appFlagGatewayMessageContentLimited) == 0 {
+			problems = append(problems, startupProblem{check: "intents", err: "the Message Content intent is off", fatal: true,
+				fix: "Turn on Privileged Gateway Intents → MESSAGE CONTENT INTENT at " + portal + ". Without it every message arrives empty."})
+		}
+		if app.Flags&(appFlagGatewayGuildMembers|appFlagGatewayGuildMembersLimited) == 0 {
+			problems = append(problems, startupProblem{check: "intents", err: "the Server Members intent is off", fatal: true,
+				fix: "Turn on Privileged Gateway Intents → SERVER MEMBERS INTENT at " + portal + ". Discord refuses the connection without it."})
+		} else if err := probeMembersIntent(s); err != nil {
+			problems = append(problems, startupProblem{check: "intents", err: err.Error(),
+				fix: "Discord refused to list members. If you just turned on SERVER MEMBERS INTENT at " + portal + ", wait a minute and restart."})
+		}
+	}
+
+	if err := checkAgentPing(s); err != nil {
+		problems = append(problems, startupProblem{check: "agent", err: err.Error(), fatal: config().StartupRequireAgent,
+			fix: fmt.Sprintf("Check that the agent is running and that AI_AGENT_URL (%s) points at it.", strings.Join(config().AIAgentURLs, ", "))})
+	}
+	return problems
+}
+
+// probeMembersIntent lists one member of one of the bot's servers, which
+// Discord only allows with the Server Members intent.
+func probeMembersIntent(s *discordgo.Session) error {
+	guilds, err := s.UserGuilds(1, "", "")
+	if err != nil || len(guilds) == 0 {
+		return nil // nothing to probe yet
+	}
+	if _, err := s.GuildMembers(guilds[0].ID, "", 1); err != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeMissingAccess {
+			return errors.New("listing members failed with Missing Access")
+		}
+	}
+	return nil
+}
+
+func restStatus(err error) int {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		return restErr.Response.StatusCode
+	}
+	return 0
+}
+
+// gatewayOpenHint explains the gateway close codes an operator can fix.
+func gatewayOpenHint(err error) string {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "4004"):
+		return "Discord rejected DISCORD_TOKEN. Generate a new one with Bot → Reset Token in the developer portal."
+	case strings.Contains(msg, "4014"):
+		return "A privileged intent is off. Turn on MESSAGE CONTENT INTENT and SERVER MEMBERS INTENT under Bot in the developer portal."
+	}
+	return ""
+}
+
+// watchMessageContent notices when guild messages keep arriving without
+// content, which happens when the Message Content intent is switched off
+// while the bot runs, and tells the operators once.
+func watchMessageContent(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if m.GuildID == "" || m.Author.Bot {
+		return
+	}
+	empty := m.Content == "" && len(m.Attachments) == 0 && len(m.Embeds) == 0 && len(m.StickerItems) == 0
+	emptyContentMu.Lock()
+	defer emptyContentMu.Unlock()
+	if !empty {
+		emptyContentRun = 0
+		return
+	}
+	emptyContentRun++
+	if emptyContentRun < emptyContentThreshold || emptyContentWarned {
+		return
+	}
+	emptyContentWarned = true
+	metrics.Inc("empty_message_content_total")
+	text := fmt.Sprintf("❌ The last %d server messages arrived without content, so Elsie can't read them. Turn on Privileged Gateway Intents → MESSAGE CONTENT INTENT under Bot in the Discord developer portal, then restart.", emptyContentRun)
+	log.Print(text)
+	go postToAdminChannel(s, text)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+const guildStatsBucket = "guild_stats"
+
+// GuildStats are the usage counters for one guild over the current
+// reporting period.
+type GuildStats struct {
+	PeriodStart     time.Time      `json:"period_start"`
+	Messages        int            `json:"messages"`
+	AgentRequests   int            `json:"agent_requests"`
+	AgentErrors     int            `json:"agent_errors"`
+	SendErrors      int            `json:"send_errors"`
+	ResponseChars   int            `json:"response_chars"`
+	ChannelMessages map[string]int `json:"channel_messages"`
+}
+
+// statsRecorder accumulates counters in memory and flushes them to the
+// store periodically so the hot path never waits on disk.
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats map[string]*GuildStats
+	dirty map[string]bool
+}
+
+var guildStats = &statsRecorder{
+	stats: make(map[string]*GuildStats),
+	dirty: make(map[string]bool),
+}
+
+// get returns the live stats for guildID, loading them on first use.
+// Callers must hold r.mu.
+func (r *statsRecorder) get(guildID string) *GuildStats {
+	if st, ok := r.stats[guildID]; ok {
+		return st
+	}
+	st := &GuildStats{}
+	if _, err := store.Get(guildStatsBucket, guildID, st); err != nil {
+		log.Printf("Error loading stats for guild %s: %v", guildID, err)
+	}
+	if st.PeriodStart.IsZero() {
+		st.PeriodStart = time.Now()
+	}
+	if st.ChannelMessages == nil {
+		st.ChannelMessages = make(map[string]int)
+	}
+	r.stats[guildID] = st
+	return st
+}
+
+func (r *statsRecorder) update(guildID string, fn func(st *GuildStats)) {
+	if guildID == "" || !telemetryAllowed(guildID) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.get(guildID))
+	r.dirty[guildID] = true
+}
+
+// recordMessage counts a message forwarded to the agent.
+func (r *statsRecorder) recordMessage(guildID, channelID string) {
+	r.update(guildID, func(st *GuildStats) {
+		st.Messages++
+		st.ChannelMessages[channelID]++
+	})
+}
+
+// recordAgentCall counts an agent request and its outcome.
+func (r *statsRecorder) recordAgentCall(guildID string, failed bool, responseChars int) {
+	r.update(guildID, func(st *GuildStats) {
+		st.AgentRequests++
+		if failed {
+			st.AgentErrors++
+		}
+		st.ResponseChars += responseChars
+	})
+}
+
+func (r *statsRecorder) recordSendError(guildID string) {
+	r.update(guildID, func(st *GuildStats) { st.SendErrors++ })
+}
+
+// clone returns a copy of st that shares no maps with it, so it can be
+// read or saved after r.mu is released.
+func (st *GuildStats) clone() GuildStats {
+	c := *st
+	c.ChannelMessages = maps.Clone(st.ChannelMessages)
+	return c
+}
+
+// snapshot returns a copy of the guild's current stats.
+func (r *statsRecorder) snapshot(guildID string) GuildStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(guildID).clone()
+}
+
+// reset starts a new reporting period for guildID.
+func (r *statsRecorder) reset(guildID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats[guildID] = &GuildStats{PeriodStart: time.Now(), ChannelMessages: make(map[string]int)}
+	r.dirty[guildID] = true
+}
+
+// drop forgets the guild's stats without persisting them.
+func (r *statsRecorder) drop(guildID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stats, guildID)
+	delete(r.dirty, guildID)
+}
+
+// flush persists every guild whose stats changed since the last flush.
+func (r *statsRecorder) flush() {
+	r.mu.Lock()
+	pending := make(map[string]GuildStats, len(r.dirty))
+	for guildID := range r.dirty {
+		pending[guildID] = r.stats[guildID].clone()
+	}
+	r.dirty = make(map[string]bool)
+	r.mu.Unlock()
+
+	for guildID, st := range pending {
+		if err := store.Put(guildStatsBucket, guildID, st); err != nil {
+			log.Printf("Error saving stats for guild %s: %v", guildID, err)
+		}
+	}
+}
+
+func runStatsFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		guildStats.flush()
+	}
+}
+
+// topChannels returns up to n channel IDs ordered by message count.
+func (st GuildStats) topChannels(n int) []string {
+	ids := make([]string, 0, len(st.ChannelMessages))
+	for id := range st.ChannelMessages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return st.ChannelMessages[ids[i]] > st.ChannelMessages[ids[j]] })
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Store is a small persistent key/value store grouped into buckets. Values
+// are kept as JSON in a single file and written atomically on every change,
+// which is plenty for per-user and per-guild settings.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	buckets map[string]map[string]json.RawMessage
+}
+
+// store is the process-wide persistence layer, opened in main.
+var store *Store
+
+// OpenStore loads the store at path, creating an empty one if the file does
+// not exist yet.
+func OpenStore(path string) (*Store, error) {
+	st := &Store{
+		path:    path,
+		buckets: make(map[string]map[string]json.RawMessage),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading store: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &st.buckets); err != nil {
+			return nil, fmt.Errorf("parsing store %s: %w", path, err)
+		}
+	}
+	return st, nil
+}
+
+// Get decodes the value stored under bucket/key into v. It reports false if
+// no value exists.
+func (st *Store) Get(bucket, key string, v interface{}) (bool, error) {
+	st.mu.Lock()
+	raw, ok := st.buckets[bucket][key]
+	st.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("decoding %s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+// Put stores v under bucket/key and persists the change.
+func (st *Store) Put(bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s/%s: %w", bucket, key, err)
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.buckets[bucket] == nil {
+		st.buckets[bucket] = make(map[string]json.RawMessage)
+	}
+	st.buckets[bucket][key] = raw
+	return st.flush()
+}
+
+// Delete removes bucket/key and persists the change.
+func (st *Store) Delete(bucket, key string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.buckets[bucket][key]; !ok {
+		return nil
+	}
+	delete(st.buckets[bucket], key)
+	return st.flush()
+}
+
+// Keys lists the keys of bucket in sorted order.
+func (st *Store) Keys(bucket string) []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	keys := make([]string, 0, len(st.buckets[bucket]))
+	for k := range st.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flush writes the store to disk via a temp file and rename so a crash
+// mid-write never leaves a truncated file. Callers must hold st.mu.
+func (st *Store) flush() error {
+	data, err := json.MarshalIndent(st.buckets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding store: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o755); err != nil {
+		return fmt.Errorf("creating store directory: %w", err)
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return fmt.Errorf("replacing store: %w", err)
+	}
+	return nil
+}
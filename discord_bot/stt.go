@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Speech-to-text providers for voice listening, picked with STT_PROVIDER.
+const (
+	sttAgent  = "agent"
+	sttOpenAI = "openai"
+)
+
+// defaultSTTURL is the OpenAI-compatible transcription endpoint used when
+// STT_URL isn't set.
+const defaultSTTURL = "https://api.openai.com/v1/audio/transcriptions"
+
+// utterance is one stretch of speech from one member, as Ogg Opus.
+type utterance struct {
+	guildID   string
+	channelID string
+	userID    string
+	audio     []byte
+}
+
+// transcribeRequest is the payload for the agent's POST /transcribe.
+type transcribeRequest struct {
+	RequestID string `json:"request_id"`
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+	Language  string `json:"language,omitempty"`
+	Format    string `json:"format"`
+	Audio     string `json:"audio"`
+}
+
+// transcribeResponse is what both providers answer with.
+type transcribeResponse struct {
+	Text string `json:"text"`
+}
+
+// sttProviders turn an utterance into text.
+var sttProviders = map[string]func(rlog requestLog, u utterance) (string, error){
+	sttAgent:  transcribeWithAgent,
+	sttOpenAI: transcribeWithOpenAI,
+}
+
+// transcribe runs u through the configured STT provider.
+func transcribe(rlog requestLog, u utterance) (string, error) {
+	provider, ok := sttProviders[config().STTProvider]
+	if !ok {
+		return "", fmt.Errorf("unknown STT provider %q", config().STTProvider)
+	}
+	text, err := provider(rlog, u)
+	return strings.TrimSpace(text), err
+}
+
+// transcribeWithAgent sends the audio, base64-encoded, to the agent's
+// POST /transcribe.
+func transcribeWithAgent(rlog requestLog, u utterance) (string, error) {
+	pool := poolFor(channelPersona(botSession, u.guildID, u.channelID).ID)
+	if !pool.supports(featureTranscribe) {
+		return "", errors.New("agent does not support transcription")
+	}
+	body, err := pool.call(rlog.id, "/transcribe", transcribeRequest{
+		RequestID: rlog.id,
+		GuildID:   u.guildID,
+		ChannelID: u.channelID,
+		UserID:    u.userID,
+		Language:  agentLocale(u.guildID, u.userID),
+		Format:    "ogg/opus",
+		Audio:     base64.StdEncoding.EncodeToString(u.audio),
+	})
+	if err != nil {
+		return "", err
+	}
+	var resp transcribeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid transcription: %w", err)
+	}
+	return resp.Text, nil
+}
+
+// transcribeWithOpenAI uploads the audio to an OpenAI-compatible
+// /audio/transcriptions endpoint, such as OpenAI's or a local Whisper server.
+func transcribeWithOpenAI(rlog requestLog, u utterance) (string, error) {
+	c := config()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", c.STTModel)
+	if lang := agentLocale(u.guildID, u.userID); lang != "" {
+		form.WriteField("language", lang)
+	}
+	file, err := form.CreateFormFile("file", "utterance.ogg")
+	if err != nil {
+		return "", err
+	}
+	file.Write(u.audio)
+	form.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.AgentTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.STTURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Request-ID", rlog.id)
+	if c.STTAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.STTAPIKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("STT provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out transcribeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("invalid transcription: %w", err)
+	}
+	return out.Text, nil
+}
+
+// Discord sends 20ms stereo Opus frames at 48kHz.
+const (
+	opusSampleRate      = 48000
+	opusChannels        = 2
+	opusSamplesPerFrame = 960
+)
+
+// oggOpus wraps raw Opus frames in an Ogg container, one frame per page,
+// which STT services accept as an .ogg file.
+func oggOpus(frames [][]byte) []byte {
+	var out bytes.Buffer
+	seq := uint32(0)
+	page := func(headerType byte, granule uint64, packet []byte) {
+		var hdr bytes.Buffer
+		hdr.WriteString("OggS")
+		hdr.WriteByte(0) // version
+		hdr.WriteByte(headerType)
+		binary.Write(&hdr, binary.LittleEndian, granule)
+		binary.Write(&hdr, binary.LittleEndian, uint32(0x456c7369)) // stream serial
+		binary.Write(&hdr, binary.LittleEndian, seq)
+		binary.Write(&hdr, binary.LittleEndian, uint32(0)) // checksum, filled below
+		var lacing []byte
+		for n := len(packet); ; n -= 255 {
+			if n < 255 {
+				lacing = append(lacing, byte(n))
+				break
+			}
+			lacing = append(lacing, 255)
+		}
+		hdr.WriteByte(byte(len(lacing)))
+		hdr.Write(lacing)
+		p := append(hdr.Bytes(), packet...)
+		binary.LittleEndian.PutUint32(p[22:], oggCRC(p))
+		out.Write(p)
+		seq++
+	}
+
+	head := []byte("OpusHead")
+	head = append(head, 1, opusChannels)
+	head = binary.LittleEndian.AppendUint16(head, 0) // pre-skip
+	head = binary.LittleEndian.AppendUint32(head, opusSampleRate)
+	head = binary.LittleEndian.AppendUint16(head, 0) // output gain
+	head = append(head, 0)                           // channel mapping family
+	page(0x02, 0, head)
+
+	vendor := "elsie"
+	tags := []byte("OpusTags")
+	tags = binary.LittleEndian.AppendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = binary.LittleEndian.AppendUint32(tags, 0) // no comments
+	page(0, 0, tags)
+
+	for i, frame := range frames {
+		headerType := byte(0)
+		if i == len(frames)-1 {
+			headerType = 0x04
+		}
+		page(headerType, uint64(i+1)*opusSamplesPerFrame, frame)
+	}
+	return out.Bytes()
+}
+
+// oggCRCTable is the CRC-32 table Ogg uses: polynomial 0x04c11db7,
+// unreflected, no final XOR.
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
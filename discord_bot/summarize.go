@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// summarizeRequest is the payload for POST /summarize.
+type summarizeRequest struct {
+	RequestID   string              `json:"request_id"`
+	SessionID   string              `json:"session_id"`
+	Persona     string              `json:"persona"`
+	GuildID     string              `json:"guild_id,omitempty"`
+	ChannelID   string              `json:"channel_id"`
+	ChannelName string              `json:"channel_name,omitempty"`
+	IsThread    bool                `json:"is_thread"`
+	Messages    []transcriptMessage `json:"messages"`
+}
+
+// transcriptMessage is one line of the history sent for summarizing.
+type transcriptMessage struct {
+	Author    string    `json:"author"`
+	AuthorID  string    `json:"author_id"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	Bot       bool      `json:"bot,omitempty"`
+}
+
+// summarizeResponse is the agent's answer to POST /summarize.
+type summarizeResponse struct {
+	Title      string   `json:"title"`
+	Summary    string   `json:"summary"`
+	Highlights []string `json:"highlights"`
+}
+
+// summarizeCooldowns holds the last summary time per channel, so a busy
+// channel can't queue up summaries of the same history.
+var summarizeCooldowns = newLRUCache[string, time.Time]("summarize_cooldowns", 1000, time.Hour)
+
+func init() {
+	trackCache(summarizeCooldowns)
+	registerCommand(command{name: "summarize", handler: summarizeCommand})
+}
+
+// readRecentHistory returns up to limit messages before the command,
+// oldest first.
+func readRecentHistory(s *discordgo.Session, channelID, beforeID string, limit int) ([]*discordgo.Message, error) {
+	var history []*discordgo.Message
+	before := beforeID
+	for len(history) < limit {
+		page := min(100, limit-len(history))
+		batch, err := s.ChannelMessages(channelID, page, before, "", "")
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, batch...)
+		if len(batch) < page {
+			break
+		}
+		before = batch[len(batch)-1].ID
+	}
+	// Pages come newest first
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history, nil
+}
+
+// summaryTranscript keeps the conversation from history: player posts and
+// the bot's own posts, without commands, OOC chatter, other bots or members
+// who opted out or are blocked.
+func summaryTranscript(s *discordgo.Session, guildID string, history []*discordgo.Message) []transcriptMessage {
+	names := map[string]string{}
+	excluded := map[string]bool{}
+	var out []transcriptMessage
+	for _, msg := range history {
+		if msg.Author == nil || msg.Type != discordgo.MessageTypeDefault && msg.Type != discordgo.MessageTypeReply {
+			continue
+		}
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			continue
+		}
+		if _, ooc := parseOOC(content); ooc {
+			continue
+		}
+		if _, cmd := trimCommandPrefix(guildID, content); cmd {
+			continue
+		}
+		own := msg.Author.ID == s.State.User.ID || (msg.WebhookID != "" && isOwnWebhook(msg.WebhookID))
+		if msg.Author.Bot && !own {
+			continue
+		}
+		if !own {
+			skip, ok := excluded[msg.Author.ID]
+			if !ok {
+				skip = userExclusion(guildID, msg.Author.ID) != ""
+				excluded[msg.Author.ID] = skip
+			}
+			if skip {
+				continue
+			}
+		}
+		name, ok := names[msg.Author.ID]
+		if !ok {
+			name = msg.Author.Username
+			if guildID != "" && msg.WebhookID == "" {
+				if member, err := getMember(s, guildID, msg.Author.ID); err == nil && member.Nick != "" {
+					name = member.Nick
+				}
+			}
+			names[msg.Author.ID] = name
+		}
+		out = append(out, transcriptMessage{
+			Author:    name,
+			AuthorID:  msg.Author.ID,
+			Content:   content,
+			Timestamp: msg.Timestamp,
+			Bot:       own,
+		})
+	}
+	return out
+}
+
+// summaryEmbed renders the agent's recap.
+func summaryEmbed(guildID string, resp summarizeResponse, transcript []transcriptMessage) *discordgo.MessageEmbed {
+	title := resp.Title
+	if title == "" {
+		title = "Scene recap"
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "📜 " + truncateText(title, 240),
+		Description: truncateText(strings.TrimSpace(resp.Summary), 4000),
+		Color:       themeColor(guildID, "info"),
+	}
+	if len(resp.Highlights) > 0 {
+		var b strings.Builder
+		for _, h := range resp.Highlights {
+			fmt.Fprintf(&b, "• %s\n", strings.TrimSpace(h))
+		}
+		embed.Fields = []*discordgo.MessageEmbedField{{Name: "Highlights", Value: truncateText(b.String(), 1024)}}
+	}
+	first, last := transcript[0].Timestamp, transcript[len(transcript)-1].Timestamp
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("%d messages • %s – %s", len(transcript), first.UTC().Format("Jan 2 15:04"), last.UTC().Format("Jan 2 15:04 UTC")),
+	}
+	return embed
+}
+
+// summarizeCommand is `!elsie summarize [messages]`: a recap of the last
+// messages in the channel, or the whole thread when run in one.
+func summarizeCommand(ctx *commandContext) {
+	c := config()
+	usage := fmt.Sprintf("Usage: `!elsie summarize [number of messages, up to %d]`", c.SummarizeMaxMessages)
+	channel, err := getChannel(ctx.s, ctx.m.ChannelID)
+	if err != nil {
+		ctx.reply("*frowns* I couldn't read this channel.")
+		return
+	}
+	limit := c.SummarizeDefaultMessages
+	if channel.IsThread() {
+		limit = c.SummarizeMaxMessages
+	}
+	if len(ctx.args) > 0 {
+		n, err := strconv.Atoi(ctx.args[0])
+		if err != nil || n < 1 {
+			ctx.reply(usage)
+			return
+		}
+		limit = min(n, c.SummarizeMaxMessages)
+	}
+	if last, ok := summarizeCooldowns.Get(ctx.m.ChannelID); ok && !isBotOwner(ctx.m.Author.ID) {
+		if wait := c.SummarizeCooldown - time.Since(last); wait > 0 {
+			ctx.reply(fmt.Sprintf("📜 I just summarized this channel — try again in %s.", wait.Round(time.Second)))
+			return
+		}
+	}
+
+	p := channelPersona(ctx.s, ctx.m.GuildID, ctx.m.ChannelID)
+	pool := poolFor(p.ID)
+	if !pool.supports(featureSummarize) {
+		ctx.reply("📜 My agent can't write summaries yet.")
+		return
+	}
+	rlog := requestLog{id: newRequestID()}
+	ctx.s.ChannelTyping(ctx.m.ChannelID)
+
+	history, err := readRecentHistory(ctx.s, ctx.m.ChannelID, ctx.m.ID, limit)
+	if err != nil {
+		rlog.Printf("Error reading history to summarize in %s: %v", ctx.m.ChannelID, err)
+		ctx.reply("*frowns* I couldn't read this channel's history. I need Read Message History here.")
+		return
+	}
+	transcript := summaryTranscript(ctx.s, ctx.m.GuildID, history)
+	if len(transcript) == 0 {
+		ctx.reply("📜 There's nothing here to summarize yet.")
+		return
+	}
+	summarizeCooldowns.Add(ctx.m.ChannelID, time.Now())
+
+	req := summarizeRequest{
+		RequestID:   rlog.id,
+		SessionID:   p.sessionID(ctx.m.ChannelID),
+		Persona:     p.ID,
+		GuildID:     ctx.m.GuildID,
+		ChannelID:   ctx.m.ChannelID,
+		ChannelName: channel.Name,
+		IsThread:    channel.IsThread(),
+		Messages:    transcript,
+	}
+	body, err := pool.call(rlog.id, "/summarize", req)
+	var rejected *agentRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		rlog.Printf("Agent does not support summaries: %v", err)
+		ctx.reply("📜 My agent can't write summaries yet.")
+		return
+	case err != nil:
+		rlog.Printf("Error summarizing %s: %v", ctx.m.ChannelID, err)
+		summarizeCooldowns.Remove(ctx.m.ChannelID)
+		ctx.reply("*winces* I lost my train of thought. Try again in a moment.")
+		return
+	}
+	var resp summarizeResponse
+	if err := json.Unmarshal(body, &resp); err != nil || strings.TrimSpace(resp.Summary) == "" {
+		rlog.Printf("Invalid summary response: %v", err)
+		ctx.reply("*winces* I lost my train of thought. Try again in a moment.")
+		return
+	}
+	if screened, ok := screenContent(ctx.s, ctx.m.GuildID, ctx.m.ChannelID, "", "outbound", resp.Summary); ok {
+		resp.Summary = screened
+	} else {
+		ctx.reply("📜 I'd rather not repeat that summary here.")
+		return
+	}
+
+	if _, err := ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, summaryEmbed(ctx.m.GuildID, resp, transcript)); err != nil {
+		rlog.Printf("Error posting summary: %v", err)
+		return
+	}
+	rlog.Printf("📜 Summarized %d messages in %s", len(transcript), ctx.m.ChannelID)
+	metrics.Inc("summaries_total")
+}
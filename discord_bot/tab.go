@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	tabBucket = "tabs"
+
+	// defaultDrinkPrice and defaultDishPrice are charged for catalog items
+	// without a price.
+	defaultDrinkPrice = 5
+	defaultDishPrice  = 6
+
+	// maxTabItems is how many recent orders a tab lists; totals keep
+	// counting past it.
+	maxTabItems = 20
+)
+
+// TabItem is one drink or dish put on a tab.
+type TabItem struct {
+	DrinkID string    `json:"drink_id,omitempty"`
+	DishID  string    `json:"dish_id,omitempty"`
+	Name    string    `json:"name"`
+	Price   int       `json:"price"`
+	Time    time.Time `json:"time"`
+}
+
+// Tab is a customer's running (fictional) bill in one guild. Balance resets
+// with `tab clear`; Lifetime and Orders feed the leaderboard and never do.
+type Tab struct {
+	Items    []TabItem `json:"items,omitempty"`
+	Balance  int       `json:"balance"`
+	Lifetime int       `json:"lifetime"`
+	Orders   int       `json:"orders"`
+}
+
+// tabsMu serializes changes to tabs.
+var tabsMu sync.Mutex
+
+// loadTabs returns the guild's tabs keyed by user ID.
+func loadTabs(guildID string) map[string]*Tab {
+	tabs := map[string]*Tab{}
+	if _, err := store.Get(tabBucket, guildID, &tabs); err != nil {
+		log.Printf("Error loading tabs for %s: %v", guildID, err)
+	}
+	return tabs
+}
+
+func (d Drink) price() int {
+	if d.Price > 0 {
+		return d.Price
+	}
+	return defaultDrinkPrice
+}
+
+func (d Drink) tabItem() TabItem {
+	return TabItem{DrinkID: d.ID, Name: d.Name, Price: d.price()}
+}
+
+func (d Dish) price() int {
+	if d.Price > 0 {
+		return d.Price
+	}
+	return defaultDishPrice
+}
+
+func (d Dish) tabItem() TabItem {
+	return TabItem{DishID: d.ID, Name: d.Name, Price: d.price()}
+}
+
+// addToTab puts a drink or dish on the user's tab and returns the updated
+// tab.
+func addToTab(guildID, userID string, item TabItem) (*Tab, error) {
+	tabsMu.Lock()
+	defer tabsMu.Unlock()
+	tabs := loadTabs(guildID)
+	tab := tabs[userID]
+	if tab == nil {
+		tab = &Tab{}
+		tabs[userID] = tab
+	}
+	item.Time = time.Now().UTC()
+	tab.Items = append(tab.Items, item)
+	if len(tab.Items) > maxTabItems {
+		tab.Items = tab.Items[len(tab.Items)-maxTabItems:]
+	}
+	tab.Balance += item.Price
+	tab.Lifetime += item.Price
+	tab.Orders++
+	metrics.Inc("tab_orders_total")
+	return tab, store.Put(tabBucket, guildID, tabs)
+}
+
+// clearTab settles the user's balance, keeping their leaderboard totals.
+func clearTab(guildID, userID string) (settled int, err error) {
+	tabsMu.Lock()
+	defer tabsMu.Unlock()
+	tabs := loadTabs(guildID)
+	tab := tabs[userID]
+	if tab == nil || tab.Balance == 0 {
+		return 0, nil
+	}
+	settled = tab.Balance
+	tab.Balance = 0
+	tab.Items = nil
+	return settled, store.Put(tabBucket, guildID, tabs)
+}
+
+// tabOrderRequest finds a catalog drink or dish in a message asking to put
+// it on the customer's tab, e.g. "Elsie, a Romulan Ale — put it on my tab".
+func tabOrderRequest(content string) (TabItem, bool) {
+	lower := strings.ToLower(content)
+	if !strings.Contains(lower, "on my tab") && !strings.Contains(lower, "to my tab") {
+		return TabItem{}, false
+	}
+	for _, d := range drinkCatalog() {
+		if strings.Contains(lower, strings.ToLower(d.Name)) {
+			return d.tabItem(), true
+		}
+	}
+	for _, d := range foodCatalog() {
+		if strings.Contains(lower, strings.ToLower(d.Name)) {
+			return d.tabItem(), true
+		}
+	}
+	return TabItem{}, false
+}
+
+// id is the catalog ID of the drink or dish.
+func (item TabItem) id() string {
+	if item.DishID != "" {
+		return item.DishID
+	}
+	return item.DrinkID
+}
+
+// tabContext describes a drink or dish just put on a tab for the agent.
+func tabContext(item TabItem, tab *Tab) map[string]interface{} {
+	ctx := map[string]interface{}{
+		"price":   item.Price,
+		"balance": tab.Balance,
+		"orders":  tab.Orders,
+	}
+	if item.DishID != "" {
+		ctx["dish_id"], ctx["dish_name"] = item.DishID, item.Name
+	} else {
+		ctx["drink_id"], ctx["drink_name"] = item.DrinkID, item.Name
+	}
+	return ctx
+}
+
+// sessionOrder is a drink or dish a customer ordered in a channel, kept so
+// the agent can bring up earlier rounds.
+type sessionOrder struct {
+	channelID string
+	item      TabItem
+	time      time.Time
+}
+
+// maxSessionOrders is how many orders per customer the agent is reminded of.
+const maxSessionOrders = 10
+
+// sessionOrders are each customer's recent orders by user ID. Orders older
+// than the cache TTL have left the conversation anyway.
+var sessionOrders = newLRUCache[string, []sessionOrder]("session_orders", 5000, 6*time.Hour)
+
+func init() {
+	trackCache(sessionOrders)
+	registerCommand(command{name: "tab", handler: tabCommand})
+	registerDataEraser(dataEraser{name: "session orders", user: eraseSessionOrders})
+}
+
+// rememberOrder records an order for the channel's session, whether or not
+// it went on a tab.
+func rememberOrder(channelID, userID string, item TabItem) {
+	orders, _ := sessionOrders.Get(userID)
+	orders = append(append([]sessionOrder(nil), orders...), sessionOrder{channelID: channelID, item: item, time: time.Now().UTC()})
+	if len(orders) > maxSessionOrders {
+		orders = orders[len(orders)-maxSessionOrders:]
+	}
+	sessionOrders.Add(userID, orders)
+}
+
+// sessionOrdersContext lists what the user ordered in the channel's session,
+// oldest first, for the agent, or nil.
+func sessionOrdersContext(channelID, userID string) []map[string]interface{} {
+	orders, _ := sessionOrders.Get(userID)
+	var out []map[string]interface{}
+	for _, o := range orders {
+		if o.channelID != channelID {
+			continue
+		}
+		itemType, id := "drink", o.item.DrinkID
+		if o.item.DishID != "" {
+			itemType, id = "food", o.item.DishID
+		}
+		out = append(out, map[string]interface{}{
+			"item_type": itemType,
+			"id":        id,
+			"name":      o.item.Name,
+			"time":      o.time.Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
+func eraseSessionOrders(s *discordgo.Session, userID string) (int, error) {
+	orders, ok := sessionOrders.Get(userID)
+	if !ok {
+		return 0, nil
+	}
+	sessionOrders.Remove(userID)
+	return len(orders), nil
+}
+
+// tabCommand is `!elsie tab [clear|top]`.
+func tabCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Tabs are per server — use this command in a server channel.")
+		return
+	}
+	sub := ""
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	switch sub {
+	case "":
+		tabsMu.Lock()
+		tab := loadTabs(ctx.m.GuildID)[ctx.m.Author.ID]
+		tabsMu.Unlock()
+		if tab == nil || tab.Balance == 0 {
+			ctx.reply("🧾 *Elsie checks the ledger* Your tab's clean. Order with `/order` or `/replicate`, or ask me to put one on your tab.")
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "🧾 **Your tab** — %d credits\n", tab.Balance)
+		for _, item := range tab.Items {
+			fmt.Fprintf(&b, "• %s — %d (%s)\n", item.Name, item.Price, item.Time.Format("Jan 2 15:04"))
+		}
+		b.WriteString("Settle up with `!elsie tab clear`.")
+		ctx.reply(b.String())
+	case "clear":
+		settled, err := clearTab(ctx.m.GuildID, ctx.m.Author.ID)
+		if err != nil {
+			log.Printf("Error clearing tab: %v", err)
+			ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+			return
+		}
+		if settled == 0 {
+			ctx.reply("🧾 Nothing to settle — your tab's already clean.")
+			return
+		}
+		ctx.reply(fmt.Sprintf("🧾 *Elsie stamps the ledger* %d credits settled. Pleasure doing business.", settled))
+	case "top":
+		tabsMu.Lock()
+		tabs := loadTabs(ctx.m.GuildID)
+		tabsMu.Unlock()
+		ctx.replyQuietly(tabLeaderboard(tabs))
+	default:
+		ctx.reply("Usage: `!elsie tab`, `!elsie tab clear`, `!elsie tab top`")
+	}
+}
+
+// tabLeaderboard ranks the guild's best customers by lifetime spend.
+func tabLeaderboard(tabs map[string]*Tab) string {
+	ids := make([]string, 0, len(tabs))
+	for id, tab := range tabs {
+		if tab.Orders > 0 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return "🏆 Nobody's run up a tab yet."
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := tabs[ids[i]], tabs[ids[j]]
+		if a.Lifetime != b.Lifetime {
+			return a.Lifetime > b.Lifetime
+		}
+		return a.Orders > b.Orders
+	})
+	if len(ids) > 10 {
+		ids = ids[:10]
+	}
+	var b strings.Builder
+	b.WriteString("🏆 **The bar's best customers**\n")
+	for i, id := range ids {
+		fmt.Fprintf(&b, "%d. <@%s> — %d credits over %d orders\n", i+1, id, tabs[id].Lifetime, tabs[id].Orders)
+	}
+	return b.String()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const defaultThemeName = "starfleet"
+
+// theme is a guild's flavor pack for system messages: canned phrases
+// (text/template strings, several variants picked at random), emoji and
+// embed colors. Anything a theme leaves out comes from the default theme.
+type theme struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Phrases     map[string][]string `json:"phrases"`
+	Emoji       map[string]string   `json:"emoji"`
+	Colors      map[string]int      `json:"colors"`
+}
+
+var themes = map[string]*theme{
+	"starfleet": {
+		Name:        "starfleet",
+		Description: "Ten Forward aboard a Starfleet vessel (default)",
+		Phrases: map[string][]string{
+			"save_failed":      {"*holographic matrix flickers* I couldn't save that setting. Please try again later."},
+			"inbound_blocked":  {"*Elsie sets down the glass* I'm afraid I can't serve that one."},
+			"outbound_blocked": {"*Elsie pauses, then thinks better of what she was about to say.*"},
+			"nsfw_refusal":     {"*Elsie shakes her head* I don't tend bar in this part of the station, I'm afraid."},
+			"shutdown_notice":  {"*Elsie steps into the back room for a moment.* Don't go anywhere — I'll be right back."},
+			"back_notice":      {"*Elsie steps back behind the bar, straightening her uniform.* Sorry about that. Where were we?"},
+			"quota_exceeded":   {"*Elsie holds up a hand* Easy there — {{.Who}} had a lot to drink lately. Try again in {{.Wait}}."},
+			"big_tipper":       {"*Elsie rings the little brass bell behind the bar* Another round of thanks for {{.Who}} — {{.Total}} karma tipped so far. Generous as ever."},
+			"thinking":         {"*Elsie taps the replicator controls...*", "*Elsie holds up a finger while the replicator hums...*"},
+			"poll_winner":      {"*Elsie taps the display on the bar* The votes are in on \"{{.Question}}\": {{.Winner}} it is, with {{.Votes}} of {{.Total}} votes."},
+			"poll_tie":         {"*Elsie raises an eyebrow* A dead heat on \"{{.Question}}\" — {{.Winner}} with {{.Votes}} votes each. Someone had better break the tie."},
+			"poll_no_votes":    {"*Elsie wipes down the empty ballot box* Nobody voted on \"{{.Question}}\". Next round's on whoever speaks up first."},
+			"drink_of_the_day": {"*Elsie chalks a new name on the board behind the bar* Today's special is the {{.Drink}}. The first one's on the house — well, almost."},
+		},
+		Emoji: map[string]string{
+			"bar":          "🍺",
+			"announcement": "📢",
+		},
+		Colors: map[string]int{
+			"info":      0x3498DB,
+			"highlight": 0xF1C40F,
+			"warning":   0xE67E22,
+			"danger":    0xC0392B,
+		},
+	},
+	"klingon": {
+		Name:        "klingon",
+		Description: "A Klingon mead hall — blood wine and bluster",
+		Phrases: map[string][]string{
+			"save_failed":      {"*slams the tankard down* The data core refuses my command! Try again later, warrior."},
+			"inbound_blocked":  {"*Elsie growls and pours the blood wine back* That request has no honor. I will not serve it."},
+			"outbound_blocked": {"*Elsie bares her teeth, then thinks better of the insult she was about to deliver.*"},
+			"nsfw_refusal":     {"*Elsie folds her arms* Even a Klingon hall has corners I do not serve."},
+			"shutdown_notice":  {"*Elsie hefts a cask toward the back room* Guard my hall, warriors. I return shortly!"},
+			"back_notice":      {"*Elsie kicks the back-room door open and returns to the bar* I am back! Who needs more blood wine?"},
+			"quota_exceeded":   {"*Elsie blocks the cask* Enough! {{.Who}} drunk deep already. Return in {{.Wait}}, if you can still stand."},
+			"big_tipper":       {"*Elsie pounds the table* Hear me! {{.Who}} has tipped {{.Total}} karma. A warrior of true generosity!"},
+			"thinking":         {"*Elsie hauls a fresh cask up from the cellar...*", "*Elsie growls at the stubborn tap...*"},
+			"poll_winner":      {"*Elsie bangs her tankard on the table* The hall has spoken on \"{{.Question}}\": {{.Winner}}, with {{.Votes}} of {{.Total}} votes! Qapla'!"},
+			"poll_tie":         {"*Elsie snarls* A tie on \"{{.Question}}\" — {{.Winner}} with {{.Votes}} votes each. Settle it with honor!"},
+			"poll_no_votes":    {"*Elsie glares around the hall* Not one warrior voted on \"{{.Question}}\"? Cowards, all of you."},
+			"drink_of_the_day": {"*Elsie slams a tankard on the table* Today the hall drinks {{.Drink}}! Drink deep, or drink elsewhere."},
+		},
+		Emoji: map[string]string{
+			"bar":          "🍷",
+			"announcement": "⚔️",
+		},
+		Colors: map[string]int{
+			"info":      0x8B0000,
+			"highlight": 0xB8860B,
+			"warning":   0xA0522D,
+			"danger":    0x5C0000,
+		},
+	},
+	"civilian": {
+		Name:        "civilian",
+		Description: "A civilian cantina on a trade station",
+		Phrases: map[string][]string{
+			"save_failed":      {"*Elsie taps the counter terminal* Hm, the station computer didn't take that. Try again in a bit?"},
+			"inbound_blocked":  {"*Elsie slides the glass back* Sorry, friend, house rules — can't serve that one."},
+			"outbound_blocked": {"*Elsie opens her mouth, then decides to keep that one to herself.*"},
+			"nsfw_refusal":     {"*Elsie shakes her head* That corner of the station's not my beat, sorry."},
+			"shutdown_notice":  {"*Elsie flips the sign to \"back in five\"* Just a quick restock — hang tight."},
+			"back_notice":      {"*Elsie flips the sign back to \"open\"* All restocked. What can I get you?"},
+			"quota_exceeded":   {"*Elsie caps the bottle* Slow down — {{.Who}} run up quite a bill already. Try again in {{.Wait}}."},
+			"big_tipper":       {"*Elsie tips her hat* {{.Who}}, that makes {{.Total}} karma you've tipped. The regulars won't forget it."},
+			"thinking":         {"*Elsie rummages under the counter...*", "*Elsie squints at the order screen...*"},
+			"poll_winner":      {"*Elsie reads the tally off the counter screen* On \"{{.Question}}\", it's {{.Winner}} — {{.Votes}} of {{.Total}} votes."},
+			"poll_tie":         {"*Elsie shrugs* Even split on \"{{.Question}}\": {{.Winner}}, {{.Votes}} votes each. Flip a credit chip?"},
+			"poll_no_votes":    {"*Elsie taps the empty screen* Nobody voted on \"{{.Question}}\". Guess it can wait."},
+			"drink_of_the_day": {"*Elsie flips the chalkboard around* Today's special: {{.Drink}}. Trust me on this one."},
+		},
+		Emoji: map[string]string{
+			"bar":          "🥃",
+			"announcement": "📣",
+		},
+		Colors: map[string]int{
+			"info":      0x16A085,
+			"highlight": 0xD35400,
+			"warning":   0xF39C12,
+			"danger":    0x7F8C8D,
+		},
+	},
+}
+
+// loadThemePacks merges THEME_PACKS_FILE, a JSON array of themes, into the
+// built-ins. A pack with a built-in's name overrides only what it sets.
+func loadThemePacks() {
+	if config().ThemePacksFile == "" {
+		return
+	}
+	data, err := os.ReadFile(config().ThemePacksFile)
+	if err != nil {
+		log.Printf("Error reading theme packs, using built-ins: %v", err)
+		return
+	}
+	var packs []*theme
+	if err := json.Unmarshal(data, &packs); err != nil {
+		log.Printf("Invalid theme packs %s, using built-ins: %v", config().ThemePacksFile, err)
+		return
+	}
+	for _, p := range packs {
+		p.Name = strings.ToLower(p.Name)
+		if p.Name == "" {
+			continue
+		}
+		existing, ok := themes[p.Name]
+		if !ok {
+			themes[p.Name] = p
+			continue
+		}
+		for k, v := range p.Phrases {
+			existing.Phrases[k] = v
+		}
+		for k, v := range p.Emoji {
+			existing.Emoji[k] = v
+		}
+		for k, v := range p.Colors {
+			existing.Colors[k] = v
+		}
+		if p.Description != "" {
+			existing.Description = p.Description
+		}
+	}
+	log.Printf("🎨 Loaded %d theme packs from %s", len(packs), config().ThemePacksFile)
+}
+
+// guildTheme returns the guild's theme, or the default.
+func guildTheme(guildID string) *theme {
+	if t, ok := themes[loadGuildConfig(guildID).Theme]; ok {
+		return t
+	}
+	return themes[defaultThemeName]
+}
+
+var (
+	phraseTemplatesMu sync.Mutex
+	phraseTemplates   = map[string]*template.Template{}
+)
+
+// themePhrase renders the guild theme's phrase for key with data, falling
+// back to the default theme when the guild's doesn't define it.
+func themePhrase(guildID, key string, data interface{}) string {
+	variants := guildTheme(guildID).Phrases[key]
+	if len(variants) == 0 {
+		variants = themes[defaultThemeName].Phrases[key]
+	}
+	if len(variants) == 0 {
+		return key
+	}
+	text := translate(guildLanguage(guildID), variants[rand.Intn(len(variants))])
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	phraseTemplatesMu.Lock()
+	tmpl, ok := phraseTemplates[text]
+	if !ok {
+		var err error
+		if tmpl, err = template.New(key).Parse(text); err != nil {
+			phraseTemplatesMu.Unlock()
+			log.Printf("Invalid theme phrase %q: %v", key, err)
+			return text
+		}
+		phraseTemplates[text] = tmpl
+	}
+	phraseTemplatesMu.Unlock()
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		log.Printf("Error rendering theme phrase %q: %v", key, err)
+		return text
+	}
+	return b.String()
+}
+
+// themeEmoji returns the guild theme's emoji for key.
+func themeEmoji(guildID, key string) string {
+	if e, ok := guildTheme(guildID).Emoji[key]; ok {
+		return e
+	}
+	return themes[defaultThemeName].Emoji[key]
+}
+
+// themeColor returns the guild theme's embed color for key.
+func themeColor(guildID, key string) int {
+	if c, ok := guildTheme(guildID).Colors[key]; ok {
+		return c
+	}
+	return themes[defaultThemeName].Colors[key]
+}
+
+func init() {
+	registerCommand(command{name: "theme", handler: themeCommand})
+}
+
+// themeCommand is `!elsie theme [name]`.
+func themeCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Themes are per server — use this command in a server channel.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		var b strings.Builder
+		fmt.Fprintf(&b, "🎨 **Theme:** %s\n", guildTheme(ctx.m.GuildID).Name)
+		for _, name := range sortedKeys(themes) {
+			fmt.Fprintf(&b, "• `%s` — %s\n", name, themes[name].Description)
+		}
+		b.WriteString("Usage: `!elsie theme <name>`")
+		ctx.reply(b.String())
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change my theme.")
+		return
+	}
+	name := strings.ToLower(ctx.args[0])
+	if _, ok := themes[name]; !ok {
+		ctx.reply(fmt.Sprintf("I don't know a `%s` theme. Try one of: %s.", name, strings.Join(sortedKeys(themes), ", ")))
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.Theme = name }); err != nil {
+		log.Printf("Error saving theme: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	ctx.reply(fmt.Sprintf("%s Theme set to **%s**.", themeEmoji(ctx.m.GuildID, "bar"), name))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// What happens to a scene thread that is about to auto-archive, set with
+// THREAD_ARCHIVE_MODE and per guild with `!elsie scene autoarchive`.
+const (
+	threadArchiveBump   = "bump"
+	threadArchiveMarker = "marker"
+	threadArchiveOff    = "off"
+)
+
+// threadArchiveSweepInterval is how often scene threads are checked.
+const threadArchiveSweepInterval = 5 * time.Minute
+
+// threadResume is a scene thread that was unarchived, waiting for its next
+// agent call to hear about it.
+type threadResume struct {
+	Title      string
+	StartedAt  time.Time
+	ArchivedAt time.Time
+}
+
+var (
+	threadArchiveOnce sync.Once
+
+	// threadResumes holds resumed scene threads by channel ID until
+	// someone talks there.
+	threadResumes = newLRUCache[string, threadResume]("thread_resumes", 1000, 24*time.Hour)
+)
+
+func init() {
+	trackCache(threadResumes)
+	registerSceneSubcommand("autoarchive", sceneAutoArchiveCommand)
+}
+
+// threadArchiveMode is the guild's choice, or THREAD_ARCHIVE_MODE.
+func threadArchiveMode(guildID string) string {
+	if mode := loadGuildConfig(guildID).ThreadArchiveMode; mode != "" {
+		return mode
+	}
+	return config().ThreadArchiveMode
+}
+
+// startThreadArchiveWatcher starts the scene thread sweep once.
+func startThreadArchiveWatcher(s *discordgo.Session) {
+	threadArchiveOnce.Do(func() { go runThreadArchiveWatcher(s) })
+}
+
+func runThreadArchiveWatcher(s *discordgo.Session) {
+	ticker := time.NewTicker(threadArchiveSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, channelID := range store.Keys(sceneBucket) {
+			checkSceneThreadArchive(s, channelID)
+		}
+	}
+}
+
+// checkSceneThreadArchive bumps an active scene thread, or posts a "scene
+// paused" marker in it, once it is within THREAD_ARCHIVE_WARNING of
+// auto-archiving.
+func checkSceneThreadArchive(s *discordgo.Session, channelID string) {
+	sc := loadScene(channelID)
+	if !sc.Active || !sc.ArchivedAt.IsZero() {
+		return
+	}
+	channel, err := fetchChannel(s, channelID)
+	if err != nil || !channel.IsThread() || channel.ThreadMetadata == nil || channel.ThreadMetadata.Archived {
+		return
+	}
+	mode := threadArchiveMode(channel.GuildID)
+	if mode == threadArchiveOff {
+		return
+	}
+	meta := channel.ThreadMetadata
+	lastActive := meta.ArchiveTimestamp
+	if t, err := discordgo.SnowflakeTimestamp(channel.LastMessageID); err == nil && t.After(lastActive) {
+		lastActive = t
+	}
+	archivesAt := lastActive.Add(time.Duration(meta.AutoArchiveDuration) * time.Minute)
+	if time.Until(archivesAt) > config().ThreadArchiveWarning {
+		return
+	}
+
+	switch mode {
+	case threadArchiveBump:
+		// Changing the auto-archive duration restarts Discord's inactivity
+		// timer; setting it straight back leaves the thread as it was.
+		other := 10080
+		if meta.AutoArchiveDuration == other {
+			other = 4320
+		}
+		for _, d := range []int{other, meta.AutoArchiveDuration} {
+			if _, err := s.ChannelEditComplex(channelID, &discordgo.ChannelEdit{AutoArchiveDuration: d}); err != nil {
+				log.Printf("Error bumping scene thread %s: %v", channelID, err)
+				return
+			}
+		}
+		channelCache.Remove(channelID)
+		log.Printf("🧵 Bumped scene thread %s before it auto-archived", channelID)
+	case threadArchiveMarker:
+		if sc.PauseMarkerID != "" && sc.PauseMarkerID == channel.LastMessageID {
+			return
+		}
+		msg, err := s.ChannelMessageSendEmbed(channelID, &discordgo.MessageEmbed{
+			Title:       tr(channel.GuildID, "⏸️ Scene paused"),
+			Description: tr(channel.GuildID, "This thread has gone quiet and will archive soon. Post here any time to pick the scene back up — I'll remember where we left off."),
+			Color:       themeColor(channel.GuildID, "info"),
+		})
+		if err != nil {
+			log.Printf("Error posting scene paused marker in %s: %v", channelID, err)
+			return
+		}
+		if err := updateScene(channelID, func(sc *Scene) { sc.PauseMarkerID = msg.ID }); err != nil {
+			log.Printf("Error saving scene paused marker for %s: %v", channelID, err)
+		}
+		log.Printf("🧵 Marked scene thread %s as paused before it auto-archived", channelID)
+	default:
+		return
+	}
+	metrics.Inc(metricLabel("thread_archive_actions_total", "action", mode))
+}
+
+// threadUpdate keeps scene threads open in bump mode, and notes when one is
+// archived so that unarchiving it restores the scene's session.
+func threadUpdate(s *discordgo.Session, t *discordgo.ThreadUpdate) {
+	if t.Channel == nil {
+		return
+	}
+	channelCache.Add(t.ID, t.Channel)
+	if t.ThreadMetadata == nil || !botReady.Load() {
+		return
+	}
+	sc := loadScene(t.ID)
+	if !sc.Active {
+		return
+	}
+	archived := t.ThreadMetadata.Archived
+	switch {
+	case archived && sc.ArchivedAt.IsZero():
+		if threadArchiveMode(t.GuildID) == threadArchiveBump && !t.ThreadMetadata.Locked {
+			unarchived := false
+			_, err := s.ChannelEditComplex(t.ID, &discordgo.ChannelEdit{Archived: &unarchived})
+			if err == nil {
+				log.Printf("🧵 Reopened scene thread %s after it auto-archived", t.ID)
+				metrics.Inc(metricLabel("thread_archive_actions_total", "action", "reopened"))
+				return
+			}
+			log.Printf("Error reopening scene thread %s: %v", t.ID, err)
+		}
+		if err := updateScene(t.ID, func(sc *Scene) { sc.ArchivedAt = time.Now() }); err != nil {
+			log.Printf("Error recording archived scene thread %s: %v", t.ID, err)
+		}
+	case !archived && !sc.ArchivedAt.IsZero():
+		restoreSceneSession(s, t.Channel, sc)
+	}
+}
+
+// restoreSceneSession runs when an archived scene thread is opened again.
+// It reloads the session's memory checkpoint and queues the scene details
+// for the next agent call, so Elsie picks up where the scene paused.
+func restoreSceneSession(s *discordgo.Session, channel *discordgo.Channel, sc *Scene) {
+	err := updateScene(channel.ID, func(sc *Scene) {
+		sc.ArchivedAt, sc.PauseMarkerID = time.Time{}, ""
+	})
+	if err != nil {
+		log.Printf("Error recording resumed scene thread %s: %v", channel.ID, err)
+	}
+	sessionID := channelPersona(s, channel.GuildID, channel.ID).sessionID(channel.ID)
+	checkpointMu.Lock()
+	delete(checkpoints, sessionID)
+	checkpoint(sessionID)
+	checkpointMu.Unlock()
+	threadResumes.Add(channel.ID, threadResume{Title: sc.Title, StartedAt: sc.StartedAt, ArchivedAt: sc.ArchivedAt})
+	log.Printf("🧵 Scene thread %s unarchived after %s; restored session %s", channel.ID, time.Since(sc.ArchivedAt).Round(time.Minute), sessionID)
+	metrics.Inc(metricLabel("thread_archive_actions_total", "action", "restored"))
+}
+
+// sceneResumeContext tells the agent, once, that the thread's scene was
+// archived and has just been picked back up, or returns nil.
+func sceneResumeContext(channelID string) map[string]interface{} {
+	r, ok := threadResumes.Get(channelID)
+	if !ok {
+		return nil
+	}
+	threadResumes.Remove(channelID)
+	return map[string]interface{}{
+		"title":       r.Title,
+		"started_at":  r.StartedAt.UTC().Format(time.RFC3339),
+		"archived_at": r.ArchivedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// sceneAutoArchiveCommand is `!elsie scene autoarchive [bump|marker|off|default]`.
+func sceneAutoArchiveCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie scene autoarchive bump|marker|off|default`"
+	if len(ctx.args) == 0 {
+		ctx.reply("🧵 **Scene threads about to auto-archive:** " + threadArchiveMode(ctx.m.GuildID) + "\n" + usage)
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can change what happens to quiet scene threads.")
+		return
+	}
+	mode := strings.ToLower(ctx.args[0])
+	switch mode {
+	case threadArchiveBump, threadArchiveMarker, threadArchiveOff:
+	case "default":
+		mode = ""
+	default:
+		ctx.reply(usage)
+		return
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.ThreadArchiveMode = mode }); err != nil {
+		log.Printf("Error saving thread archive mode: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	switch threadArchiveMode(ctx.m.GuildID) {
+	case threadArchiveBump:
+		ctx.reply("🧵 I'll keep active scene threads from auto-archiving.")
+	case threadArchiveMarker:
+		ctx.reply("🧵 I'll post a \"scene paused\" marker in active scene threads before they auto-archive.")
+	default:
+		ctx.reply("🧵 I'll leave quiet scene threads to auto-archive.")
+	}
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const triviaBucket = "trivia"
+
+// maxTriviaRounds caps one game so a channel isn't taken over for long.
+const maxTriviaRounds = 20
+
+// TriviaQuestion is one question; any of Answers is accepted.
+type TriviaQuestion struct {
+	Set      string   `json:"set"`
+	Question string   `json:"question"`
+	Answers  []string `json:"answers"`
+}
+
+// TriviaScore is a player's running total in one guild.
+type TriviaScore struct {
+	Points int `json:"points"`
+	Games  int `json:"games"`
+}
+
+// defaultTriviaPack is the built-in question pack.
+var defaultTriviaPack = []TriviaQuestion{
+	{Set: "trek", Question: "What is the registry number of the Enterprise-D?", Answers: []string{"NCC-1701-D", "1701-D", "1701D"}},
+	{Set: "trek", Question: "Who tends bar in Ten Forward aboard the Enterprise-D?", Answers: []string{"Guinan"}},
+	{Set: "trek", Question: "What species is Worf?", Answers: []string{"Klingon"}},
+	{Set: "trek", Question: "What is the name of Data's cat?", Answers: []string{"Spot"}},
+	{Set: "trek", Question: "Which Ferengi runs the bar on Deep Space Nine?", Answers: []string{"Quark"}},
+	{Set: "trek", Question: "What is the Klingon homeworld?", Answers: []string{"Qo'noS", "Kronos"}},
+	{Set: "trek", Question: "What drink does Captain Picard order from the replicator?", Answers: []string{"Earl Grey", "Tea, Earl Grey, hot", "Tea"}},
+	{Set: "trek", Question: "What substitute for alcohol is served aboard Starfleet ships?", Answers: []string{"Synthehol"}},
+	{Set: "trek", Question: "What is the name of the Vulcan mind technique for sharing thoughts?", Answers: []string{"Mind meld", "Vulcan mind meld"}},
+	{Set: "trek", Question: "What crystals regulate a starship's matter-antimatter reaction?", Answers: []string{"Dilithium"}},
+	{Set: "bar", Question: "What green Romulan drink is technically illegal in the Federation?", Answers: []string{"Romulan ale"}},
+	{Set: "bar", Question: "What honored Klingon drink is often served warm?", Answers: []string{"Blood wine", "Bloodwine"}},
+	{Set: "bar", Question: "What is the classic Ferengi soft drink?", Answers: []string{"Slug-o-cola", "Slug-o-Cola"}},
+	{Set: "bar", Question: "Which blue Andorian liquor shares its name with the planet?", Answers: []string{"Andorian ale"}},
+	{Set: "bar", Question: "What Saurian drink did Dr. McCoy keep in his office?", Answers: []string{"Saurian brandy"}},
+	{Set: "bar", Question: "What Cardassian liqueur is known for being an acquired taste?", Answers: []string{"Kanar"}},
+}
+
+// triviaPack is the local question pack in effect.
+func triviaPack() []TriviaQuestion {
+	return currentCatalogs.Load().trivia
+}
+
+// triviaGame is a game in progress in one channel. Games live in memory
+// only; a restart ends them without scoring.
+type triviaGame struct {
+	guildID   string
+	channelID string
+	startedBy string
+	questions []TriviaQuestion
+	round     int // index of the current question
+	open      bool
+	timer     *time.Timer
+	points    map[string]int
+}
+
+var (
+	triviaMu    sync.Mutex
+	triviaGames = map[string]*triviaGame{} // by channel ID
+)
+
+func init() {
+	registerCommand(command{name: "trivia", handler: triviaCommand})
+}
+
+// loadTriviaPack reads the questions at path, TRIVIA_PACK_FILE, a JSON
+// array of questions, falling back to the built-in ones.
+func loadTriviaPack(path string) []TriviaQuestion {
+	if path == "" {
+		return defaultTriviaPack
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading trivia pack, using defaults: %v", err)
+		return defaultTriviaPack
+	}
+	var questions []TriviaQuestion
+	if err := json.Unmarshal(data, &questions); err != nil || len(questions) == 0 {
+		log.Printf("Invalid trivia pack %s, using defaults: %v", path, err)
+		return defaultTriviaPack
+	}
+	log.Printf("❓ Loaded %d trivia questions from %s", len(questions), path)
+	return questions
+}
+
+// triviaSets lists the local pack's question sets with their sizes.
+func triviaSets() map[string]int {
+	sets := map[string]int{}
+	for _, q := range triviaPack() {
+		sets[strings.ToLower(q.Set)]++
+	}
+	return sets
+}
+
+// localTriviaQuestions picks n shuffled questions from a set, or from the
+// whole pack when set is empty.
+func localTriviaQuestions(set string, n int) []TriviaQuestion {
+	var pool []TriviaQuestion
+	for _, q := range triviaPack() {
+		if set == "" || strings.EqualFold(q.Set, set) {
+			pool = append(pool, q)
+		}
+	}
+	rand.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	if len(pool) > n {
+		pool = pool[:n]
+	}
+	return pool
+}
+
+// agentTriviaQuestions asks the agent for n questions on a topic. The agent
+// answers with a JSON array of {"question", "answers"} objects, optionally
+// wrapped in prose.
+func agentTriviaQuestions(s *discordgo.Session, guildID, channelID, topic string, n int) ([]TriviaQuestion, error) {
+	rlog := requestLog{id: newRequestID()}
+	p := channelPersona(s, guildID, channelID)
+	ctx := baseContext(s, channelID, guildID, nil)
+	ctx["request_id"] = rlog.id
+	ctx["persona"] = p.ID
+	ctx["intent"] = "trivia_questions"
+	ctx["trivia"] = map[string]interface{}{"topic": topic, "count": n}
+	prompt := fmt.Sprintf(`Write %d short trivia questions about %s. Reply with only a JSON array of objects with "question" and "answers" (a list of accepted short answers).`, n, topic)
+	resp, err := callAgent(Message{Message: prompt, Context: ctx, RequestID: rlog.id, Persona: p.ID})
+	if err != nil {
+		return nil, err
+	}
+	text := resp.Response
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in trivia response")
+	}
+	var questions []TriviaQuestion
+	if err := json.Unmarshal([]byte(text[start:end+1]), &questions); err != nil {
+		return nil, fmt.Errorf("decoding trivia questions: %w", err)
+	}
+	valid := questions[:0]
+	for _, q := range questions {
+		if strings.TrimSpace(q.Question) != "" && len(q.Answers) > 0 {
+			q.Set = topic
+			valid = append(valid, q)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("agent returned no usable trivia questions")
+	}
+	if len(valid) > n {
+		valid = valid[:n]
+	}
+	return valid, nil
+}
+
+// normalizeAnswer folds case, punctuation and leading articles so "The
+// Enterprise!" matches "enterprise".
+func normalizeAnswer(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			b.WriteRune(r)
+		}
+	}
+	words := strings.Fields(b.String())
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+func (q TriviaQuestion) accepts(guess string) bool {
+	guess = normalizeAnswer(guess)
+	if guess == "" {
+		return false
+	}
+	for _, a := range q.Answers {
+		if normalizeAnswer(a) == guess {
+			return true
+		}
+	}
+	return false
+}
+
+// askTriviaQuestion posts the current question and starts its answer
+// window. Callers hold triviaMu.
+func askTriviaQuestion(s *discordgo.Session, g *triviaGame) {
+	q := g.questions[g.round]
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("❓ Question %d of %d", g.round+1, len(g.questions)),
+		Description: q.Question,
+		Color:       themeColor(g.guildID, "info"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s • answer in chat within %s", q.Set, config().TriviaAnswerWindow)},
+	}
+	if _, err := s.ChannelMessageSendEmbed(g.channelID, embed); err != nil {
+		log.Printf("Error posting trivia question in %s: %v", g.channelID, err)
+	}
+	g.open = true
+	round := g.round
+	g.timer = time.AfterFunc(config().TriviaAnswerWindow, func() { triviaTimeout(s, g, round) })
+}
+
+// triviaTimeout closes a question nobody got right.
+func triviaTimeout(s *discordgo.Session, g *triviaGame, round int) {
+	triviaMu.Lock()
+	defer triviaMu.Unlock()
+	if triviaGames[g.channelID] != g || g.round != round || !g.open {
+		return
+	}
+	g.open = false
+	s.ChannelMessageSend(g.channelID, fmt.Sprintf("⏰ Time's up! The answer was **%s**.", g.questions[round].Answers[0]))
+	advanceTrivia(s, g)
+}
+
+// advanceTrivia moves to the next question after a short pause, or ends the
+// game. Callers hold triviaMu.
+func advanceTrivia(s *discordgo.Session, g *triviaGame) {
+	g.round++
+	if g.round >= len(g.questions) {
+		endTrivia(s, g)
+		return
+	}
+	round := g.round
+	g.timer = time.AfterFunc(3*time.Second, func() {
+		triviaMu.Lock()
+		defer triviaMu.Unlock()
+		if triviaGames[g.channelID] == g && g.round == round {
+			askTriviaQuestion(s, g)
+		}
+	})
+}
+
+// endTrivia posts the results, adds them to the guild's scores and frees
+// the channel. Callers hold triviaMu.
+func endTrivia(s *discordgo.Session, g *triviaGame) {
+	delete(triviaGames, g.channelID)
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	metrics.Inc("trivia_games_total")
+
+	var lines []string
+	for _, e := range rankScores(g.points) {
+		lines = append(lines, fmt.Sprintf("<@%s> — %d", e.userID, e.points))
+	}
+	if len(lines) == 0 {
+		lines = []string{"Nobody scored this time. The house wins!"}
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "🏆 Trivia results",
+		Description: strings.Join(lines, "\n"),
+		Color:       themeColor(g.guildID, "highlight"),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "See the all-time standings with !elsie trivia top"},
+	}
+	if _, err := s.ChannelMessageSendEmbed(g.channelID, embed); err != nil {
+		log.Printf("Error posting trivia results in %s: %v", g.channelID, err)
+	}
+	if len(g.points) > 0 {
+		if err := recordTriviaScores(g.guildID, g.points); err != nil {
+			log.Printf("Error saving trivia scores for guild %s: %v", g.guildID, err)
+		}
+	}
+}
+
+// recordTriviaScores adds one game's points to the guild's scores.
+func recordTriviaScores(guildID string, points map[string]int) error {
+	scores := map[string]*TriviaScore{}
+	if _, err := store.Get(triviaBucket, guildID, &scores); err != nil {
+		return err
+	}
+	for userID, p := range points {
+		sc := scores[userID]
+		if sc == nil {
+			sc = &TriviaScore{}
+			scores[userID] = sc
+		}
+		sc.Points += p
+		sc.Games++
+	}
+	return store.Put(triviaBucket, guildID, scores)
+}
+
+type rankedScore struct {
+	userID string
+	points int
+}
+
+// rankScores orders players by points, highest first.
+func rankScores(points map[string]int) []rankedScore {
+	ranked := make([]rankedScore, 0, len(points))
+	for id, p := range points {
+		ranked = append(ranked, rankedScore{id, p})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].points != ranked[j].points {
+			return ranked[i].points > ranked[j].points
+		}
+		return ranked[i].userID < ranked[j].userID
+	})
+	return ranked
+}
+
+// checkTriviaAnswer treats messages in a channel with an open question as
+// guesses. It reports whether the message was taken by the game, which
+// keeps guesses from reaching the agent.
+func checkTriviaAnswer(s *discordgo.Session, m *discordgo.MessageCreate, content string) bool {
+	triviaMu.Lock()
+	defer triviaMu.Unlock()
+	g, ok := triviaGames[m.ChannelID]
+	if !ok {
+		return false
+	}
+	if !g.open || !g.questions[g.round].accepts(content) {
+		return true
+	}
+	g.open = false
+	g.timer.Stop()
+	g.points[m.Author.ID]++
+	metrics.Inc("trivia_correct_answers_total")
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("🎉 <@%s> got it! The answer was **%s**.", m.Author.ID, g.questions[g.round].Answers[0]))
+	advanceTrivia(s, g)
+	return true
+}
+
+// triviaCommand is `!elsie trivia [start [rounds] [set|topic]|stop|top|sets]`.
+func triviaCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie trivia start [rounds] [set or topic]`, `!elsie trivia stop`, `!elsie trivia top`, `!elsie trivia sets`"
+	if ctx.m.GuildID == "" {
+		ctx.reply("Trivia is played in server channels — start a game there.")
+		return
+	}
+	sub := ""
+	if len(ctx.args) > 0 {
+		sub = strings.ToLower(ctx.args[0])
+	}
+	switch sub {
+	case "start":
+		startTrivia(ctx, ctx.args[1:])
+	case "stop":
+		triviaMu.Lock()
+		defer triviaMu.Unlock()
+		g, ok := triviaGames[ctx.m.ChannelID]
+		if !ok {
+			ctx.reply("❓ There's no trivia game running here.")
+			return
+		}
+		if g.startedBy != ctx.m.Author.ID && !isModerator(ctx.s, ctx.m) {
+			ctx.reply("*shakes head* Only whoever started the game or a moderator can stop it.")
+			return
+		}
+		ctx.reply("❓ Calling it early!")
+		endTrivia(ctx.s, g)
+	case "top":
+		showTriviaLeaderboard(ctx)
+	case "sets":
+		sets := triviaSets()
+		names := make([]string, 0, len(sets))
+		for name := range sets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString("❓ **Trivia sets**\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "• `%s` — %d questions\n", name, sets[name])
+		}
+		b.WriteString("Any other topic is written by the agent, e.g. `!elsie trivia start 5 Deep Space Nine`.")
+		ctx.reply(b.String())
+	default:
+		ctx.reply(usage)
+	}
+}
+
+// startTrivia starts a game in the command's channel. A known set name
+// uses the local pack; any other topic asks the agent, falling back to the
+// local pack if it can't help.
+func startTrivia(ctx *commandContext, args []string) {
+	rounds := config().TriviaDefaultRounds
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			rounds, args = n, args[1:]
+		}
+	}
+	if rounds < 1 || rounds > maxTriviaRounds {
+		ctx.reply(fmt.Sprintf("❓ Pick between 1 and %d rounds.", maxTriviaRounds))
+		return
+	}
+	topic := strings.Join(args, " ")
+
+	triviaMu.Lock()
+	_, running := triviaGames[ctx.m.ChannelID]
+	triviaMu.Unlock()
+	if running {
+		ctx.reply("❓ A game is already running here. `!elsie trivia stop` ends it.")
+		return
+	}
+
+	var questions []TriviaQuestion
+	if _, known := triviaSets()[strings.ToLower(topic)]; topic != "" && !known {
+		ctx.s.ChannelTyping(ctx.m.ChannelID)
+		qs, err := agentTriviaQuestions(ctx.s, ctx.m.GuildID, ctx.m.ChannelID, topic, rounds)
+		if err != nil {
+			log.Printf("Error fetching trivia questions from agent: %v", err)
+			ctx.reply("❓ I couldn't come up with questions on that, so we'll use the house pack.")
+			topic = ""
+		}
+		questions = qs
+	}
+	if questions == nil {
+		questions = localTriviaQuestions(topic, rounds)
+	}
+	if len(questions) == 0 {
+		ctx.reply("❓ I'm out of questions for that set.")
+		return
+	}
+
+	triviaMu.Lock()
+	defer triviaMu.Unlock()
+	if _, running := triviaGames[ctx.m.ChannelID]; running {
+		return
+	}
+	g := &triviaGame{
+		guildID:   ctx.m.GuildID,
+		channelID: ctx.m.ChannelID,
+		startedBy: ctx.m.Author.ID,
+		questions: questions,
+		points:    map[string]int{},
+	}
+	triviaGames[ctx.m.ChannelID] = g
+	log.Printf("❓ Trivia started in %s by %s (%d questions)", ctx.m.ChannelID, logUser("", ctx.m.Author.ID), len(questions))
+	ctx.reply(fmt.Sprintf("❓ **Trivia night!** %d questions — first correct answer in chat scores a point.", len(questions)))
+	askTriviaQuestion(ctx.s, g)
+}
+
+// showTriviaLeaderboard posts the guild's all-time trivia standings.
+func showTriviaLeaderboard(ctx *commandContext) {
+	scores := map[string]*TriviaScore{}
+	if _, err := store.Get(triviaBucket, ctx.m.GuildID, &scores); err != nil {
+		log.Printf("Error loading trivia scores for guild %s: %v", ctx.m.GuildID, err)
+	}
+	points := make(map[string]int, len(scores))
+	for id, sc := range scores {
+		points[id] = sc.Points
+	}
+	ranked := rankScores(points)
+	if len(ranked) == 0 {
+		ctx.reply("❓ Nobody has scored yet. Start a game with `!elsie trivia start`.")
+		return
+	}
+	medals := []string{"🥇", "🥈", "🥉"}
+	var lines []string
+	for i, e := range ranked {
+		if i == 10 {
+			break
+		}
+		marker := fmt.Sprintf("%d.", i+1)
+		if i < len(medals) {
+			marker = medals[i]
+		}
+		lines = append(lines, fmt.Sprintf("%s <@%s> — %d points in %d games", marker, e.userID, e.points, scores[e.userID].Games))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "🏆 Trivia leaderboard",
+		Description: strings.Join(lines, "\n"),
+		Color:       themeColor(ctx.m.GuildID, "highlight"),
+	}
+	if _, err := ctx.s.ChannelMessageSendEmbed(ctx.m.ChannelID, embed); err != nil {
+		log.Printf("Error posting trivia leaderboard: %v", err)
+	}
+}
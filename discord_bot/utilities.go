@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Results of local utilities are remembered per channel for a while so the
+// agent can weave them into its next in-character reply.
+var utilityResults = newLRUCache[string, []string]("utilities", 1000, 15*time.Minute)
+
+const maxUtilityResults = 5
+
+func init() {
+	trackCache(utilityResults)
+	registerCommand(command{name: "convert", handler: convertCommand})
+}
+
+// rememberUtilityResult records result for the channel unless the user asked
+// to keep it out of the conversation with --private.
+func rememberUtilityResult(ctx *commandContext, result string) {
+	if ctx.hasFlag("private") {
+		return
+	}
+	results, _ := utilityResults.Get(ctx.m.ChannelID)
+	results = append(append([]string(nil), results...), result)
+	if len(results) > maxUtilityResults {
+		results = results[len(results)-maxUtilityResults:]
+	}
+	utilityResults.Add(ctx.m.ChannelID, results)
+}
+
+// recentUtilityResults returns the channel's remembered utility results for
+// the agent context, or nil.
+func recentUtilityResults(channelID string) []string {
+	results, _ := utilityResults.Get(channelID)
+	return results
+}
+
+// withoutFlags drops `--flag` arguments.
+func withoutFlags(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+// unit is a measurement unit expressed as a factor of its dimension's base
+// unit. Temperatures are converted separately because they have offsets.
+type unit struct {
+	dimension string
+	factor    float64
+	symbol    string
+}
+
+var units = map[string]unit{
+	// length, in meters
+	"m": {"length", 1, "m"}, "meter": {"length", 1, "m"}, "metre": {"length", 1, "m"},
+	"km": {"length", 1e3, "km"}, "kilometer": {"length", 1e3, "km"}, "kilometre": {"length", 1e3, "km"},
+	"cm": {"length", 0.01, "cm"}, "centimeter": {"length", 0.01, "cm"},
+	"ft": {"length", 0.3048, "ft"}, "foot": {"length", 0.3048, "ft"}, "feet": {"length", 0.3048, "ft"},
+	"in": {"length", 0.0254, "in"}, "inch": {"length", 0.0254, "in"}, "inches": {"length", 0.0254, "in"},
+	"mi": {"length", 1609.344, "mi"}, "mile": {"length", 1609.344, "mi"},
+	"au": {"length", 1.495978707e11, "AU"},
+	"ly": {"length", 9.4607304725808e15, "ly"}, "lightyear": {"length", 9.4607304725808e15, "ly"},
+	"pc": {"length", 3.0856775814913673e16, "pc"}, "parsec": {"length", 3.0856775814913673e16, "pc"},
+	"ls": {"length", 299792458, "light-seconds"}, "lightsecond": {"length", 299792458, "light-seconds"},
+
+	// mass, in kilograms
+	"kg": {"mass", 1, "kg"}, "kilogram": {"mass", 1, "kg"},
+	"g": {"mass", 1e-3, "g"}, "gram": {"mass", 1e-3, "g"},
+	"lb": {"mass", 0.45359237, "lb"}, "lbs": {"mass", 0.45359237, "lb"}, "pound": {"mass", 0.45359237, "lb"},
+	"t": {"mass", 1000, "t"}, "tonne": {"mass", 1000, "t"}, "ton": {"mass", 1000, "t"},
+
+	// time, in seconds
+	"s": {"time", 1, "s"}, "sec": {"time", 1, "s"}, "second": {"time", 1, "s"},
+	"min": {"time", 60, "min"}, "minute": {"time", 60, "min"},
+	"h": {"time", 3600, "h"}, "hr": {"time", 3600, "h"}, "hour": {"time", 3600, "h"},
+	"day": {"time", 86400, "days"}, "week": {"time", 604800, "weeks"},
+	"year": {"time", 31557600, "years"}, "yr": {"time", 31557600, "years"},
+
+	// speed, in meters per second
+	"m/s": {"speed", 1, "m/s"}, "km/s": {"speed", 1e3, "km/s"},
+	"km/h": {"speed", 1 / 3.6, "km/h"}, "kph": {"speed", 1 / 3.6, "km/h"},
+	"mph": {"speed", 0.44704, "mph"},
+	"c":   {"speed", 299792458, "c"}, "lightspeed": {"speed", 299792458, "c"},
+}
+
+var temperatures = map[string]string{
+	"c": "°C", "celsius": "°C", "°c": "°C",
+	"f": "°F", "fahrenheit": "°F", "°f": "°F",
+	"k": "K", "kelvin": "K",
+}
+
+// lookupUnit normalizes spelling ("light years", "Kilometres") and finds the
+// unit.
+func lookupUnit(name string) (unit, bool) {
+	name = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(name))
+	if u, ok := units[name]; ok {
+		return u, true
+	}
+	u, ok := units[strings.TrimSuffix(name, "s")]
+	return u, ok
+}
+
+func toKelvin(v float64, symbol string) float64 {
+	switch symbol {
+	case "°C":
+		return v + 273.15
+	case "°F":
+		return (v-32)*5/9 + 273.15
+	}
+	return v
+}
+
+func fromKelvin(v float64, symbol string) float64 {
+	switch symbol {
+	case "°C":
+		return v - 273.15
+	case "°F":
+		return (v-273.15)*9/5 + 32
+	}
+	return v
+}
+
+func formatQuantity(v float64) string {
+	if v != 0 && (math.Abs(v) >= 1e9 || math.Abs(v) < 1e-3) {
+		return strconv.FormatFloat(v, 'e', 4, 64)
+	}
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
+
+// convertCommand is `!elsie convert <amount> <unit> to <unit>`.
+func convertCommand(ctx *commandContext) {
+	usage := "Usage: `!elsie convert 5 lightyears to km` (length, mass, time, speed, temperature) [--private]"
+	args := withoutFlags(ctx.args)
+	// "in" doubles as the inch symbol, so it only separates when "to" is absent
+	split := -1
+	for _, sep := range []string{"to", "in"} {
+		for i := len(args) - 2; i >= 2 && split < 0; i-- {
+			if strings.EqualFold(args[i], sep) {
+				split = i
+			}
+		}
+	}
+	if len(args) < 4 || split < 2 || split == len(args)-1 {
+		ctx.reply(usage)
+		return
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(args[0], ",", ""), 64)
+	if err != nil {
+		ctx.reply(usage)
+		return
+	}
+	fromName := strings.Join(args[1:split], " ")
+	toName := strings.Join(args[split+1:], " ")
+
+	var result string
+	fromTemp, fromIsTemp := temperatures[strings.ToLower(fromName)]
+	toTemp, toIsTemp := temperatures[strings.ToLower(toName)]
+	if fromIsTemp && toIsTemp {
+		converted := fromKelvin(toKelvin(amount, fromTemp), toTemp)
+		result = fmt.Sprintf("%s %s = %s %s", formatQuantity(amount), fromTemp, formatQuantity(converted), toTemp)
+	} else {
+		from, ok1 := lookupUnit(fromName)
+		to, ok2 := lookupUnit(toName)
+		if !ok1 || !ok2 {
+			ctx.reply(fmt.Sprintf("*squints at the padd* I don't know how to convert `%s` to `%s`.", fromName, toName))
+			return
+		}
+		if from.dimension != to.dimension {
+			ctx.reply(fmt.Sprintf("*raises an eyebrow* You can't turn %s into %s, not even on the holodeck.", from.dimension, to.dimension))
+			return
+		}
+		converted := amount * from.factor / to.factor
+		result = fmt.Sprintf("%s %s = %s %s", formatQuantity(amount), from.symbol, formatQuantity(converted), to.symbol)
+	}
+	rememberUtilityResult(ctx, result)
+	ctx.reply("📐 " + result)
+}
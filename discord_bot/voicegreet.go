@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voiceGreeted remembers who was greeted in the last day, per guild.
+var voiceGreeted = newLRUCache[string, time.Time]("voice_greets", 10000, 24*time.Hour)
+
+func init() {
+	trackCache(voiceGreeted)
+	registerCommand(command{name: "voicegreet", handler: voiceGreetCommand})
+}
+
+// voiceStateUpdate greets the first person into the guild's bar voice
+// channel, in that channel's text chat.
+func voiceStateUpdate(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	if v.VoiceState != nil && v.GuildID != "" {
+		leaveEmptyVoice(s, v)
+	}
+	if !botReady.Load() || safeMode.Load() || v.VoiceState == nil || v.GuildID == "" || v.ChannelID == "" {
+		return
+	}
+	if v.BeforeUpdate != nil && v.BeforeUpdate.ChannelID == v.ChannelID {
+		return // mute, deafen or stream change, not a join
+	}
+	cfg := loadGuildConfig(v.GuildID)
+	if cfg.GreetVoiceChannelID == "" || cfg.GreetVoiceChannelID != v.ChannelID {
+		return
+	}
+	if v.Member != nil && v.Member.User != nil && v.Member.User.Bot {
+		return
+	}
+	if occupied(s, v.GuildID, v.ChannelID, v.UserID) {
+		return
+	}
+	key := v.GuildID + ":" + v.UserID
+	if _, seen := voiceGreeted.Get(key); seen {
+		return
+	}
+	voiceGreeted.Add(key, time.Now())
+	go greetVoiceJoin(s, v.GuildID, v.ChannelID, v.UserID)
+}
+
+// occupied reports whether anyone other than userID (and other bots) is
+// already in the voice channel.
+func occupied(s *discordgo.Session, guildID, channelID, userID string) bool {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return true // without state we can't tell; stay quiet
+	}
+	s.State.RLock()
+	defer s.State.RUnlock()
+	for _, vs := range guild.VoiceStates {
+		if vs.ChannelID != channelID || vs.UserID == userID || vs.UserID == s.State.User.ID {
+			continue
+		}
+		if vs.Member != nil && vs.Member.User != nil && vs.Member.User.Bot {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// greetVoiceJoin asks the agent for a short greeting and posts it in the
+// voice channel's text chat, falling back to a canned line.
+func greetVoiceJoin(s *discordgo.Session, guildID, channelID, userID string) {
+	rlog := requestLog{id: newRequestID()}
+	p := channelPersona(s, guildID, channelID)
+	text := fmt.Sprintf("*Elsie looks up from polishing a glass.* Welcome in, <@%s>! First one here — what can I get you?", userID)
+
+	var user *discordgo.User
+	if member, err := getMember(s, guildID, userID); err == nil {
+		user = member.User
+	}
+	ctx := baseContext(s, channelID, guildID, user)
+	ctx["session_id"] = p.sessionID(channelID)
+	ctx["request_id"] = rlog.id
+	ctx["persona"] = p.ID
+	ctx["intent"] = "voice_greeting"
+	prompt := fmt.Sprintf("<@%s> just walked into the empty bar's voice channel. Greet them briefly, in character, in one sentence, mentioning them as <@%s>.", userID, userID)
+	resp, err := callAgent(Message{Message: prompt, Context: ctx, RequestID: rlog.id, Persona: p.ID})
+	switch {
+	case err != nil:
+		rlog.Printf("Error generating voice greeting: %v", err)
+	case resp.silent():
+		return
+	case strings.TrimSpace(resp.Response) != "":
+		if screened, ok := screenContent(s, guildID, channelID, userID, "outbound", resp.Response); ok {
+			text = screened
+		}
+	}
+	if _, err := sendAs(s, channelID, p, text); err != nil {
+		rlog.Printf("Error posting voice greeting: %v", err)
+		return
+	}
+	rlog.Printf("🎙️ Greeted %s in voice channel %s", logUser("", userID), channelID)
+	metrics.Inc("voice_greetings_total")
+}
+
+// voiceGreetCommand is `!elsie voicegreet [<voice channel ID>|off]`.
+func voiceGreetCommand(ctx *commandContext) {
+	if ctx.m.GuildID == "" {
+		ctx.reply("Voice greetings are per server — use this command in a server channel.")
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply("*shakes head* Only server admins can set up voice greetings.")
+		return
+	}
+	if len(ctx.args) == 0 {
+		cfg := loadGuildConfig(ctx.m.GuildID)
+		state := "off"
+		if cfg.GreetVoiceChannelID != "" {
+			state = fmt.Sprintf("greeting the first arrival in <#%s>", cfg.GreetVoiceChannelID)
+		}
+		ctx.reply("🎙️ **Voice greetings:** " + state + "\nUsage: `!elsie voicegreet <voice channel>` or `!elsie voicegreet off`")
+		return
+	}
+
+	channelID := ""
+	if !strings.EqualFold(ctx.args[0], "off") {
+		channelID = parseChannelMention(ctx.args[0])
+		channel, err := getChannel(ctx.s, channelID)
+		if channelID == "" || err != nil || channel.GuildID != ctx.m.GuildID ||
+			(channel.Type != discordgo.ChannelTypeGuildVoice && channel.Type != discordgo.ChannelTypeGuildStageVoice) {
+			ctx.reply("*squints* That isn't a voice channel in this server. Mention it (`<#id>`) or give its ID.")
+			return
+		}
+	}
+	if err := updateGuildConfig(ctx.m.GuildID, ctx.m.Author.ID, func(cfg *GuildConfig) { cfg.GreetVoiceChannelID = channelID }); err != nil {
+		log.Printf("Error saving voice greeting channel: %v", err)
+		ctx.reply(themePhrase(ctx.m.GuildID, "save_failed", nil))
+		return
+	}
+	if channelID == "" {
+		ctx.reply("🎙️ Voice greetings are off.")
+		return
+	}
+	ctx.reply(fmt.Sprintf("🎙️ I'll greet whoever walks into an empty <#%s>, at most once a day per person.", channelID))
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voiceListener is the bot listening in one guild's voice channel. Audio
+// arrives as Opus frames per SSRC; speaking updates say which member each
+// SSRC belongs to.
+type voiceListener struct {
+	guildID   string
+	channelID string
+	vc        *discordgo.VoiceConnection
+	done      chan struct{}
+	stopOnce  sync.Once
+
+	mu       sync.Mutex
+	speakers map[uint32]string
+	speech   map[uint32]*speechBuffer
+}
+
+// speechBuffer collects one member's frames until they pause.
+type speechBuffer struct {
+	frames [][]byte
+	last   time.Time
+}
+
+var (
+	voiceListenersMu sync.Mutex
+	voiceListeners   = map[string]*voiceListener{}
+)
+
+// opusSilence is the frame Discord sends when someone stops talking.
+var opusSilence = []byte{0xF8, 0xFF, 0xFE}
+
+func init() {
+	registerCommand(command{name: "voice", handler: voiceCommand})
+}
+
+// guildVoiceListener returns the guild's listener, or nil.
+func guildVoiceListener(guildID string) *voiceListener {
+	voiceListenersMu.Lock()
+	defer voiceListenersMu.Unlock()
+	return voiceListeners[guildID]
+}
+
+// startVoiceListener joins channelID and starts transcribing what members
+// say there. A guild has at most one listener.
+func startVoiceListener(s *discordgo.Session, guildID, channelID string) error {
+	if l := guildVoiceListener(guildID); l != nil {
+		l.stop()
+	}
+	// Self-muted: Elsie answers in the text chat, not in audio
+	vc, err := s.ChannelVoiceJoin(guildID, channelID, true, false)
+	if err != nil {
+		return err
+	}
+	l := &voiceListener{
+		guildID:   guildID,
+		channelID: channelID,
+		vc:        vc,
+		done:      make(chan struct{}),
+		speakers:  map[uint32]string{},
+		speech:    map[uint32]*speechBuffer{},
+	}
+	vc.AddHandler(func(_ *discordgo.VoiceConnection, vs *discordgo.VoiceSpeakingUpdate) {
+		l.mu.Lock()
+		l.speakers[uint32(vs.SSRC)] = vs.UserID
+		l.mu.Unlock()
+	})
+	voiceListenersMu.Lock()
+	voiceListeners[guildID] = l
+	voiceListenersMu.Unlock()
+
+	go l.receive()
+	go l.flushLoop(s)
+	log.Printf("🎙️ Listening in voice channel %s", channelID)
+	return nil
+}
+
+// stop leaves the voice channel. It is safe to call more than once.
+func (l *voiceListener) stop() {
+	l.stopOnce.Do(func() {
+		close(l.done)
+		voiceListenersMu.Lock()
+		if voiceListeners[l.guildID] == l {
+			delete(voiceListeners, l.guildID)
+		}
+		voiceListenersMu.Unlock()
+		if err := l.vc.Disconnect(); err != nil {
+			log.Printf("Error leaving voice channel %s: %v", l.channelID, err)
+		}
+		log.Printf("🎙️ Stopped listening in voice channel %s", l.channelID)
+	})
+}
+
+// stopVoiceListeners leaves every voice channel, on shutdown.
+func stopVoiceListeners() {
+	voiceListenersMu.Lock()
+	listeners := make([]*voiceListener, 0, len(voiceListeners))
+	for _, l := range voiceListeners {
+		listeners = append(listeners, l)
+	}
+	voiceListenersMu.Unlock()
+	for _, l := range listeners {
+		l.stop()
+	}
+}
+
+// stopDisabledVoiceListeners leaves the voice channels of guilds that have
+// turned off content processing, so no more of their audio is transcribed.
+func stopDisabledVoiceListeners() {
+	voiceListenersMu.Lock()
+	var listeners []*voiceListener
+	for guildID, l := range voiceListeners {
+		if contentProcessingDisabled(guildID) {
+			listeners = append(listeners, l)
+		}
+	}
+	voiceListenersMu.Unlock()
+	for _, l := range listeners {
+		l.stop()
+	}
+}
+
+// receive buffers incoming frames per speaker.
+func (l *voiceListener) receive() {
+	for {
+		select {
+		case <-l.done:
+			return
+		case p, ok := <-l.vc.OpusRecv:
+			if !ok {
+				return
+			}
+			if len(p.Opus) == 0 || string(p.Opus) == string(opusSilence) {
+				continue
+			}
+			l.mu.Lock()
+			buf := l.speech[p.SSRC]
+			if buf == nil {
+				buf = &speechBuffer{}
+				l.speech[p.SSRC] = buf
+			}
+			buf.frames = append(buf.frames, p.Opus)
+			buf.last = time.Now()
+			l.mu.Unlock()
+		}
+	}
+}
+
+// flushLoop hands each speaker's buffer off for transcription once they
+// pause for VOICE_SILENCE_GAP, or talk for longer than
+// VOICE_MAX_UTTERANCE.
+func (l *voiceListener) flushLoop(s *discordgo.Session) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	maxFrames := int(config().VoiceMaxUtterance / (20 * time.Millisecond))
+	minFrames := int(config().VoiceMinUtterance / (20 * time.Millisecond))
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+		}
+		l.mu.Lock()
+		for ssrc, buf := range l.speech {
+			if time.Since(buf.last) < config().VoiceSilenceGap && len(buf.frames) < maxFrames {
+				continue
+			}
+			delete(l.speech, ssrc)
+			userID := l.speakers[ssrc]
+			if userID == "" || len(buf.frames) < minFrames {
+				continue
+			}
+			go l.handleUtterance(s, userID, buf.frames)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// voiceWakeWord reports whether a transcript is addressed to Elsie. With
+// VOICE_WAKE_WORD empty, everything said is.
+func voiceWakeWord(text string) bool {
+	if config().VoiceWakeWord == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(text), config().VoiceWakeWord)
+}
+
+// handleUtterance transcribes one utterance and, if it is addressed to
+// Elsie, answers it through the agent like a message in the voice
+// channel's text chat.
+func (l *voiceListener) handleUtterance(s *discordgo.Session, userID string, frames [][]byte) {
+	member, err := getMember(s, l.guildID, userID)
+	if err != nil || member.User == nil || member.User.Bot || userExclusion(l.guildID, userID) != "" {
+		return
+	}
+	// The audio itself is content: it isn't sent for transcription where
+	// the guild turned processing off or the channel is refused
+	if contentProcessingDisabled(l.guildID) {
+		l.stop()
+		return
+	}
+	if refusesChannel(s, l.guildID, l.channelID) {
+		return
+	}
+	rlog := requestLog{id: newRequestID()}
+	text, err := transcribe(rlog, utterance{guildID: l.guildID, channelID: l.channelID, userID: userID, audio: oggOpus(frames)})
+	if err != nil {
+		rlog.Printf("Error transcribing voice from %s: %v", logUser("", userID), err)
+		metrics.Inc(metricLabel("voice_transcriptions_total", "result", "error"))
+		return
+	}
+	metrics.Inc(metricLabel("voice_transcriptions_total", "result", "ok"))
+	if text == "" {
+		return
+	}
+	rlog.Printf("🎙️ Heard %s: %s", logUser(member.User.Username, userID), logText(text))
+	if !voiceWakeWord(text) || safeMode.Load() {
+		return
+	}
+
+	content, allowed := screenContent(s, l.guildID, l.channelID, userID, "inbound", text)
+	if !allowed {
+		return
+	}
+	if ok, scope, _ := consumeQuota(l.guildID, l.channelID, userID); !ok {
+		rlog.Printf("Voice request from %s over the %s quota", logUser("", userID), scope)
+		return
+	}
+	noteChannelActivity(l.guildID, l.channelID)
+	// In listening mode the agent still hears what's said, but Elsie
+	// doesn't answer
+	listening := channelListening(s, l.guildID, l.channelID)
+	extra := map[string]interface{}{"input_mode": "voice"}
+	if listening {
+		extra["listening"] = true
+	} else {
+		s.ChannelTyping(l.channelID)
+	}
+
+	// The transcript goes down the same path as a typed message in the
+	// voice channel's text chat
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: l.channelID,
+		GuildID:   l.guildID,
+		Author:    member.User,
+		Content:   content,
+	}}
+	p := channelPersona(s, l.guildID, l.channelID)
+	exchange := exchangeRecord{
+		Time:           time.Now(),
+		RequestID:      rlog.id,
+		GuildID:        l.guildID,
+		ChannelID:      l.channelID,
+		AuthorID:       userID,
+		Persona:        p.ID,
+		AgentSessionID: p.sessionID(l.channelID),
+		Outcome:        exchangeNoResponse,
+	}
+	defer func() { exchanges.record(exchange) }()
+
+	guildStats.recordMessage(l.guildID, l.channelID)
+	resp := processWithAIEnhanced(content, s, m, p, extra, rlog)
+	response := ""
+	if resp != nil {
+		response = resp.Response
+	}
+	guildStats.recordAgentCall(l.guildID, resp == nil, len(response))
+	if listening {
+		if resp != nil {
+			recordForwarded(p, p.sessionID(l.channelID))
+			rlog.Printf("👂 Listening mode: keeping the answer to %s's voice request to myself", logUser("", userID))
+			metrics.Inc("listening_suppressed_total")
+		}
+		return
+	}
+	if resp == nil {
+		exchange.Outcome = exchangeFallback
+		content = fallbackResponse(p, content)
+	} else {
+		recordForwarded(p, p.sessionID(l.channelID))
+		if resp.silent() || strings.TrimSpace(response) == "" {
+			return
+		}
+		if delay := resp.replyDelay(); delay > 0 {
+			waitTyping(s, l.channelID, delay)
+		}
+		var ok bool
+		if content, ok = screenContent(s, l.guildID, l.channelID, userID, "outbound", response); !ok {
+			content = themePhrase(l.guildID, "outbound_blocked", nil)
+		}
+		exchange.Outcome = exchangeSent
+	}
+
+	sent, err := sendVoiceReply(s, l.channelID, p, member, text, content)
+	exchange.ResponseMessageIDs = messageIDs(sent)
+	if err != nil {
+		rlog.Printf("Error posting voice reply: %v", err)
+		exchange.Outcome = exchangeSendError
+		return
+	}
+	metrics.Inc("voice_replies_total")
+}
+
+// sendVoiceReply posts the answer in the voice channel's text chat. With
+// VOICE_REPLY_TTS, Discord reads it aloud; otherwise it quotes what was
+// heard so the chat can follow along.
+func sendVoiceReply(s *discordgo.Session, channelID string, p *persona, member *discordgo.Member, heard, reply string) ([]*discordgo.Message, error) {
+	if !config().VoiceReplyTTS {
+		name := member.Nick
+		if name == "" {
+			name = member.User.Username
+		}
+		return sendAs(s, channelID, p, fmt.Sprintf("> 🎙️ **%s:** %s\n%s", name, truncateText(heard, 300), reply))
+	}
+	var sent []*discordgo.Message
+	for _, chunk := range messageChunks(postProcess(s, channelID, reply)) {
+		msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: chunk, TTS: true})
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}
+
+// leaveEmptyVoice stops listening once the last member leaves the channel,
+// or when the bot is moved or disconnected.
+func leaveEmptyVoice(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+	l := guildVoiceListener(v.GuildID)
+	if l == nil {
+		return
+	}
+	if v.UserID == s.State.User.ID {
+		if v.ChannelID != l.channelID {
+			l.stop()
+		}
+		return
+	}
+	if v.BeforeUpdate == nil || v.BeforeUpdate.ChannelID != l.channelID || v.ChannelID == l.channelID {
+		return
+	}
+	if !occupied(s, v.GuildID, l.channelID, "") {
+		l.stop()
+	}
+}
+
+// userVoiceChannel returns the voice channel userID is in, or "".
+func userVoiceChannel(s *discordgo.Session, guildID, userID string) string {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return ""
+	}
+	s.State.RLock()
+	defer s.State.RUnlock()
+	for _, vs := range guild.VoiceStates {
+		if vs.UserID == userID {
+			return vs.ChannelID
+		}
+	}
+	return ""
+}
+
+// voiceCommand is `!elsie voice [join [<voice channel>]|leave]`.
+func voiceCommand(ctx *commandContext) {
+	usage := ctx.tr("Usage: `!elsie voice join [voice channel]` or `!elsie voice leave`")
+	if ctx.m.GuildID == "" {
+		ctx.reply(ctx.tr("Voice listening is per server — use this command in a server channel."))
+		return
+	}
+	if !config().VoiceListenEnabled {
+		ctx.reply(ctx.tr("🎙️ Voice listening is turned off for this bot."))
+		return
+	}
+	l := guildVoiceListener(ctx.m.GuildID)
+	if len(ctx.args) == 0 {
+		if l == nil {
+			ctx.reply(ctx.tr("🎙️ I'm not listening in any voice channel.") + "\n" + usage)
+		} else {
+			ctx.reply(ctx.tr("🎙️ I'm listening in <#%s>.", l.channelID) + "\n" + usage)
+		}
+		return
+	}
+	if !isGuildAdmin(ctx.s, ctx.m) {
+		ctx.reply(ctx.tr("*shakes head* Only server admins can bring me into voice."))
+		return
+	}
+
+	switch strings.ToLower(ctx.args[0]) {
+	case "leave", "off":
+		if l == nil {
+			ctx.reply(ctx.tr("🎙️ I'm not listening in any voice channel."))
+			return
+		}
+		l.stop()
+		ctx.reply(ctx.tr("🎙️ I've stopped listening in <#%s>.", l.channelID))
+	case "join":
+		channelID := userVoiceChannel(ctx.s, ctx.m.GuildID, ctx.m.Author.ID)
+		if len(ctx.args) > 1 {
+			channelID = parseChannelMention(ctx.args[1])
+		}
+		channel, err := getChannel(ctx.s, channelID)
+		if channelID == "" || err != nil || channel.GuildID != ctx.m.GuildID ||
+			(channel.Type != discordgo.ChannelTypeGuildVoice && channel.Type != discordgo.ChannelTypeGuildStageVoice) {
+			ctx.reply(ctx.tr("*squints* Join a voice channel first, or mention one (`<#id>`)."))
+			return
+		}
+		if err := startVoiceListener(ctx.s, ctx.m.GuildID, channelID); err != nil {
+			log.Printf("Error joining voice channel %s: %v", channelID, err)
+			ctx.reply(ctx.tr("*frowns* I couldn't join <#%s>. I need Connect and View Channel there.", channelID))
+			return
+		}
+		notice := ctx.tr("🎙️ I'm listening in <#%s>. What's said there is transcribed, and I answer in its text chat.", channelID)
+		if config().VoiceWakeWord != "" {
+			notice += " " + ctx.tr("Say “%s” to get my attention.", config().VoiceWakeWord)
+		}
+		if channelID != ctx.m.ChannelID {
+			ctx.s.ChannelMessageSend(channelID, notice)
+		}
+		ctx.reply(notice)
+	default:
+		ctx.reply(usage)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	webhookBucket = "webhooks"
+	webhookName   = "Elsie Personas"
+)
+
+// storedWebhook is the bot's webhook in one channel, used to post under
+// display identities other than the bot account's.
+type storedWebhook struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+var (
+	// webhookMu serializes webhook creation so a channel gets exactly one.
+	webhookMu sync.Mutex
+	// ownWebhooks maps the IDs of webhooks the bot created to their tokens,
+	// so their messages are never mistaken for players'.
+	ownWebhooks sync.Map
+)
+
+// loadOwnWebhooks registers the webhooks recorded in the store.
+func loadOwnWebhooks() {
+	for _, channelID := range store.Keys(webhookBucket) {
+		var hook storedWebhook
+		if ok, err := store.Get(webhookBucket, channelID, &hook); ok && err == nil {
+			ownWebhooks.Store(hook.ID, hook.Token)
+		}
+	}
+}
+
+// isOwnWebhook reports whether webhookID belongs to the bot.
+func isOwnWebhook(webhookID string) bool {
+	_, ok := ownWebhooks.Load(webhookID)
+	return ok
+}
+
+// ownWebhookToken returns the token of one of the bot's webhooks.
+func ownWebhookToken(webhookID string) (string, bool) {
+	token, ok := ownWebhooks.Load(webhookID)
+	if !ok {
+		return "", false
+	}
+	return token.(string), true
+}
+
+// editOwnWebhookMessage edits a message posted through one of the bot's
+// webhooks in channelID. discordgo's WebhookMessageEdit can't address a
+// thread, and Discord only finds thread messages with thread_id, so those
+// are sent by hand.
+func editOwnWebhookMessage(s *discordgo.Session, webhookID, channelID, messageID string, data *discordgo.WebhookEdit) error {
+	token, ok := ownWebhookToken(webhookID)
+	if !ok {
+		return fmt.Errorf("webhook %s is not the bot's", webhookID)
+	}
+	channel, err := getChannel(s, channelID)
+	if err != nil || !isThreadChannel(channel) {
+		_, err = s.WebhookMessageEdit(webhookID, token, messageID, data)
+		return err
+	}
+	uri := discordgo.EndpointWebhookMessage(webhookID, token, messageID) + "?thread_id=" + channelID
+	_, err = s.RequestWithBucketID("PATCH", uri, data, discordgo.EndpointWebhookToken("", ""))
+	return err
+}
+
+// channelWebhook returns the bot's webhook for channelID, creating it on
+// first use. Threads post through their parent channel's webhook, so
+// threadID is set when channelID is a thread.
+func channelWebhook(s *discordgo.Session, channelID string) (hook storedWebhook, threadID string, err error) {
+	channel, err := getChannel(s, channelID)
+	if err != nil {
+		return hook, "", err
+	}
+	if channel.GuildID == "" {
+		return hook, "", errors.New("webhooks are not available in DMs")
+	}
+	target := channelID
+	if isThreadChannel(channel) {
+		threadID, target = channelID, channel.ParentID
+	}
+
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	if ok, _ := store.Get(webhookBucket, target, &hook); ok && hook.ID != "" {
+		return hook, threadID, nil
+	}
+
+	// Adopt a webhook left from an earlier install before creating one.
+	hooks, err := s.ChannelWebhooks(target)
+	if err != nil {
+		return hook, "", fmt.Errorf("listing webhooks: %w", err)
+	}
+	for _, h := range hooks {
+		if h.Name == webhookName && h.Token != "" && h.User != nil && h.User.ID == s.State.User.ID {
+			hook = storedWebhook{ID: h.ID, Token: h.Token}
+		}
+	}
+	if hook.ID == "" {
+		h, err := s.WebhookCreate(target, webhookName, "")
+		if err != nil {
+			return hook, "", fmt.Errorf("creating webhook: %w", err)
+		}
+		hook = storedWebhook{ID: h.ID, Token: h.Token}
+		log.Printf("🪝 Created persona webhook in channel %s", target)
+	}
+	ownWebhooks.Store(hook.ID, hook.Token)
+	if err := store.Put(webhookBucket, target, hook); err != nil {
+		log.Printf("Error saving webhook for %s: %v", target, err)
+	}
+	return hook, threadID, nil
+}
+
+// forgetChannelWebhook drops a stored webhook that no longer works, e.g.
+// after someone deleted it in the channel settings.
+func forgetChannelWebhook(s *discordgo.Session, channelID string) {
+	target := channelID
+	if channel, err := getChannel(s, channelID); err == nil && isThreadChannel(channel) {
+		target = channel.ParentID
+	}
+	webhookMu.Lock()
+	defer webhookMu.Unlock()
+	store.Delete(webhookBucket, target)
+}
+
+// sendAs sends text to a channel under the persona's display name and
+// avatar. Elsie speaks as the bot account; other personas speak as their own
+// bot if they have one, or post through the channel's webhook, falling back
+// to the bot account if that fails.
+func sendAs(s *discordgo.Session, channelID string, p *persona, text string) ([]*discordgo.Message, error) {
+	text = postProcess(s, channelID, text)
+	if p == nil || p.ID == defaultPersonaID {
+		return sendChunks(s, channelID, text)
+	}
+	if bot := personaBotSession(p); bot != nil {
+		sent, err := sendChunks(bot, channelID, text)
+		if err == nil || len(sent) > 0 {
+			return sent, err
+		}
+		// e.g. the persona's bot wasn't invited to this server
+		log.Printf("%s bot can't post in %s, using the webhook: %v", p.Name, channelID, err)
+	}
+	hook, threadID, err := channelWebhook(s, channelID)
+	if err != nil {
+		log.Printf("Persona webhook unavailable in %s, sending as the bot: %v", channelID, err)
+		return sendChunks(s, channelID, text)
+	}
+
+	var sent []*discordgo.Message
+	for _, chunk := range messageChunks(text) {
+		params := &discordgo.WebhookParams{Content: chunk, Username: p.Name, AvatarURL: p.AvatarURL}
+		var msg *discordgo.Message
+		if threadID != "" {
+			msg, err = s.WebhookThreadExecute(hook.ID, hook.Token, true, threadID, params)
+		} else {
+			msg, err = s.WebhookExecute(hook.ID, hook.Token, true, params)
+		}
+		if err != nil {
+			if len(sent) == 0 {
+				log.Printf("Persona webhook failed in %s, sending as the bot: %v", channelID, err)
+				forgetChannelWebhook(s, channelID)
+				return sendChunks(s, channelID, text)
+			}
+			return sent, err
+		}
+		sent = append(sent, msg)
+	}
+	return sent, nil
+}